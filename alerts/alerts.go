@@ -0,0 +1,165 @@
+package alerts
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Severity représente la gravité d'une alerte
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+	SeverityUnknown  Severity = "unknown"
+)
+
+// rank retourne l'ordre de tri d'une sévérité (la plus grave en premier)
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 0
+	case SeverityWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Alert représente une alerte active
+type Alert struct {
+	Key       string    `json:"key"`        // Identifiant unique (ex: check:srv-1:load)
+	MachineID string    `json:"machine_id"` // Vide pour les alertes non liées à une machine
+	Source    string    `json:"source"`     // check, probe, cert, log, forecast...
+	Name      string    `json:"name"`
+	Severity  Severity  `json:"severity"`
+	Message   string    `json:"message"`
+	Samples   []string  `json:"samples,omitempty"` // Lignes d'exemple (alertes sur logs)
+	Since     time.Time `json:"since"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventType indique le type de changement d'une alerte
+type EventType string
+
+const (
+	EventRaised   EventType = "raised"   // Nouvelle alerte ou changement de sévérité
+	EventUpdated  EventType = "updated"  // Même sévérité, message mis à jour
+	EventResolved EventType = "resolved" // Retour à la normale
+)
+
+// Event est transmis aux abonnés à chaque changement d'alerte
+type Event struct {
+	Type  EventType
+	Alert Alert
+}
+
+// Manager centralise les alertes actives de toutes les sources
+type Manager struct {
+	active    map[string]*Alert
+	listeners []func(Event)
+	mu        sync.RWMutex
+}
+
+// NewManager crée un gestionnaire d'alertes
+func NewManager() *Manager {
+	return &Manager{
+		active: make(map[string]*Alert),
+	}
+}
+
+// Subscribe enregistre un abonné notifié à chaque changement.
+// Les abonnés sont appelés de manière synchrone et ne doivent pas bloquer.
+func (m *Manager) Subscribe(fn func(Event)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Raise déclenche ou met à jour une alerte
+func (m *Manager) Raise(a Alert) {
+	now := time.Now()
+	a.UpdatedAt = now
+
+	m.mu.Lock()
+	eventType := EventRaised
+	if existing, ok := m.active[a.Key]; ok {
+		a.Since = existing.Since
+		if existing.Severity == a.Severity {
+			eventType = EventUpdated
+		}
+	} else if a.Since.IsZero() {
+		a.Since = now
+	}
+	stored := a
+	m.active[a.Key] = &stored
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	notify(listeners, Event{Type: eventType, Alert: a})
+}
+
+// Resolve supprime une alerte active (sans effet si elle n'existe pas)
+func (m *Manager) Resolve(key string) {
+	m.mu.Lock()
+	existing, ok := m.active[key]
+	if ok {
+		delete(m.active, key)
+	}
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	if ok {
+		resolved := *existing
+		resolved.UpdatedAt = time.Now()
+		notify(listeners, Event{Type: EventResolved, Alert: resolved})
+	}
+}
+
+// Get retourne une alerte active par sa clé
+func (m *Manager) Get(key string) (Alert, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.active[key]
+	if !ok {
+		return Alert{}, false
+	}
+	return *a, true
+}
+
+// Active retourne toutes les alertes actives, les plus graves en premier
+func (m *Manager) Active() []Alert {
+	return m.filter(func(*Alert) bool { return true })
+}
+
+// ForMachine retourne les alertes actives d'une machine
+func (m *Manager) ForMachine(machineID string) []Alert {
+	return m.filter(func(a *Alert) bool { return a.MachineID == machineID })
+}
+
+// filter retourne une copie triée des alertes correspondant au prédicat
+func (m *Manager) filter(keep func(*Alert) bool) []Alert {
+	m.mu.RLock()
+	result := make([]Alert, 0, len(m.active))
+	for _, a := range m.active {
+		if keep(a) {
+			result = append(result, *a)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Severity.rank() != result[j].Severity.rank() {
+			return result[i].Severity.rank() < result[j].Severity.rank()
+		}
+		return result[i].Since.Before(result[j].Since)
+	})
+	return result
+}
+
+func notify(listeners []func(Event), e Event) {
+	for _, fn := range listeners {
+		fn(e)
+	}
+}
@@ -0,0 +1,56 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_RaiseAndResolve(t *testing.T) {
+	m := NewManager()
+
+	var events []Event
+	m.Subscribe(func(e Event) { events = append(events, e) })
+
+	m.Raise(Alert{Key: "check:srv-1:load", MachineID: "srv-1", Severity: SeverityWarning, Message: "load 6"})
+	first, ok := m.Get("check:srv-1:load")
+	require.True(t, ok)
+
+	// Même sévérité: mise à jour, "Since" conservé
+	time.Sleep(5 * time.Millisecond)
+	m.Raise(Alert{Key: "check:srv-1:load", MachineID: "srv-1", Severity: SeverityWarning, Message: "load 7"})
+	second, _ := m.Get("check:srv-1:load")
+	assert.Equal(t, first.Since, second.Since)
+	assert.Equal(t, "load 7", second.Message)
+
+	// Escalade: nouvel événement "raised"
+	m.Raise(Alert{Key: "check:srv-1:load", MachineID: "srv-1", Severity: SeverityCritical, Message: "load 12"})
+
+	m.Resolve("check:srv-1:load")
+	m.Resolve("check:srv-1:load") // idempotent
+
+	require.Len(t, events, 4)
+	assert.Equal(t, EventRaised, events[0].Type)
+	assert.Equal(t, EventUpdated, events[1].Type)
+	assert.Equal(t, EventRaised, events[2].Type)
+	assert.Equal(t, EventResolved, events[3].Type)
+	assert.Empty(t, m.Active())
+}
+
+func TestManager_ActiveSortedBySeverity(t *testing.T) {
+	m := NewManager()
+	m.Raise(Alert{Key: "a", MachineID: "srv-1", Severity: SeverityWarning})
+	m.Raise(Alert{Key: "b", MachineID: "srv-2", Severity: SeverityCritical})
+	m.Raise(Alert{Key: "c", MachineID: "srv-1", Severity: SeverityUnknown})
+
+	active := m.Active()
+	require.Len(t, active, 3)
+	assert.Equal(t, "b", active[0].Key)
+	assert.Equal(t, "a", active[1].Key)
+	assert.Equal(t, "c", active[2].Key)
+
+	forMachine := m.ForMachine("srv-1")
+	assert.Len(t, forMachine, 2)
+}
@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/storage"
+)
+
+// Résultat d'une action
+const (
+	OutcomeSuccess = storage.AuditSuccess
+	OutcomeFailure = storage.AuditFailure
+)
+
+// Délai maximal entre deux tentatives d'envoi vers une destination en panne
+const maxRetryDelay = 30 * time.Second
+
+// Event est le schéma des événements transmis aux destinations externes
+type Event struct {
+	Time      time.Time `json:"time"`
+	ID        int64     `json:"id"` // Identifiant de la ligne dans audit_logs
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Outcome   string    `json:"outcome"`
+	SourceIP  string    `json:"source_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	Hash      string    `json:"hash"` // Hash de la chaîne d'intégrité, pour rapprocher l'événement de la base
+}
+
+// NewEvent convertit une entrée du journal d'audit
+func NewEvent(l storage.AuditLog) Event {
+	ip := l.IPAddress
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	outcome := l.Outcome
+	if outcome == "" {
+		outcome = Outcome(l.Action)
+	}
+	return Event{
+		Time:      l.Timestamp.UTC(),
+		ID:        l.ID,
+		Actor:     l.User,
+		Action:    l.Action,
+		Target:    l.Target,
+		Outcome:   outcome,
+		SourceIP:  ip,
+		UserAgent: l.UserAgent,
+		Details:   l.Details,
+		Hash:      l.Hash,
+	}
+}
+
+// Outcome retourne le résultat d'une entrée sans résultat explicite : les échecs et refus
+// d'authentification sont journalisés sous leur propre nom
+func Outcome(action string) string {
+	switch action {
+	case "LOGIN_FAILED", "ACCESS_DENIED", "AUDIT_TAMPERED":
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// Sink est une destination des événements d'audit. Write n'est appelé que depuis la file de la
+// destination : une erreur déclenche un nouvel essai du même événement.
+type Sink interface {
+	Name() string
+	Write(Event) error
+	Close() error
+}
+
+// Forwarder transmet les événements aux destinations sans bloquer l'appelant. Chaque destination a sa
+// file d'attente ; quand elle est pleine (destination en panne), les nouveaux événements sont perdus
+// pour cette destination et comptés. La table audit_logs reste la référence.
+type Forwarder struct {
+	mu     sync.RWMutex
+	closed bool
+	queues []*queue
+	done   chan struct{} // Fermé pour interrompre les nouveaux essais à l'arrêt
+	wg     sync.WaitGroup
+}
+
+type queue struct {
+	sink    Sink
+	events  chan Event
+	dropped atomic.Int64
+}
+
+// NewForwarder démarre une file de bufferSize événements par destination
+func NewForwarder(bufferSize int, sinks ...Sink) *Forwarder {
+	f := &Forwarder{done: make(chan struct{})}
+	for _, s := range sinks {
+		q := &queue{sink: s, events: make(chan Event, bufferSize)}
+		f.queues = append(f.queues, q)
+		f.wg.Add(1)
+		go f.run(q)
+	}
+	return f
+}
+
+// NewForwarderFromConfig crée les destinations configurées (syslog, fichier JSON lines)
+func NewForwarderFromConfig(cfg config.AuditConfig) (*Forwarder, error) {
+	var sinks []Sink
+	if cfg.Syslog != nil {
+		s, err := NewSyslogSink(*cfg.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("syslog: %w", err)
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.File != nil {
+		s, err := NewFileSink(*cfg.File)
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return nil, fmt.Errorf("fichier: %w", err)
+		}
+		sinks = append(sinks, s)
+	}
+	return NewForwarder(cfg.BufferSize, sinks...), nil
+}
+
+// Record transmet une entrée du journal (hook de storage.DB)
+func (f *Forwarder) Record(l storage.AuditLog) {
+	f.Forward(NewEvent(l))
+}
+
+// Forward place l'événement dans la file de chaque destination, sans attendre
+func (f *Forwarder) Forward(e Event) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return
+	}
+	for _, q := range f.queues {
+		select {
+		case q.events <- e:
+		default:
+			// Journalisé au premier événement perdu puis tous les 100
+			if n := q.dropped.Add(1); n == 1 || n%100 == 0 {
+				log.Printf("AVERTISSEMENT: Audit %s: file pleine, %d événement(s) perdu(s)", q.sink.Name(), n)
+			}
+		}
+	}
+}
+
+// Dropped retourne le nombre d'événements perdus par destination
+func (f *Forwarder) Dropped() map[string]int64 {
+	dropped := make(map[string]int64, len(f.queues))
+	for _, q := range f.queues {
+		dropped[q.sink.Name()] = q.dropped.Load()
+	}
+	return dropped
+}
+
+// Close transmet les événements en attente (au plus timeout) puis ferme les destinations
+func (f *Forwarder) Close(timeout time.Duration) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	for _, q := range f.queues {
+		close(q.events)
+	}
+	f.mu.Unlock()
+
+	flushed := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(timeout):
+		log.Printf("AVERTISSEMENT: Audit: événements en attente non transmis à l'arrêt")
+		close(f.done)
+		<-flushed
+	}
+	for _, q := range f.queues {
+		q.sink.Close()
+	}
+}
+
+// run envoie les événements d'une file dans l'ordre, en réessayant avec un délai croissant
+func (f *Forwarder) run(q *queue) {
+	defer f.wg.Done()
+	for e := range q.events {
+		delay := time.Second
+		for {
+			err := q.sink.Write(e)
+			if err == nil {
+				break
+			}
+			log.Printf("Erreur audit %s: %v (nouvel essai dans %s)", q.sink.Name(), err, delay)
+			select {
+			case <-time.After(delay):
+			case <-f.done:
+				return
+			}
+			delay = min(delay*2, maxRetryDelay)
+		}
+	}
+}
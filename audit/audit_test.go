@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	sink, err := NewSyslogSink(config.AuditSyslogConfig{
+		Network: "udp", Address: pc.LocalAddr().String(), Facility: "authpriv", AppName: "go-monitoring", Hostname: "mon-1",
+	})
+	require.NoError(t, err)
+	f := NewForwarder(10, sink)
+	defer f.Close(time.Second)
+
+	// Événement issu de la base : identifiant et hash de la chaîne
+	db, err := storage.InitDB(filepath.Join(t.TempDir(), "audit.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetAuditHook(f.Record)
+	require.NoError(t, db.RecordAudit(storage.AuditLog{
+		User: "alice", Action: "LOGIN_FAILED", Target: `web-1 [prod]`, Details: "mot de passe incorrect",
+		IPAddress: "192.0.2.10:51234", UserAgent: `curl/8.5 "test"`,
+	}))
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+
+	// authpriv (10) * 8 + warning (4)
+	assert.True(t, strings.HasPrefix(msg, "<84>1 "), msg)
+	assert.Contains(t, msg, " mon-1 go-monitoring "+strconv.Itoa(os.Getpid())+" LOGIN_FAILED [audit@32473 id=\"1\" actor=\"alice\"")
+	assert.Contains(t, msg, `target="web-1 [prod\]"`)
+	assert.Contains(t, msg, `outcome="failure" srcIP="192.0.2.10" userAgent="curl/8.5 \"test\""]`)
+
+	var e Event
+	require.NoError(t, json.Unmarshal([]byte(msg[strings.Index(msg, "] {")+2:]), &e))
+	assert.Equal(t, "mot de passe incorrect", e.Details)
+	assert.NotEmpty(t, e.Hash)
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	sink, err := NewSyslogSink(config.AuditSyslogConfig{
+		Network: "tcp", Address: ln.Addr().String(), Facility: "local4", AppName: "go-monitoring", Framing: "octet-counting",
+	})
+	require.NoError(t, err)
+	f := NewForwarder(10, sink)
+	defer f.Close(time.Second)
+
+	f.Forward(Event{Time: time.Now(), ID: 1, Actor: "bob", Action: "USER_CREATE", Outcome: Outcome("USER_CREATE")})
+	f.Forward(Event{Time: time.Now(), ID: 2, Actor: "bob", Action: "USER_DELETE", Outcome: Outcome("USER_DELETE")})
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, action := range []string{"USER_CREATE", "USER_DELETE"} {
+		size, err := r.ReadString(' ')
+		require.NoError(t, err)
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		require.NoError(t, err)
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		require.NoError(t, err)
+		// local4 (20) * 8 + notice (5)
+		assert.True(t, strings.HasPrefix(string(msg), "<165>1 "), string(msg))
+		assert.Contains(t, string(msg), " "+action+" [audit@32473 ")
+	}
+}
+
+// blockingSink simule une destination injoignable : Write attend la fermeture de release
+type blockingSink struct {
+	started chan struct{}
+	release chan struct{}
+	written int
+}
+
+func (s *blockingSink) Name() string { return "bloquante" }
+func (s *blockingSink) Close() error { return nil }
+func (s *blockingSink) Write(Event) error {
+	if s.written == 0 {
+		close(s.started)
+	}
+	s.written++
+	<-s.release
+	return nil
+}
+
+func TestForwarderDoesNotBlock(t *testing.T) {
+	sink := &blockingSink{started: make(chan struct{}), release: make(chan struct{})}
+	f := NewForwarder(2, sink)
+
+	f.Forward(Event{ID: 1})
+	<-sink.started
+	done := make(chan struct{})
+	go func() {
+		for i := 2; i <= 5; i++ {
+			f.Forward(Event{ID: int64(i)})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Forward bloqué par une destination en panne")
+	}
+	// Un événement en cours d'envoi, deux en file, deux perdus
+	assert.Equal(t, map[string]int64{"bloquante": 2}, f.Dropped())
+
+	close(sink.release)
+	f.Close(time.Second)
+	assert.Equal(t, 3, sink.written)
+	f.Forward(Event{ID: 6}) // Ignoré après l'arrêt
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	sink, err := NewFileSink(config.AuditFileConfig{Path: path, MaxSizeMB: 1, MaxBackups: 2})
+	require.NoError(t, err)
+	sink.maxSize = 300 // Environ deux événements par fichier
+	defer sink.Close()
+
+	for i := 1; i <= 7; i++ {
+		require.NoError(t, sink.Write(Event{Time: time.Now(), ID: int64(i), Actor: "admin", Action: "MACHINE_UPDATE", Outcome: OutcomeSuccess}))
+	}
+
+	ids := func(name string) []int64 {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		var ids []int64
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var e Event
+			require.NoError(t, json.Unmarshal([]byte(line), &e))
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []int64{7}, ids(path))
+	assert.Equal(t, []int64{5, 6}, ids(path+".1"))
+	assert.Equal(t, []int64{3, 4}, ids(path+".2"))
+	assert.NoFileExists(t, path+".3")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestFileSinkNoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(config.AuditFileConfig{Path: path, MaxSizeMB: 1, MaxBackups: 0})
+	require.NoError(t, err)
+	sink.maxSize = 300
+	defer sink.Close()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, sink.Write(Event{Time: time.Now(), ID: int64(i), Actor: "admin", Action: "MACHINE_UPDATE", Outcome: OutcomeSuccess}))
+	}
+
+	// Vidé sur place à chaque rotation : seul le dernier événement reste, sans copie
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var e Event
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &e))
+	assert.Equal(t, int64(5), e.ID)
+	matches, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestNewEventOutcome(t *testing.T) {
+	assert.Equal(t, OutcomeFailure, NewEvent(storage.AuditLog{Action: "LOGIN_FAILED"}).Outcome)
+	assert.Equal(t, OutcomeSuccess, NewEvent(storage.AuditLog{Action: "RESTART_SERVICE"}).Outcome)
+	// Résultat explicite d'une action qui a échoué
+	assert.Equal(t, OutcomeFailure, NewEvent(storage.AuditLog{Action: "RESTART_SERVICE", Outcome: storage.AuditFailure}).Outcome)
+	assert.Equal(t, OutcomeFailure, NewEvent(storage.AuditLog{Action: "RUNBOOK_END", Outcome: storage.AuditFailure}).Outcome)
+}
+
+// shortWriteFile simule un disque plein : la première écriture s'arrête au milieu de la ligne
+type shortWriteFile struct {
+	logFile
+	failed bool
+}
+
+func (f *shortWriteFile) Write(p []byte) (int, error) {
+	if !f.failed {
+		f.failed = true
+		n, _ := f.logFile.Write(p[:len(p)/2])
+		return n, io.ErrShortWrite
+	}
+	return f.logFile.Write(p)
+}
+
+func TestFileSinkShortWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(config.AuditFileConfig{Path: path, MaxSizeMB: 1, MaxBackups: 1})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	e := Event{Time: time.Now(), ID: 1, Actor: "admin", Action: "USER_CREATE", Outcome: OutcomeSuccess}
+	require.NoError(t, sink.Write(e))
+	sink.file = &shortWriteFile{logFile: sink.file}
+	e.ID = 2
+	assert.Error(t, sink.Write(e))
+	require.NoError(t, sink.Write(e)) // Nouvel essai du forwarder
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	for i, line := range lines {
+		var got Event
+		require.NoError(t, json.Unmarshal([]byte(line), &got), line)
+		assert.Equal(t, int64(i+1), got.ID)
+	}
+	assert.Equal(t, int64(len(data)), sink.size)
+}
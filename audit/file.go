@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go-monitoring/config"
+)
+
+// FileSink écrit un événement JSON par ligne, en ajout seul. Au-delà de la taille maximale, le fichier
+// devient path.1 (path.1 devient path.2...) et les plus anciens au-delà de MaxBackups sont supprimés.
+// Avec MaxBackups 0, aucune copie n'est conservée : le fichier est vidé sur place.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file logFile
+	size int64
+}
+
+// logFile est le fichier ouvert par le FileSink (*os.File)
+type logFile interface {
+	io.WriteCloser
+	Truncate(size int64) error
+}
+
+// NewFileSink ouvre (ou crée) le fichier, lisible par le seul compte du service
+func NewFileSink(cfg config.AuditFileConfig) (*FileSink, error) {
+	s := &FileSink{path: cfg.Path, maxSize: int64(cfg.MaxSizeMB) << 20, maxBackups: cfg.MaxBackups}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0700); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Name() string {
+	return "fichier " + s.path
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.size = f, info.Size()
+	return nil
+}
+
+func (s *FileSink) Write(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		// Réouverture après l'échec d'une rotation
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotation: %w", err)
+		}
+	}
+	n, err := s.file.Write(line)
+	if err != nil && n > 0 {
+		// Écriture partielle : le fragment est retiré (à défaut, terminé par un saut de ligne)
+		// pour que le nouvel essai du même événement écrive une ligne complète
+		if terr := s.file.Truncate(s.size); terr != nil {
+			m, _ := s.file.Write([]byte{'\n'})
+			s.size += int64(n + m)
+		}
+		return err
+	}
+	s.size += int64(n)
+	return err
+}
+
+// rotate décale les fichiers conservés et ouvre un nouveau fichier vide
+func (s *FileSink) rotate() error {
+	if s.maxBackups <= 0 {
+		if err := s.file.Truncate(0); err != nil {
+			return err
+		}
+		s.size = 0
+		return nil
+	}
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
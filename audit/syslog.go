@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-monitoring/config"
+)
+
+// Délai de connexion et d'écriture vers le serveur syslog
+const syslogTimeout = 5 * time.Second
+
+// Identifiant des données structurées (numéro d'entreprise réservé à la documentation, RFC 5612)
+const syslogSDID = "audit@32473"
+
+// Sévérités syslog : notice pour une action réussie, warning pour un échec ou un refus
+const (
+	severityWarning = 4
+	severityNotice  = 5
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogSink envoie les événements en syslog RFC 5424 sur UDP, TCP ou TLS. La connexion est
+// ouverte au premier envoi et rouverte après une erreur.
+type SyslogSink struct {
+	cfg      config.AuditSyslogConfig
+	facility int
+	hostname string
+	procID   string
+	tls      *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink valide la configuration sans se connecter : le serveur peut être injoignable au démarrage
+func NewSyslogSink(cfg config.AuditSyslogConfig) (*SyslogSink, error) {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("facility inconnue: %s", cfg.Facility)
+	}
+	s := &SyslogSink{cfg: cfg, facility: facility, hostname: cfg.Hostname, procID: strconv.Itoa(os.Getpid())}
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	if cfg.Network == "tls" {
+		host, _, err := net.SplitHostPort(cfg.Address)
+		if err != nil {
+			return nil, fmt.Errorf("adresse invalide %s: %w", cfg.Address, err)
+		}
+		pool, err := caCertPool(cfg.CACert)
+		if err != nil {
+			return nil, err
+		}
+		s.tls = &tls.Config{ServerName: host, RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog " + s.cfg.Network + "://" + s.cfg.Address
+}
+
+// Write envoie un message ; en UDP, chaque message est un datagramme
+func (s *SyslogSink) Write(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	msg := s.format(e)
+	if s.cfg.Network != "udp" {
+		if s.cfg.Framing == "newline" {
+			msg = append(msg, '\n')
+		} else {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	if s.tls != nil {
+		return tls.DialWithDialer(dialer, "tcp", s.cfg.Address, s.tls)
+	}
+	return dialer.Dial(s.cfg.Network, s.cfg.Address)
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// format construit le message RFC 5424 : en-tête, données structurées du schéma d'audit
+// et événement complet en JSON comme message libre
+func (s *SyslogSink) format(e Event) []byte {
+	severity := severityNotice
+	if e.Outcome == OutcomeFailure {
+		severity = severityWarning
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, p := range [][2]string{
+		{"id", strconv.FormatInt(e.ID, 10)},
+		{"actor", e.Actor},
+		{"action", e.Action},
+		{"target", e.Target},
+		{"outcome", e.Outcome},
+		{"srcIP", e.SourceIP},
+		{"userAgent", e.UserAgent},
+	} {
+		if p[1] != "" {
+			sd.WriteString(" " + p[0] + `="` + sdEscaper.Replace(p[1]) + `"`)
+		}
+	}
+	sd.WriteString("]")
+
+	body, _ := json.Marshal(e)
+	return fmt.Appendf(nil, "<%d>1 %s %s %s %s %s %s %s",
+		s.facility*8+severity,
+		e.Time.UTC().Format(time.RFC3339Nano),
+		headerField(s.hostname, 255),
+		headerField(s.cfg.AppName, 48),
+		headerField(s.procID, 128),
+		headerField(e.Action, 32),
+		sd.String(),
+		body)
+}
+
+// Caractères à échapper dans une valeur de paramètre (RFC 5424, section 6.3.3)
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// headerField limite un champ d'en-tête aux caractères ASCII imprimables, "-" s'il est vide
+func headerField(v string, maxLen int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, v)
+	if field == "" {
+		return "-"
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	return field
+}
+
+// caCertPool charge une autorité de certification PEM (nil : magasin système)
+func caCertPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("lecture du certificat %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("certificat invalide: %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"net/http"
+
+	"go-monitoring/storage"
+)
+
+// Utilisateur inscrit au journal quand l'identité n'est pas connue (échec SSO avant le jeton d'identité)
+const unknownAuditUser = "inconnu"
+
+// Audit enregistre une action de l'utilisateur de la requête (session ou jeton d'API) dans le journal d'audit
+func (am *AuthManager) Audit(r *http.Request, action, target, details string) {
+	if am == nil {
+		return
+	}
+	am.AuditAs(r, am.GetUsername(r), action, target, details)
+}
+
+// AuditResult enregistre une action de l'utilisateur de la requête qui peut échouer : avec err, elle est
+// transmise aux destinations externes comme un échec
+func (am *AuthManager) AuditResult(r *http.Request, action, target, details string, err error) {
+	if am == nil {
+		return
+	}
+	outcome := storage.AuditSuccess
+	if err != nil {
+		outcome = storage.AuditFailure
+	}
+	am.record(r, am.GetUsername(r), action, target, details, outcome)
+}
+
+// AuditAs enregistre une action au nom d'un utilisateur donné (connexion, avant l'ouverture de la session)
+func (am *AuthManager) AuditAs(r *http.Request, username, action, target, details string) {
+	if am == nil {
+		return
+	}
+	am.record(r, username, action, target, details, "")
+}
+
+func (am *AuthManager) record(r *http.Request, username, action, target, details, outcome string) {
+	db := am.store()
+	if db == nil {
+		return
+	}
+	if username == "" {
+		username = unknownAuditUser
+	}
+	db.RecordAudit(storage.AuditLog{
+		User:      username,
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		IPAddress: r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+		Outcome:   outcome,
+	})
+}
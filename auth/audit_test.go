@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func auditActions(t *testing.T, db *storage.DB, filter storage.AuditFilter) []string {
+	t.Helper()
+	logs, _, err := db.SearchAuditLogs(filter)
+	require.NoError(t, err)
+	var actions []string
+	for i := len(logs) - 1; i >= 0; i-- {
+		actions = append(actions, logs[i].Action)
+	}
+	return actions
+}
+
+func TestLoginAudit(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "operator"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{sessionConfig(30, 120)})
+
+	rec := postLogin(am, url.Values{"username": {"alice"}, "password": {"mauvais"}})
+	assert.False(t, hasSessionCookie(rec))
+	cookie := login(t, am, "alice", "password123")
+	req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+	req.AddCookie(cookie)
+	am.LogoutHandler(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"LOGIN_FAILED", "LOGIN", "LOGOUT"}, auditActions(t, db, storage.AuditFilter{User: "alice"}))
+	logs, total, err := db.SearchAuditLogs(storage.AuditFilter{Action: "LOGIN", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "source=local", logs[0].Details)
+}
+
+func TestAuditChain(t *testing.T) {
+	db := setupTestDB(t)
+	for _, action := range []string{"USER_CREATE", "USER_ROLE", "MACHINE_UPDATE", "USER_DELETE"} {
+		require.NoError(t, db.LogAction("admin", action, "bob", "", "192.0.2.1:1234"))
+	}
+	v, err := db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.True(t, v.Valid)
+	assert.Equal(t, 4, v.Checked)
+
+	// Filtres et pagination
+	logs, total, err := db.SearchAuditLogs(storage.AuditFilter{Target: "bo", Limit: 3, Offset: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "USER_CREATE", logs[0].Action)
+	assert.Equal(t, []string{"USER_ROLE"}, auditActions(t, db, storage.AuditFilter{Action: "USER_ROLE"}))
+	assert.Empty(t, auditActions(t, db, storage.AuditFilter{Since: time.Now().Add(time.Hour)}))
+
+	// Ligne modifiée
+	_, err = db.Exec("UPDATE audit_logs SET details = 'rien' WHERE action = 'USER_ROLE'")
+	require.NoError(t, err)
+	v, err = db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+	assert.Equal(t, int64(2), v.BrokenID)
+	_, err = db.Exec("UPDATE audit_logs SET details = '' WHERE action = 'USER_ROLE'")
+	require.NoError(t, err)
+
+	// Ligne supprimée
+	_, err = db.Exec("DELETE FROM audit_logs WHERE action = 'MACHINE_UPDATE'")
+	require.NoError(t, err)
+	v, err = db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+	assert.Equal(t, int64(4), v.BrokenID)
+}
+
+func TestAuditChainRejectsBlankedHashes(t *testing.T) {
+	db := setupTestDB(t)
+	for _, action := range []string{"USER_CREATE", "USER_ROLE", "USER_DELETE"} {
+		require.NoError(t, db.LogAction("admin", action, "bob", "", ""))
+	}
+
+	// Historique réécrit puis hash effacés : les lignes ne passent pas pour antérieures à la chaîne
+	_, err := db.Exec("UPDATE audit_logs SET details = 'rien', hash = '', prev_hash = '' WHERE id <= 2")
+	require.NoError(t, err)
+	v, err := db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+	assert.Equal(t, int64(1), v.BrokenID)
+
+	_, err = db.Exec("UPDATE audit_logs SET hash = '', prev_hash = ''")
+	require.NoError(t, err)
+	v, err = db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+	assert.Zero(t, v.Unchained)
+}
+
+func TestAuditChainLegacyRows(t *testing.T) {
+	// Base antérieure à la chaîne d'intégrité
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`CREATE TABLE audit_logs (id INTEGER PRIMARY KEY AUTOINCREMENT, timestamp DATETIME NOT NULL,
+		user TEXT NOT NULL, action TEXT NOT NULL, target TEXT, details TEXT, ip_address TEXT)`)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = legacy.Exec("INSERT INTO audit_logs (timestamp, user, action) VALUES (?, 'admin', 'LOGIN')", time.Now().UTC())
+		require.NoError(t, err)
+	}
+	require.NoError(t, legacy.Close())
+
+	db, err := storage.InitDB(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.LogAction("admin", "USER_CREATE", "bob", "", ""))
+
+	v, err := db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.True(t, v.Valid)
+	assert.Equal(t, 2, v.Unchained)
+	assert.Equal(t, 1, v.Checked)
+
+	// Le début de la chaîne est conservé aux démarrages suivants
+	require.NoError(t, db.Close())
+	db, err = storage.InitDB(path)
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE audit_logs SET hash = '', prev_hash = '' WHERE action = 'USER_CREATE'")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	db, err = storage.InitDB(path)
+	require.NoError(t, err)
+	v, err = db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+	assert.Equal(t, int64(3), v.BrokenID)
+}
+
+func TestAuditPurgeKeepsChain(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.LogAction("admin", "USER_CREATE", "bob", "", ""))
+	require.NoError(t, db.LogAction("admin", "USER_DELETE", "bob", "", ""))
+	// Lignes anciennes (leur hash ne correspond plus, mais elles sont purgées)
+	_, err := db.Exec("UPDATE audit_logs SET timestamp = ?", time.Now().UTC().AddDate(0, 0, -400))
+	require.NoError(t, err)
+	require.NoError(t, db.LogAction("admin", "MACHINE_CREATE", "web-1", "", ""))
+
+	n, err := db.PurgeAuditLogs(time.Now().AddDate(0, 0, -365))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	require.NoError(t, db.LogAction("system", "AUDIT_PURGE", "audit", "", ""))
+
+	// Les lignes restantes s'ancrent sur la dernière ligne purgée
+	v, err := db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.True(t, v.Valid)
+	assert.Equal(t, 2, v.Checked)
+	assert.Equal(t, []string{"MACHINE_CREATE", "AUDIT_PURGE"}, auditActions(t, db, storage.AuditFilter{}))
+
+	// Suppression de la première ligne restante : détectée grâce à l'ancrage
+	_, err = db.Exec("DELETE FROM audit_logs WHERE action = 'MACHINE_CREATE'")
+	require.NoError(t, err)
+	v, err = db.VerifyAuditChain()
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+}
+
+func TestAuditCarriesUserAgent(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "user"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{permissionsConfig()})
+	var forwarded []storage.AuditLog
+	db.SetAuditHook(func(l storage.AuditLog) { forwarded = append(forwarded, l) })
+
+	cookie := login(t, am, "alice", "password123")
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("User-Agent", "curl/8.5")
+	am.Require(config.CapabilityAdmin, func(http.ResponseWriter, *http.Request) {})(httptest.NewRecorder(), req)
+
+	require.NotEmpty(t, forwarded)
+	denied := forwarded[len(forwarded)-1]
+	assert.Equal(t, "ACCESS_DENIED", denied.Action)
+	assert.Equal(t, "alice", denied.User)
+	assert.Equal(t, "curl/8.5", denied.UserAgent)
+	assert.Equal(t, req.RemoteAddr, denied.IPAddress)
+}
+
+func TestAuditResultOutcome(t *testing.T) {
+	db := setupTestDB(t)
+	am := NewAuthManager(NewUserManager(db, nil))
+	var forwarded []storage.AuditLog
+	db.SetAuditHook(func(l storage.AuditLog) { forwarded = append(forwarded, l) })
+
+	req := httptest.NewRequest(http.MethodPost, "/api/machine/web-1/service/nginx/restart", nil)
+	am.AuditResult(req, "RESTART_SERVICE", "web-1:nginx", "SUCCESS ", nil)
+	am.AuditResult(req, "RESTART_SERVICE", "web-1:nginx", "FAILED exit status 1", errors.New("exit status 1"))
+
+	require.Len(t, forwarded, 2)
+	assert.Equal(t, storage.AuditSuccess, forwarded[0].Outcome)
+	assert.Equal(t, storage.AuditFailure, forwarded[1].Outcome)
+}
@@ -3,140 +3,382 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"html/template"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"go-monitoring/middleware"
+	"go-monitoring/pkg/qrcode"
 )
 
-// Session structure pour stocker les sessions actives
-type Session struct {
-	Username string
-	Expiry   time.Time
-}
-
-// AuthManager gère les sessions et middlewares
+// AuthManager gère les sessions (persistées en base) et middlewares
 type AuthManager struct {
 	UserManager *UserManager
-	sessions    map[string]Session
+	config      ConfigSource // Permissions par rôle (voir Require), durées des sessions, politique 2FA
+
+	oidc *OIDCProvider // Connexion SSO (nil si non configurée)
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin // Connexions en attente du second facteur
 }
 
 func NewAuthManager(um *UserManager) *AuthManager {
 	return &AuthManager{
 		UserManager: um,
-		sessions:    make(map[string]Session),
+		pending:     make(map[string]pendingLogin),
 	}
 }
 
-// Middleware protège les routes
+// Middleware protège les routes : session (cookie) ou jeton d'API (Authorization: Bearer).
+// Sans authentification valide, les API reçoivent un 401 JSON et les pages sont redirigées vers /login.
 func (am *AuthManager) Middleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Ignorer les fichiers statiques (images, css, js) si nécessaire
 		// Mais ici les statiques sont souvent publics.
 		// Si on veut protéger, on applique middleware.
 
-		cookie, err := r.Cookie("session_token")
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		// Jeton d'API : le cookie éventuel est ignoré
+		if token, ok := bearerToken(r); ok {
+			ident, status, message := am.authenticateToken(r, token)
+			if status != 0 {
+				writeAuthError(w, status, message)
+				return
+			}
+			next(w, withIdentity(r, ident))
 			return
 		}
 
-		sessionToken := cookie.Value
-		session, exists := am.sessions[sessionToken]
-		if !exists {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		cookie, err := r.Cookie("session_token")
+		if err != nil {
+			am.unauthenticated(w, r)
 			return
 		}
 
-		if session.Expiry.Before(time.Now()) {
-			delete(am.sessions, sessionToken)
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		session, ok := am.lookupSession(cookie.Value)
+		if !ok {
+			am.unauthenticated(w, r)
 			return
 		}
+		am.touchSession(session, r)
 
 		// Tout est bon, on continue
-		next(w, r)
+		next(w, withSession(r, session))
+	}
+}
+
+// unauthenticated répond à une requête sans session valide
+func (am *AuthManager) unauthenticated(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeAuthError(w, http.StatusUnauthorized, "Non authentifié")
+		return
 	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
 // LoginHandler gère la page de connexion
 func (am *AuthManager) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		// Vérifier ou créer un cookie de session pour le CSRF
-		cookie, err := r.Cookie("session_token")
-		var sessionID string
-		if err != nil || cookie.Value == "" {
-			sessionID = generateToken()
-			http.SetCookie(w, &http.Cookie{
-				Name:     "session_token",
-				Value:    sessionID,
-				Expires:  time.Now().Add(24 * time.Hour),
-				Path:     "/",
-				HttpOnly: true,
-			})
-		} else {
-			sessionID = cookie.Value
-		}
+		am.renderLogin(w, r, map[string]interface{}{})
+		return
+	}
 
-		csrfToken := middleware.GetCSRFTokenForSession(sessionID)
+	// POST Login : seconde étape (code TOTP ou enrôlement imposé)
+	if loginToken := r.FormValue("login_token"); loginToken != "" {
+		am.secondFactor(w, r, loginToken)
+		return
+	}
 
-		tmpl, err := template.ParseFiles("templates/login.html")
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	user, err := am.UserManager.Authenticate(username, password)
+	if err != nil {
+		am.AuditAs(r, username, "LOGIN_FAILED", username, err.Error())
+		am.renderLogin(w, r, map[string]interface{}{
+			"Error": "Identifiants incorrects",
+		})
+		return
+	}
+
+	am.completeLogin(w, r, user)
+}
+
+// completeLogin termine une connexion dont l'identité est vérifiée (formulaire ou SSO) :
+// second facteur, enrôlement imposé par la politique ou ouverture de la session
+func (am *AuthManager) completeLogin(w http.ResponseWriter, r *http.Request, user *User) {
+	// Double authentification activée : le code est demandé avant d'ouvrir la session
+	if user.TOTPEnabled {
+		am.renderLogin(w, r, map[string]interface{}{
+			"Step":       "totp",
+			"LoginToken": am.beginPendingLogin(pendingLogin{Username: user.Username}),
+		})
+		return
+	}
+
+	// Double authentification imposée au rôle mais pas encore configurée : enrôlement avant la session
+	if am.config != nil && TOTPRequired(am.config.GetConfig(), user.Role) {
+		secret, err := am.UserManager.BeginTOTPEnrollment(user.Username)
 		if err != nil {
-			http.Error(w, "Erreur template login", http.StatusInternalServerError)
+			log.Printf("Erreur enrôlement TOTP %s: %v", user.Username, err)
+			http.Error(w, "Erreur enrôlement double authentification", http.StatusInternalServerError)
 			return
 		}
-		tmpl.Execute(w, map[string]interface{}{
-			"CSRFToken": csrfToken,
+		p := pendingLogin{Username: user.Username, Enroll: true, Secret: secret}
+		am.renderEnrollment(w, r, am.beginPendingLogin(p), p, "")
+		return
+	}
+
+	am.openSession(w, r, user.Username, nil)
+}
+
+// openSession ouvre la session, après le changement de mot de passe s'il est imposé ou expiré.
+// Les codes de secours d'un enrôlement sont affichés une fois la session ouverte.
+func (am *AuthManager) openSession(w http.ResponseWriter, r *http.Request, username string, recoveryCodes []string) {
+	if reason := am.UserManager.PasswordChangeRequired(username); reason != "" {
+		p := pendingLogin{Username: username, ChangePassword: true, Reason: reason, RecoveryCodes: recoveryCodes}
+		am.renderPasswordChange(w, r, am.beginPendingLogin(p), p, "")
+		return
+	}
+	if !am.startSession(w, r, username) {
+		return
+	}
+	if len(recoveryCodes) > 0 {
+		am.renderLogin(w, r, map[string]interface{}{
+			"Step":          "recovery",
+			"RecoveryCodes": recoveryCodes,
 		})
 		return
 	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
 
-	// POST Login
-	username := r.FormValue("username")
-	password := r.FormValue("password")
+// Délai pour saisir le second facteur après le mot de passe
+const pendingLoginTTL = 5 * time.Minute
 
-	user, err := am.UserManager.Authenticate(username, password)
-	if err != nil {
-		// Récupérer le token CSRF existant pour le réinjecter dans le formulaire
-		csrfToken := ""
-		if cookie, err := r.Cookie("session_token"); err == nil {
-			csrfToken = middleware.GetCSRFTokenForSession(cookie.Value)
+// pendingLogin est une connexion dont le mot de passe est vérifié, en attente du second facteur
+type pendingLogin struct {
+	Username string
+	Enroll   bool   // Enrôlement imposé par la politique (double authentification non configurée)
+	Secret   string // Secret proposé à l'enrôlement
+	Expiry   time.Time
+
+	// Changement de mot de passe imposé ou expiré, demandé après le second facteur
+	ChangePassword bool
+	Reason         string
+	RecoveryCodes  []string // Codes de l'enrôlement, affichés après le changement
+}
+
+// beginPendingLogin mémorise une connexion en attente et retourne son jeton (champ caché du formulaire)
+func (am *AuthManager) beginPendingLogin(p pendingLogin) string {
+	token := generateToken()
+	now := time.Now()
+	p.Expiry = now.Add(pendingLoginTTL)
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	for t, other := range am.pending {
+		if other.Expiry.Before(now) {
+			delete(am.pending, t)
+		}
+	}
+	am.pending[token] = p
+	return token
+}
+
+// pendingLogin retourne une connexion en attente non expirée
+func (am *AuthManager) pendingLogin(token string) (pendingLogin, bool) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	p, ok := am.pending[token]
+	if !ok || p.Expiry.Before(time.Now()) {
+		delete(am.pending, token)
+		return pendingLogin{}, false
+	}
+	return p, true
+}
+
+func (am *AuthManager) endPendingLogin(token string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	delete(am.pending, token)
+}
+
+// secondFactor traite la seconde étape de connexion
+func (am *AuthManager) secondFactor(w http.ResponseWriter, r *http.Request, token string) {
+	p, ok := am.pendingLogin(token)
+	if !ok {
+		am.renderLogin(w, r, map[string]interface{}{
+			"Error": "Délai dépassé, reconnectez-vous",
+		})
+		return
+	}
+	if p.ChangePassword {
+		am.changePassword(w, r, token, p)
+		return
+	}
+	code := r.FormValue("code")
+
+	if p.Enroll {
+		codes, err := am.UserManager.ConfirmTOTPEnrollment(p.Username, code)
+		if err != nil {
+			am.renderEnrollment(w, r, token, p, "Code invalide")
+			return
 		}
+		am.endPendingLogin(token)
+		am.AuditAs(r, p.Username, "TOTP_ENABLE", p.Username, "enrôlement à la connexion")
+		am.openSession(w, r, p.Username, codes)
+		return
+	}
 
-		tmpl, _ := template.ParseFiles("templates/login.html")
-		tmpl.Execute(w, map[string]interface{}{
-			"Error":     "Identifiants incorrects",
-			"CSRFToken": csrfToken,
+	recovery, err := am.UserManager.VerifySecondFactor(p.Username, code)
+	if err != nil {
+		am.AuditAs(r, p.Username, "LOGIN_FAILED", p.Username, "second facteur : "+err.Error())
+		if errors.Is(err, ErrInvalidCode) {
+			am.renderLogin(w, r, map[string]interface{}{
+				"Step":       "totp",
+				"LoginToken": token,
+				"Error":      "Code invalide",
+			})
+			return
+		}
+		// Compte verrouillé ou désactivé : retour au mot de passe
+		am.endPendingLogin(token)
+		am.renderLogin(w, r, map[string]interface{}{
+			"Error": err.Error(),
 		})
 		return
 	}
+	am.endPendingLogin(token)
+	if recovery {
+		am.AuditAs(r, p.Username, "TOTP_RECOVERY_CODE", p.Username, "connexion avec un code de secours")
+	}
+	am.openSession(w, r, p.Username, nil)
+}
 
-	// Créer session
-	sessionToken := generateToken()
-	expiresAt := time.Now().Add(24 * time.Hour)
+// changePassword enregistre le nouveau mot de passe demandé avant l'ouverture de la session
+func (am *AuthManager) changePassword(w http.ResponseWriter, r *http.Request, token string, p pendingLogin) {
+	password := r.FormValue("new_password")
+	if password != r.FormValue("confirm_password") {
+		am.renderPasswordChange(w, r, token, p, "Les mots de passe ne correspondent pas")
+		return
+	}
+	if err := am.UserManager.UpdatePassword(p.Username, password); err != nil {
+		am.renderPasswordChange(w, r, token, p, err.Error())
+		return
+	}
+	am.endPendingLogin(token)
+	am.AuditAs(r, p.Username, "PASSWORD_CHANGE", p.Username, "changement à la connexion : "+p.Reason)
+	// Un mot de passe expiré a pu servir à ouvrir d'autres sessions
+	if _, err := am.RevokeUserSessions(p.Username, ""); err != nil {
+		log.Printf("Erreur révocation des sessions de %s: %v", p.Username, err)
+	}
+	am.openSession(w, r, p.Username, p.RecoveryCodes)
+}
 
-	am.sessions[sessionToken] = Session{
-		Username: user.Username,
-		Expiry:   expiresAt,
+// startSession ouvre la session et pose le cookie (nouveau jeton : le cookie de la page de connexion
+// n'est pas réutilisé). En cas d'échec, l'erreur est déjà répondue.
+func (am *AuthManager) startSession(w http.ResponseWriter, r *http.Request, username string) bool {
+	sessionToken, session, err := am.createSession(username, r)
+	if err != nil {
+		log.Printf("Erreur création session %s: %v", username, err)
+		http.Error(w, "Erreur création de session", http.StatusInternalServerError)
+		return false
 	}
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
 		Value:    sessionToken,
-		Expires:  expiresAt,
+		Expires:  session.ExpiresAt,
 		Path:     "/",
 		HttpOnly: true,
 	})
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	details := ""
+	if u, err := am.store().GetUser(username); err == nil {
+		details = "source=" + u.Source
+	}
+	am.AuditAs(r, username, "LOGIN", username, details)
+	return true
+}
+
+// renderEnrollment affiche l'enrôlement imposé : QR code (généré localement) et secret à saisir
+func (am *AuthManager) renderEnrollment(w http.ResponseWriter, r *http.Request, token string, p pendingLogin, errMsg string) {
+	data := map[string]interface{}{
+		"Step":       "enroll",
+		"LoginToken": token,
+		"Secret":     p.Secret,
+		"Error":      errMsg,
+	}
+	if code, err := qrcode.Encode(TOTPURI(p.Username, p.Secret)); err == nil {
+		data["QRCode"] = template.HTML(code.SVG())
+	}
+	am.renderLogin(w, r, data)
+}
+
+// renderPasswordChange demande un nouveau mot de passe (changement imposé ou mot de passe expiré)
+func (am *AuthManager) renderPasswordChange(w http.ResponseWriter, r *http.Request, token string, p pendingLogin, errMsg string) {
+	am.renderLogin(w, r, map[string]interface{}{
+		"Step":         "password_change",
+		"LoginToken":   token,
+		"Reason":       p.Reason,
+		"PasswordHint": am.UserManager.PasswordHint(),
+		"Error":        errMsg,
+	})
+}
+
+// renderLogin affiche la page de connexion avec le jeton CSRF de la session en cours
+func (am *AuthManager) renderLogin(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	// Le cookie est créé à l'affichage (GET /login, retour du SSO) ; un POST le porte déjà (CSRF)
+	csrfToken := ""
+	if r.Method == http.MethodGet {
+		csrfToken = middleware.GetCSRFTokenForSession(am.ensureLoginCookie(w, r))
+	} else if cookie, err := r.Cookie("session_token"); err == nil {
+		csrfToken = middleware.GetCSRFTokenForSession(cookie.Value)
+	}
+	data["CSRFToken"] = csrfToken
+	if _, ok := data["Step"]; !ok {
+		data["Step"] = "password"
+	}
+	if am.oidc != nil {
+		data["SSOLabel"] = am.oidc.Label()
+	}
+
+	tmpl, err := template.ParseFiles("templates/login.html")
+	if err != nil {
+		http.Error(w, "Erreur template login", http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, data)
+}
+
+// ensureLoginCookie retourne le cookie de la page de connexion, créé au besoin (porteur du jeton CSRF)
+func (am *AuthManager) ensureLoginCookie(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie("session_token"); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	sessionID := generateToken()
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    sessionID,
+		Expires:  time.Now().Add(24 * time.Hour),
+		Path:     "/",
+		HttpOnly: true,
+	})
+	return sessionID
 }
 
 // LogoutHandler gère la déconnexion
 func (am *AuthManager) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("session_token")
-	if err == nil {
-		delete(am.sessions, cookie.Value)
+	if db := am.store(); err == nil && db != nil {
+		if session, _ := db.GetSessionByHash(HashAPIToken(cookie.Value)); session != nil {
+			db.DeleteSession(session.ID)
+			am.AuditAs(r, session.Username, "LOGOUT", session.Username, "")
+		}
 	}
 
 	http.SetCookie(w, &http.Cookie{
@@ -151,56 +393,44 @@ func (am *AuthManager) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 // IsAdminSession vérifie si la session appartient à un admin
 func (am *AuthManager) IsAdminSession(token string) bool {
-	session, exists := am.sessions[token]
-	if !exists {
-		return false
-	}
-	if session.Expiry.Before(time.Now()) {
+	session, ok := am.lookupSession(token)
+	if !ok {
 		return false
 	}
 	return am.UserManager.IsAdmin(session.Username)
 }
 
-// GetUserRole retourne le rôle de l'utilisateur connecté
-func (am *AuthManager) GetUserRole(r *http.Request) string {
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
-		return ""
+// SessionUser retourne l'utilisateur d'une session, si elle existe et n'a pas expiré
+// (connexions longues comme le WebSocket, qui revérifient leur session)
+func (am *AuthManager) SessionUser(token string) (string, bool) {
+	session, ok := am.lookupSession(token)
+	if !ok {
+		return "", false
 	}
+	return session.Username, true
+}
 
-	sessionToken := cookie.Value
-	session, exists := am.sessions[sessionToken]
-	if !exists || session.Expiry.Before(time.Now()) {
+// GetUserRole retourne le rôle de l'utilisateur connecté (lu à chaque requête pour rester à jour)
+func (am *AuthManager) GetUserRole(r *http.Request) string {
+	if ident, ok := requestIdentity(r); ok {
+		return ident.Role
+	}
+	session := am.CurrentSession(r)
+	if session == nil {
 		return ""
 	}
-
-	// Récupérer le rôle via UserManager pour être à jour
-	// (Si on stockait le rôle dans la session, ce serait plus rapide mais moins "live")
-	// Mais UserManager.users est en mémoire, c'est rapide.
-	// On n'a pas accès direct à UserManager.users ici sans méthode publique,
-	// mais on peut ajouter une méthode GetUser(username) ou juste déduire du IsAdmin.
-
-	// Simplification: on récupère l'user complet ou on modifie Session pour inclure le rôle?
-	// La session a juste Username.
-
-	// On va utiliser GetAllUsers() pour trouver l'user? Non c'est lourd.
-	// On va ajouter GetUserRole à UserManager.
 	return am.UserManager.GetUserRole(session.Username)
 }
 
 // GetUsername retourne le nom de l'utilisateur connecté
 func (am *AuthManager) GetUsername(r *http.Request) string {
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
-		return ""
+	if ident, ok := requestIdentity(r); ok {
+		return ident.Username
 	}
-
-	sessionToken := cookie.Value
-	session, exists := am.sessions[sessionToken]
-	if !exists || session.Expiry.Before(time.Now()) {
+	session := am.CurrentSession(r)
+	if session == nil {
 		return ""
 	}
-
 	return session.Username
 }
 
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/pkg/crypto"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrDirectoryCredentials = errors.New("identifiants refusés par l'annuaire")
+	ErrDirectoryUserUnknown = errors.New("utilisateur absent de l'annuaire")
+	ErrDirectoryNoRole      = errors.New("aucun groupe de l'annuaire n'est associé à un rôle")
+)
+
+// LDAPDirectory authentifie les utilisateurs auprès d'un annuaire LDAP / Active Directory
+// et déduit leur rôle de leurs groupes. Les groupes lus sont mis en cache (cache_ttl).
+type LDAPDirectory struct {
+	cfg          config.LDAPConfig
+	bindPassword string
+	tlsConfig    *tls.Config
+	ttl, timeout time.Duration
+
+	mu    sync.Mutex
+	cache map[string]directoryEntry
+}
+
+// directoryEntry est le résultat d'une recherche dans l'annuaire
+type directoryEntry struct {
+	role    string
+	err     error // ErrDirectoryUserUnknown, ErrDirectoryNoRole ou annuaire injoignable
+	expires time.Time
+}
+
+// NewLDAPDirectory prépare la connexion à l'annuaire (configuration normalisée par config.LoadConfig)
+func NewLDAPDirectory(cfg config.LDAPConfig) (*LDAPDirectory, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") {
+		return nil, fmt.Errorf("URL LDAP invalide: %s", cfg.URL)
+	}
+	if u.Scheme == "ldap" && !cfg.StartTLS {
+		log.Printf("AVERTISSEMENT: annuaire LDAP sans TLS (%s) : les mots de passe circulent en clair", cfg.URL)
+	}
+
+	password := cfg.BindPassword
+	if crypto.IsEncrypted(password) {
+		if password, err = crypto.Decrypt(password); err != nil {
+			return nil, fmt.Errorf("déchiffrement du mot de passe LDAP: %w", err)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if tlsConfig.RootCAs, err = caCertPool(cfg.CACert); err != nil {
+		return nil, err
+	}
+
+	return &LDAPDirectory{
+		cfg:          cfg,
+		bindPassword: password,
+		tlsConfig:    tlsConfig,
+		ttl:          time.Duration(cfg.CacheTTL) * time.Second,
+		timeout:      time.Duration(cfg.Timeout) * time.Second,
+		cache:        make(map[string]directoryEntry),
+	}, nil
+}
+
+// caCertPool charge l'autorité de certification d'un service interne (nil : magasin système)
+func caCertPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("lecture du certificat %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("certificat invalide: %s", path)
+	}
+	return pool, nil
+}
+
+// connect ouvre une connexion (LDAPS ou StartTLS) liée au compte de service
+func (d *LDAPDirectory) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(d.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: d.timeout}),
+		ldap.DialWithTLSConfig(d.tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("connexion à l'annuaire: %w", err)
+	}
+	conn.SetTimeout(d.timeout)
+
+	if d.cfg.StartTLS {
+		if err := conn.StartTLS(d.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS: %w", err)
+		}
+	}
+	if d.cfg.BindDN != "" {
+		if err := conn.Bind(d.cfg.BindDN, d.bindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("bind du compte de service: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// search retourne le DN et les groupes d'un utilisateur
+func (d *LDAPDirectory) search(conn *ldap.Conn, username string) (string, []string, error) {
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "%s", ldap.EscapeFilter(username))
+	req := ldap.NewSearchRequest(d.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(d.timeout/time.Second), false, filter, []string{d.cfg.GroupAttribute}, nil)
+
+	res, err := conn.Search(req)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return "", nil, fmt.Errorf("recherche de %s: %w", username, err)
+	}
+	switch {
+	case res == nil || len(res.Entries) == 0:
+		return "", nil, ErrDirectoryUserUnknown
+	case len(res.Entries) > 1:
+		return "", nil, fmt.Errorf("plusieurs entrées pour %s (filtre %s)", username, d.cfg.UserFilter)
+	}
+	entry := res.Entries[0]
+	return entry.DN, entry.GetAttributeValues(d.cfg.GroupAttribute), nil
+}
+
+// roleFor retourne le rôle du premier groupe associé dont l'utilisateur est membre
+func (d *LDAPDirectory) roleFor(groups []string) (string, error) {
+	for _, m := range d.cfg.GroupMappings {
+		for _, g := range groups {
+			if sameDN(m.Group, g) {
+				return m.Role, nil
+			}
+		}
+	}
+	if d.cfg.DefaultRole != "" {
+		return d.cfg.DefaultRole, nil
+	}
+	return "", ErrDirectoryNoRole
+}
+
+// sameDN compare deux DN sans tenir compte de la casse ni des espaces autour des séparateurs
+func sameDN(a, b string) bool {
+	da, errA := ldap.ParseDN(a)
+	db, errB := ldap.ParseDN(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+	return da.EqualFold(db)
+}
+
+// Authenticate vérifie le mot de passe par un bind avec le DN de l'utilisateur et retourne son rôle
+func (d *LDAPDirectory) Authenticate(username, password string) (string, error) {
+	// Un bind sans mot de passe est anonyme et réussit sur de nombreux annuaires
+	if username == "" || password == "" {
+		return "", ErrDirectoryCredentials
+	}
+
+	conn, err := d.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	dn, groups, err := d.search(conn, username)
+	if err != nil {
+		if errors.Is(err, ErrDirectoryUserUnknown) {
+			return "", ErrDirectoryCredentials
+		}
+		return "", err
+	}
+	if err := conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return "", ErrDirectoryCredentials
+		}
+		return "", fmt.Errorf("bind de %s: %w", username, err)
+	}
+
+	role, err := d.roleFor(groups)
+	d.store(username, role, err)
+	return role, err
+}
+
+// Lookup retourne le rôle actuel d'un utilisateur (groupes relus après cache_ttl, avec le compte de service)
+func (d *LDAPDirectory) Lookup(username string) (string, error) {
+	now := time.Now()
+	d.mu.Lock()
+	if e, ok := d.cache[username]; ok && e.expires.After(now) {
+		d.mu.Unlock()
+		return e.role, e.err
+	}
+	d.mu.Unlock()
+
+	role, err := d.lookup(username)
+	d.store(username, role, err)
+	return role, err
+}
+
+func (d *LDAPDirectory) lookup(username string) (string, error) {
+	conn, err := d.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	_, groups, err := d.search(conn, username)
+	if err != nil {
+		return "", err
+	}
+	return d.roleFor(groups)
+}
+
+func (d *LDAPDirectory) store(username, role string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cache[username] = directoryEntry{role: role, err: err, expires: time.Now().Add(d.ttl)}
+}
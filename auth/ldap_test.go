@@ -0,0 +1,423 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/storage"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLDAPEntry est une entrée de l'annuaire de test
+type fakeLDAPEntry struct {
+	DN       string
+	Password string
+	Attrs    map[string][]string
+}
+
+// fakeLDAP est un serveur LDAP minimal (bind simple, recherche, StartTLS) pour les tests
+type fakeLDAP struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	entries  []fakeLDAPEntry
+	searches []string // DN lié au moment de chaque recherche
+}
+
+const (
+	ldapServiceDN  = "cn=svc-monitoring,ou=services,dc=corp,dc=local"
+	ldapOpsProdDN  = "CN=Ops-Prod,OU=Groups,DC=corp,DC=local"
+	ldapAdminsDN   = "CN=Monitoring-Admins,OU=Groups,DC=corp,DC=local"
+	ldapStartTLSID = "1.3.6.1.4.1.1466.20037"
+)
+
+// testTLSConfig génère un certificat auto-signé pour 127.0.0.1 et écrit son PEM (ca_cert)
+func testTLSConfig(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap.corp.local"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, caFile
+}
+
+// startFakeLDAP démarre le serveur ; en LDAPS, la connexion est chiffrée dès l'ouverture
+func startFakeLDAP(t *testing.T, tlsConfig *tls.Config, ldaps bool, entries []fakeLDAPEntry) *fakeLDAP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if ldaps {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	s := &fakeLDAP{ln: ln, tlsConfig: tlsConfig, entries: entries}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeLDAP) addr() string { return s.ln.Addr().String() }
+
+func (s *fakeLDAP) setGroups(dn string, groups ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if strings.EqualFold(s.entries[i].DN, dn) {
+			s.entries[i].Attrs["memberOf"] = groups
+		}
+	}
+}
+
+func (s *fakeLDAP) serve(conn net.Conn) {
+	defer func() { conn.Close() }() // conn est remplacée par la connexion TLS après StartTLS
+	bound := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case 0: // BindRequest
+			dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+			code := int64(49) // invalidCredentials
+			if dn == "" && password == "" {
+				code, bound = 0, ""
+			} else if e := s.find(dn); e != nil && e.Password == password {
+				code, bound = 0, e.DN
+			}
+			conn.Write(ldapResult(id, 1, code).Bytes())
+		case 2: // UnbindRequest
+			return
+		case 3: // SearchRequest
+			s.mu.Lock()
+			s.searches = append(s.searches, bound)
+			s.mu.Unlock()
+			for _, e := range s.search(id, op) {
+				conn.Write(e.Bytes())
+			}
+			conn.Write(ldapResult(id, 5, 0).Bytes())
+		case 23: // ExtendedRequest
+			if op.Children[0].Data.String() != ldapStartTLSID {
+				conn.Write(ldapResult(id, 24, 2).Bytes())
+				continue
+			}
+			conn.Write(ldapResult(id, 24, 0).Bytes())
+			conn = tls.Server(conn, s.tlsConfig)
+		default:
+			return
+		}
+	}
+}
+
+func (s *fakeLDAP) find(dn string) *fakeLDAPEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if strings.EqualFold(s.entries[i].DN, dn) {
+			e := s.entries[i]
+			return &e
+		}
+	}
+	return nil
+}
+
+// search retourne les entrées (SearchResultEntry) sous la base qui vérifient le filtre
+func (s *fakeLDAP) search(id int64, op *ber.Packet) []*ber.Packet {
+	base := strings.ToLower(op.Children[0].Data.String())
+	filter := op.Children[6]
+	var wanted []string
+	for _, a := range op.Children[7].Children {
+		wanted = append(wanted, a.Data.String())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*ber.Packet
+	for _, e := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(e.DN), base) || !matchFilter(filter, e) {
+			continue
+		}
+		p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "Search Result Entry")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "DN"))
+		attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for _, name := range wanted {
+			values, ok := attrValues(e, name)
+			if !ok {
+				continue
+			}
+			attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, v := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+			}
+			attr.AppendChild(set)
+			attrs.AppendChild(attr)
+		}
+		entry.AppendChild(attrs)
+		p.AppendChild(entry)
+		out = append(out, p)
+	}
+	return out
+}
+
+func attrValues(e fakeLDAPEntry, name string) ([]string, bool) {
+	for k, v := range e.Attrs {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// matchFilter évalue les filtres utilisés par l'authentification : &, |, !, égalité, présence
+func matchFilter(f *ber.Packet, e fakeLDAPEntry) bool {
+	switch f.Tag {
+	case 0:
+		for _, c := range f.Children {
+			if !matchFilter(c, e) {
+				return false
+			}
+		}
+		return true
+	case 1:
+		for _, c := range f.Children {
+			if matchFilter(c, e) {
+				return true
+			}
+		}
+		return false
+	case 2:
+		return !matchFilter(f.Children[0], e)
+	case 3:
+		values, _ := attrValues(e, f.Children[0].Data.String())
+		for _, v := range values {
+			if strings.EqualFold(v, f.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case 7:
+		_, ok := attrValues(e, f.Data.String())
+		return ok
+	}
+	return false
+}
+
+func ldapResult(id int64, app ber.Tag, code int64) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	r := ber.Encode(ber.ClassApplication, ber.TypeConstructed, app, nil, "Response")
+	r.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	r.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	p.AppendChild(r)
+	return p
+}
+
+func ldapUsers() []fakeLDAPEntry {
+	user := func(name string, groups ...string) fakeLDAPEntry {
+		return fakeLDAPEntry{
+			DN:       "CN=" + name + ",OU=Users,DC=corp,DC=local",
+			Password: name + "-secret",
+			Attrs: map[string][]string{
+				"objectClass":    {"top", "person", "user"},
+				"sAMAccountName": {name},
+				"memberOf":       groups,
+			},
+		}
+	}
+	return []fakeLDAPEntry{
+		{DN: ldapServiceDN, Password: "svc-secret", Attrs: map[string][]string{"objectClass": {"person"}}},
+		user("alice", "CN=Staff,OU=Groups,DC=corp,DC=local", "cn=ops-prod, ou=groups, dc=corp, dc=local"),
+		user("root", ldapAdminsDN, ldapOpsProdDN),
+		user("guest", "CN=Staff,OU=Groups,DC=corp,DC=local"),
+	}
+}
+
+func ldapConfig(url, caFile string) config.LDAPConfig {
+	return config.LDAPConfig{
+		URL:            url,
+		CACert:         caFile,
+		BindDN:         ldapServiceDN,
+		BindPassword:   "svc-secret",
+		BaseDN:         "DC=corp,DC=local",
+		UserFilter:     "(&(objectClass=user)(sAMAccountName=%s))",
+		GroupAttribute: "memberOf",
+		GroupMappings: []config.LDAPGroupMapping{
+			{Group: ldapAdminsDN, Role: config.RoleAdmin},
+			{Group: ldapOpsProdDN, Role: "operator"},
+		},
+		CacheTTL: 300,
+		Timeout:  5,
+	}
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	tlsConfig, caFile := testTLSConfig(t)
+	server := startFakeLDAP(t, tlsConfig, true, ldapUsers())
+	dir, err := NewLDAPDirectory(ldapConfig("ldaps://"+server.addr(), caFile))
+	require.NoError(t, err)
+
+	db := setupTestDB(t)
+	um := NewUserManager(db, nil)
+	um.SetDirectory(dir)
+
+	// Première connexion : compte local créé, rôle déduit du premier groupe associé
+	user, err := um.Authenticate("alice", "alice-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "operator", user.Role)
+	assert.Equal(t, storage.UserSourceLDAP, user.Source)
+	stored, err := db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Empty(t, stored.PasswordHash, "aucun mot de passe local")
+
+	user, err = um.Authenticate("root", "root-secret")
+	require.NoError(t, err)
+	assert.Equal(t, config.RoleAdmin, user.Role, "l'ordre des associations détermine le rôle")
+
+	// La recherche se fait avec le compte de service
+	server.mu.Lock()
+	assert.Equal(t, ldapServiceDN, server.searches[0])
+	server.mu.Unlock()
+
+	// Mauvais mot de passe : échec compté pour le compte provisionné
+	_, err = um.Authenticate("alice", "wrong")
+	assert.Error(t, err)
+	stored, _ = db.GetUser("alice")
+	assert.Equal(t, 1, stored.FailedAttempts)
+
+	// Mot de passe vide (bind anonyme), utilisateur inconnu, injection dans le filtre, aucun groupe associé
+	for _, c := range [][2]string{{"alice", ""}, {"nobody", "x"}, {"*", "alice-secret"}, {"guest", "guest-secret"}} {
+		_, err = um.Authenticate(c[0], c[1])
+		assert.Error(t, err, c[0])
+	}
+	_, err = db.GetUser("guest")
+	assert.Error(t, err, "pas de compte sans rôle")
+
+	// Mot de passe et rôle gérés par l'annuaire
+	assert.ErrorIs(t, um.UpdatePassword("alice", "newpassword"), ErrExternalAccount)
+	assert.ErrorIs(t, um.UpdateUserRole("alice", config.RoleAdmin), ErrExternalAccount)
+}
+
+func TestLDAPStartTLS(t *testing.T) {
+	tlsConfig, caFile := testTLSConfig(t)
+	server := startFakeLDAP(t, tlsConfig, false, ldapUsers())
+	cfg := ldapConfig("ldap://"+server.addr(), caFile)
+	cfg.StartTLS = true
+	dir, err := NewLDAPDirectory(cfg)
+	require.NoError(t, err)
+
+	role, err := dir.Authenticate("alice", "alice-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "operator", role)
+
+	// Certificat non reconnu : connexion refusée
+	cfg.CACert = ""
+	untrusted, err := NewLDAPDirectory(cfg)
+	require.NoError(t, err)
+	_, err = untrusted.Authenticate("alice", "alice-secret")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrDirectoryCredentials)
+}
+
+func TestLDAPRoleSyncAndBreakGlass(t *testing.T) {
+	tlsConfig, caFile := testTLSConfig(t)
+	server := startFakeLDAP(t, tlsConfig, true, ldapUsers())
+	dir, err := NewLDAPDirectory(ldapConfig("ldaps://"+server.addr(), caFile))
+	require.NoError(t, err)
+
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "localpassword", Role: "user"}})
+	um.SetDirectory(dir)
+	expire := func() {
+		dir.mu.Lock()
+		dir.cache = make(map[string]directoryEntry)
+		dir.mu.Unlock()
+	}
+
+	// Compte local prioritaire sur l'annuaire (même nom)
+	user, err := um.Authenticate("alice", "localpassword")
+	require.NoError(t, err)
+	assert.Equal(t, storage.UserSourceLocal, user.Source)
+	_, err = um.Authenticate("alice", "alice-secret")
+	assert.Error(t, err)
+
+	_, err = um.Authenticate("root", "root-secret")
+	require.NoError(t, err)
+	assert.Equal(t, config.RoleAdmin, um.GetUserRole("root"))
+
+	// Groupes en cache pendant cache_ttl, relus ensuite
+	server.setGroups("CN=root,OU=Users,DC=corp,DC=local", ldapOpsProdDN)
+	assert.Equal(t, config.RoleAdmin, um.GetUserRole("root"))
+	expire()
+	assert.Equal(t, "operator", um.GetUserRole("root"))
+
+	// Retiré de tout groupe associé : plus aucun rôle
+	server.setGroups("CN=root,OU=Users,DC=corp,DC=local")
+	expire()
+	assert.Equal(t, "", um.GetUserRole("root"))
+	assert.False(t, HasCapability(permissionsConfig(), um.GetUserRole("root"), config.CapabilityLogs, ""))
+
+	// Annuaire injoignable : le dernier rôle connu est conservé, les comptes locaux restent utilisables
+	server.setGroups("CN=root,OU=Users,DC=corp,DC=local", ldapAdminsDN)
+	expire()
+	assert.Equal(t, config.RoleAdmin, um.GetUserRole("root"))
+	server.ln.Close()
+	expire()
+	assert.Equal(t, config.RoleAdmin, um.GetUserRole("root"))
+	_, err = um.Authenticate("root", "root-secret")
+	assert.Error(t, err)
+	_, err = um.Authenticate("admin", "admin")
+	assert.NoError(t, err, "compte local de secours")
+}
+
+func TestSameDN(t *testing.T) {
+	assert.True(t, sameDN("CN=Ops-Prod,OU=Groups,DC=corp,DC=local", "cn=ops-prod, ou=groups, dc=corp, dc=local"))
+	assert.False(t, sameDN("CN=Ops-Prod,OU=Groups,DC=corp,DC=local", "CN=Ops-Preprod,OU=Groups,DC=corp,DC=local"))
+}
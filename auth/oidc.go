@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go-monitoring/config"
+	"go-monitoring/pkg/crypto"
+	"go-monitoring/storage"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Délai pour revenir du fournisseur d'identité après la redirection
+const oidcLoginTTL = 10 * time.Minute
+
+// Cookie liant la redirection au navigateur qui l'a demandée (protection contre l'injection de code)
+const oidcStateCookie = "oidc_state"
+
+var ErrOIDCNoRole = errors.New("aucune valeur du claim de rôles n'est associée à un rôle")
+
+// OIDCProvider gère la connexion OpenID Connect (code d'autorisation avec PKCE).
+// La découverte du fournisseur est faite à la première connexion, puis conservée.
+type OIDCProvider struct {
+	cfg    config.OIDCConfig
+	secret string
+	client *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	pending  map[string]oidcLogin // Par state
+}
+
+// oidcLogin est une redirection en cours vers le fournisseur
+type oidcLogin struct {
+	Nonce    string
+	Verifier string // PKCE
+	Expiry   time.Time
+}
+
+// NewOIDCProvider prépare le client du fournisseur (configuration normalisée par config.LoadConfig)
+func NewOIDCProvider(cfg config.OIDCConfig) (*OIDCProvider, error) {
+	secret := cfg.ClientSecret
+	if crypto.IsEncrypted(secret) {
+		var err error
+		if secret, err = crypto.Decrypt(secret); err != nil {
+			return nil, fmt.Errorf("déchiffrement du secret OIDC: %w", err)
+		}
+	}
+
+	roots, err := caCertPool(cfg.CACert)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+
+	return &OIDCProvider{
+		cfg:     cfg,
+		secret:  secret,
+		client:  &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		pending: make(map[string]oidcLogin),
+	}, nil
+}
+
+// Label retourne le libellé du bouton de connexion
+func (p *OIDCProvider) Label() string {
+	return p.cfg.ButtonLabel
+}
+
+// discover interroge le document de découverte de l'émetteur (une seule fois en cas de succès)
+func (p *OIDCProvider) discover() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	// Contexte sans annulation : il sert aussi à télécharger les clés de signature par la suite
+	ctx := oidc.ClientContext(context.Background(), p.client)
+	provider, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("découverte de %s: %w", p.cfg.Issuer, err)
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.secret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+	}
+	p.verifier = provider.VerifierContext(ctx, &oidc.Config{ClientID: p.cfg.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// begin retourne l'URL d'autorisation et le state d'une nouvelle connexion
+func (p *OIDCProvider) begin() (string, string, error) {
+	oauth, _, err := p.discover()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, login := generateToken(), oidcLogin{
+		Nonce:    generateToken(),
+		Verifier: oauth2.GenerateVerifier(),
+		Expiry:   time.Now().Add(oidcLoginTTL),
+	}
+	now := time.Now()
+	p.mu.Lock()
+	for s, other := range p.pending {
+		if other.Expiry.Before(now) {
+			delete(p.pending, s)
+		}
+	}
+	p.pending[state] = login
+	p.mu.Unlock()
+
+	return oauth.AuthCodeURL(state, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier)), state, nil
+}
+
+// finish échange le code contre les jetons, vérifie le jeton d'identité et retourne
+// le nom d'utilisateur et le rôle déduits des claims. Le state n'est utilisable qu'une fois.
+func (p *OIDCProvider) finish(ctx context.Context, state, code string) (string, string, error) {
+	p.mu.Lock()
+	login, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+	if !ok || login.Expiry.Before(time.Now()) {
+		return "", "", errors.New("connexion SSO expirée ou inconnue")
+	}
+
+	oauth, verifier, err := p.discover()
+	if err != nil {
+		return "", "", err
+	}
+	ctx = oidc.ClientContext(ctx, p.client)
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return "", "", fmt.Errorf("échange du code: %w", err)
+	}
+	rawID, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", "", errors.New("jeton d'identité absent de la réponse")
+	}
+	idToken, err := verifier.Verify(ctx, rawID)
+	if err != nil {
+		return "", "", fmt.Errorf("jeton d'identité invalide: %w", err)
+	}
+	if idToken.Nonce != login.Nonce {
+		return "", "", errors.New("nonce du jeton d'identité invalide")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", "", err
+	}
+	usernames := claimValues(claims, p.cfg.UsernameClaim)
+	if len(usernames) != 1 || !validExternalUsername(usernames[0]) {
+		return "", "", fmt.Errorf("claim %s absent ou invalide (sujet %s)", p.cfg.UsernameClaim, idToken.Subject)
+	}
+	role, err := p.roleFor(claimValues(claims, p.cfg.RolesClaim))
+	return usernames[0], role, err
+}
+
+// roleFor retourne le rôle de la première association dont la valeur figure dans le claim
+func (p *OIDCProvider) roleFor(values []string) (string, error) {
+	for _, m := range p.cfg.RoleMappings {
+		if contains(values, m.Value) {
+			return m.Role, nil
+		}
+	}
+	if p.cfg.DefaultRole != "" {
+		return p.cfg.DefaultRole, nil
+	}
+	return "", ErrOIDCNoRole
+}
+
+// claimValues lit un claim (chaîne ou liste), éventuellement imbriqué : realm_access.roles
+func claimValues(claims map[string]interface{}, path string) []string {
+	var current interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+
+	switch v := current.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// validExternalUsername refuse les noms vides, trop longs ou contenant des espaces, des caractères
+// de contrôle ou « : » (réservé aux identités des jetons de service dans l'audit)
+func validExternalUsername(name string) bool {
+	if name == "" || len(name) > 128 {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || r == ':' || r == '/' {
+			return false
+		}
+	}
+	return true
+}
+
+// SetOIDC active la connexion SSO (bouton sur la page de connexion)
+func (am *AuthManager) SetOIDC(p *OIDCProvider) {
+	am.oidc = p
+}
+
+// OIDCLoginHandler redirige vers le fournisseur d'identité
+func (am *AuthManager) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if am.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	authURL, state, err := am.oidc.begin()
+	if err != nil {
+		log.Printf("OIDC: %v", err)
+		am.renderLogin(w, r, map[string]interface{}{
+			"Error": "Fournisseur d'identité injoignable",
+		})
+		return
+	}
+
+	// SameSite=Lax : le cookie accompagne le retour du fournisseur (navigation de premier niveau)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		MaxAge:   int(oidcLoginTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler termine la connexion au retour du fournisseur d'identité
+func (am *AuthManager) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if am.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc/", MaxAge: -1})
+
+	var username string // Connu après la vérification du jeton d'identité
+	fail := func(format string, args ...interface{}) {
+		log.Printf("OIDC: "+format, args...)
+		am.AuditAs(r, username, "LOGIN_FAILED", "oidc", fmt.Sprintf(format, args...))
+		am.renderLogin(w, r, map[string]interface{}{
+			"Error": "Connexion SSO refusée",
+		})
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		fail("erreur du fournisseur: %s %s", e, q.Get("error_description"))
+		return
+	}
+	state := q.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		fail("state absent ou différent du cookie")
+		return
+	}
+
+	username, role, err := am.oidc.finish(r.Context(), state, q.Get("code"))
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+	user, err := am.UserManager.LoginExternal(username, role, storage.UserSourceOIDC)
+	if err != nil {
+		fail("connexion de %s refusée: %v", username, err)
+		return
+	}
+	am.completeLogin(w, r, user)
+}
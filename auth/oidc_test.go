@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockOIDC est un fournisseur OpenID Connect minimal : découverte, clés, autorisation et jetons
+type mockOIDC struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]interface{} // Claims du prochain jeton d'identité
+	nonce  string                 // Remplace le nonce reçu s'il est défini
+	codes  map[string]mockAuthRequest
+}
+
+type mockAuthRequest struct {
+	nonce, challenge, redirectURI string
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockOIDC{key: key, codes: make(map[string]mockAuthRequest)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+			"n": b64(key.N.Bytes()),
+			"e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("GET /authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "requête invalide", http.StatusBadRequest)
+			return
+		}
+		code := generateToken()
+		m.mu.Lock()
+		m.codes[code] = mockAuthRequest{q.Get("nonce"), q.Get("code_challenge"), q.Get("redirect_uri")}
+		m.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+q.Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mu.Lock()
+		req, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code")) // Code à usage unique
+		claims, nonce := m.claims, m.nonce
+		m.mu.Unlock()
+
+		id, secret, _ := r.BasicAuth()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || id != "monitoring" || secret != "s3cret" ||
+			r.PostForm.Get("redirect_uri") != req.redirectURI || b64(sum[:]) != req.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		if nonce == "" {
+			nonce = req.nonce
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"id_token":     m.sign(t, claims, nonce),
+		})
+	})
+	m.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sign produit un jeton d'identité RS256
+func (m *mockOIDC) sign(t *testing.T, claims map[string]interface{}, nonce string) string {
+	payload := map[string]interface{}{
+		"iss":   m.URL,
+		"aud":   "monitoring",
+		"sub":   "0b1c2d",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	signed := b64(header) + "." + b64(body)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, sum[:])
+	require.NoError(t, err)
+	return signed + "." + b64(sig)
+}
+
+func (m *mockOIDC) setClaims(claims map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claims = claims
+}
+
+// config retourne la configuration du client (autorité du serveur de test en fichier PEM)
+func (m *mockOIDC) config(t *testing.T) config.OIDCConfig {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: m.Certificate().Raw}), 0600))
+	return config.OIDCConfig{
+		Issuer:        m.URL,
+		ClientID:      "monitoring",
+		ClientSecret:  "s3cret",
+		RedirectURL:   "https://monitoring.test/auth/oidc/callback",
+		Scopes:        []string{"openid", "profile"},
+		CACert:        caFile,
+		UsernameClaim: "preferred_username",
+		RolesClaim:    "realm_access.roles",
+		RoleMappings: []config.OIDCRoleMapping{
+			{Value: "monitoring-admins", Role: "admin"},
+			{Value: "monitoring-ops", Role: "operator"},
+		},
+		ButtonLabel: "Connexion SSO",
+	}
+}
+
+// oidcRedirect lance la connexion et suit la redirection du fournisseur jusqu'à l'URL de retour
+func oidcRedirect(t *testing.T, am *AuthManager, m *mockOIDC) (*http.Request, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	am.OIDCLoginHandler(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	var state *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			state = c
+		}
+	}
+	require.NotNil(t, state)
+
+	client := m.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	return httptest.NewRequest(http.MethodGet, resp.Header.Get("Location"), nil), state
+}
+
+func oidcCallback(am *AuthManager, req *http.Request, state *http.Cookie) *httptest.ResponseRecorder {
+	r := req.Clone(req.Context())
+	if state != nil {
+		r.AddCookie(state)
+	}
+	rec := httptest.NewRecorder()
+	am.OIDCCallbackHandler(rec, r)
+	return rec
+}
+
+// loggedIn indique une session ouverte (la page de connexion pose aussi un cookie, porteur du jeton CSRF)
+func loggedIn(rec *httptest.ResponseRecorder) bool {
+	return rec.Code == http.StatusSeeOther && hasSessionCookie(rec)
+}
+
+func setupOIDC(t *testing.T, users []config.UserConfig) (*AuthManager, *mockOIDC, *storage.DB) {
+	t.Chdir("..")
+	m := newMockOIDC(t)
+	db := setupTestDB(t)
+	am := NewAuthManager(NewUserManager(db, users))
+	am.SetConfigSource(staticConfig{sessionConfig(30, 120)})
+	provider, err := NewOIDCProvider(m.config(t))
+	require.NoError(t, err)
+	am.SetOIDC(provider)
+	return am, m, db
+}
+
+func TestOIDCLogin(t *testing.T) {
+	am, m, db := setupOIDC(t, nil)
+
+	// Première connexion : compte créé avec le rôle déduit du claim imbriqué
+	m.setClaims(map[string]interface{}{
+		"preferred_username": "carol",
+		"realm_access":       map[string]interface{}{"roles": []string{"offline_access", "monitoring-admins"}},
+	})
+	req, state := oidcRedirect(t, am, m)
+	rec := oidcCallback(am, req, state)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.True(t, hasSessionCookie(rec))
+
+	u, err := db.GetUser("carol")
+	require.NoError(t, err)
+	assert.Equal(t, storage.UserSourceOIDC, u.Source)
+	assert.Equal(t, "admin", u.Role)
+	assert.Empty(t, u.PasswordHash)
+
+	// Le code et le state ne sont utilisables qu'une fois
+	rec = oidcCallback(am, req, state)
+	assert.False(t, loggedIn(rec))
+	assert.Contains(t, rec.Body.String(), "Connexion SSO refusée")
+
+	// Le rôle suit le fournisseur à chaque connexion
+	m.setClaims(map[string]interface{}{
+		"preferred_username": "carol",
+		"realm_access":       map[string]interface{}{"roles": []string{"monitoring-ops"}},
+	})
+	req, state = oidcRedirect(t, am, m)
+	require.True(t, loggedIn(oidcCallback(am, req, state)))
+	u, err = db.GetUser("carol")
+	require.NoError(t, err)
+	assert.Equal(t, "operator", u.Role)
+
+	// Pas de mot de passe local pour un compte SSO
+	_, err = am.UserManager.Authenticate("carol", "")
+	assert.Error(t, err)
+}
+
+func TestOIDCLoginRejected(t *testing.T) {
+	am, m, db := setupOIDC(t, []config.UserConfig{{Username: "alice", Password: "password123", Role: "admin"}})
+	admins := map[string]interface{}{"roles": []string{"monitoring-admins"}}
+
+	// State absent ou différent du cookie (réponse injectée dans un autre navigateur)
+	m.setClaims(map[string]interface{}{"preferred_username": "carol", "realm_access": admins})
+	req, _ := oidcRedirect(t, am, m)
+	assert.False(t, loggedIn(oidcCallback(am, req, nil)))
+	req, _ = oidcRedirect(t, am, m)
+	assert.False(t, loggedIn(oidcCallback(am, req, &http.Cookie{Name: oidcStateCookie, Value: "autre"})))
+
+	// Nonce différent de celui de la redirection (jeton rejoué)
+	m.mu.Lock()
+	m.nonce = "rejoue"
+	m.mu.Unlock()
+	req, state := oidcRedirect(t, am, m)
+	assert.False(t, loggedIn(oidcCallback(am, req, state)))
+	m.mu.Lock()
+	m.nonce = ""
+	m.mu.Unlock()
+
+	// Aucune valeur associée à un rôle : pas de compte créé
+	m.setClaims(map[string]interface{}{
+		"preferred_username": "dave",
+		"realm_access":       map[string]interface{}{"roles": []string{"offline_access"}},
+	})
+	req, state = oidcRedirect(t, am, m)
+	assert.False(t, loggedIn(oidcCallback(am, req, state)))
+	_, err := db.GetUser("dave")
+	assert.Error(t, err)
+
+	// Un compte local homonyme n'est pas repris par le SSO
+	m.setClaims(map[string]interface{}{"preferred_username": "alice", "realm_access": admins})
+	req, state = oidcRedirect(t, am, m)
+	assert.False(t, loggedIn(oidcCallback(am, req, state)))
+	u, err := db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, storage.UserSourceLocal, u.Source)
+
+	// Nom d'utilisateur invalide
+	m.setClaims(map[string]interface{}{"preferred_username": "svc:backup", "realm_access": admins})
+	req, state = oidcRedirect(t, am, m)
+	assert.False(t, loggedIn(oidcCallback(am, req, state)))
+}
+
+func TestClaimValues(t *testing.T) {
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"groups":["a","b",3],"role":"ops","realm_access":{"roles":["x"]}}`), &claims))
+
+	assert.Equal(t, []string{"a", "b"}, claimValues(claims, "groups"))
+	assert.Equal(t, []string{"ops"}, claimValues(claims, "role"))
+	assert.Equal(t, []string{"x"}, claimValues(claims, "realm_access.roles"))
+	assert.Nil(t, claimValues(claims, "role.sub"))
+	assert.Nil(t, claimValues(claims, "missing"))
+}
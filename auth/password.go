@@ -0,0 +1,190 @@
+package auth
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go-monitoring/config"
+	"go-monitoring/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrWeakPassword   = errors.New("mot de passe trop faible")
+	ErrPasswordReused = errors.New("mot de passe déjà utilisé récemment")
+)
+
+// Longueur maximale acceptée par bcrypt (octets)
+const maxPasswordBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			set[line] = true
+		}
+	}
+	return set
+}()
+
+// isCommonPassword détecte les mots de passe de la liste embarquée, seuls ou suivis
+// de chiffres et de symboles (Azerty2024!)
+func isCommonPassword(password string) bool {
+	p := strings.ToLower(password)
+	if commonPasswords[p] {
+		return true
+	}
+	base := strings.TrimRightFunc(p, func(r rune) bool { return !unicode.IsLetter(r) })
+	return base != p && commonPasswords[base]
+}
+
+// CheckPasswordPolicy vérifie un nouveau mot de passe : longueur, types de caractères,
+// liste des mots de passe courants et nom d'utilisateur
+func CheckPasswordPolicy(policy config.PasswordPolicy, username, password string) error {
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w : %d octets au plus", ErrWeakPassword, maxPasswordBytes)
+	}
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return fmt.Errorf("%w : %d caractères minimum", ErrWeakPassword, policy.MinLength)
+	}
+
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < policy.MinClasses {
+		return fmt.Errorf("%w : %d types de caractères requis parmi minuscules, majuscules, chiffres et symboles",
+			ErrWeakPassword, policy.MinClasses)
+	}
+
+	if isCommonPassword(password) {
+		return fmt.Errorf("%w : mot de passe trop courant", ErrWeakPassword)
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return fmt.Errorf("%w : il ne doit pas contenir le nom d'utilisateur", ErrWeakPassword)
+	}
+	return nil
+}
+
+// PasswordPolicyHint décrit la politique pour les formulaires
+func PasswordPolicyHint(policy config.PasswordPolicy) string {
+	hint := fmt.Sprintf("%d caractères minimum, dont %d types parmi minuscules, majuscules, chiffres et symboles",
+		policy.MinLength, policy.MinClasses)
+	if policy.History > 0 {
+		hint += fmt.Sprintf(" ; différent des %d derniers", policy.History)
+	}
+	return hint
+}
+
+// SetConfigSource lie la politique des mots de passe (settings.password_policy).
+// Sans configuration, aucune règle n'est appliquée (outils, tests).
+func (um *UserManager) SetConfigSource(src ConfigSource) {
+	um.config = src
+}
+
+// passwordPolicy retourne la politique courante (normalisée par config.LoadConfig)
+func (um *UserManager) passwordPolicy() (config.PasswordPolicy, bool) {
+	if um.config == nil {
+		return config.PasswordPolicy{}, false
+	}
+	cfg := um.config.GetConfig()
+	if cfg == nil {
+		return config.PasswordPolicy{}, false
+	}
+	return cfg.Settings.PasswordPolicy, true
+}
+
+// PasswordHint décrit la politique courante ("" sans politique)
+func (um *UserManager) PasswordHint() string {
+	policy, ok := um.passwordPolicy()
+	if !ok {
+		return ""
+	}
+	return PasswordPolicyHint(policy)
+}
+
+// checkNewPassword applique la politique et refuse le mot de passe actuel et les history-1 précédents
+func (um *UserManager) checkNewPassword(u *storage.UserDB, password string) error {
+	policy, ok := um.passwordPolicy()
+	if !ok {
+		return nil
+	}
+	if err := CheckPasswordPolicy(policy, u.Username, password); err != nil {
+		return err
+	}
+	if policy.History == 0 {
+		return nil
+	}
+
+	hashes := []string{u.PasswordHash}
+	if policy.History > 1 {
+		previous, err := um.db.GetPasswordHistory(u.Username, policy.History-1)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, previous...)
+	}
+	for _, h := range hashes {
+		if h != "" && bcrypt.CompareHashAndPassword([]byte(h), []byte(password)) == nil {
+			return fmt.Errorf("%w : choisissez un mot de passe différent des %d derniers", ErrPasswordReused, policy.History)
+		}
+	}
+	return nil
+}
+
+// passwordHistoryKept retourne le nombre d'anciens mots de passe à conserver
+func (um *UserManager) passwordHistoryKept() int {
+	if policy, ok := um.passwordPolicy(); ok && policy.History > 1 {
+		return policy.History - 1
+	}
+	return 0
+}
+
+// passwordChangeReason indique pourquoi un compte local doit changer de mot de passe avant d'ouvrir
+// une session ("" sinon) : changement imposé (compte initial, réinitialisation) ou expiration
+func (um *UserManager) passwordChangeReason(u *storage.UserDB) string {
+	if u.Source != storage.UserSourceLocal {
+		return ""
+	}
+	if u.MustChangePassword {
+		return "Vous devez choisir un nouveau mot de passe"
+	}
+	if policy, ok := um.passwordPolicy(); ok && policy.ExpiryDays > 0 &&
+		time.Since(u.PasswordChangedAt) > time.Duration(policy.ExpiryDays)*24*time.Hour {
+		return "Votre mot de passe a expiré"
+	}
+	return ""
+}
+
+// PasswordChangeRequired retourne la raison pour laquelle l'utilisateur doit changer de mot de passe ("" sinon)
+func (um *UserManager) PasswordChangeRequired(username string) string {
+	u, err := um.db.GetUser(username)
+	if err != nil {
+		return ""
+	}
+	return um.passwordChangeReason(u)
+}
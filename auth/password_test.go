@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func policyConfig(history, expiryDays int) *config.Config {
+	cfg := sessionConfig(30, 120)
+	cfg.Settings.PasswordPolicy = config.PasswordPolicy{MinLength: 12, MinClasses: 3, History: history, ExpiryDays: expiryDays}
+	return cfg
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	policy := config.PasswordPolicy{MinLength: 12, MinClasses: 3}
+
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"Court1!", false},                 // trop court
+		{"toutenminuscules", false},        // une seule classe
+		{"Minuscules et Majuscules", true}, // minuscules, majuscules, espaces
+		{"Password123!", false},            // liste des mots de passe courants
+		{"Azerty2024!!", false},            // mot courant suivi de chiffres et symboles
+		{"Motdepasse-2025", false},         // variante française
+		{"alice-Secret-42", false},         // contient le nom d'utilisateur
+		{"Correct-Cheval-9", true},
+		{"Étoile-Filante-7", true},
+	}
+	for _, tt := range tests {
+		err := CheckPasswordPolicy(policy, "Alice", tt.password)
+		if tt.ok {
+			assert.NoError(t, err, tt.password)
+		} else {
+			assert.ErrorIs(t, err, ErrWeakPassword, tt.password)
+		}
+	}
+}
+
+func TestPasswordHistoryAndReset(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "Initial-Pass-1", Role: "user"}})
+	um.SetConfigSource(staticConfig{policyConfig(3, 0)})
+
+	// Politique appliquée à la création
+	assert.ErrorIs(t, um.AddUser("bob", "bob", "user"), ErrWeakPassword)
+	require.NoError(t, um.AddUser("bob", "Correct-Cheval-9", "user"))
+
+	// Le mot de passe actuel et les deux précédents ne sont pas réutilisables (historique de 3)
+	assert.ErrorIs(t, um.UpdatePassword("alice", "Initial-Pass-1"), ErrPasswordReused)
+	require.NoError(t, um.UpdatePassword("alice", "Second-Pass-2"))
+	require.NoError(t, um.UpdatePassword("alice", "Third-Pass-3"))
+	assert.ErrorIs(t, um.UpdatePassword("alice", "Initial-Pass-1"), ErrPasswordReused)
+	require.NoError(t, um.UpdatePassword("alice", "Fourth-Pass-4"))
+	assert.NoError(t, um.UpdatePassword("alice", "Initial-Pass-1"), "sorti de l'historique")
+
+	// Réinitialisation par un administrateur : changement imposé, levé par le changement de l'utilisateur
+	require.NoError(t, um.ResetPassword("alice", "Temporaire-Pass-5", true))
+	assert.NotEmpty(t, um.PasswordChangeRequired("alice"))
+	require.NoError(t, um.UpdatePassword("alice", "Definitif-Pass-6"))
+	assert.Empty(t, um.PasswordChangeRequired("alice"))
+
+	// Suppression : l'historique disparaît avec le compte
+	require.NoError(t, um.DeleteUser("alice"))
+	hashes, err := db.GetPasswordHistory("alice", 10)
+	require.NoError(t, err)
+	assert.Empty(t, hashes)
+}
+
+func TestPasswordExpiry(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "Initial-Pass-1", Role: "user"}})
+	um.SetConfigSource(staticConfig{policyConfig(5, 90)})
+	assert.Empty(t, um.PasswordChangeRequired("alice"))
+
+	_, err := db.Exec("UPDATE users SET password_changed_at = ? WHERE username = ?", time.Now().AddDate(0, 0, -91), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "Votre mot de passe a expiré", um.PasswordChangeRequired("alice"))
+	for _, u := range um.GetAllUsers() {
+		assert.Equal(t, u.Username == "alice" || u.Username == "admin", u.PasswordChange, u.Username)
+	}
+
+	// Sans expiration configurée, seul le changement imposé compte
+	um.SetConfigSource(staticConfig{policyConfig(5, 0)})
+	assert.Empty(t, um.PasswordChangeRequired("alice"))
+}
+
+func TestLoginBootstrapAdminMustChange(t *testing.T) {
+	t.Chdir("..")
+	db := setupTestDB(t)
+	um := NewUserManager(db, nil)
+	um.SetConfigSource(staticConfig{policyConfig(5, 0)})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{policyConfig(5, 0)})
+
+	// admin/admin : pas de session avant le changement du mot de passe
+	rec := postLogin(am, url.Values{"username": {"admin"}, "password": {"admin"}})
+	assert.False(t, hasSessionCookie(rec))
+	assert.Contains(t, rec.Body.String(), "new_password")
+	token := loginToken(t, rec)
+
+	rec = postLogin(am, url.Values{"login_token": {token}, "new_password": {"admin"}, "confirm_password": {"admin"}})
+	assert.False(t, hasSessionCookie(rec))
+	assert.Contains(t, rec.Body.String(), "trop faible")
+
+	rec = postLogin(am, url.Values{"login_token": {token}, "new_password": {"Correct-Cheval-9"}, "confirm_password": {"Correct-Cheval-8"}})
+	assert.False(t, hasSessionCookie(rec))
+	assert.Contains(t, rec.Body.String(), "ne correspondent pas")
+
+	rec = postLogin(am, url.Values{"login_token": {token}, "new_password": {"Correct-Cheval-9"}, "confirm_password": {"Correct-Cheval-9"}})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.True(t, hasSessionCookie(rec))
+
+	// Connexion suivante directe ; un nouveau démarrage ne réimpose pas le changement
+	login(t, am, "admin", "Correct-Cheval-9")
+	NewUserManager(db, nil)
+	assert.Empty(t, um.PasswordChangeRequired("admin"))
+}
+
+func TestBootstrapAdminDefaultPasswordFlagged(t *testing.T) {
+	db := setupTestDB(t)
+	NewUserManager(db, nil)
+	require.NoError(t, db.SetMustChangePassword("admin", false))
+
+	// Base existante où admin/admin n'a jamais été changé : changement imposé au démarrage
+	um := NewUserManager(db, nil)
+	assert.NotEmpty(t, um.PasswordChangeRequired("admin"))
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"go-monitoring/config"
+)
+
+// ConfigSource fournit la configuration courante (permissions et groupes des machines)
+type ConfigSource interface {
+	GetConfig() *config.Config
+}
+
+// HasCapability indique si un rôle possède une capacité sur une machine.
+// Sans machine (machineID vide), il suffit que la capacité soit accordée sur au moins un groupe.
+func HasCapability(cfg *config.Config, role, capability, machineID string) bool {
+	if role == config.RoleAdmin {
+		return true
+	}
+	if role == "" || capability == config.CapabilityAdmin || cfg == nil {
+		return false
+	}
+
+	group, known := "", false
+	if machineID != "" {
+		if mc := cfg.GetMachine(machineID); mc != nil {
+			group, known = mc.Group, true
+		}
+	}
+
+	for _, grant := range cfg.Permissions[role] {
+		if !contains(grant.Capabilities, capability) {
+			continue
+		}
+		if machineID == "" || len(grant.Groups) == 0 {
+			return true
+		}
+		if known && contains(grant.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanView indique si un rôle voit une machine (dashboard, flux temps réel) :
+// il faut au moins une capacité sur le groupe de la machine
+func CanView(cfg *config.Config, role, machineID string) bool {
+	if role == config.RoleAdmin {
+		return true
+	}
+	if role == "" || cfg == nil {
+		return false
+	}
+	mc := cfg.GetMachine(machineID)
+	if mc == nil {
+		return false
+	}
+	for _, grant := range cfg.Permissions[role] {
+		if len(grant.Groups) == 0 || contains(grant.Groups, mc.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetConfigSource lie la configuration utilisée pour évaluer les permissions
+func (am *AuthManager) SetConfigSource(src ConfigSource) {
+	am.config = src
+}
+
+// Can indique si l'utilisateur de la requête possède une capacité (sur la machine si machineID est renseigné)
+func (am *AuthManager) Can(r *http.Request, capability, machineID string) bool {
+	var cfg *config.Config
+	if am.config != nil {
+		cfg = am.config.GetConfig()
+	}
+	return HasCapability(cfg, am.GetUserRole(r), capability, machineID)
+}
+
+// CanView indique si l'utilisateur de la requête voit la machine
+func (am *AuthManager) CanView(r *http.Request, machineID string) bool {
+	var cfg *config.Config
+	if am.config != nil {
+		cfg = am.config.GetConfig()
+	}
+	return CanView(cfg, am.GetUserRole(r), machineID)
+}
+
+// Visible retourne le filtre des machines visibles par l'utilisateur de la requête, pour filtrer une liste
+// (rôle et configuration lus une seule fois)
+func (am *AuthManager) Visible(r *http.Request) func(machineID string) bool {
+	if am == nil {
+		return func(string) bool { return true }
+	}
+	var cfg *config.Config
+	if am.config != nil {
+		cfg = am.config.GetConfig()
+	}
+	role := am.GetUserRole(r)
+	return func(machineID string) bool {
+		return CanView(cfg, role, machineID)
+	}
+}
+
+// Viewer filtre les machines visibles par un utilisateur sur une connexion longue (flux temps réel).
+// Le rôle est lu à la création puis relu seulement après un changement de droits (voir
+// UserManager.AccessVersion), pour ne pas interroger la base ou l'annuaire à chaque machine et à chaque
+// trame. Il n'est pas sûr pour un usage concurrent : une seule goroutine (la boucle du hub) l'utilise.
+type Viewer struct {
+	am       *AuthManager
+	username string
+	role     string
+	version  uint64
+}
+
+// NewViewer mémorise le rôle courant de l'utilisateur
+func (am *AuthManager) NewViewer(username string) *Viewer {
+	version := am.UserManager.AccessVersion()
+	return &Viewer{am: am, username: username, role: am.UserManager.GetUserRole(username), version: version}
+}
+
+// refresh relit le rôle si les droits ont changé (version lue avant le rôle : un changement
+// concurrent provoque une nouvelle lecture au prochain appel)
+func (v *Viewer) refresh() {
+	version := v.am.UserManager.AccessVersion()
+	if version == v.version {
+		return
+	}
+	v.version, v.role = version, v.am.UserManager.GetUserRole(v.username)
+}
+
+// CanView indique si l'utilisateur voit la machine (configuration courante, rôle mémorisé)
+func (v *Viewer) CanView(machineID string) bool {
+	v.refresh()
+	var cfg *config.Config
+	if v.am.config != nil {
+		cfg = v.am.config.GetConfig()
+	}
+	return CanView(cfg, v.role, machineID)
+}
+
+// Capabilities retourne les capacités de l'utilisateur sur une machine (pour l'affichage)
+func (am *AuthManager) Capabilities(r *http.Request, machineID string) map[string]bool {
+	caps := make(map[string]bool)
+	for _, c := range []string{config.CapabilityTerminal, config.CapabilityFiles, config.CapabilityLogs, config.CapabilityServices, config.CapabilityCommands, config.CapabilityAdmin} {
+		caps[c] = am.Can(r, c, machineID)
+	}
+	return caps
+}
+
+// Require protège une route : session valide, puis capacité sur la machine {id} de l'URL s'il y en a une.
+// Un refus est audité ; les API reçoivent un 403 JSON, les pages sont redirigées vers l'accueil.
+func (am *AuthManager) Require(capability string, next http.HandlerFunc) http.HandlerFunc {
+	return am.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		machineID := ""
+		if capability != config.CapabilityAdmin {
+			machineID = r.PathValue("id")
+		}
+
+		if !am.Can(r, capability, machineID) {
+			am.deny(w, r, "capability="+capability)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// RequireMachine protège une route de consultation d'une machine {id} : session valide et machine
+// visible par l'utilisateur (voir CanView). Un refus est traité comme pour Require.
+func (am *AuthManager) RequireMachine(next http.HandlerFunc) http.HandlerFunc {
+	return am.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		if !am.CanView(r, r.PathValue("id")) {
+			am.deny(w, r, "visibility")
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// deny audite un refus d'accès ; les API reçoivent un 403 JSON, les pages sont redirigées vers l'accueil
+func (am *AuthManager) deny(w http.ResponseWriter, r *http.Request, details string) {
+	username := am.GetUsername(r)
+	log.Printf("Accès refusé: %s (%s) sur %s", username, details, r.URL.Path)
+	am.AuditAs(r, username, "ACCESS_DENIED", r.URL.Path, details)
+
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeAuthError(w, http.StatusForbidden, "Accès refusé")
+	} else {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
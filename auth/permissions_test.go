@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-monitoring/config"
+	"go-monitoring/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticConfig struct{ cfg *config.Config }
+
+func (s staticConfig) GetConfig() *config.Config { return s.cfg }
+
+func permissionsConfig() *config.Config {
+	return &config.Config{
+		Machines: []config.MachineConfig{
+			{ID: "prod-1", Group: "Production"},
+			{ID: "stg-1", Group: "Staging"},
+		},
+		Permissions: map[string][]config.PermissionGrant{
+			"user": {{Capabilities: []string{config.CapabilityLogs}}},
+			"operator": {
+				{Capabilities: []string{config.CapabilityLogs}},
+				{Capabilities: []string{config.CapabilityTerminal, config.CapabilityFiles}, Groups: []string{"Staging"}},
+			},
+		},
+	}
+}
+
+func TestHasCapability(t *testing.T) {
+	cfg := permissionsConfig()
+
+	tests := []struct {
+		name       string
+		role       string
+		capability string
+		machine    string
+		want       bool
+	}{
+		{"admin a tout", "admin", config.CapabilityTerminal, "prod-1", true},
+		{"admin administre", "admin", config.CapabilityAdmin, "", true},
+		{"user lit les logs", "user", config.CapabilityLogs, "prod-1", true},
+		{"user sans terminal", "user", config.CapabilityTerminal, "prod-1", false},
+		{"user sans services", "user", config.CapabilityServices, "", false},
+		{"operator terminal staging", "operator", config.CapabilityTerminal, "stg-1", true},
+		{"operator pas de terminal en prod", "operator", config.CapabilityTerminal, "prod-1", false},
+		{"operator capacité sur au moins un groupe", "operator", config.CapabilityFiles, "", true},
+		{"machine inconnue et groupe restreint", "operator", config.CapabilityTerminal, "inconnue", false},
+		{"admin non accordable", "operator", config.CapabilityAdmin, "", false},
+		{"rôle inconnu", "guest", config.CapabilityLogs, "prod-1", false},
+		{"sans session", "", config.CapabilityLogs, "prod-1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HasCapability(cfg, tt.role, tt.capability, tt.machine))
+		})
+	}
+}
+
+func TestCanView(t *testing.T) {
+	cfg := permissionsConfig()
+	cfg.Permissions["staging"] = []config.PermissionGrant{{Capabilities: []string{config.CapabilityLogs}, Groups: []string{"Staging"}}}
+	cfg.Permissions["vide"] = []config.PermissionGrant{}
+
+	assert.True(t, CanView(cfg, "admin", "prod-1"))
+	assert.True(t, CanView(cfg, "user", "prod-1"))
+	assert.True(t, CanView(cfg, "staging", "stg-1"))
+	assert.False(t, CanView(cfg, "staging", "prod-1"))
+	assert.False(t, CanView(cfg, "vide", "stg-1"))
+	assert.False(t, CanView(cfg, "user", "inconnue"))
+	assert.False(t, CanView(cfg, "", "prod-1"))
+}
+
+func TestRequire(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "operator"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{permissionsConfig()})
+	token, _, err := am.createSession("alice", httptest.NewRequest(http.MethodPost, "/login", nil))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux.HandleFunc("GET /api/machine/{id}/terminal", am.Require(config.CapabilityTerminal, ok))
+	mux.HandleFunc("GET /api/users", am.Require(config.CapabilityAdmin, ok))
+	mux.HandleFunc("GET /audit", am.Require(config.CapabilityAdmin, ok))
+
+	do := func(path string, withSession bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if withSession {
+			req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, do("/api/machine/stg-1/terminal", true).Code)
+
+	rec := do("/api/machine/prod-1/terminal", true)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Accès refusé")
+
+	assert.Equal(t, http.StatusForbidden, do("/api/users", true).Code)
+
+	rec = do("/audit", true)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/", rec.Header().Get("Location"))
+
+	// Sans session : 401 JSON pour les API, redirection vers la connexion pour les pages
+	rec = do("/api/machine/stg-1/terminal", false)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Non authentifié")
+
+	rec = do("/audit", false)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/login", rec.Header().Get("Location"))
+
+	logs, err := db.GetAuditLogs(10)
+	assert.NoError(t, err)
+	denied := 0
+	for _, l := range logs {
+		if l.Action == "ACCESS_DENIED" {
+			denied++
+		}
+	}
+	assert.Equal(t, 3, denied)
+}
+
+func TestRequireMachine(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "staging"}})
+	am := NewAuthManager(um)
+	cfg := permissionsConfig()
+	cfg.Permissions["staging"] = []config.PermissionGrant{{Capabilities: []string{config.CapabilityLogs}, Groups: []string{"Staging"}}}
+	am.SetConfigSource(staticConfig{cfg})
+	token, _, err := am.createSession("alice", httptest.NewRequest(http.MethodPost, "/login", nil))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux.HandleFunc("GET /api/machine/{id}/history", am.RequireMachine(ok))
+	mux.HandleFunc("GET /machine/{id}", am.RequireMachine(ok))
+
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, do("/api/machine/stg-1/history").Code)
+	assert.Equal(t, http.StatusForbidden, do("/api/machine/prod-1/history").Code)
+	assert.Equal(t, http.StatusForbidden, do("/api/machine/inconnue/history").Code)
+	assert.Equal(t, http.StatusSeeOther, do("/machine/prod-1").Code)
+
+	// Filtre de liste : rôle et configuration lus une fois par requête
+	req := httptest.NewRequest(http.MethodGet, "/api/machines", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+	visible := am.Visible(req)
+	assert.True(t, visible("stg-1"))
+	assert.False(t, visible("prod-1"))
+
+	assert.Equal(t, []string{"ACCESS_DENIED", "ACCESS_DENIED", "ACCESS_DENIED"},
+		auditActions(t, db, storage.AuditFilter{User: "alice", Action: "ACCESS_DENIED"}))
+}
+
+func TestViewerCachesRole(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "staging"}})
+	am := NewAuthManager(um)
+	cfg := permissionsConfig()
+	cfg.Permissions["staging"] = []config.PermissionGrant{{Capabilities: []string{config.CapabilityLogs}, Groups: []string{"Staging"}}}
+	am.SetConfigSource(staticConfig{cfg})
+
+	v := am.NewViewer("alice")
+	assert.True(t, v.CanView("stg-1"))
+	assert.False(t, v.CanView("prod-1"))
+
+	// Modification directe en base : le rôle mémorisé n'est pas relu à chaque appel
+	require.NoError(t, db.UpdateUserRole("alice", "admin"))
+	assert.False(t, v.CanView("prod-1"))
+
+	// Changement de rôle par le gestionnaire : relu
+	require.NoError(t, um.UpdateUserRole("alice", "user"))
+	assert.True(t, v.CanView("prod-1"))
+
+	// Révocation des sessions : relu
+	require.NoError(t, db.UpdateUserRole("alice", "staging"))
+	_, _, err := am.createSession("alice", httptest.NewRequest(http.MethodPost, "/login", nil))
+	require.NoError(t, err)
+	n, err := am.RevokeUserSessions("alice", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+	assert.False(t, v.CanView("prod-1"))
+
+	// Compte désactivé : relu (rôle conservé, la session est vérifiée par Access.Valid)
+	require.NoError(t, db.UpdateUserRole("alice", "user"))
+	require.NoError(t, um.ToggleUserStatus("alice", false))
+	assert.True(t, v.CanView("prod-1"))
+}
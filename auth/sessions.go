@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"go-monitoring/models"
+	"go-monitoring/storage"
+)
+
+// Durées des sessions sans configuration (voir settings.session_idle_timeout et session_absolute_timeout)
+const (
+	defaultSessionIdle     = 8 * time.Hour
+	defaultSessionAbsolute = 24 * time.Hour
+)
+
+// sessionTouchInterval limite l'enregistrement de l'activité d'une session (une écriture par minute au plus)
+const sessionTouchInterval = time.Minute
+
+// maxUserAgent tronque le User-Agent conservé avec la session
+const maxUserAgent = 256
+
+// sessionKey est la clé de contexte de la session validée par Middleware
+type sessionKey struct{}
+
+// store retourne la base des sessions (nil sans gestionnaire d'utilisateurs)
+func (am *AuthManager) store() *storage.DB {
+	if am.UserManager == nil {
+		return nil
+	}
+	return am.UserManager.db
+}
+
+// sessionTimeouts retourne l'expiration après inactivité et la durée maximale des sessions
+func (am *AuthManager) sessionTimeouts() (idle, absolute time.Duration) {
+	idle, absolute = defaultSessionIdle, defaultSessionAbsolute
+	if am.config == nil {
+		return idle, absolute
+	}
+	cfg := am.config.GetConfig()
+	if cfg == nil {
+		return idle, absolute
+	}
+	if cfg.Settings.SessionIdleTimeout > 0 {
+		idle = time.Duration(cfg.Settings.SessionIdleTimeout) * time.Minute
+	}
+	if cfg.Settings.SessionAbsoluteTimeout > 0 {
+		absolute = time.Duration(cfg.Settings.SessionAbsoluteTimeout) * time.Minute
+	}
+	return idle, absolute
+}
+
+// createSession ouvre une session pour un utilisateur et retourne le jeton du cookie.
+// Seule l'empreinte du jeton est conservée en base.
+func (am *AuthManager) createSession(username string, r *http.Request) (string, *models.UserSession, error) {
+	db := am.store()
+	if db == nil {
+		return "", nil, errors.New("base de données indisponible")
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := generateToken()
+	now := time.Now()
+	_, absolute := am.sessionTimeouts()
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+
+	s := &models.UserSession{
+		ID:         hex.EncodeToString(b),
+		Username:   username,
+		Hash:       HashAPIToken(token),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(absolute),
+		IP:         r.RemoteAddr,
+		UserAgent:  userAgent,
+	}
+	if err := db.CreateSession(*s); err != nil {
+		return "", nil, err
+	}
+	return token, s, nil
+}
+
+// lookupSession retourne la session valide correspondant à un jeton de cookie.
+// Une session expirée est supprimée.
+func (am *AuthManager) lookupSession(token string) (*models.UserSession, bool) {
+	db := am.store()
+	if token == "" || db == nil {
+		return nil, false
+	}
+	s, err := db.GetSessionByHash(HashAPIToken(token))
+	if err != nil || s == nil {
+		return nil, false
+	}
+	idle, _ := am.sessionTimeouts()
+	if s.Expired(time.Now(), idle) {
+		if err := db.DeleteSession(s.ID); err != nil {
+			log.Printf("Erreur suppression session expirée %s: %v", s.ID, err)
+		}
+		return nil, false
+	}
+	return s, true
+}
+
+// touchSession enregistre l'activité d'une session (au plus une fois par sessionTouchInterval)
+func (am *AuthManager) touchSession(s *models.UserSession, r *http.Request) {
+	now := time.Now()
+	if now.Sub(s.LastSeenAt) < sessionTouchInterval {
+		return
+	}
+	if err := am.store().TouchSession(s.ID, r.RemoteAddr, now); err != nil {
+		log.Printf("Erreur mise à jour session %s: %v", s.ID, err)
+		return
+	}
+	s.LastSeenAt, s.IP = now, r.RemoteAddr
+}
+
+// CurrentSession retourne la session de la requête (nil pour un jeton d'API ou sans session valide)
+func (am *AuthManager) CurrentSession(r *http.Request) *models.UserSession {
+	if s, ok := r.Context().Value(sessionKey{}).(*models.UserSession); ok {
+		return s
+	}
+	if _, ok := requestIdentity(r); ok {
+		return nil
+	}
+	cookie, err := r.Cookie("session_token")
+	if err != nil {
+		return nil
+	}
+	s, _ := am.lookupSession(cookie.Value)
+	return s
+}
+
+// UserSessions retourne les sessions actives de l'utilisateur de la requête, la session courante étant marquée
+func (am *AuthManager) UserSessions(r *http.Request) ([]models.UserSession, error) {
+	db := am.store()
+	username := am.GetUsername(r)
+	if db == nil || username == "" {
+		return []models.UserSession{}, nil
+	}
+	all, err := db.ListUserSessions(username)
+	if err != nil {
+		return nil, err
+	}
+
+	current := am.CurrentSession(r)
+	idle, _ := am.sessionTimeouts()
+	now := time.Now()
+	sessions := []models.UserSession{}
+	for _, s := range all {
+		if s.Expired(now, idle) {
+			continue
+		}
+		s.Current = current != nil && s.ID == current.ID
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// RevokeUserSessions déconnecte un utilisateur : toutes ses sessions sauf exceptID (si renseigné).
+// Les connexions WebSocket de ces sessions sont fermées à la diffusion suivante.
+func (am *AuthManager) RevokeUserSessions(username, exceptID string) (int64, error) {
+	db := am.store()
+	if db == nil {
+		return 0, nil
+	}
+	n, err := db.DeleteUserSessions(username, exceptID)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		log.Printf("%d session(s) révoquée(s) pour %s", n, username)
+		am.UserManager.accessChanged()
+	}
+	return n, nil
+}
+
+// PurgeExpiredSessions supprime les sessions expirées (tâche de fond)
+func (am *AuthManager) PurgeExpiredSessions() (int64, error) {
+	db := am.store()
+	if db == nil {
+		return 0, nil
+	}
+	idle, _ := am.sessionTimeouts()
+	now := time.Now()
+	return db.DeleteExpiredSessions(now, now.Add(-idle))
+}
+
+// withSession attache la session validée à la requête
+func withSession(r *http.Request, s *models.UserSession) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionKey{}, s))
+}
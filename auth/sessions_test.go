@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionConfig(idle, absolute int) *config.Config {
+	cfg := permissionsConfig()
+	cfg.Settings.SessionIdleTimeout = idle
+	cfg.Settings.SessionAbsoluteTimeout = absolute
+	return cfg
+}
+
+func login(t *testing.T, am *AuthManager, username, password string) *http.Cookie {
+	t.Helper()
+	form := url.Values{"username": {username}, "password": {password}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+	am.LoginHandler(rec, req)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session_token" {
+			return c
+		}
+	}
+	t.Fatal("cookie de session absent")
+	return nil
+}
+
+func TestSessionsPersistAndLogout(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "operator"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{sessionConfig(30, 120)})
+
+	cookie := login(t, am, "alice", "password123")
+	assert.WithinDuration(t, time.Now().Add(120*time.Minute), cookie.Expires, time.Minute)
+
+	// Seule l'empreinte du jeton est conservée
+	stored, err := db.GetSessionByHash(HashAPIToken(cookie.Value))
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, "alice", stored.Username)
+	assert.Equal(t, "test-agent", stored.UserAgent)
+
+	// Redémarrage : un nouveau gestionnaire retrouve la session en base
+	restarted := NewAuthManager(um)
+	user, ok := restarted.SessionUser(cookie.Value)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", user)
+
+	req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+	req.AddCookie(cookie)
+	restarted.LogoutHandler(httptest.NewRecorder(), req)
+	_, ok = am.SessionUser(cookie.Value)
+	assert.False(t, ok)
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "operator"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{sessionConfig(30, 120)})
+
+	var seen string
+	handler := am.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		seen = am.GetUsername(r)
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/machines", nil)
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	// Activité : la dernière activité est repoussée
+	active, s, err := am.createSession("alice", httptest.NewRequest(http.MethodPost, "/login", nil))
+	require.NoError(t, err)
+	require.NoError(t, db.TouchSession(s.ID, "", time.Now().Add(-20*time.Minute)))
+	assert.Equal(t, http.StatusOK, do(active))
+	assert.Equal(t, "alice", seen)
+	touched, err := db.GetSession(s.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), touched.LastSeenAt, time.Minute)
+
+	// Inactivité
+	idle, s, err := am.createSession("alice", httptest.NewRequest(http.MethodPost, "/login", nil))
+	require.NoError(t, err)
+	require.NoError(t, db.TouchSession(s.ID, "", time.Now().Add(-31*time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, do(idle))
+	gone, err := db.GetSession(s.ID)
+	require.NoError(t, err)
+	assert.Nil(t, gone, "session expirée supprimée")
+
+	// Durée maximale atteinte malgré l'activité
+	now := time.Now()
+	require.NoError(t, db.CreateSession(models.UserSession{
+		ID: "ancienne", Username: "alice", Hash: HashAPIToken("ancienne"),
+		CreatedAt: now.Add(-3 * time.Hour), LastSeenAt: now, ExpiresAt: now.Add(-time.Minute),
+	}))
+	assert.Equal(t, http.StatusUnauthorized, do("ancienne"))
+
+	// Purge des sessions expirées non consultées
+	require.NoError(t, db.CreateSession(models.UserSession{
+		ID: "oubliee", Username: "alice", Hash: HashAPIToken("oubliee"),
+		CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour),
+	}))
+	n, err := am.PurgeExpiredSessions()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, http.StatusOK, do(active))
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{
+		{Username: "alice", Password: "password123", Role: "operator"},
+		{Username: "bob", Password: "password123", Role: "user"},
+	})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{sessionConfig(30, 120)})
+
+	first := login(t, am, "alice", "password123")
+	second := login(t, am, "alice", "password123")
+	bob := login(t, am, "bob", "password123")
+
+	// Liste : la session de la requête est marquée
+	req := httptest.NewRequest(http.MethodGet, "/api/profile/sessions", nil)
+	req.AddCookie(first)
+	sessions, err := am.UserSessions(req)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	current := am.CurrentSession(req)
+	require.NotNil(t, current)
+	for _, s := range sessions {
+		assert.Equal(t, s.ID == current.ID, s.Current)
+	}
+
+	// Toutes les sessions sauf la courante
+	n, err := am.RevokeUserSessions("alice", current.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, ok := am.SessionUser(second.Value)
+	assert.False(t, ok)
+	_, ok = am.SessionUser(first.Value)
+	assert.True(t, ok)
+	_, ok = am.SessionUser(bob.Value)
+	assert.True(t, ok, "les sessions des autres utilisateurs sont conservées")
+
+	// Suppression du compte : ses sessions disparaissent
+	require.NoError(t, um.DeleteUser("bob"))
+	_, ok = am.SessionUser(bob.Value)
+	assert.False(t, ok)
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// Portées des jetons d'API. Elles restreignent le jeton en plus des capacités du rôle.
+const (
+	ScopeRead            = "read"             // Lecture (GET) des API
+	ScopeMachinesWrite   = "machines:write"   // Ajout, modification et suppression de machines
+	ScopeServicesControl = "services:control" // Actions sur les services, runbooks
+)
+
+// Scopes liste les portées attribuables à un jeton
+var Scopes = []string{ScopeRead, ScopeMachinesWrite, ScopeServicesControl}
+
+// APITokenPrefix préfixe les jetons générés (repérables dans un dépôt ou des journaux)
+const APITokenPrefix = "mgo_"
+
+// identityKey est la clé de contexte de l'identité portée par un jeton d'API
+type identityKey struct{}
+
+// tokenIdentity est l'identité d'une requête authentifiée par jeton
+type tokenIdentity struct {
+	Username string
+	Role     string
+}
+
+// NewAPIToken génère un jeton et son empreinte (seule l'empreinte est conservée)
+func NewAPIToken() (token, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token = APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashAPIToken(token)
+}
+
+// HashAPIToken retourne l'empreinte SHA-256 d'un jeton. Les jetons étant aléatoires
+// et longs, un hachage rapide suffit et permet la recherche directe en base.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidScope indique si une portée existe
+func ValidScope(scope string) bool {
+	return contains(Scopes, scope)
+}
+
+// RequiredScope retourne la portée nécessaire pour appeler une route avec un jeton,
+// ou une chaîne vide si la route n'est pas accessible par jeton (pages, WebSocket,
+// gestion du profil et des jetons, autres actions)
+func RequiredScope(r *http.Request) string {
+	path := r.URL.Path
+	if !strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/api/profile/") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return ScopeRead
+	}
+	switch {
+	case path == "/api/machines" || strings.HasPrefix(path, "/api/machines/"):
+		return ScopeMachinesWrite
+	case strings.HasPrefix(path, "/api/machine/") && strings.Contains(path, "/service/"):
+		return ScopeServicesControl
+	case strings.HasPrefix(path, "/api/runbooks/"):
+		return ScopeServicesControl
+	}
+	return ""
+}
+
+// bearerToken extrait le jeton de l'en-tête Authorization
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// authenticateToken vérifie un jeton d'API et sa portée pour la requête, puis enregistre son utilisation.
+// En cas de refus, retourne le code HTTP et le message à renvoyer.
+func (am *AuthManager) authenticateToken(r *http.Request, token string) (tokenIdentity, int, string) {
+	if am.UserManager == nil || am.UserManager.db == nil {
+		return tokenIdentity{}, http.StatusUnauthorized, "Jeton invalide"
+	}
+	db := am.UserManager.db
+
+	t, err := db.GetAPITokenByHash(HashAPIToken(token))
+	if err != nil || t == nil {
+		return tokenIdentity{}, http.StatusUnauthorized, "Jeton invalide"
+	}
+	now := time.Now()
+	if t.Expired(now) {
+		return tokenIdentity{}, http.StatusUnauthorized, "Jeton expiré"
+	}
+
+	ident := tokenIdentity{Username: t.Username, Role: t.Role}
+	if t.Kind == models.APITokenPersonal {
+		// Rôle et état du compte lus à chaque requête
+		u, err := db.GetUser(t.Username)
+		if err != nil || !u.IsActive {
+			return tokenIdentity{}, http.StatusUnauthorized, "Jeton invalide"
+		}
+		ident.Role = am.UserManager.GetUserRole(t.Username)
+	} else {
+		ident.Username = "token:" + t.Name
+	}
+
+	if scope := RequiredScope(r); scope == "" || !contains(t.Scopes, scope) {
+		log.Printf("Jeton %s: portée insuffisante pour %s %s", t.Prefix, r.Method, r.URL.Path)
+		am.AuditAs(r, ident.Username, "ACCESS_DENIED", r.URL.Path, "token="+t.ID+" scope="+scope)
+		return tokenIdentity{}, http.StatusForbidden, "Portée du jeton insuffisante"
+	}
+
+	if err := db.TouchAPIToken(t.ID, r.RemoteAddr, now); err != nil {
+		log.Printf("Erreur mise à jour du jeton %s: %v", t.ID, err)
+	}
+	return ident, 0, ""
+}
+
+// requestIdentity retourne l'identité portée par un jeton d'API, s'il y en a une
+func requestIdentity(r *http.Request) (tokenIdentity, bool) {
+	ident, ok := r.Context().Value(identityKey{}).(tokenIdentity)
+	return ident, ok
+}
+
+// writeAuthError répond une erreur d'authentification en JSON (routes API)
+func writeAuthError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// withIdentity attache l'identité d'un jeton à la requête
+func withIdentity(r *http.Request, ident tokenIdentity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, ident))
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method, path string
+		websocket    bool
+		want         string
+	}{
+		{"GET", "/api/machines", false, ScopeRead},
+		{"GET", "/api/machine/srv-1/history", false, ScopeRead},
+		{"POST", "/api/machines", false, ScopeMachinesWrite},
+		{"DELETE", "/api/machines/srv-1", false, ScopeMachinesWrite},
+		{"POST", "/api/machine/srv-1/service/nginx/restart", false, ScopeServicesControl},
+		{"POST", "/api/runbooks/purge/run", false, ScopeServicesControl},
+		{"POST", "/api/users", false, ""},
+		{"POST", "/api/commands/runs", false, ""},
+		{"GET", "/api/profile/tokens", false, ""},
+		{"GET", "/api/machine/srv-1/terminal", true, ""},
+		{"GET", "/", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.websocket {
+				req.Header.Set("Upgrade", "websocket")
+			}
+			assert.Equal(t, tt.want, RequiredScope(req))
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "operator"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{permissionsConfig()})
+
+	create := func(kind, name, role string, scopes []string, expires *time.Time) string {
+		token, hash := NewAPIToken()
+		require.True(t, strings.HasPrefix(token, APITokenPrefix))
+		require.NoError(t, db.CreateAPIToken(models.APIToken{
+			ID: name, Name: name, Kind: kind, Username: "alice", Role: role, Scopes: scopes,
+			Prefix: token[:8], Hash: hash, CreatedAt: time.Now(), ExpiresAt: expires,
+		}))
+		return token
+	}
+	past := time.Now().Add(-time.Hour)
+	reader := create(models.APITokenPersonal, "lecture", "", []string{ScopeRead}, nil)
+	expired := create(models.APITokenPersonal, "expire", "", []string{ScopeRead}, &past)
+	service := create(models.APITokenService, "ci", "admin", []string{ScopeRead, ScopeMachinesWrite}, nil)
+
+	var seenUser, seenRole string
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		seenUser, seenRole = am.GetUsername(r), am.GetUserRole(r)
+		w.WriteHeader(http.StatusOK)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/machine/{id}/terminal/sessions", am.Require(config.CapabilityTerminal, ok))
+	mux.HandleFunc("GET /api/machines", am.Middleware(ok))
+	mux.HandleFunc("POST /api/machines", am.Require(config.CapabilityAdmin, ok))
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.10:4242"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Jeton personnel : identité et permissions du propriétaire
+	assert.Equal(t, http.StatusOK, do("GET", "/api/machines", reader).Code)
+	assert.Equal(t, "alice", seenUser)
+	assert.Equal(t, "operator", seenRole)
+	assert.Equal(t, http.StatusOK, do("GET", "/api/machine/stg-1/terminal/sessions", reader).Code)
+	assert.Equal(t, http.StatusForbidden, do("GET", "/api/machine/prod-1/terminal/sessions", reader).Code)
+
+	tok, err := db.GetAPIToken("lecture")
+	require.NoError(t, err)
+	require.NotNil(t, tok.LastUsedAt)
+	assert.Equal(t, "192.0.2.10:4242", tok.LastUsedIP)
+
+	// Portée insuffisante
+	rec := do("POST", "/api/machines", reader)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Portée du jeton insuffisante")
+
+	// Jeton de service : son propre rôle
+	assert.Equal(t, http.StatusOK, do("POST", "/api/machines", service).Code)
+	assert.Equal(t, "token:ci", seenUser)
+	assert.Equal(t, "admin", seenRole)
+
+	// Jetons refusés : 401 JSON
+	for _, token := range []string{expired, "mgo_inconnu"} {
+		rec := do("GET", "/api/machines", token)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	}
+
+	// Compte désactivé : ses jetons personnels ne sont plus acceptés
+	require.NoError(t, um.ToggleUserStatus("alice", false))
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/api/machines", reader).Code)
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/pkg/crypto"
+)
+
+// Paramètres TOTP (RFC 6238) : ceux attendus par les applications d'authentification courantes
+const (
+	totpPeriod = 30 // secondes
+	totpDigits = 6
+	totpSkew   = 1 // Pas de temps acceptés avant et après l'heure courante (décalage d'horloge)
+)
+
+// TOTPIssuer est l'émetteur affiché par l'application d'authentification
+const TOTPIssuer = "MonitorGo"
+
+// recoveryCodeCount est le nombre de codes de secours générés à l'activation
+const recoveryCodeCount = 10
+
+var (
+	ErrTOTPNotEnabled     = errors.New("double authentification non activée")
+	ErrTOTPAlreadyEnabled = errors.New("double authentification déjà activée")
+	ErrTOTPNoEnrollment   = errors.New("aucun enrôlement en cours")
+	ErrInvalidCode        = errors.New("code invalide")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret génère un secret TOTP (160 bits, base32)
+func NewTOTPSecret() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return totpEncoding.EncodeToString(b)
+}
+
+// TOTPURI retourne l'URI otpauth:// à encoder dans le QR code d'enrôlement
+func TOTPURI(username, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", TOTPIssuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(TOTPIssuer+":"+username) + "?" + v.Encode()
+}
+
+// totpCode calcule le code d'un pas de temps (HOTP, RFC 4226, sur le compteur de temps)
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTP vérifie un code à l'instant donné et retourne le pas de temps correspondant
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// newRecoveryCodes génère les codes de secours (affichés une fois) et leurs empreintes
+func newRecoveryCodes() (codes, hashes []string) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 6)
+		rand.Read(b)
+		c := strings.ToLower(totpEncoding.EncodeToString(b)) // 10 caractères
+		code := c[:5] + "-" + c[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashAPIToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes
+}
+
+// normalizeRecoveryCode ignore la casse, les espaces et les tirets saisis
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// normalizeCode retire les espaces d'un code saisi (« 123 456 »)
+func normalizeCode(code string) string {
+	return strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+}
+
+// TOTPRequired indique si la politique impose la double authentification au rôle
+func TOTPRequired(cfg *config.Config, role string) bool {
+	return cfg != nil && contains(cfg.Settings.RequireTOTPRoles, role)
+}
+
+// sealTOTPSecret chiffre le secret avec la clé maître si elle est configurée
+func sealTOTPSecret(secret string) string {
+	encrypted, err := crypto.Encrypt(secret)
+	if err != nil {
+		log.Printf("AVERTISSEMENT: secret TOTP conservé en clair: %v", err)
+		return secret
+	}
+	return encrypted
+}
+
+// openTOTPSecret déchiffre un secret conservé chiffré
+func openTOTPSecret(stored string) (string, error) {
+	if !crypto.IsEncrypted(stored) {
+		return stored, nil
+	}
+	return crypto.Decrypt(stored)
+}
+
+// TOTPStatus retourne l'état de la double authentification d'un utilisateur
+func (um *UserManager) TOTPStatus(username string) (enabled bool, recoveryCodes int, err error) {
+	_, enabled, _, err = um.db.GetTOTP(username)
+	if err != nil || !enabled {
+		return false, 0, err
+	}
+	recoveryCodes, err = um.db.CountRecoveryCodes(username)
+	return enabled, recoveryCodes, err
+}
+
+// BeginTOTPEnrollment génère un nouveau secret, en attente de confirmation par un premier code
+func (um *UserManager) BeginTOTPEnrollment(username string) (string, error) {
+	_, enabled, _, err := um.db.GetTOTP(username)
+	if err != nil {
+		return "", errors.New("utilisateur introuvable")
+	}
+	if enabled {
+		return "", ErrTOTPAlreadyEnabled
+	}
+	secret := NewTOTPSecret()
+	if err := um.db.SetTOTPSecret(username, sealTOTPSecret(secret)); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ConfirmTOTPEnrollment active la double authentification si le code correspond au secret en attente.
+// Retourne les codes de secours, affichés une seule fois.
+func (um *UserManager) ConfirmTOTPEnrollment(username, code string) ([]string, error) {
+	stored, enabled, _, err := um.db.GetTOTP(username)
+	if err != nil {
+		return nil, errors.New("utilisateur introuvable")
+	}
+	if enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if stored == "" {
+		return nil, ErrTOTPNoEnrollment
+	}
+	secret, err := openTOTPSecret(stored)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := matchTOTP(secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes := newRecoveryCodes()
+	if err := um.db.EnableTOTP(username, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP désactive la double authentification (secret et codes de secours supprimés)
+func (um *UserManager) DisableTOTP(username string) error {
+	return um.db.DisableTOTP(username)
+}
+
+// RegenerateRecoveryCodes remplace les codes de secours (les anciens deviennent invalides)
+func (um *UserManager) RegenerateRecoveryCodes(username string) ([]string, error) {
+	_, enabled, _, err := um.db.GetTOTP(username)
+	if err != nil || !enabled {
+		return nil, ErrTOTPNotEnabled
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := um.db.ReplaceRecoveryCodes(username, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkTOTP vérifie un code TOTP ou un code de secours, sans effet sur le verrouillage.
+// Un code TOTP déjà utilisé est refusé (rejeu). recovery indique qu'un code de secours a été consommé.
+func (um *UserManager) checkTOTP(username, code string) (recovery bool, err error) {
+	stored, enabled, _, err := um.db.GetTOTP(username)
+	if err != nil || !enabled {
+		return false, ErrTOTPNotEnabled
+	}
+
+	code = normalizeCode(code)
+	if len(code) == totpDigits {
+		secret, err := openTOTPSecret(stored)
+		if err != nil {
+			return false, err
+		}
+		step, ok := matchTOTP(secret, code, time.Now())
+		if !ok {
+			return false, ErrInvalidCode
+		}
+		if fresh, err := um.db.UseTOTPStep(username, step); err != nil || !fresh {
+			return false, ErrInvalidCode
+		}
+		return false, nil
+	}
+
+	used, err := um.db.UseRecoveryCode(username, HashAPIToken(normalizeRecoveryCode(code)))
+	if err != nil || !used {
+		return false, ErrInvalidCode
+	}
+	return true, nil
+}
+
+// VerifyTOTP vérifie un code (TOTP ou code de secours) pour un utilisateur déjà authentifié
+// (actions sensibles du profil). Un échec compte comme une tentative de connexion ratée.
+func (um *UserManager) VerifyTOTP(username, code string) error {
+	if _, err := um.checkTOTP(username, code); err != nil {
+		um.db.RecordLoginAttempt(username, false)
+		return err
+	}
+	return nil
+}
+
+// VerifySecondFactor est la seconde étape de connexion : même verrouillage que le mot de passe
+// (RecordLoginAttempt), un échec compte comme une tentative ratée.
+func (um *UserManager) VerifySecondFactor(username, code string) (recovery bool, err error) {
+	u, err := um.db.GetUser(username)
+	if err != nil || !u.IsActive {
+		return false, errors.New("utilisateur ou mot de passe incorrect")
+	}
+	if !u.LockedUntil.IsZero() && u.LockedUntil.After(time.Now()) {
+		wait := time.Until(u.LockedUntil).Round(time.Minute)
+		return false, errors.New("compte verrouillé pour encore " + wait.String())
+	}
+
+	recovery, err = um.checkTOTP(username, code)
+	if err != nil {
+		if locked, _ := um.db.RecordLoginAttempt(username, false); locked {
+			return false, errors.New("trop d'échecs : compte verrouillé 15 minutes")
+		}
+		return false, err
+	}
+
+	if _, err := um.db.RecordLoginAttempt(username, true); err != nil {
+		log.Printf("Erreur recording login attempt: %v", err)
+	}
+	return recovery, nil
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// currentCode calcule le code TOTP courant d'un secret
+func currentCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	require.NoError(t, err)
+	return totpCode(key, at.Unix()/totpPeriod)
+}
+
+var loginTokenRe = regexp.MustCompile(`name="login_token" value="([^"]+)"`)
+
+// postLogin soumet le formulaire de connexion (les templates sont lus depuis la racine du module)
+func postLogin(am *AuthManager, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	am.LoginHandler(rec, req)
+	return rec
+}
+
+func loginToken(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	m := loginTokenRe.FindStringSubmatch(rec.Body.String())
+	require.NotNil(t, m, "jeton de seconde étape absent")
+	return m[1]
+}
+
+func hasSessionCookie(rec *httptest.ResponseRecorder) bool {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session_token" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Vecteur de la RFC 6238 (SHA1, T=59 s) : 94287082 sur 8 chiffres
+	key := []byte("12345678901234567890")
+	assert.Equal(t, "287082", totpCode(key, 59/totpPeriod))
+
+	secret := totpEncoding.EncodeToString(key)
+	now := time.Unix(59, 0)
+	_, ok := matchTOTP(secret, "287082", now)
+	assert.True(t, ok)
+	_, ok = matchTOTP(secret, "287082", now.Add(2*totpPeriod*time.Second))
+	assert.False(t, ok, "hors de la tolérance de décalage")
+}
+
+func TestTOTPEnrollmentAndRecoveryCodes(t *testing.T) {
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "admin"}})
+
+	secret, err := um.BeginTOTPEnrollment("alice")
+	require.NoError(t, err)
+	assert.Contains(t, TOTPURI("alice", secret), "otpauth://totp/MonitorGo:alice?")
+
+	// Le secret n'est actif qu'après confirmation
+	enabled, _, err := um.TOTPStatus("alice")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	_, err = um.ConfirmTOTPEnrollment("alice", "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	codes, err := um.ConfirmTOTPEnrollment("alice", currentCode(t, secret, time.Now()))
+	require.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	enabled, remaining, err := um.TOTPStatus("alice")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, recoveryCodeCount, remaining)
+
+	_, err = um.BeginTOTPEnrollment("alice")
+	assert.ErrorIs(t, err, ErrTOTPAlreadyEnabled)
+
+	// Le code ayant servi à l'enrôlement ne peut pas être rejoué
+	_, err = um.VerifySecondFactor("alice", currentCode(t, secret, time.Now()))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	// Un code de secours ne sert qu'une fois (casse et tiret indifférents)
+	recovery, err := um.VerifySecondFactor("alice", strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")))
+	require.NoError(t, err)
+	assert.True(t, recovery)
+	_, err = um.VerifySecondFactor("alice", codes[0])
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	_, remaining, _ = um.TOTPStatus("alice")
+	assert.Equal(t, recoveryCodeCount-1, remaining)
+
+	// Régénération : les anciens codes deviennent invalides
+	fresh, err := um.RegenerateRecoveryCodes("alice")
+	require.NoError(t, err)
+	_, err = um.VerifySecondFactor("alice", codes[1])
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	_, err = um.VerifySecondFactor("alice", fresh[0])
+	assert.NoError(t, err)
+
+	require.NoError(t, um.DisableTOTP("alice"))
+	enabled, _, _ = um.TOTPStatus("alice")
+	assert.False(t, enabled)
+	_, err = um.VerifySecondFactor("alice", fresh[1])
+	assert.ErrorIs(t, err, ErrTOTPNotEnabled)
+}
+
+func TestLoginWithTOTP(t *testing.T) {
+	t.Chdir("..")
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "operator"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{sessionConfig(30, 120)})
+
+	secret, err := um.BeginTOTPEnrollment("alice")
+	require.NoError(t, err)
+	_, err = um.ConfirmTOTPEnrollment("alice", currentCode(t, secret, time.Now().Add(-totpPeriod*time.Second)))
+	require.NoError(t, err)
+
+	// Mot de passe correct : pas de session, le code est demandé
+	rec := postLogin(am, url.Values{"username": {"alice"}, "password": {"password123"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, hasSessionCookie(rec))
+	token := loginToken(t, rec)
+
+	rec = postLogin(am, url.Values{"login_token": {token}, "code": {"000000"}})
+	assert.False(t, hasSessionCookie(rec))
+	assert.Contains(t, rec.Body.String(), "Code invalide")
+
+	rec = postLogin(am, url.Values{"login_token": {token}, "code": {currentCode(t, secret, time.Now())}})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.True(t, hasSessionCookie(rec))
+
+	// Le jeton de seconde étape n'est utilisable qu'une fois
+	rec = postLogin(am, url.Values{"login_token": {token}, "code": {currentCode(t, secret, time.Now())}})
+	assert.False(t, hasSessionCookie(rec))
+}
+
+func TestLoginTOTPLockout(t *testing.T) {
+	t.Chdir("..")
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{{Username: "alice", Password: "password123", Role: "operator"}})
+	am := NewAuthManager(um)
+	am.SetConfigSource(staticConfig{sessionConfig(30, 120)})
+
+	secret, err := um.BeginTOTPEnrollment("alice")
+	require.NoError(t, err)
+	_, err = um.ConfirmTOTPEnrollment("alice", currentCode(t, secret, time.Now().Add(-totpPeriod*time.Second)))
+	require.NoError(t, err)
+
+	// Le mot de passe correct ne remet pas le compteur à zéro : les échecs de code s'accumulent
+	for i := 0; i < 5; i++ {
+		rec := postLogin(am, url.Values{"username": {"alice"}, "password": {"password123"}})
+		token := loginToken(t, rec)
+		postLogin(am, url.Values{"login_token": {token}, "code": {"000000"}})
+	}
+
+	u, err := db.GetUser("alice")
+	require.NoError(t, err)
+	assert.True(t, u.LockedUntil.After(time.Now()), "compte verrouillé")
+
+	// Verrouillé : même le bon mot de passe est refusé
+	rec := postLogin(am, url.Values{"username": {"alice"}, "password": {"password123"}})
+	assert.NotContains(t, rec.Body.String(), "login_token")
+	assert.False(t, hasSessionCookie(rec))
+}
+
+func TestLoginRequiredEnrollment(t *testing.T) {
+	t.Chdir("..")
+	db := setupTestDB(t)
+	um := NewUserManager(db, []config.UserConfig{
+		{Username: "root", Password: "password123", Role: "admin"},
+		{Username: "bob", Password: "password123", Role: "user"},
+	})
+	am := NewAuthManager(um)
+	cfg := sessionConfig(30, 120)
+	cfg.Settings.RequireTOTPRoles = []string{"admin"}
+	am.SetConfigSource(staticConfig{cfg})
+
+	// Rôle non concerné : connexion directe
+	login(t, am, "bob", "password123")
+
+	// Administrateur sans double authentification : enrôlement avant toute session
+	rec := postLogin(am, url.Values{"username": {"root"}, "password": {"password123"}})
+	assert.False(t, hasSessionCookie(rec))
+	body := rec.Body.String()
+	assert.Contains(t, body, "<svg")
+	token := loginToken(t, rec)
+
+	m := regexp.MustCompile(`class="totp-secret">([A-Z2-7]+)<`).FindStringSubmatch(body)
+	require.NotNil(t, m, "secret affiché")
+
+	rec = postLogin(am, url.Values{"login_token": {token}, "code": {currentCode(t, m[1], time.Now())}})
+	assert.True(t, hasSessionCookie(rec))
+	assert.Contains(t, rec.Body.String(), "recovery-codes")
+
+	enabled, remaining, err := um.TOTPStatus("root")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, recoveryCodeCount, remaining)
+}
@@ -2,9 +2,12 @@ package auth
 
 import (
 	"errors"
+	"fmt"
 	"go-monitoring/config"
 	"go-monitoring/storage"
 	"log"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -16,12 +19,21 @@ type User struct {
 	Role        string
 	IsActive    bool
 	LockedUntil time.Time
+	TOTPEnabled bool
+	Source      string // storage.UserSourceLocal, UserSourceLDAP ou UserSourceOIDC
+	// Changement de mot de passe requis à la prochaine connexion (imposé ou expiré)
+	PasswordChange bool
 }
 
 // UserManager gère l'authentification des utilisateurs
 type UserManager struct {
 	db        *storage.DB
 	dummyHash string
+	directory *LDAPDirectory // Annuaire optionnel, consulté pour les comptes absents de la base locale
+	config    ConfigSource   // Politique des mots de passe (voir SetConfigSource)
+
+	// Incrémenté à chaque changement de droits (rôle, statut, suppression, révocation de sessions)
+	accessVersion atomic.Uint64
 }
 
 // NewUserManager crée un gestionnaire d'utilisateur
@@ -49,25 +61,39 @@ func NewUserManager(db *storage.DB, cfgUsers []config.UserConfig) *UserManager {
 			}
 
 			log.Printf("Migration user config vers DB: %s", u.Username)
-			db.CreateUser(u.Username, hash, u.Role)
+			db.CreateUser(u.Username, hash, u.Role, false)
 		}
 	}
 
-	// Check if admin exists
-	if _, err := db.GetUser("admin"); err != nil {
-		log.Println("Création du compte admin par défaut")
+	// Compte admin initial (admin/admin) : le mot de passe doit être changé à la première connexion,
+	// y compris pour les bases existantes où il n'a jamais été changé
+	if u, err := db.GetUser("admin"); err != nil {
+		log.Println("Création du compte admin par défaut (mot de passe à changer à la première connexion)")
 		hash, _ := bcrypt.GenerateFromPassword([]byte("admin"), bcrypt.DefaultCost)
-		db.CreateUser("admin", string(hash), "admin")
+		db.CreateUser("admin", string(hash), "admin", true)
+	} else if !u.MustChangePassword && bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte("admin")) == nil {
+		log.Println("AVERTISSEMENT: le compte admin utilise le mot de passe par défaut, changement imposé à la prochaine connexion")
+		db.SetMustChangePassword("admin", true)
 	}
 
 	return um
 }
 
-// Authenticate vérifie le couple username/password avec gestion de lockout
+// SetDirectory active l'authentification par annuaire LDAP
+func (um *UserManager) SetDirectory(d *LDAPDirectory) {
+	um.directory = d
+}
+
+// Authenticate vérifie le couple username/password avec gestion de lockout.
+// Les comptes locaux (bcrypt) restent prioritaires : ils servent de secours si l'annuaire est indisponible.
 func (um *UserManager) Authenticate(username, password string) (*User, error) {
 	userDB, err := um.db.GetUser(username)
 	userFound := (err == nil)
 
+	if um.directory != nil && (!userFound || userDB.Source == storage.UserSourceLDAP) {
+		return um.authenticateDirectory(username, password, userDB)
+	}
+
 	if !userFound {
 		// Dummy user to burn time and prevent timing attacks
 		userDB = &storage.UserDB{
@@ -104,9 +130,13 @@ func (um *UserManager) Authenticate(username, password string) (*User, error) {
 	}
 
 	// 4. Record Attempt (Success)
-	_, recordErr := um.db.RecordLoginAttempt(username, true)
-	if recordErr != nil {
-		log.Printf("Erreur recording login attempt: %v", recordErr)
+	// Avec la double authentification, le compteur n'est remis à zéro qu'après la seconde étape
+	// (VerifySecondFactor) : sinon le mot de passe permettrait de tester les codes sans fin.
+	if !userDB.TOTPEnabled {
+		_, recordErr := um.db.RecordLoginAttempt(username, true)
+		if recordErr != nil {
+			log.Printf("Erreur recording login attempt: %v", recordErr)
+		}
 	}
 
 	return &User{
@@ -114,22 +144,147 @@ func (um *UserManager) Authenticate(username, password string) (*User, error) {
 		Role:        userDB.Role,
 		IsActive:    userDB.IsActive,
 		LockedUntil: userDB.LockedUntil,
+		TOTPEnabled: userDB.TOTPEnabled,
+		Source:      userDB.Source,
 	}, nil
 }
 
-// AddUser ajoute un nouvel utilisateur
+// authenticateDirectory authentifie un utilisateur de l'annuaire. Son compte local est créé à la
+// première connexion (sans mot de passe local) puis son rôle est resynchronisé à chaque connexion.
+// Le verrouillage est vérifié avant d'interroger l'annuaire pour ne pas y verrouiller le compte.
+func (um *UserManager) authenticateDirectory(username, password string, userDB *storage.UserDB) (*User, error) {
+	if userDB != nil {
+		if !userDB.IsActive {
+			return nil, errors.New("utilisateur ou mot de passe incorrect")
+		}
+		if !userDB.LockedUntil.IsZero() && userDB.LockedUntil.After(time.Now()) {
+			wait := time.Until(userDB.LockedUntil).Round(time.Minute)
+			return nil, errors.New("compte verrouillé pour encore " + wait.String())
+		}
+	}
+
+	role, err := um.directory.Authenticate(username, password)
+	if err != nil {
+		if errors.Is(err, ErrDirectoryCredentials) {
+			if userDB != nil {
+				um.db.RecordLoginAttempt(username, false)
+			}
+		} else {
+			log.Printf("LDAP: connexion de %s refusée: %v", username, err)
+		}
+		return nil, errors.New("utilisateur ou mot de passe incorrect")
+	}
+
+	if userDB, err = um.syncExternalUser(username, role, storage.UserSourceLDAP, userDB); err != nil {
+		return nil, err
+	}
+	return um.externalLoginSucceeded(userDB), nil
+}
+
+// LoginExternal termine la connexion d'un utilisateur authentifié par un fournisseur d'identité (SSO) :
+// compte créé à la première connexion, rôle resynchronisé ensuite. Un compte local ou d'une autre
+// origine portant le même nom n'est jamais repris.
+func (um *UserManager) LoginExternal(username, role, source string) (*User, error) {
+	userDB, err := um.db.GetUser(username)
+	if err == nil {
+		if userDB.Source != source {
+			return nil, fmt.Errorf("le compte %s existe déjà (%s)", username, userDB.Source)
+		}
+		if !userDB.IsActive {
+			return nil, errors.New("compte désactivé")
+		}
+		if !userDB.LockedUntil.IsZero() && userDB.LockedUntil.After(time.Now()) {
+			wait := time.Until(userDB.LockedUntil).Round(time.Minute)
+			return nil, errors.New("compte verrouillé pour encore " + wait.String())
+		}
+	} else {
+		userDB = nil
+	}
+
+	if userDB, err = um.syncExternalUser(username, role, source, userDB); err != nil {
+		return nil, err
+	}
+	return um.externalLoginSucceeded(userDB), nil
+}
+
+// syncExternalUser crée le compte local d'un utilisateur externe (sans mot de passe local) ou met son rôle à jour
+func (um *UserManager) syncExternalUser(username, role, source string, userDB *storage.UserDB) (*storage.UserDB, error) {
+	if userDB == nil {
+		log.Printf("%s: création du compte %s (rôle %s)", strings.ToUpper(source), username, role)
+		if err := um.db.CreateExternalUser(username, role, source); err != nil {
+			return nil, err
+		}
+		return um.db.GetUser(username)
+	}
+	if userDB.Role != role {
+		log.Printf("%s: rôle de %s mis à jour (%s -> %s)", strings.ToUpper(source), username, userDB.Role, role)
+		if err := um.db.UpdateUserRole(username, role); err != nil {
+			return nil, err
+		}
+		um.accessChanged()
+		userDB.Role = role
+	}
+	return userDB, nil
+}
+
+// externalLoginSucceeded remet à zéro le compteur d'échecs (après le second facteur s'il est activé)
+func (um *UserManager) externalLoginSucceeded(userDB *storage.UserDB) *User {
+	if !userDB.TOTPEnabled {
+		if _, err := um.db.RecordLoginAttempt(userDB.Username, true); err != nil {
+			log.Printf("Erreur recording login attempt: %v", err)
+		}
+	}
+	return &User{
+		Username:    userDB.Username,
+		Role:        userDB.Role,
+		IsActive:    userDB.IsActive,
+		LockedUntil: userDB.LockedUntil,
+		TOTPEnabled: userDB.TOTPEnabled,
+		Source:      userDB.Source,
+	}
+}
+
+// directoryRole retourne le rôle d'un utilisateur de l'annuaire d'après ses groupes (en cache).
+// Retiré de l'annuaire ou de tout groupe associé, il perd son rôle ; annuaire injoignable,
+// le dernier rôle connu est conservé.
+func (um *UserManager) directoryRole(u *storage.UserDB) string {
+	role, err := um.directory.Lookup(u.Username)
+	if err != nil {
+		if !errors.Is(err, ErrDirectoryUserUnknown) && !errors.Is(err, ErrDirectoryNoRole) {
+			log.Printf("LDAP: groupes de %s non relus: %v", u.Username, err)
+			return u.Role
+		}
+		role = ""
+	}
+	if role != u.Role {
+		log.Printf("LDAP: rôle de %s mis à jour (%s -> %s)", u.Username, u.Role, role)
+		if err := um.db.UpdateUserRole(u.Username, role); err != nil {
+			log.Printf("Erreur mise à jour du rôle de %s: %v", u.Username, err)
+		}
+		um.accessChanged()
+	}
+	return role
+}
+
+// ErrExternalAccount est retourné pour les modifications d'un compte géré par l'annuaire ou le SSO
+var ErrExternalAccount = errors.New("compte géré par l'annuaire ou le SSO : modification impossible ici")
+
+// AddUser ajoute un nouvel utilisateur (mot de passe soumis à la politique)
 func (um *UserManager) AddUser(username, password, role string) error {
 	// Check exist
 	if _, err := um.db.GetUser(username); err == nil {
 		return errors.New("cet utilisateur existe déjà")
 	}
+	if err := um.checkNewPassword(&storage.UserDB{Username: username}, password); err != nil {
+		return err
+	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
-	return um.db.CreateUser(username, string(hash), role)
+	return um.db.CreateUser(username, string(hash), role, false)
 }
 
 // UpdateUser met à jour le rôle (NON IMPLEMENTÉ en DB pour l'instant, on n'a pas methods UpdateUserRole)
@@ -140,6 +295,7 @@ func (um *UserManager) AddUser(username, password, role string) error {
 
 // ToggleUserStatus active/désactive
 func (um *UserManager) ToggleUserStatus(username string, active bool) error {
+	defer um.accessChanged()
 	return um.db.ToggleUserStatus(username, active)
 }
 
@@ -148,29 +304,67 @@ func (um *UserManager) UnlockUser(username string) error {
 	return um.db.UnlockUser(username)
 }
 
-// UpdatePassword modifie le mot de passe
+// UpdatePassword modifie le mot de passe (changement par l'utilisateur : le changement imposé est levé)
 func (um *UserManager) UpdatePassword(username, newPassword string) error {
-	_, err := um.db.GetUser(username)
+	return um.setPassword(username, newPassword, false)
+}
+
+// ResetPassword remplace le mot de passe d'un utilisateur (administration) ; avec mustChange,
+// l'utilisateur devra le changer à sa prochaine connexion
+func (um *UserManager) ResetPassword(username, newPassword string, mustChange bool) error {
+	return um.setPassword(username, newPassword, mustChange)
+}
+
+// RequirePasswordChange impose le changement du mot de passe à la prochaine connexion
+func (um *UserManager) RequirePasswordChange(username string) error {
+	return um.db.SetMustChangePassword(username, true)
+}
+
+func (um *UserManager) setPassword(username, newPassword string, mustChange bool) error {
+	u, err := um.db.GetUser(username)
 	if err != nil {
 		return errors.New("utilisateur introuvable")
 	}
+	if u.Source != storage.UserSourceLocal {
+		return ErrExternalAccount
+	}
+	if err := um.checkNewPassword(u, newPassword); err != nil {
+		return err
+	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
-	return um.db.UpdatePassword(username, string(hash))
+	return um.db.UpdatePassword(username, string(hash), mustChange, um.passwordHistoryKept())
 }
 
-// UpdateUserRole modifie le rôle
+// UpdateUserRole modifie le rôle (les rôles des comptes externes suivent leurs groupes)
 func (um *UserManager) UpdateUserRole(username, role string) error {
+	if u, err := um.db.GetUser(username); err == nil && u.Source != storage.UserSourceLocal {
+		return ErrExternalAccount
+	}
+	defer um.accessChanged()
 	return um.db.UpdateUserRole(username, role)
 }
 
 // DeleteUser supprime un utilisateur
 func (um *UserManager) DeleteUser(username string) error {
-	return um.db.DeleteUser(username)
+	defer um.accessChanged()
+	if err := um.db.DeleteUser(username); err != nil {
+		return err
+	}
+	if err := um.db.DeleteUserAPITokens(username); err != nil {
+		return err
+	}
+	if _, err := um.db.DeleteUserSessions(username, ""); err != nil {
+		return err
+	}
+	if err := um.db.DeletePasswordHistory(username); err != nil {
+		return err
+	}
+	return um.db.DisableTOTP(username)
 }
 
 // GetAllUsers retourne la liste des utilisateurs
@@ -184,10 +378,13 @@ func (um *UserManager) GetAllUsers() []User {
 	var users []User
 	for _, u := range usersDB {
 		users = append(users, User{
-			Username:    u.Username,
-			Role:        u.Role,
-			IsActive:    u.IsActive,
-			LockedUntil: u.LockedUntil,
+			Username:       u.Username,
+			Role:           u.Role,
+			IsActive:       u.IsActive,
+			LockedUntil:    u.LockedUntil,
+			TOTPEnabled:    u.TOTPEnabled,
+			Source:         u.Source,
+			PasswordChange: um.passwordChangeReason(&u) != "",
 		})
 	}
 	return users
@@ -199,9 +396,22 @@ func (um *UserManager) GetUserRole(username string) string {
 	if err != nil {
 		return ""
 	}
+	if u.Source == storage.UserSourceLDAP && um.directory != nil {
+		return um.directoryRole(u)
+	}
 	return u.Role
 }
 
+// AccessVersion change à chaque modification des droits d'un utilisateur : les connexions longues
+// (WebSocket) qui ont mémorisé un rôle le relisent quand elle a changé
+func (um *UserManager) AccessVersion() uint64 {
+	return um.accessVersion.Load()
+}
+
+func (um *UserManager) accessChanged() {
+	um.accessVersion.Add(1)
+}
+
 // IsAdmin vérifie si admin
 func (um *UserManager) IsAdmin(username string) bool {
 	role := um.GetUserRole(username)
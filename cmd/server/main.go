@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -8,11 +9,14 @@ import (
 	"syscall"
 	"time"
 
+	"go-monitoring/alerts"
+	"go-monitoring/audit"
 	"go-monitoring/auth"
 	"go-monitoring/cache"
 	"go-monitoring/config"
 	"go-monitoring/handlers"
 	"go-monitoring/middleware"
+	"go-monitoring/realtime"
 	"go-monitoring/ssh"
 	"go-monitoring/storage"
 )
@@ -41,6 +45,17 @@ func main() {
 		log.Fatalf("Erreur initialisation base de données: %v", err)
 	}
 
+	// Transfert du journal d'audit (syslog RFC 5424, fichier JSON lines), sans bloquer les requêtes
+	var auditForwarder *audit.Forwarder
+	if cfg.Audit != nil {
+		auditForwarder, err = audit.NewForwarderFromConfig(*cfg.Audit)
+		if err != nil {
+			log.Fatalf("Erreur configuration du transfert d'audit: %v", err)
+		}
+		db.SetAuditHook(auditForwarder.Record)
+		log.Println("Transfert du journal d'audit activé")
+	}
+
 	// Démarrer la routine de nettoyage des tokens CSRF
 	middleware.StartCleanupRoutine()
 	log.Println("Routine de nettoyage CSRF démarrée")
@@ -75,6 +90,73 @@ func main() {
 		}
 	}()
 
+	// Gestionnaire d'alertes (checks, sondes, ...)
+	alertManager := alerts.NewManager()
+
+	// Runbooks de remédiation : abonnés aux alertes avant le démarrage des tâches de fond
+	runbookRunner := handlers.NewRunbookRunner(cm, db, alertManager)
+
+	// Tâche de fond pour les checks personnalisés (intervalle propre à chaque check)
+	checkScheduler := handlers.NewCheckScheduler(cm, db, alertManager)
+	go func() {
+		log.Println("Démarrage de l'ordonnanceur de checks personnalisés")
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkScheduler.RunDue()
+		}
+	}()
+
+	// Tâche de fond pour les règles d'alerte sur les logs (lecture incrémentale depuis un curseur)
+	logRuleScheduler := handlers.NewLogRuleScheduler(cm, db, alertManager)
+	go func() {
+		log.Println("Démarrage de l'ordonnanceur de règles de logs")
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			logRuleScheduler.RunDue()
+		}
+	}()
+
+	// Tâche de fond pour les sondes synthétiques HTTP/TCP/TLS
+	probeScheduler := handlers.NewProbeScheduler(cm, db, alertManager)
+	go func() {
+		log.Println("Démarrage de l'ordonnanceur de sondes")
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			probeScheduler.RunDue()
+		}
+	}()
+
+	// Tâche de fond pour le scan des certificats (au démarrage puis toutes les 6h)
+	certScanner := handlers.NewCertScanner(cm, alertManager)
+	go func() {
+		log.Println("Démarrage du scan des certificats")
+		certScanner.ScanAll()
+		ticker := time.NewTicker(6 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			certScanner.ScanAll()
+		}
+	}()
+
+	// Tâche de fond pour les prévisions de remplissage des disques (toutes les 10 min)
+	diskForecaster := handlers.NewDiskForecaster(cm, db, alertManager)
+	go func() {
+		log.Println("Démarrage des prévisions de remplissage disque")
+		diskForecaster.Refresh()
+		ticker := time.NewTicker(storage.DiskHistoryInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			diskForecaster.Refresh()
+			// Historique disque conservé 30 jours (tendances à long terme)
+			if err := db.CleanupDiskHistory(30 * 24 * time.Hour); err != nil {
+				log.Printf("Erreur nettoyage historique disque: %v", err)
+			}
+		}
+	}()
+
 	// Tâche de fond pour le temps réel (WebSocket - 5s)
 	go func() {
 		log.Println("Démarrage de la collecte temps réel (tout les 5 secondes)")
@@ -86,7 +168,7 @@ func main() {
 
 			// Force refresh pour le temps réel
 			machines := handlers.CollectAllMachines(currentCfg, currentPool, metricsCache, 5*time.Second, true)
-			handlers.WSHub.Broadcast(machines)
+			handlers.WSHub.Broadcast(realtime.Snapshot{Machines: machines, Alerts: alertManager.Active()})
 		}
 	}()
 
@@ -94,9 +176,69 @@ func main() {
 	userManager := auth.NewUserManager(db, cfg.Users)
 	authManager := auth.NewAuthManager(userManager)
 
+	// Annuaire LDAP / Active Directory : les comptes locaux restent utilisables en secours
+	if cfg.LDAP != nil {
+		directory, err := auth.NewLDAPDirectory(*cfg.LDAP)
+		if err != nil {
+			log.Fatalf("Erreur configuration LDAP: %v", err)
+		}
+		userManager.SetDirectory(directory)
+		log.Printf("Authentification LDAP activée (%s)", cfg.LDAP.URL)
+	}
+
+	// Connexion SSO OpenID Connect : découverte de l'émetteur à la première connexion
+	if cfg.OIDC != nil {
+		provider, err := auth.NewOIDCProvider(*cfg.OIDC)
+		if err != nil {
+			log.Fatalf("Erreur configuration OIDC: %v", err)
+		}
+		authManager.SetOIDC(provider)
+		log.Printf("Connexion OIDC activée (%s)", cfg.OIDC.Issuer)
+	}
+
 	// Lier le UserManager au ConfigManager (pour usage API)
 	cm.SetUserManager(userManager)
 
+	// Les permissions par rôle et groupe de machines sont lues dans la configuration courante
+	authManager.SetConfigSource(cm)
+
+	// Politique des mots de passe (settings.password_policy)
+	userManager.SetConfigSource(cm)
+
+	// Purge des sessions expirées (durée maximale ou inactivité, voir settings.session_*_timeout)
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := authManager.PurgeExpiredSessions(); err != nil {
+				log.Printf("Erreur purge des sessions: %v", err)
+			}
+		}
+	}()
+
+	// Rétention du journal d'audit (settings.audit_retention_days) ; chaque purge est elle-même auditée
+	purgeAudit := func() {
+		days := cm.GetConfig().Settings.AuditRetentionDays
+		if days <= 0 {
+			return
+		}
+		before := time.Now().AddDate(0, 0, -days)
+		n, err := db.PurgeAuditLogs(before)
+		if err != nil {
+			log.Printf("Erreur purge du journal d'audit: %v", err)
+		} else if n > 0 {
+			db.LogAction("system", "AUDIT_PURGE", "audit", fmt.Sprintf("lignes=%d avant=%s", n, before.Format(time.RFC3339)), "")
+		}
+	}
+	go func() {
+		purgeAudit()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeAudit()
+		}
+	}()
+
 	// Configurer le routeur
 	mux := http.NewServeMux()
 
@@ -104,43 +246,100 @@ func main() {
 	mux.HandleFunc("GET /login", authManager.LoginHandler)
 	mux.HandleFunc("POST /login", authManager.LoginHandler)
 	mux.HandleFunc("/logout", authManager.LogoutHandler)
+	mux.HandleFunc("GET /auth/oidc/login", authManager.OIDCLoginHandler)
+	mux.HandleFunc("GET /auth/oidc/callback", authManager.OIDCCallbackHandler)
 
 	// Pages protégées
 	log.Println("Registering GET /{$}")
 	mux.HandleFunc("GET /{$}", authManager.Middleware(handlers.DashboardWithCM(cm, authManager)))
 
 	log.Println("Registering GET /machine/{id}")
-	mux.HandleFunc("GET /machine/{id}", authManager.Middleware(handlers.MachineDetailWithCM(cm, authManager)))
+	mux.HandleFunc("GET /machine/{id}", authManager.RequireMachine(handlers.MachineDetailWithCM(cm, authManager, db)))
 
-	mux.HandleFunc("GET /alerts", authManager.Middleware(handlers.RenderPageWithCM(cm, authManager, "alerts")))
+	// Flux temps réel du dashboard (abonnements par sujet, filtrés selon les permissions)
+	mux.HandleFunc("GET /ws", authManager.Middleware(handlers.ServeWS(authManager)))
+	mux.HandleFunc("GET /api/ws/stats", authManager.Require(config.CapabilityAdmin, handlers.WSStats()))
+
+	mux.HandleFunc("GET /alerts", authManager.Middleware(handlers.AlertsPage(cm, db, authManager, alertManager)))
+	mux.HandleFunc("GET /probes", authManager.Middleware(handlers.ProbesPage(cm, db, authManager)))
+	mux.HandleFunc("GET /certificates", authManager.Middleware(handlers.CertificatesPage(cm, authManager, certScanner)))
 	mux.HandleFunc("GET /settings", authManager.Middleware(handlers.RenderPageWithCM(cm, authManager, "settings")))
-	mux.HandleFunc("GET /users", authManager.Middleware(handlers.UsersPage(cfg, authManager)))
-	mux.HandleFunc("GET /audit", authManager.Middleware(handlers.AuditPage(cfg, db, authManager)))
-
-	// API protégées
-	mux.HandleFunc("GET /api/machine/{id}/disks", authManager.Middleware(handlers.DiskListWithCM(cm)))
-	mux.HandleFunc("GET /api/machine/{id}/disk", authManager.Middleware(handlers.DiskDetailsWithCM(cm)))
-	mux.HandleFunc("GET /api/machine/{id}/browse", authManager.Middleware(handlers.BrowseDirectoryWithCM(cm)))
-	mux.HandleFunc("GET /api/machines", authManager.Middleware(handlers.ListMachines(cm)))
-	mux.HandleFunc("POST /api/machines", authManager.Middleware(handlers.AddMachine(cm)))
-	mux.HandleFunc("PUT /api/machines/{id}", authManager.Middleware(handlers.UpdateMachine(cm)))
-	mux.HandleFunc("DELETE /api/machines/{id}", authManager.Middleware(handlers.RemoveMachine(cm)))
-	mux.HandleFunc("GET /api/machine/{id}/history", authManager.Middleware(handlers.GetMachineHistory(db)))
-	mux.HandleFunc("GET /api/machine/{id}/terminal", authManager.Middleware(handlers.WebTerminalHandler(cm)))
-	mux.HandleFunc("GET /api/status", authManager.Middleware(handlers.GetStatus(cfg, pool, metricsCache)))
-	mux.HandleFunc("POST /api/machine/{id}/service/{service}/{action}", authManager.Middleware(handlers.HandleServiceAction(cm, db, authManager)))
-	mux.HandleFunc("GET /api/machine/{id}/logs", authManager.Middleware(handlers.ListLogSources(cm)))
-	mux.HandleFunc("GET /api/machine/{id}/logs/view", authManager.Middleware(handlers.GetLogContent(cm, db, authManager)))
+	mux.HandleFunc("GET /sessions", authManager.Middleware(handlers.RenderPageWithCM(cm, authManager, "sessions")))
+	mux.HandleFunc("GET /users", authManager.Require(config.CapabilityAdmin, handlers.UsersPage(cfg, authManager)))
+	mux.HandleFunc("GET /audit", authManager.Require(config.CapabilityAdmin, handlers.AuditPage(cfg, db, authManager)))
+	mux.HandleFunc("GET /recordings", authManager.Require(config.CapabilityAdmin, handlers.RecordingsPage(cm, authManager)))
+	mux.HandleFunc("GET /commands", authManager.Require(config.CapabilityCommands, handlers.CommandsPage(cm, authManager)))
+
+	// API protégées (capacités par rôle et groupe de machines, voir la section permissions)
+	mux.HandleFunc("GET /api/machine/{id}/disks", authManager.RequireMachine(handlers.DiskListWithCM(cm)))
+	mux.HandleFunc("GET /api/machine/{id}/disk", authManager.RequireMachine(handlers.DiskDetailsWithCM(cm)))
+	mux.HandleFunc("GET /api/machine/{id}/disk/usage", authManager.Require(config.CapabilityFiles, handlers.DiskUsageWithCM(cm)))
+	mux.HandleFunc("GET /api/machine/{id}/browse", authManager.Require(config.CapabilityFiles, handlers.BrowseDirectoryWithCM(cm)))
+	mux.HandleFunc("GET /api/machine/{id}/files/download", authManager.Require(config.CapabilityFiles, handlers.DownloadFile(cm, authManager)))
+	mux.HandleFunc("GET /api/machine/{id}/files/preview", authManager.Require(config.CapabilityFiles, handlers.PreviewFile(cm, authManager)))
+	mux.HandleFunc("POST /api/machine/{id}/files/upload", authManager.Require(config.CapabilityAdmin, handlers.UploadFile(cm, authManager)))
+	mux.HandleFunc("GET /api/machines", authManager.Middleware(handlers.ListMachines(cm, authManager)))
+	mux.HandleFunc("POST /api/machines", authManager.Require(config.CapabilityAdmin, handlers.AddMachine(cm, authManager)))
+	mux.HandleFunc("PUT /api/machines/{id}", authManager.Require(config.CapabilityAdmin, handlers.UpdateMachine(cm, authManager)))
+	mux.HandleFunc("DELETE /api/machines/{id}", authManager.Require(config.CapabilityAdmin, handlers.RemoveMachine(cm, authManager)))
+	mux.HandleFunc("GET /api/machines/{id}/log-sources", authManager.Require(config.CapabilityAdmin, handlers.GetMachineLogSources(cm)))
+	mux.HandleFunc("PUT /api/machines/{id}/log-sources", authManager.Require(config.CapabilityAdmin, handlers.UpdateMachineLogSources(cm, authManager)))
+	mux.HandleFunc("GET /api/machine/{id}/history", authManager.RequireMachine(handlers.GetMachineHistory(db)))
+	mux.HandleFunc("GET /api/machine/{id}/terminal", authManager.Require(config.CapabilityTerminal, handlers.WebTerminalHandler(cm, db, authManager)))
+	mux.HandleFunc("GET /api/machine/{id}/terminal/sessions", authManager.Require(config.CapabilityTerminal, handlers.ListTerminalSessions()))
+	mux.HandleFunc("DELETE /api/machine/{id}/terminal/sessions/{session}", authManager.Require(config.CapabilityAdmin, handlers.TerminateTerminalSession(authManager)))
+	mux.HandleFunc("GET /api/status", authManager.Middleware(handlers.GetStatus(cfg, pool, metricsCache, authManager)))
+	mux.HandleFunc("POST /api/machine/{id}/service/{service}/{action}", authManager.Require(config.CapabilityServices, handlers.HandleServiceAction(cm, authManager)))
+	mux.HandleFunc("GET /api/machine/{id}/logs", authManager.Require(config.CapabilityLogs, handlers.ListLogSources(cm)))
+	mux.HandleFunc("GET /api/machine/{id}/logs/view", authManager.Require(config.CapabilityLogs, handlers.GetLogContent(cm, authManager)))
+	mux.HandleFunc("GET /api/machine/{id}/logs/stream", authManager.Require(config.CapabilityLogs, handlers.StreamLogs(cm)))
+	mux.HandleFunc("GET /api/logs/search", authManager.Require(config.CapabilityLogs, handlers.SearchLogs(cm, authManager)))
+	mux.HandleFunc("GET /api/machine/{id}/checks", authManager.RequireMachine(handlers.ListMachineChecks(cm, db)))
+	mux.HandleFunc("GET /api/machine/{id}/checks/{check}/history", authManager.RequireMachine(handlers.GetCheckHistory(db)))
+	mux.HandleFunc("GET /api/alerts", authManager.Middleware(handlers.ListAlerts(alertManager, authManager)))
+	mux.HandleFunc("POST /api/runbooks/{name}/run", authManager.Require(config.CapabilityServices, handlers.RunRunbook(cm, authManager, runbookRunner)))
+	mux.HandleFunc("GET /api/runbooks/runs", authManager.Require(config.CapabilityServices, handlers.ListRunbookRuns(db, authManager)))
+	mux.HandleFunc("GET /api/runbooks/runs/{run}", authManager.Require(config.CapabilityServices, handlers.GetRunbookRun(db, authManager)))
+	mux.HandleFunc("GET /api/certificates", authManager.Middleware(handlers.ListExpiringCertificates(cm, authManager, certScanner)))
+	mux.HandleFunc("GET /api/machine/{id}/certificates", authManager.RequireMachine(handlers.ListMachineCertificates(cm, certScanner)))
+	mux.HandleFunc("GET /api/machine/{id}/disks/forecast", authManager.RequireMachine(handlers.GetDiskForecast(cm)))
+	mux.HandleFunc("GET /api/machine/{id}/disks/history", authManager.RequireMachine(handlers.GetDiskHistory(cm, db)))
+	mux.HandleFunc("GET /api/probes", authManager.Middleware(handlers.ListProbes(cm, db, authManager)))
+	mux.HandleFunc("GET /api/probes/{name}/history", authManager.Middleware(handlers.GetProbeHistory(cm, db, authManager)))
+	mux.HandleFunc("GET /api/recordings", authManager.Require(config.CapabilityAdmin, handlers.ListRecordings(db)))
+	mux.HandleFunc("GET /api/recordings/{id}/cast", authManager.Require(config.CapabilityAdmin, handlers.GetRecordingCast(db, authManager)))
+	mux.HandleFunc("POST /api/commands/runs", authManager.Require(config.CapabilityCommands, handlers.StartCommandRun(cm, db, authManager)))
+	mux.HandleFunc("GET /api/commands/runs", authManager.Require(config.CapabilityCommands, handlers.ListCommandRuns(db, authManager)))
+	mux.HandleFunc("GET /api/commands/runs/{run}", authManager.Require(config.CapabilityCommands, handlers.GetCommandRun(db, authManager)))
+	mux.HandleFunc("GET /api/commands/runs/{run}/stream", authManager.Require(config.CapabilityCommands, handlers.StreamCommandRun(db, authManager)))
 
 	// API Utilisateurs (Admin seulement)
-	mux.HandleFunc("GET /api/users", authManager.Middleware(handlers.ListUsers(cm, authManager)))
-	mux.HandleFunc("POST /api/users", authManager.Middleware(handlers.CreateUser(cm, authManager)))
-	mux.HandleFunc("DELETE /api/users/{username}", authManager.Middleware(handlers.DeleteUser(cm, authManager)))
-	mux.HandleFunc("PUT /api/users/{username}/password", authManager.Middleware(handlers.UpdateUserPassword(cm, authManager)))
-	mux.HandleFunc("PUT /api/users/{username}/role", authManager.Middleware(handlers.UpdateUserRole(cm, authManager)))
-	mux.HandleFunc("POST /api/users/{username}/toggle-status", authManager.Middleware(handlers.ToggleUserStatus(cm, authManager)))
-	mux.HandleFunc("POST /api/users/{username}/unlock", authManager.Middleware(handlers.UnlockUser(cm, authManager)))
+	// Journal d'audit (Admin seulement) : filtres, export et vérification de la chaîne de hash
+	mux.HandleFunc("GET /api/audit", authManager.Require(config.CapabilityAdmin, handlers.ListAuditLogs(db)))
+	mux.HandleFunc("GET /api/audit/export", authManager.Require(config.CapabilityAdmin, handlers.ExportAuditLogs(db, authManager)))
+	mux.HandleFunc("GET /api/audit/verify", authManager.Require(config.CapabilityAdmin, handlers.VerifyAuditLogs(db, authManager)))
+
+	mux.HandleFunc("GET /api/users", authManager.Require(config.CapabilityAdmin, handlers.ListUsers(cm, authManager)))
+	mux.HandleFunc("POST /api/users", authManager.Require(config.CapabilityAdmin, handlers.CreateUser(cm, authManager)))
+	mux.HandleFunc("DELETE /api/users/{username}", authManager.Require(config.CapabilityAdmin, handlers.DeleteUser(cm, authManager)))
+	mux.HandleFunc("PUT /api/users/{username}/password", authManager.Require(config.CapabilityAdmin, handlers.UpdateUserPassword(cm, authManager)))
+	mux.HandleFunc("PUT /api/users/{username}/role", authManager.Require(config.CapabilityAdmin, handlers.UpdateUserRole(cm, authManager)))
+	mux.HandleFunc("POST /api/users/{username}/toggle-status", authManager.Require(config.CapabilityAdmin, handlers.ToggleUserStatus(cm, authManager)))
+	mux.HandleFunc("POST /api/users/{username}/unlock", authManager.Require(config.CapabilityAdmin, handlers.UnlockUser(cm, authManager)))
+	mux.HandleFunc("POST /api/users/{username}/logout", authManager.Require(config.CapabilityAdmin, handlers.ForceLogoutUser(db, authManager)))
+	mux.HandleFunc("DELETE /api/users/{username}/totp", authManager.Require(config.CapabilityAdmin, handlers.ResetUserTOTP(db, authManager)))
 	mux.HandleFunc("POST /api/profile/password", authManager.Middleware(handlers.UpdateSelfPassword(cm, authManager)))
+	mux.HandleFunc("GET /api/profile/tokens", authManager.Middleware(handlers.ListAPITokens(db, authManager)))
+	mux.HandleFunc("POST /api/profile/tokens", authManager.Middleware(handlers.CreateAPIToken(cm, db, authManager)))
+	mux.HandleFunc("DELETE /api/profile/tokens/{token}", authManager.Middleware(handlers.RevokeAPIToken(db, authManager)))
+	mux.HandleFunc("GET /api/profile/sessions", authManager.Middleware(handlers.ListSessions(authManager)))
+	mux.HandleFunc("DELETE /api/profile/sessions", authManager.Middleware(handlers.RevokeOtherSessions(authManager)))
+	mux.HandleFunc("DELETE /api/profile/sessions/{session}", authManager.Middleware(handlers.RevokeSession(db, authManager)))
+	mux.HandleFunc("GET /api/profile/totp", authManager.Middleware(handlers.GetTOTPStatus(cm, authManager)))
+	mux.HandleFunc("POST /api/profile/totp/setup", authManager.Middleware(handlers.SetupTOTP(authManager)))
+	mux.HandleFunc("POST /api/profile/totp/enable", authManager.Middleware(handlers.EnableTOTP(authManager)))
+	mux.HandleFunc("POST /api/profile/totp/disable", authManager.Middleware(handlers.DisableTOTP(cm, authManager)))
+	mux.HandleFunc("POST /api/profile/totp/recovery-codes", authManager.Middleware(handlers.RegenerateRecoveryCodes(authManager)))
 
 	// Fichiers statiques (publics)
 	fs := http.FileServer(http.Dir("static"))
@@ -153,6 +352,7 @@ func main() {
 		<-sigChan
 		log.Println("Arrêt du serveur...")
 		pool.CloseAll()
+		auditForwarder.Close(5 * time.Second)
 		os.Exit(0)
 	}()
 
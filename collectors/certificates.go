@@ -0,0 +1,237 @@
+package collectors
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go-monitoring/models"
+	"go-monitoring/ssh"
+)
+
+// Extensions des fichiers examinés lors du parcours d'un répertoire
+var certExtensions = []string{".pem", ".crt", ".cer"}
+
+// Taille maximale d'un fichier de certificats (évite de lire de gros fichiers par erreur)
+const maxCertFileSize = 256 * 1024
+
+// CollectCertificates liste les certificats présents dans les chemins configurés.
+// Linux: fichiers/répertoires PEM (seuls les blocs CERTIFICATE quittent la machine).
+// Windows: magasins PowerShell (ex: Cert:\LocalMachine\My).
+// Les chemins ont été validés au chargement de la configuration.
+func CollectCertificates(client ssh.SSHExecutor, paths []string, osType string) ([]models.CertificateInfo, error) {
+	if len(paths) == 0 {
+		return []models.CertificateInfo{}, nil
+	}
+
+	if osType == "windows" {
+		return collectCertificatesWindows(client, paths)
+	}
+	return collectCertificatesLinux(client, paths)
+}
+
+// collectCertificatesLinux extrait les blocs PEM des fichiers trouvés par find
+func collectCertificatesLinux(client ssh.SSHExecutor, paths []string) ([]models.CertificateInfo, error) {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = "'" + p + "'"
+	}
+
+	// Les noms de fichiers sont passés en arguments au shell (jamais interprétés)
+	cmd := "find " + strings.Join(quoted, " ") +
+		` -maxdepth 3 -type f \( -name '*.pem' -o -name '*.crt' -o -name '*.cer' \) -size -256k` +
+		` -exec sh -c 'for f; do echo "### $f"; sed -n "/-----BEGIN CERTIFICATE-----/,/-----END CERTIFICATE-----/p" "$f"; done' sh {} + 2>/dev/null || true`
+
+	output, err := client.Execute(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCertDump(output, time.Now()), nil
+}
+
+// parseCertDump découpe la sortie "### fichier" + blocs PEM en certificats
+func parseCertDump(output string, now time.Time) []models.CertificateInfo {
+	var certs []models.CertificateInfo
+	var source string
+	var block strings.Builder
+
+	flush := func() {
+		if source != "" {
+			certs = append(certs, ParsePEMCertificates(source, []byte(block.String()), now)...)
+		}
+		block.Reset()
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if name, ok := strings.CutPrefix(line, "### "); ok {
+			flush()
+			source = strings.TrimSpace(name)
+			continue
+		}
+		block.WriteString(line)
+		block.WriteString("\n")
+	}
+	flush()
+
+	sortCertificates(certs)
+	return certs
+}
+
+// ParsePEMCertificates décode tous les certificats X.509 d'un contenu PEM
+func ParsePEMCertificates(source string, data []byte, now time.Time) []models.CertificateInfo {
+	var certs []models.CertificateInfo
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		sum := sha1.Sum(cert.Raw)
+		sans := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+
+		certs = append(certs, models.CertificateInfo{
+			Source:      source,
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			SANs:        sans,
+			NotAfter:    cert.NotAfter,
+			DaysLeft:    daysUntil(cert.NotAfter, now),
+			Expired:     cert.NotAfter.Before(now),
+			Fingerprint: strings.ToUpper(hex.EncodeToString(sum[:])),
+		})
+	}
+	return certs
+}
+
+// collectCertificatesWindows liste les certificats des magasins via PowerShell
+func collectCertificatesWindows(client ssh.SSHExecutor, stores []string) ([]models.CertificateInfo, error) {
+	var certs []models.CertificateInfo
+	now := time.Now()
+
+	for _, store := range stores {
+		cmd := `powershell -Command "Get-ChildItem -Path '` + store + `' | Where-Object { $_.NotAfter } | ForEach-Object { Write-Output ('{0}|{1}|{2}|{3}|{4}' -f $_.Thumbprint, $_.NotAfter.ToUniversalTime().ToString('yyyy-MM-ddTHH:mm:ssZ'), ($_.DnsNameList -join ','), $_.Issuer, $_.Subject) }"`
+
+		output, err := client.Execute(cmd)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, parseWindowsCertOutput(store, output, now)...)
+	}
+
+	sortCertificates(certs)
+	return certs, nil
+}
+
+// parseWindowsCertOutput interprète les lignes "empreinte|notAfter|SAN|émetteur|sujet"
+func parseWindowsCertOutput(store, output string, now time.Time) []models.CertificateInfo {
+	var certs []models.CertificateInfo
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "|", 5)
+		if len(parts) != 5 {
+			continue
+		}
+
+		notAfter, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			continue
+		}
+
+		var sans []string
+		for _, san := range strings.Split(parts[2], ",") {
+			if san = strings.TrimSpace(san); san != "" {
+				sans = append(sans, san)
+			}
+		}
+
+		certs = append(certs, models.CertificateInfo{
+			Source:      store,
+			Subject:     parts[4],
+			Issuer:      parts[3],
+			SANs:        sans,
+			NotAfter:    notAfter,
+			DaysLeft:    daysUntil(notAfter, now),
+			Expired:     notAfter.Before(now),
+			Fingerprint: strings.ToUpper(parts[0]),
+		})
+	}
+	return certs
+}
+
+// CollectLocalCertificates parcourt les chemins configurés sur la machine locale
+func CollectLocalCertificates(paths []string) ([]models.CertificateInfo, error) {
+	var certs []models.CertificateInfo
+	now := time.Now()
+
+	for _, root := range paths {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				// Même profondeur que la variante distante (find -maxdepth 3)
+				if strings.Count(strings.TrimPrefix(path, root), string(filepath.Separator)) >= 3 {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !hasCertExtension(path) {
+				return nil
+			}
+			if info, err := d.Info(); err != nil || info.Size() > maxCertFileSize {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			certs = append(certs, ParsePEMCertificates(path, data, now)...)
+			return nil
+		})
+	}
+
+	sortCertificates(certs)
+	return certs, nil
+}
+
+func hasCertExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range certExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// daysUntil retourne le nombre de jours entiers avant expiration (négatif si expiré depuis plus d'un jour)
+func daysUntil(t, now time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
+
+// sortCertificates trie par date d'expiration (les plus urgents en premier)
+func sortCertificates(certs []models.CertificateInfo) {
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+}
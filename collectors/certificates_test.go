@@ -0,0 +1,108 @@
+package collectors
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCertPEM génère un certificat auto-signé expirant à notAfter
+func testCertPEM(t *testing.T, cn string, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn, "www." + cn},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestParseCertDump(t *testing.T) {
+	now := time.Now()
+	soon := testCertPEM(t, "intranet.local", now.Add(10*24*time.Hour+time.Hour))
+	later := testCertPEM(t, "portal.local", now.Add(200*24*time.Hour))
+	expired := testCertPEM(t, "old.local", now.Add(-48*time.Hour))
+
+	output := "### /etc/nginx/ssl/portal.pem\n" + later +
+		"### /etc/ssl/certs/chain.pem\n" + soon + expired +
+		"### /etc/ssl/private/empty.pem\n"
+
+	certs := parseCertDump(output, now)
+	require.Len(t, certs, 3)
+
+	// Tri par date d'expiration
+	assert.Equal(t, "CN=old.local", certs[0].Subject)
+	assert.Equal(t, -2, certs[0].DaysLeft)
+	assert.True(t, certs[0].Expired)
+	assert.False(t, certs[1].Expired)
+	assert.Equal(t, "/etc/ssl/certs/chain.pem", certs[1].Source)
+	assert.Equal(t, 10, certs[1].DaysLeft)
+	assert.Equal(t, []string{"intranet.local", "www.intranet.local", "10.0.0.1"}, certs[1].SANs)
+	assert.Equal(t, "CN=intranet.local", certs[1].Issuer)
+	assert.Len(t, certs[1].Fingerprint, 40)
+	assert.Equal(t, "/etc/nginx/ssl/portal.pem", certs[2].Source)
+}
+
+func TestParseWindowsCertOutput(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	output := "ABCDEF0123|2026-01-15T00:00:00Z|intranet.local,www.intranet.local|CN=Corp CA, O=Corp|CN=intranet.local, O=Corp\r\n" +
+		"garbage line\r\n" +
+		"0123ABCD|2027-01-01T00:00:00Z||CN=Corp CA|CN=client\r\n"
+
+	certs := parseWindowsCertOutput(`Cert:\LocalMachine\My`, output, now)
+	require.Len(t, certs, 2)
+	assert.Equal(t, "CN=intranet.local, O=Corp", certs[0].Subject)
+	assert.Equal(t, "CN=Corp CA, O=Corp", certs[0].Issuer)
+	assert.Equal(t, []string{"intranet.local", "www.intranet.local"}, certs[0].SANs)
+	assert.Equal(t, 14, certs[0].DaysLeft)
+	assert.Empty(t, certs[1].SANs)
+}
+
+func TestCollectCertificates_LinuxCommand(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	_, err := CollectCertificates(client, []string{"/etc/ssl/certs", "/etc/nginx"}, "linux")
+	require.Error(t, err) // aucune réponse enregistrée
+
+	require.Len(t, client.ExecutedCommands, 1)
+	cmd := client.ExecutedCommands[0]
+	assert.True(t, strings.HasPrefix(cmd, "find '/etc/ssl/certs' '/etc/nginx' "))
+	// Seuls les blocs CERTIFICATE sont transmis (jamais les clés privées)
+	assert.Contains(t, cmd, "BEGIN CERTIFICATE")
+}
+
+func TestCollectLocalCertificates(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nginx"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nginx", "site.crt"), []byte(testCertPEM(t, "site.local", now.Add(5*24*time.Hour))), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(testCertPEM(t, "ignored.local", now)), 0644))
+
+	certs, err := CollectLocalCertificates([]string{dir})
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	assert.Equal(t, "CN=site.local", certs[0].Subject)
+	assert.Equal(t, filepath.Join(dir, "nginx", "site.crt"), certs[0].Source)
+}
@@ -0,0 +1,217 @@
+package collectors
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/ssh"
+)
+
+// États Nagios (codes de sortie des plugins)
+const (
+	CheckOK       = 0
+	CheckWarning  = 1
+	CheckCritical = 2
+	CheckUnknown  = 3
+)
+
+// CheckStateName retourne le nom d'un état Nagios
+func CheckStateName(state int) string {
+	switch state {
+	case CheckOK:
+		return "ok"
+	case CheckWarning:
+		return "warning"
+	case CheckCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// RunCheck exécute un check personnalisé et interprète sa sortie au format Nagios.
+// La commande provient exclusivement de la configuration (jamais de l'API).
+func RunCheck(client ssh.SSHExecutor, machineID string, check config.CheckConfig) models.CheckResult {
+	result := models.CheckResult{
+		MachineID: machineID,
+		Name:      check.Name,
+		Timestamp: time.Now(),
+	}
+
+	res, err := client.ExecuteWithResult(check.Command, time.Duration(check.Timeout)*time.Second)
+	if res != nil {
+		result.Duration = res.Duration
+	}
+
+	switch {
+	case errors.Is(err, ssh.ErrCommandTimeout):
+		// Comportement par défaut de Nagios: un timeout est critique
+		result.State = CheckCritical
+		result.Output = "CRITICAL - délai d'exécution dépassé (" + strconv.Itoa(check.Timeout) + "s)"
+	case err != nil:
+		result.State = CheckUnknown
+		result.Output = "UNKNOWN - " + err.Error()
+	default:
+		result.State = res.ExitCode
+		if result.State < CheckOK || result.State > CheckUnknown {
+			result.State = CheckUnknown
+		}
+		output := res.Stdout
+		if strings.TrimSpace(output) == "" {
+			output = res.Stderr
+		}
+		result.Output, result.LongText, result.PerfData = ParseNagiosOutput(output)
+	}
+
+	result.StateName = CheckStateName(result.State)
+	return result
+}
+
+// ParseNagiosOutput découpe la sortie d'un plugin Nagios en
+// texte principal, texte long et perfdata.
+//
+// Format: TEXTE | PERFDATA
+//
+//	TEXTE LONG
+//	TEXTE LONG | PERFDATA
+//	PERFDATA
+func ParseNagiosOutput(output string) (string, string, []models.PerfDatum) {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(output), "\r\n", "\n"), "\n")
+
+	var perf []string
+	summary, firstPerf, _ := strings.Cut(lines[0], "|")
+	if firstPerf != "" {
+		perf = append(perf, firstPerf)
+	}
+
+	var longText []string
+	inPerf := false
+	for _, line := range lines[1:] {
+		if inPerf {
+			perf = append(perf, line)
+			continue
+		}
+		text, p, found := strings.Cut(line, "|")
+		if text != "" {
+			longText = append(longText, text)
+		}
+		if found {
+			perf = append(perf, p)
+			inPerf = true
+		}
+	}
+
+	return strings.TrimSpace(summary), strings.TrimSpace(strings.Join(longText, "\n")), ParsePerfData(strings.Join(perf, " "))
+}
+
+// ParsePerfData parse une chaîne de perfdata Nagios: 'label'=value[UOM];[warn];[crit];[min];[max]
+// Les entrées invalides sont ignorées.
+func ParsePerfData(s string) []models.PerfDatum {
+	var result []models.PerfDatum
+
+	for _, item := range splitPerfData(s) {
+		label, rest, ok := cutPerfLabel(item)
+		if !ok || label == "" {
+			continue
+		}
+
+		fields := strings.Split(rest, ";")
+		value, uom := splitValueUOM(fields[0])
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		d := models.PerfDatum{Label: label, Value: v, UOM: uom}
+		if len(fields) > 1 {
+			d.Warn = fields[1]
+		}
+		if len(fields) > 2 {
+			d.Crit = fields[2]
+		}
+		if len(fields) > 3 {
+			d.Min = fields[3]
+		}
+		if len(fields) > 4 {
+			d.Max = fields[4]
+		}
+		result = append(result, d)
+	}
+
+	return result
+}
+
+// splitPerfData découpe les perfdata sur les espaces en respectant les labels entre quotes
+func splitPerfData(s string) []string {
+	var items []string
+	var current strings.Builder
+	inQuote := false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\'':
+			// '' est un quote échappé à l'intérieur d'un label
+			if inQuote && i+1 < len(s) && s[i+1] == '\'' {
+				current.WriteString("''")
+				i++
+				continue
+			}
+			inQuote = !inQuote
+			current.WriteByte(ch)
+		case (ch == ' ' || ch == '\t' || ch == '\n') && !inQuote:
+			if current.Len() > 0 {
+				items = append(items, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	if current.Len() > 0 {
+		items = append(items, current.String())
+	}
+	return items
+}
+
+// cutPerfLabel sépare le label (éventuellement entre quotes) du reste de l'entrée
+func cutPerfLabel(item string) (string, string, bool) {
+	if !strings.HasPrefix(item, "'") {
+		return strings.Cut(item, "=")
+	}
+
+	var label strings.Builder
+	for i := 1; i < len(item); i++ {
+		if item[i] != '\'' {
+			label.WriteByte(item[i])
+			continue
+		}
+		if i+1 < len(item) && item[i+1] == '\'' {
+			label.WriteByte('\'')
+			i++
+			continue
+		}
+		if i+1 < len(item) && item[i+1] == '=' {
+			return label.String(), item[i+2:], true
+		}
+		return "", "", false
+	}
+	return "", "", false
+}
+
+// splitValueUOM sépare la valeur numérique de son unité (ex: "95.5%" -> "95.5", "%")
+func splitValueUOM(s string) (string, string) {
+	i := len(s)
+	for i > 0 {
+		c := s[i-1]
+		if (c >= '0' && c <= '9') || c == '.' {
+			break
+		}
+		i--
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,123 @@
+package collectors
+
+import (
+	"errors"
+	"testing"
+
+	"go-monitoring/config"
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePerfData(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+		label    string
+		value    float64
+		uom      string
+		warn     string
+		crit     string
+	}{
+		{"simple", "load1=0.50", 1, "load1", 0.5, "", "", ""},
+		{"with uom and thresholds", "used=85.5%;80;90;0;100", 1, "used", 85.5, "%", "80", "90"},
+		{"quoted label with spaces", "'disk /var'=1024KB;2048;4096", 1, "disk /var", 1024, "KB", "2048", "4096"},
+		{"escaped quote", "'it''s'=1s", 1, "it's", 1, "s", "", ""},
+		{"multiple entries", "a=1 b=2c;;5", 2, "a", 1, "", "", ""},
+		{"counter uom", "packets=1234c", 1, "packets", 1234, "c", "", ""},
+		{"empty thresholds", "time=0.002s;;;0", 1, "time", 0.002, "s", "", ""},
+		{"invalid value", "x=U", 0, "", 0, "", "", ""},
+		{"no equal sign", "garbage", 0, "", 0, "", "", ""},
+		{"empty", "", 0, "", 0, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParsePerfData(tt.input)
+			require.Len(t, result, tt.expected)
+			if tt.expected == 0 {
+				return
+			}
+			assert.Equal(t, tt.label, result[0].Label)
+			assert.Equal(t, tt.value, result[0].Value)
+			assert.Equal(t, tt.uom, result[0].UOM)
+			assert.Equal(t, tt.warn, result[0].Warn)
+			assert.Equal(t, tt.crit, result[0].Crit)
+		})
+	}
+}
+
+func TestParseNagiosOutput(t *testing.T) {
+	output := "DISK OK - free space: / 3326 MB (56%) | /=2643MB;5948;5958;0;5968\n" +
+		"/ 15272 MB (77%);\n" +
+		"/boot 68 MB (69%); | /boot=68MB;88;93;0;98\n" +
+		"/home=69357MB;253404;253409;0;253414"
+
+	summary, longText, perf := ParseNagiosOutput(output)
+
+	assert.Equal(t, "DISK OK - free space: / 3326 MB (56%)", summary)
+	assert.Equal(t, "/ 15272 MB (77%);\n/boot 68 MB (69%);", longText)
+	require.Len(t, perf, 3)
+	assert.Equal(t, "/", perf[0].Label)
+	assert.Equal(t, "/boot", perf[1].Label)
+	assert.Equal(t, "/home", perf[2].Label)
+	assert.Equal(t, float64(69357), perf[2].Value)
+}
+
+func TestParseNagiosOutput_NoPerfData(t *testing.T) {
+	summary, longText, perf := ParseNagiosOutput("PROCS OK: 12 processes\n")
+
+	assert.Equal(t, "PROCS OK: 12 processes", summary)
+	assert.Empty(t, longText)
+	assert.Empty(t, perf)
+}
+
+func TestRunCheck_ExitCodes(t *testing.T) {
+	tests := []struct {
+		code      int
+		stateName string
+	}{
+		{0, "ok"},
+		{1, "warning"},
+		{2, "critical"},
+		{3, "unknown"},
+		{127, "unknown"}, // commande introuvable
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stateName, func(t *testing.T) {
+			client := ssh.NewMockClientLinux()
+			check := config.CheckConfig{Name: "load", Command: "/usr/lib/nagios/plugins/check_load -w 5 -c 10", Timeout: 5}
+			client.SetResponse(check.Command, "LOAD - load average: 0.10 | load1=0.10;5;10;0")
+			client.SetExitCode(check.Command, tt.code)
+
+			result := RunCheck(client, "srv-1", check)
+
+			assert.Equal(t, "srv-1", result.MachineID)
+			assert.Equal(t, "load", result.Name)
+			assert.Equal(t, tt.stateName, result.StateName)
+			assert.Equal(t, "LOAD - load average: 0.10", result.Output)
+			require.Len(t, result.PerfData, 1)
+			assert.Equal(t, 0.10, result.PerfData[0].Value)
+		})
+	}
+}
+
+func TestRunCheck_Errors(t *testing.T) {
+	check := config.CheckConfig{Name: "slow", Command: "sleep 60", Timeout: 1}
+
+	client := ssh.NewMockClientLinux()
+	client.SetError(check.Command, ssh.ErrCommandTimeout)
+	result := RunCheck(client, "srv-1", check)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Contains(t, result.Output, "CRITICAL")
+
+	client = ssh.NewMockClientLinux()
+	client.SetError(check.Command, errors.New("connection refused"))
+	result = RunCheck(client, "srv-1", check)
+	assert.Equal(t, CheckUnknown, result.State)
+	assert.Contains(t, result.Output, "connection refused")
+}
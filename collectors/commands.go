@@ -0,0 +1,57 @@
+package collectors
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-monitoring/models"
+	"go-monitoring/ssh"
+)
+
+// CommandOutputLimit est la taille maximale conservée de stdout et de stderr par machine
+const CommandOutputLimit = 64 * 1024
+
+// RunCommand exécute une commande sur une machine et retourne sa sortie et son code de sortie.
+// Une erreur de connexion ou un dépassement de délai est reporté dans le résultat (Error).
+func RunCommand(client ssh.SSHExecutor, machineID, cmd string, timeout time.Duration) models.CommandHostResult {
+	result := models.CommandHostResult{MachineID: machineID, ExitCode: -1}
+
+	start := time.Now()
+	res, err := client.ExecuteWithResult(cmd, timeout)
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	switch {
+	case errors.Is(err, ssh.ErrCommandTimeout):
+		result.Error = "délai d'exécution dépassé (" + timeout.String() + ")"
+	case err != nil:
+		result.Error = err.Error()
+	}
+	if res == nil {
+		return result
+	}
+
+	var truncOut, truncErr bool
+	result.Stdout, truncOut = truncateOutput(res.Stdout, CommandOutputLimit)
+	result.Stderr, truncErr = truncateOutput(res.Stderr, CommandOutputLimit)
+	result.Truncated = truncOut || truncErr
+	if err == nil {
+		result.ExitCode = res.ExitCode
+	}
+	return result
+}
+
+// truncateOutput limite une sortie à max octets (sans couper un caractère UTF-8)
+// et remplace les séquences invalides
+func truncateOutput(s string, max int) (string, bool) {
+	truncated := false
+	if len(s) > max {
+		cut := max
+		for cut > max-utf8.UTFMax && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s, truncated = s[:cut], true
+	}
+	return strings.ToValidUTF8(s, "�"), truncated
+}
@@ -0,0 +1,58 @@
+package collectors
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommand(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	client.SetResponse("uptime", " 10:00:00 up 3 days,  1 user,  load average: 0.00, 0.01, 0.05\n")
+	client.SetResponse("rpm -q openssl", "package openssl is not installed\n")
+	client.SetExitCode("rpm -q openssl", 1)
+	client.SetError("sleep 60", ssh.ErrCommandTimeout)
+	client.SetError("hostname", errors.New("connexion refusée"))
+
+	res := RunCommand(client, "srv-1", "uptime", time.Second)
+	assert.Equal(t, "srv-1", res.MachineID)
+	assert.Equal(t, 0, res.ExitCode)
+	assert.Contains(t, res.Stdout, "load average")
+	assert.Empty(t, res.Error)
+
+	// Un code de sortie non nul n'est pas une erreur d'exécution
+	res = RunCommand(client, "srv-1", "rpm -q openssl", time.Second)
+	assert.Equal(t, 1, res.ExitCode)
+	assert.Empty(t, res.Error)
+
+	res = RunCommand(client, "srv-1", "sleep 60", time.Second)
+	assert.Equal(t, -1, res.ExitCode)
+	assert.Contains(t, res.Error, "délai")
+
+	res = RunCommand(client, "srv-1", "hostname", time.Second)
+	assert.Equal(t, -1, res.ExitCode)
+	assert.Equal(t, "connexion refusée", res.Error)
+}
+
+func TestRunCommand_TruncatesOutput(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	// Caractère multi-octets à cheval sur la limite
+	client.SetResponse("cat big", strings.Repeat("a", CommandOutputLimit-1)+"é"+strings.Repeat("b", 100))
+
+	res := RunCommand(client, "srv-1", "cat big", time.Second)
+	assert.True(t, res.Truncated)
+	assert.Equal(t, CommandOutputLimit-1, len(res.Stdout))
+	assert.True(t, utf8.ValidString(res.Stdout))
+}
+
+func TestTruncateOutput_InvalidUTF8(t *testing.T) {
+	out, truncated := truncateOutput("ok\xff\xfe", 1024)
+	assert.False(t, truncated)
+	assert.Equal(t, "ok�", out)
+}
@@ -5,10 +5,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
 	"go-monitoring/models"
-	"go-monitoring/pkg/security"
 	"go-monitoring/ssh"
 )
 
@@ -158,108 +156,6 @@ func detectDriveType(client ssh.SSHExecutor, device string) string {
 	return "Unknown"
 }
 
-// BrowseDirectory liste le contenu d'un répertoire via SSH
-func BrowseDirectory(client ssh.SSHExecutor, path string) (*models.DirectoryListing, error) {
-	// SÉCURITÉ: Valider le chemin pour éviter path traversal et injection de commandes
-	if err := security.ValidatePath(path); err != nil {
-		return nil, fmt.Errorf("chemin invalide: %w", err)
-	}
-
-	cleanPath := filepath.Clean(path)
-
-	listing := &models.DirectoryListing{
-		Path:   cleanPath,
-		Parent: filepath.Dir(cleanPath),
-	}
-
-	// Utiliser ls -la pour obtenir les détails
-	cmd := fmt.Sprintf("ls -la %q 2>/dev/null", cleanPath)
-	output, err := client.Execute(cmd)
-	if err != nil {
-		return nil, fmt.Errorf("erreur lecture répertoire: %w", err)
-	}
-
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	for i, line := range lines {
-		// Ignorer la première ligne (total)
-		if i == 0 && strings.HasPrefix(line, "total") {
-			continue
-		}
-
-		entry := parseLsLine(line, cleanPath)
-		if entry != nil && entry.Name != "." && entry.Name != ".." {
-			listing.Entries = append(listing.Entries, *entry)
-		}
-	}
-
-	return listing, nil
-}
-
-// parseLsLine parse une ligne de sortie ls -la
-func parseLsLine(line, basePath string) *models.DirectoryEntry {
-	// Format: drwxr-xr-x 2 owner group size date time name
-	fields := strings.Fields(line)
-	if len(fields) < 9 {
-		return nil
-	}
-
-	entry := &models.DirectoryEntry{
-		Permissions: fields[0],
-		Owner:       fields[2],
-		Group:       fields[3],
-		IsDir:       strings.HasPrefix(fields[0], "d"),
-	}
-
-	// Détecter si c'est un fichier périphérique (block ou char)
-	// Ils ont major, minor au lieu de size
-	// brw-rw---- 1 root disk 8, 0 Jan 15 10:30 sda
-	isDevice := strings.HasPrefix(fields[0], "b") || strings.HasPrefix(fields[0], "c")
-
-	if isDevice {
-		// Pour les périphériques, ls affiche major, minor
-		// Cela décale les champs suivants de 1
-		if len(fields) < 10 {
-			return nil
-		}
-
-		entry.Size = 0 // Taille non applicable ou 0
-
-		// Nom commence après la date
-		entry.Name = strings.Join(fields[9:], " ")
-		entry.Path = filepath.Join(basePath, entry.Name)
-
-		// Date de modification (indices 6, 7, 8)
-		dateStr := strings.Join(fields[6:9], " ")
-		t, err := time.Parse("Jan 2 15:04", dateStr)
-		if err != nil {
-			t, _ = time.Parse("Jan 2 2006", dateStr)
-		}
-		if !t.IsZero() {
-			entry.ModTime = t
-		}
-	} else {
-		// Fichier normal
-		size, _ := strconv.ParseInt(fields[4], 10, 64)
-		entry.Size = size
-
-		// Nom (peut contenir des espaces)
-		entry.Name = strings.Join(fields[8:], " ")
-		entry.Path = filepath.Join(basePath, entry.Name)
-
-		// Date de modification (indices 5, 6, 7)
-		dateStr := strings.Join(fields[5:8], " ")
-		t, err := time.Parse("Jan 2 15:04", dateStr)
-		if err != nil {
-			t, _ = time.Parse("Jan 2 2006", dateStr)
-		}
-		if !t.IsZero() {
-			entry.ModTime = t
-		}
-	}
-
-	return entry
-}
-
 // GetDiskDetails retourne les détails d'un disque spécifique
 // osType: "linux", "windows" ou vide (défaut Linux)
 func GetDiskDetails(client ssh.SSHExecutor, mountPoint string, osType string) (*models.DiskInfo, []models.Partition, error) {
@@ -60,161 +60,6 @@ func TestIsVirtualFS(t *testing.T) {
 	}
 }
 
-func TestParseLsLine(t *testing.T) {
-	tests := []struct {
-		name        string
-		input       string
-		basePath    string
-		expectNil   bool
-		expectedName string
-		expectedSize int64
-		expectedIsDir bool
-		expectedPerms string
-	}{
-		{
-			name:        "directory",
-			input:       "drwxr-xr-x 5 root root 4096 Jan 15 10:30 etc",
-			basePath:    "/",
-			expectNil:   false,
-			expectedName: "etc",
-			expectedSize: 4096,
-			expectedIsDir: true,
-			expectedPerms: "drwxr-xr-x",
-		},
-		{
-			name:        "regular file",
-			input:       "-rw-r--r-- 1 user group 1234567 Dec 25 15:45 file.txt",
-			basePath:    "/home/user",
-			expectNil:   false,
-			expectedName: "file.txt",
-			expectedSize: 1234567,
-			expectedIsDir: false,
-			expectedPerms: "-rw-r--r--",
-		},
-		{
-			name:        "file with spaces in name",
-			input:       "-rw-r--r-- 1 user group 9999 Nov 3 2023 my document.pdf",
-			basePath:    "/home/user/Documents",
-			expectNil:   false,
-			expectedName: "my document.pdf",
-			expectedSize: 9999,
-			expectedIsDir: false,
-			expectedPerms: "-rw-r--r--",
-		},
-		{
-			name:        "symlink",
-			input:       "lrwxrwxrwx 1 root root 11 Jan 1 00:00 link -> /usr/bin",
-			basePath:    "/usr/local/bin",
-			expectNil:   false,
-			expectedName: "link -> /usr/bin",
-			expectedSize: 11,
-			expectedIsDir: false,
-			expectedPerms: "lrwxrwxrwx",
-		},
-		{
-			name:        "large file",
-			input:       "-rw-r--r-- 1 user group 123456789012 Feb 28 23:59 largefile.iso",
-			basePath:    "/mnt/data",
-			expectNil:   false,
-			expectedName: "largefile.iso",
-			expectedSize: 123456789012,
-			expectedIsDir: false,
-			expectedPerms: "-rw-r--r--",
-		},
-		{
-			name:      "invalid - too few fields",
-			input:     "drwxr-xr-x 5 root",
-			basePath:  "/",
-			expectNil: true,
-		},
-		{
-			name:      "invalid - empty line",
-			input:     "",
-			basePath:  "/",
-			expectNil: true,
-		},
-		{
-			name:      "total line (should be skipped)",
-			input:     "total 48",
-			basePath:  "/",
-			expectNil: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := parseLsLine(tt.input, tt.basePath)
-
-			if tt.expectNil {
-				assert.Nil(t, result, "parseLsLine should return nil for invalid input")
-			} else {
-				assert.NotNil(t, result, "parseLsLine should not return nil")
-				if result != nil {
-					assert.Equal(t, tt.expectedName, result.Name, "Name should match")
-					assert.Equal(t, tt.expectedSize, result.Size, "Size should match")
-					assert.Equal(t, tt.expectedIsDir, result.IsDir, "IsDir should match")
-					assert.Equal(t, tt.expectedPerms, result.Permissions, "Permissions should match")
-				}
-			}
-		})
-	}
-}
-
-func TestParseLsLine_SpecialCases(t *testing.T) {
-	t.Run("current directory (.)", func(t *testing.T) {
-		input := "drwxr-xr-x 5 root root 4096 Jan 15 10:30 ."
-		result := parseLsLine(input, "/home/user")
-		// La fonction retourne l'entry mais le caller doit la filtrer
-		assert.NotNil(t, result)
-		assert.Equal(t, ".", result.Name)
-	})
-
-	t.Run("parent directory (..)", func(t *testing.T) {
-		input := "drwxr-xr-x 5 root root 4096 Jan 15 10:30 .."
-		result := parseLsLine(input, "/home/user")
-		assert.NotNil(t, result)
-		assert.Equal(t, "..", result.Name)
-	})
-
-	t.Run("hidden file", func(t *testing.T) {
-		input := "-rw-r--r-- 1 user group 1234 Jan 15 10:30 .bashrc"
-		result := parseLsLine(input, "/home/user")
-		assert.NotNil(t, result)
-		assert.Equal(t, ".bashrc", result.Name)
-		assert.False(t, result.IsDir)
-	})
-
-	t.Run("file with unicode name", func(t *testing.T) {
-		input := "-rw-r--r-- 1 user group 1234 Jan 15 10:30 fichier_français_日本語.txt"
-		result := parseLsLine(input, "/home/user")
-		assert.NotNil(t, result)
-		assert.Equal(t, "fichier_français_日本語.txt", result.Name)
-	})
-
-	t.Run("zero size file", func(t *testing.T) {
-		input := "-rw-r--r-- 1 user group 0 Jan 15 10:30 empty.txt"
-		result := parseLsLine(input, "/tmp")
-		assert.NotNil(t, result)
-		assert.Equal(t, int64(0), result.Size)
-	})
-
-	t.Run("executable file", func(t *testing.T) {
-		input := "-rwxr-xr-x 1 root root 54321 Jan 15 10:30 script.sh"
-		result := parseLsLine(input, "/usr/local/bin")
-		assert.NotNil(t, result)
-		assert.Equal(t, "script.sh", result.Name)
-		assert.Contains(t, result.Permissions, "x", "Should have execute permission")
-	})
-
-	t.Run("device file", func(t *testing.T) {
-		input := "brw-rw---- 1 root disk 8, 0 Jan 15 10:30 sda"
-		result := parseLsLine(input, "/dev")
-		assert.NotNil(t, result)
-		assert.Equal(t, "sda", result.Name)
-		assert.True(t, result.Permissions[0] == 'b', "Should be block device")
-	})
-}
-
 func TestDetectDriveType_DiskName(t *testing.T) {
 	// Test de la logique d'extraction du nom de disque
 	tests := []struct {
@@ -294,24 +139,6 @@ func BenchmarkIsVirtualFS(b *testing.B) {
 	}
 }
 
-func BenchmarkParseLsLine(b *testing.B) {
-	input := "-rw-r--r-- 1 user group 1234567 Dec 25 15:45 file.txt"
-	basePath := "/home/user"
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		parseLsLine(input, basePath)
-	}
-}
-
-func BenchmarkParseLsLine_ComplexName(b *testing.B) {
-	input := "-rw-r--r-- 1 user group 9999 Nov 3 2023 my document with many words.pdf"
-	basePath := "/home/user/Documents"
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		parseLsLine(input, basePath)
-	}
-}
-
 // Tests de sécurité pour BrowseDirectory
 func TestBrowseDirectory_Security(t *testing.T) {
 	// Ces tests vérifient que la validation de sécurité fonctionne
@@ -0,0 +1,307 @@
+package collectors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-monitoring/models"
+	"go-monitoring/pkg/security"
+	"go-monitoring/ssh"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// Bornes de l'analyse d'occupation disque
+const (
+	DefaultDiskUsageDepth   = 3
+	MaxDiskUsageDepth       = 6
+	DefaultDiskUsageTimeout = 60 * time.Second
+	// Au-delà, les plus petits sous-répertoires sont regroupés (OtherSize)
+	diskUsageMaxChildren = 30
+)
+
+// AnalyzeDiskUsage mesure l'occupation des sous-répertoires de root jusqu'à depth niveaux,
+// sans changer de système de fichiers (du -x ; points de jonction ignorés sous Windows).
+// Si le délai est dépassé, le rapport est partiel mais reste exploitable.
+func AnalyzeDiskUsage(client ssh.SSHExecutor, root, osType string, depth int, timeout time.Duration) (*models.DiskUsageReport, error) {
+	depth = clampDiskUsageDepth(depth)
+	if timeout <= 0 {
+		timeout = DefaultDiskUsageTimeout
+	}
+
+	cleanRoot, err := CleanRemotePath(root)
+	if err != nil {
+		return nil, fmt.Errorf("chemin invalide: %w", err)
+	}
+
+	var cmd string
+	var unit int64
+	if osType == "windows" {
+		// /C:/Users -> C:\Users
+		winRoot := strings.ReplaceAll(strings.TrimPrefix(cleanRoot, "/"), "/", "\\")
+		if !windowsDriveRegex.MatchString(winRoot) {
+			return nil, fmt.Errorf("chemin Windows attendu (ex: C:\\)")
+		}
+		if strings.HasSuffix(winRoot, ":") {
+			winRoot += "\\"
+		}
+		cmd = security.PowerShellEncodedCommand(diskUsagePowerShellScript(winRoot, depth, timeout))
+		unit = 1
+	} else {
+		cmd = fmt.Sprintf("timeout %d du -x -k -d %d %s 2>/dev/null", int(timeout.Seconds()), depth, security.ShellLiteral(cleanRoot))
+		unit = 1024
+	}
+
+	start := time.Now()
+	res, err := client.ExecuteWithResult(cmd, timeout+10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, partial := parseDiskUsageOutput(res.Stdout, unit)
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("analyse impossible (code %d): %s", res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+
+	treeRoot := normalizeUsagePath(cleanRoot)
+	if osType == "windows" {
+		treeRoot = normalizeUsagePath(strings.TrimPrefix(cleanRoot, "/"))
+	}
+
+	report := &models.DiskUsageReport{
+		Root:        treeRoot,
+		Depth:       depth,
+		GeneratedAt: time.Now(),
+		Duration:    time.Since(start),
+	}
+	switch {
+	case partial || res.ExitCode == 124:
+		report.Partial = true
+		report.Warning = "Délai dépassé : résultats partiels"
+	case res.ExitCode != 0:
+		report.Warning = "Certains répertoires n'ont pas pu être lus"
+	}
+
+	report.Tree = buildDiskUsageTree(treeRoot, sizes)
+	return report, nil
+}
+
+// diskUsagePowerShellScript reproduit du -x -d : taille cumulée des répertoires jusqu'à la profondeur
+// demandée, sans suivre les points de jonction, en respectant une échéance.
+func diskUsagePowerShellScript(root string, depth int, timeout time.Duration) string {
+	return "$ErrorActionPreference = 'SilentlyContinue'\n" +
+		fmt.Sprintf("$deadline = (Get-Date).AddSeconds(%d)\n", int(timeout.Seconds())) +
+		fmt.Sprintf("$maxDepth = %d\n", depth) +
+		"$script:partial = $false\n" +
+		"$out = New-Object System.Collections.Generic.List[string]\n" +
+		"function Measure-Dir([string]$p, [int]$d) {\n" +
+		"  $size = [int64]0\n" +
+		"  foreach ($i in Get-ChildItem -LiteralPath $p -Force) {\n" +
+		"    if ((Get-Date) -gt $deadline) { $script:partial = $true; break }\n" +
+		"    if ($i.PSIsContainer) {\n" +
+		"      if ($i.Attributes -band [IO.FileAttributes]::ReparsePoint) { continue }\n" +
+		"      $size += Measure-Dir $i.FullName ($d + 1)\n" +
+		"    } else { $size += $i.Length }\n" +
+		"  }\n" +
+		"  if ($d -le $maxDepth) { $out.Add(\"$size`t$p\") }\n" +
+		"  return $size\n" +
+		"}\n" +
+		"$null = Measure-Dir " + security.PowerShellLiteral(root) + " 0\n" +
+		"$out\n" +
+		"if ($script:partial) { 'PARTIAL' }\n"
+}
+
+// parseDiskUsageOutput lit les lignes "<taille>\t<chemin>" (du ou script PowerShell).
+// unit convertit la taille en octets (1024 pour du -k).
+func parseDiskUsageOutput(output string, unit int64) (map[string]int64, bool) {
+	sizes := make(map[string]int64)
+	partial := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "PARTIAL" {
+			partial = true
+			continue
+		}
+		sizeStr, p, ok := strings.Cut(line, "\t")
+		if !ok || p == "" {
+			continue
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 10, 64)
+		if err != nil {
+			continue
+		}
+		sizes[normalizeUsagePath(p)] = size * unit
+	}
+	return sizes, partial
+}
+
+// normalizeUsagePath ramène un chemin Unix ou Windows à une forme comparable (séparateurs /)
+func normalizeUsagePath(p string) string {
+	return path.Clean(strings.ReplaceAll(p, "\\", "/"))
+}
+
+// buildDiskUsageTree construit l'arbre trié (plus gros d'abord) à partir des tailles par chemin.
+// Les répertoires intermédiaires absents (analyse interrompue) sont recréés.
+func buildDiskUsageTree(root string, sizes map[string]int64) *models.DiskUsageNode {
+	nodes := map[string]*models.DiskUsageNode{
+		root: {Name: root, Path: root, Size: sizes[root]},
+	}
+
+	var getNode func(p string) *models.DiskUsageNode
+	getNode = func(p string) *models.DiskUsageNode {
+		if n, ok := nodes[p]; ok {
+			return n
+		}
+		n := &models.DiskUsageNode{Name: path.Base(p), Path: p, Size: sizes[p]}
+		nodes[p] = n
+		if parent := path.Dir(p); parent != p {
+			pn := getNode(parent)
+			pn.Children = append(pn.Children, n)
+		}
+		return n
+	}
+
+	for p := range sizes {
+		if p != root && isUnderRoot(p, root) {
+			getNode(p)
+		}
+	}
+
+	finalizeDiskUsageNode(nodes[root])
+	return nodes[root]
+}
+
+func isUnderRoot(p, root string) bool {
+	if root == "/" {
+		return strings.HasPrefix(p, "/")
+	}
+	return strings.HasPrefix(p, root+"/")
+}
+
+// finalizeDiskUsageNode corrige les tailles incomplètes, trie et regroupe les petits répertoires
+func finalizeDiskUsageNode(n *models.DiskUsageNode) {
+	var sum int64
+	for _, c := range n.Children {
+		finalizeDiskUsageNode(c)
+		sum += c.Size
+	}
+	if n.Size < sum {
+		n.Size = sum
+	}
+
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].Size != n.Children[j].Size {
+			return n.Children[i].Size > n.Children[j].Size
+		}
+		return n.Children[i].Name < n.Children[j].Name
+	})
+
+	if len(n.Children) > diskUsageMaxChildren {
+		for _, c := range n.Children[diskUsageMaxChildren:] {
+			n.OtherSize += c.Size
+		}
+		n.OtherCount = len(n.Children) - diskUsageMaxChildren
+		n.Children = n.Children[:diskUsageMaxChildren]
+	}
+}
+
+func clampDiskUsageDepth(depth int) int {
+	if depth <= 0 {
+		return DefaultDiskUsageDepth
+	}
+	if depth > MaxDiskUsageDepth {
+		return MaxDiskUsageDepth
+	}
+	return depth
+}
+
+// AnalyzeLocalDiskUsage est l'équivalent local d'AnalyzeDiskUsage (taille apparente des fichiers).
+// Les autres points de montage et les liens symboliques ne sont pas parcourus.
+func AnalyzeLocalDiskUsage(root string, depth int, timeout time.Duration) (*models.DiskUsageReport, error) {
+	depth = clampDiskUsageDepth(depth)
+	if timeout <= 0 {
+		timeout = DefaultDiskUsageTimeout
+	}
+
+	cleanRoot := filepath.Clean(root)
+	if strings.Contains(root, "..") {
+		return nil, fmt.Errorf("chemin invalide")
+	}
+	if info, err := os.Stat(cleanRoot); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s n'est pas un répertoire", cleanRoot)
+	}
+
+	// Équivalent de du -x : ne pas descendre dans les autres systèmes de fichiers
+	mounts := make(map[string]bool)
+	if partitions, err := disk.Partitions(true); err == nil {
+		for _, p := range partitions {
+			mounts[filepath.Clean(p.Mountpoint)] = true
+		}
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	sizes := make(map[string]int64)
+	partial, unreadable := false, false
+
+	var walk func(dir string, d int) int64
+	walk = func(dir string, d int) int64 {
+		var size int64
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			unreadable = true
+		}
+		for _, e := range entries {
+			if time.Now().After(deadline) {
+				partial = true
+				break
+			}
+			info, err := e.Info()
+			if err != nil || info.Mode()&os.ModeSymlink != 0 {
+				continue
+			}
+			full := filepath.Join(dir, e.Name())
+			if e.IsDir() {
+				if mounts[full] {
+					continue
+				}
+				size += walk(full, d+1)
+			} else {
+				size += info.Size()
+			}
+		}
+		if d <= depth {
+			sizes[normalizeUsagePath(dir)] = size
+		}
+		return size
+	}
+	walk(cleanRoot, 0)
+
+	report := &models.DiskUsageReport{
+		Root:        normalizeUsagePath(cleanRoot),
+		Depth:       depth,
+		GeneratedAt: time.Now(),
+		Duration:    time.Since(start),
+		Partial:     partial,
+		Tree:        buildDiskUsageTree(normalizeUsagePath(cleanRoot), sizes),
+	}
+	switch {
+	case partial:
+		report.Warning = "Délai dépassé : résultats partiels"
+	case unreadable:
+		report.Warning = "Certains répertoires n'ont pas pu être lus"
+	}
+	return report, nil
+}
@@ -0,0 +1,130 @@
+package collectors
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/pkg/security"
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDiskUsageTree(t *testing.T) {
+	output := "4\t/var/log/nginx\n" +
+		"120\t/var/log\n" +
+		"2048\t/var/lib/docker/overlay2\n" +
+		"3000\t/var/lib\n" +
+		"3200\t/var\n"
+	sizes, partial := parseDiskUsageOutput(output, 1024)
+	assert.False(t, partial)
+
+	tree := buildDiskUsageTree("/var", sizes)
+	assert.Equal(t, "/var", tree.Path)
+	assert.Equal(t, int64(3200*1024), tree.Size)
+	require.Len(t, tree.Children, 2)
+
+	// Trié du plus gros au plus petit
+	assert.Equal(t, "lib", tree.Children[0].Name)
+	assert.Equal(t, "/var/lib", tree.Children[0].Path)
+	assert.Equal(t, "log", tree.Children[1].Name)
+
+	// /var/lib/docker n'a pas été mesuré (analyse interrompue) : recréé d'après ses enfants
+	docker := tree.Children[0].Children[0]
+	assert.Equal(t, "/var/lib/docker", docker.Path)
+	assert.Equal(t, int64(2048*1024), docker.Size)
+}
+
+func TestBuildDiskUsageTree_GroupsSmallDirectories(t *testing.T) {
+	sizes := map[string]int64{"/data": 0}
+	for i := 0; i < diskUsageMaxChildren+5; i++ {
+		sizes[fmt.Sprintf("/data/d%02d", i)] = int64(100 - i)
+	}
+
+	tree := buildDiskUsageTree("/data", sizes)
+	assert.Len(t, tree.Children, diskUsageMaxChildren)
+	assert.Equal(t, 5, tree.OtherCount)
+	assert.Equal(t, int64(100-30+100-31+100-32+100-33+100-34), tree.OtherSize)
+	assert.Equal(t, "d00", tree.Children[0].Name)
+}
+
+func TestAnalyzeDiskUsage_Linux(t *testing.T) {
+	for _, bin := range []string{"du", "timeout"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skip(bin + " indisponible")
+		}
+	}
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "gros", "sous"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "petit"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gros", "sous", "data.bin"), make([]byte, 512*1024), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "petit", "a.txt"), []byte("a"), 0644))
+
+	executor := &shellExecutor{MockClient: ssh.NewMockClientLinux(), from: "\x00", to: ""}
+	report, err := AnalyzeDiskUsage(executor, root, "linux", 1, 10*time.Second)
+	require.NoError(t, err)
+
+	assert.Equal(t, root, report.Root)
+	assert.Equal(t, 1, report.Depth)
+	assert.False(t, report.Partial)
+	require.Len(t, report.Tree.Children, 2)
+	assert.Equal(t, "gros", report.Tree.Children[0].Name)
+	assert.GreaterOrEqual(t, report.Tree.Children[0].Size, int64(512*1024))
+	// Profondeur 1 : les sous-répertoires de "gros" ne sont pas détaillés
+	assert.Empty(t, report.Tree.Children[0].Children)
+
+	_, err = AnalyzeDiskUsage(executor, "/var/../etc", "linux", 1, time.Second)
+	assert.Error(t, err)
+}
+
+func TestAnalyzeDiskUsage_Windows(t *testing.T) {
+	client := ssh.NewMockClientWindows()
+	script := diskUsagePowerShellScript(`C:\`, 2, DefaultDiskUsageTimeout)
+	client.SetResponse(security.PowerShellEncodedCommand(script),
+		"1000\tC:\\Windows\\Temp\r\n"+
+			"5000\tC:\\Windows\r\n"+
+			"800\tC:\\Users\r\n"+
+			"6000\tC:\\\r\n"+
+			"PARTIAL\r\n")
+
+	report, err := AnalyzeDiskUsage(client, "C:", "windows", 2, 0)
+	require.NoError(t, err)
+	assert.True(t, report.Partial)
+	assert.Equal(t, "C:", report.Root)
+	assert.Equal(t, int64(6000), report.Tree.Size)
+	require.Len(t, report.Tree.Children, 2)
+	assert.Equal(t, "C:/Windows", report.Tree.Children[0].Path)
+	assert.Equal(t, "C:/Windows/Temp", report.Tree.Children[0].Children[0].Path)
+
+	// La racine n'apparaît jamais en clair dans la commande
+	require.Len(t, client.ExecutedCommands, 1)
+	assert.False(t, strings.Contains(client.ExecutedCommands[0], `C:\`))
+
+	_, err = AnalyzeDiskUsage(client, "/var/log", "windows", 2, 0)
+	assert.Error(t, err)
+}
+
+func TestAnalyzeLocalDiskUsage(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "c", "f"), make([]byte, 1000), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "top"), make([]byte, 10), 0644))
+	require.NoError(t, os.Symlink("/", filepath.Join(root, "lien")))
+
+	report, err := AnalyzeLocalDiskUsage(root, 2, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1010), report.Tree.Size)
+	require.Len(t, report.Tree.Children, 1)
+
+	b := report.Tree.Children[0].Children[0]
+	assert.Equal(t, "b", b.Name)
+	assert.Equal(t, int64(1000), b.Size)
+	assert.Empty(t, b.Children, "profondeur maximale atteinte")
+}
@@ -0,0 +1,380 @@
+package collectors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-monitoring/models"
+
+	"github.com/pkg/sftp"
+)
+
+// Limites par défaut des transferts de fichiers
+const (
+	DefaultPreviewMaxSize  = 256 * 1024        // Aperçu texte
+	DefaultDownloadMaxSize = 100 * 1024 * 1024 // Téléchargement
+	DefaultUploadMaxSize   = 20 * 1024 * 1024  // Envoi
+)
+
+var (
+	ErrFileTooLarge = errors.New("fichier trop volumineux")
+	ErrBinaryFile   = errors.New("fichier binaire")
+	ErrNotRegular   = errors.New("pas un fichier régulier")
+	ErrFileExists   = errors.New("le fichier existe déjà")
+)
+
+// FileStore abstrait l'accès aux fichiers d'une machine (SFTP distant ou disque local)
+type FileStore interface {
+	ReadDir(p string) ([]os.FileInfo, error)
+	Stat(p string) (os.FileInfo, error)
+	Open(p string) (io.ReadCloser, error)
+	// Create crée un nouveau fichier en écriture (échoue s'il existe déjà)
+	Create(p string) (io.WriteCloser, error)
+	// Rename renomme un fichier en remplaçant la destination si elle existe
+	Rename(oldPath, newPath string) error
+	Remove(p string) error
+	Close() error
+}
+
+// SFTPStore implémente FileStore au-dessus d'un client SFTP
+type SFTPStore struct {
+	Client *sftp.Client
+}
+
+func (s SFTPStore) ReadDir(p string) ([]os.FileInfo, error) { return s.Client.ReadDir(p) }
+func (s SFTPStore) Stat(p string) (os.FileInfo, error)      { return s.Client.Stat(p) }
+func (s SFTPStore) Open(p string) (io.ReadCloser, error)    { return s.Client.Open(p) }
+func (s SFTPStore) Remove(p string) error                   { return s.Client.Remove(p) }
+func (s SFTPStore) Close() error                            { return s.Client.Close() }
+
+func (s SFTPStore) Create(p string) (io.WriteCloser, error) {
+	return s.Client.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+}
+
+func (s SFTPStore) Rename(oldPath, newPath string) error {
+	// Le renommage SFTP standard refuse d'écraser ; l'extension POSIX n'existe pas sous Windows
+	if err := s.Client.PosixRename(oldPath, newPath); err == nil {
+		return nil
+	}
+	if _, err := s.Client.Stat(newPath); err == nil {
+		if err := s.Client.Remove(newPath); err != nil {
+			return err
+		}
+	}
+	return s.Client.Rename(oldPath, newPath)
+}
+
+// LocalStore implémente FileStore sur le système de fichiers du serveur (machine locale)
+type LocalStore struct{}
+
+// localPath convertit un chemin au format SFTP (/C:/Users) en chemin natif
+func localPath(p string) string {
+	if runtime.GOOS == "windows" && windowsDriveRegex.MatchString(p) {
+		return filepath.FromSlash(strings.TrimPrefix(p, "/"))
+	}
+	return filepath.FromSlash(p)
+}
+
+func (LocalStore) ReadDir(p string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(localPath(p))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func (LocalStore) Stat(p string) (os.FileInfo, error)   { return os.Stat(localPath(p)) }
+func (LocalStore) Open(p string) (io.ReadCloser, error) { return os.Open(localPath(p)) }
+func (LocalStore) Remove(p string) error                { return os.Remove(localPath(p)) }
+func (LocalStore) Close() error                         { return nil }
+
+func (LocalStore) Create(p string) (io.WriteCloser, error) {
+	return os.OpenFile(localPath(p), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+}
+
+func (LocalStore) Rename(oldPath, newPath string) error {
+	return os.Rename(localPath(oldPath), localPath(newPath))
+}
+
+// windowsDriveRegex détecte un chemin Windows commençant par une lettre de lecteur (C:, /C:)
+var windowsDriveRegex = regexp.MustCompile(`^/?[A-Za-z]:`)
+
+// CleanRemotePath normalise un chemin distant au format SFTP.
+// Les chemins Windows (C:\Users, C:/Users) deviennent /C:/Users, comme l'attend OpenSSH pour Windows.
+func CleanRemotePath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("chemin vide")
+	}
+	if strings.ContainsAny(p, "\x00\n\r") {
+		return "", fmt.Errorf("caractère interdit dans le chemin")
+	}
+
+	p = strings.ReplaceAll(p, "\\", "/")
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("path traversal interdit")
+		}
+	}
+
+	if windowsDriveRegex.MatchString(p) && !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	if !path.IsAbs(p) {
+		return "", fmt.Errorf("le chemin doit être absolu")
+	}
+
+	p = path.Clean(p)
+	// Racine d'un lecteur : /C: -> /C:/
+	if windowsDriveRegex.MatchString(p) && len(p) == 3 {
+		p += "/"
+	}
+	return p, nil
+}
+
+// BrowseDirectory liste le contenu d'un répertoire distant à partir des attributs SFTP.
+// Les dates sont complètes (année incluse) et indépendantes de la locale de la machine.
+func BrowseDirectory(fs FileStore, dir string) (*models.DirectoryListing, error) {
+	cleanPath, err := CleanRemotePath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("chemin invalide: %w", err)
+	}
+
+	infos, err := fs.ReadDir(cleanPath)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lecture répertoire: %w", err)
+	}
+
+	listing := &models.DirectoryListing{
+		Path:    cleanPath,
+		Parent:  path.Dir(strings.TrimSuffix(cleanPath, "/")),
+		Entries: make([]models.DirectoryEntry, 0, len(infos)),
+	}
+	if cleanPath == "/" {
+		listing.Parent = "/"
+	}
+
+	for _, info := range infos {
+		if info.Name() == "." || info.Name() == ".." {
+			continue
+		}
+		listing.Entries = append(listing.Entries, fileInfoToEntry(info, path.Join(cleanPath, info.Name())))
+	}
+
+	return listing, nil
+}
+
+// fileInfoToEntry convertit les attributs d'un fichier en entrée de listing
+func fileInfoToEntry(info os.FileInfo, fullPath string) models.DirectoryEntry {
+	entry := models.DirectoryEntry{
+		Name:        info.Name(),
+		Path:        fullPath,
+		IsDir:       info.IsDir(),
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Permissions: info.Mode().String(),
+		Owner:       "-",
+		Group:       "-",
+	}
+	if entry.IsDir {
+		entry.Size = 0
+	}
+
+	// SFTP ne transmet que les identifiants numériques
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		entry.Owner = strconv.FormatUint(uint64(stat.UID), 10)
+		entry.Group = strconv.FormatUint(uint64(stat.GID), 10)
+	}
+	return entry
+}
+
+// OpenFile ouvre un fichier régulier en lecture après contrôle de sa taille
+func OpenFile(fs FileStore, p string, maxSize int64) (io.ReadCloser, os.FileInfo, error) {
+	cleanPath, err := CleanRemotePath(p)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chemin invalide: %w", err)
+	}
+
+	info, err := fs.Stat(cleanPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, ErrNotRegular
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return nil, nil, fmt.Errorf("%w (%d octets, limite %d)", ErrFileTooLarge, info.Size(), maxSize)
+	}
+
+	f, err := fs.Open(cleanPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// PreviewFile lit un petit fichier texte et détecte son langage pour la coloration
+func PreviewFile(fs FileStore, p string, maxSize int64) (*models.FilePreview, error) {
+	f, info, err := OpenFile(fs, p, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Le fichier a pu grossir depuis le Stat
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("erreur lecture fichier: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	if isBinary(data) {
+		return nil, ErrBinaryFile
+	}
+
+	cleanPath, _ := CleanRemotePath(p)
+	return &models.FilePreview{
+		Path:     cleanPath,
+		Name:     info.Name(),
+		Size:     int64(len(data)),
+		ModTime:  info.ModTime(),
+		Language: DetectLanguage(info.Name()),
+		Content:  string(data),
+	}, nil
+}
+
+// isBinary considère binaire un contenu avec un octet nul ou de l'UTF-8 invalide
+func isBinary(data []byte) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(data)
+}
+
+// languageByExtension associe une extension à un langage de coloration
+var languageByExtension = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".ts":   "typescript",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".toml": "toml",
+	".xml":  "xml",
+	".html": "html",
+	".htm":  "html",
+	".css":  "css",
+	".sh":   "bash",
+	".bash": "bash",
+	".ps1":  "powershell",
+	".psm1": "powershell",
+	".bat":  "batch",
+	".cmd":  "batch",
+	".sql":  "sql",
+	".md":   "markdown",
+	".c":    "c",
+	".h":    "c",
+	".cpp":  "cpp",
+	".java": "java",
+	".rb":   "ruby",
+	".php":  "php",
+	".ini":  "ini",
+	".conf": "ini",
+	".cfg":  "ini",
+	".log":  "log",
+}
+
+// languageByName associe les fichiers sans extension significative à un langage
+var languageByName = map[string]string{
+	"dockerfile":  "dockerfile",
+	"makefile":    "makefile",
+	"nginx.conf":  "nginx",
+	".bashrc":     "bash",
+	".profile":    "bash",
+	"crontab":     "bash",
+	"sshd_config": "ini",
+	"hosts":       "plaintext",
+}
+
+// DetectLanguage devine le langage d'un fichier d'après son nom ("plaintext" par défaut)
+func DetectLanguage(name string) string {
+	lower := strings.ToLower(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if lang, ok := languageByName[lower]; ok {
+		return lang
+	}
+	if lang, ok := languageByExtension[path.Ext(lower)]; ok {
+		return lang
+	}
+	return "plaintext"
+}
+
+// UploadFile écrit le contenu de r dans dir/name, sans dépasser maxSize.
+// Le contenu est d'abord écrit dans un fichier temporaire puis renommé : un envoi
+// trop volumineux ou interrompu ne laisse pas de fichier partiel ni n'écrase l'existant.
+func UploadFile(fs FileStore, dir, name string, r io.Reader, maxSize int64, overwrite bool) (string, int64, error) {
+	cleanDir, err := CleanRemotePath(dir)
+	if err != nil {
+		return "", 0, fmt.Errorf("chemin invalide: %w", err)
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return "", 0, fmt.Errorf("nom de fichier invalide")
+	}
+
+	info, err := fs.Stat(cleanDir)
+	if err != nil {
+		return "", 0, err
+	}
+	if !info.IsDir() {
+		return "", 0, fmt.Errorf("%s n'est pas un répertoire", cleanDir)
+	}
+
+	target := path.Join(cleanDir, name)
+	if existing, err := fs.Stat(target); err == nil {
+		if !overwrite {
+			return "", 0, ErrFileExists
+		}
+		if !existing.Mode().IsRegular() {
+			return "", 0, ErrNotRegular
+		}
+	}
+
+	tmp := path.Join(cleanDir, "."+name+".upload")
+	w, err := fs.Create(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+
+	n, err := io.Copy(w, io.LimitReader(r, maxSize+1))
+	closeErr := w.Close()
+	if err == nil && n > maxSize {
+		err = ErrFileTooLarge
+	}
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fs.Rename(tmp, target)
+	}
+	if err != nil {
+		fs.Remove(tmp)
+		return "", 0, err
+	}
+	return target, n, nil
+}
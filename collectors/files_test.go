@@ -0,0 +1,253 @@
+package collectors
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSFTPStore démarre un serveur SFTP en mémoire relié par un pipe
+func newTestSFTPStore(t *testing.T) SFTPStore {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+
+	server := sftp.NewRequestServer(serverConn, sftp.InMemHandler())
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return SFTPStore{Client: client}
+}
+
+// newDiskSFTPStore démarre un serveur SFTP exposant le disque local (attributs complets, dont les dates)
+func newDiskSFTPStore(t *testing.T) SFTPStore {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+
+	server, err := sftp.NewServer(serverConn)
+	require.NoError(t, err)
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return SFTPStore{Client: client}
+}
+
+func writeRemote(t *testing.T, store SFTPStore, p, content string) {
+	t.Helper()
+	f, err := store.Client.Create(p)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestCleanRemotePath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"/var/log", "/var/log", false},
+		{"/var/log/", "/var/log", false},
+		{"/var//log/./nginx", "/var/log/nginx", false},
+		{"/", "/", false},
+		{`C:\Users\admin`, "/C:/Users/admin", false},
+		{"C:/Program Files (x86)", "/C:/Program Files (x86)", false},
+		{"/C:/Windows", "/C:/Windows", false},
+		{"C:", "/C:/", false},
+		{`C:\`, "/C:/", false},
+		{"", "", true},
+		{"relative/path", "", true},
+		{"/var/log/../../etc/shadow", "", true},
+		{`C:\Users\..\Windows`, "", true},
+		{"/tmp/a\nb", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := CleanRemotePath(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestBrowseDirectory_SFTP(t *testing.T) {
+	store := newDiskSFTPStore(t)
+	root := filepath.ToSlash(t.TempDir())
+	require.NoError(t, os.Mkdir(filepath.Join(root, "sub dir"), 0755))
+	filePath := filepath.Join(root, "mon fichier.txt")
+	require.NoError(t, os.WriteFile(filePath, []byte("bonjour"), 0644))
+
+	// Date ancienne : ls -la n'afficherait que l'année, sans l'heure
+	old := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filePath, old, old))
+
+	listing, err := BrowseDirectory(store, root+"/")
+	require.NoError(t, err)
+	assert.Equal(t, root, listing.Path)
+	assert.Equal(t, filepath.ToSlash(filepath.Dir(root)), listing.Parent)
+	require.Len(t, listing.Entries, 2)
+
+	byName := map[string]int{}
+	for i, e := range listing.Entries {
+		byName[e.Name] = i
+	}
+
+	dir := listing.Entries[byName["sub dir"]]
+	assert.True(t, dir.IsDir)
+	assert.Equal(t, root+"/sub dir", dir.Path)
+	assert.True(t, strings.HasPrefix(dir.Permissions, "d"))
+
+	file := listing.Entries[byName["mon fichier.txt"]]
+	assert.False(t, file.IsDir)
+	assert.Equal(t, int64(7), file.Size)
+	assert.Equal(t, root+"/mon fichier.txt", file.Path)
+	assert.True(t, file.ModTime.Equal(old), "la date complète doit être conservée: %v", file.ModTime)
+
+	_, err = BrowseDirectory(store, root+"/../etc")
+	assert.Error(t, err)
+
+	_, err = BrowseDirectory(store, root+"/inexistant")
+	assert.Error(t, err)
+}
+
+func TestOpenFile_SizeLimit(t *testing.T) {
+	store := newTestSFTPStore(t)
+	writeRemote(t, store, "/big.bin", strings.Repeat("x", 100))
+	require.NoError(t, store.Client.Mkdir("/dir"))
+
+	f, info, err := OpenFile(store, "/big.bin", 100)
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	f.Close()
+	require.NoError(t, err)
+	assert.Len(t, data, 100)
+	assert.Equal(t, "big.bin", info.Name())
+
+	_, _, err = OpenFile(store, "/big.bin", 99)
+	assert.True(t, errors.Is(err, ErrFileTooLarge))
+
+	_, _, err = OpenFile(store, "/dir", 0)
+	assert.True(t, errors.Is(err, ErrNotRegular))
+}
+
+func TestPreviewFile(t *testing.T) {
+	store := newTestSFTPStore(t)
+	require.NoError(t, store.Client.Mkdir("/app"))
+	writeRemote(t, store, "/app/main.go", "package main\n")
+	writeRemote(t, store, "/app/blob.dat", "abc\x00def")
+	writeRemote(t, store, "/app/large.log", strings.Repeat("ligne\n", 100))
+
+	preview, err := PreviewFile(store, "/app/main.go", DefaultPreviewMaxSize)
+	require.NoError(t, err)
+	assert.Equal(t, "go", preview.Language)
+	assert.Equal(t, "package main\n", preview.Content)
+	assert.Equal(t, "/app/main.go", preview.Path)
+
+	_, err = PreviewFile(store, "/app/blob.dat", DefaultPreviewMaxSize)
+	assert.True(t, errors.Is(err, ErrBinaryFile))
+
+	_, err = PreviewFile(store, "/app/large.log", 64)
+	assert.True(t, errors.Is(err, ErrFileTooLarge))
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := map[string]string{
+		"main.go":        "go",
+		"config.YML":     "yaml",
+		"Dockerfile":     "dockerfile",
+		"script.ps1":     "powershell",
+		`C:\app\run.bat`: "batch",
+		"nginx.conf":     "nginx",
+		"syslog":         "plaintext",
+		"archive.tar.gz": "plaintext",
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, want, DetectLanguage(name))
+		})
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	store := newTestSFTPStore(t)
+	require.NoError(t, store.Client.Mkdir("/upload"))
+
+	target, n, err := UploadFile(store, "/upload", "notes.txt", strings.NewReader("v1"), 1024, false)
+	require.NoError(t, err)
+	assert.Equal(t, "/upload/notes.txt", target)
+	assert.Equal(t, int64(2), n)
+
+	// Sans écrasement explicite, le fichier existant est conservé
+	_, _, err = UploadFile(store, "/upload", "notes.txt", strings.NewReader("v2"), 1024, false)
+	assert.True(t, errors.Is(err, ErrFileExists))
+
+	_, _, err = UploadFile(store, "/upload", "notes.txt", strings.NewReader("version 3"), 1024, true)
+	require.NoError(t, err)
+	assertRemoteContent(t, store, "/upload/notes.txt", "version 3")
+
+	// Trop volumineux : l'existant reste intact et aucun fichier temporaire ne subsiste
+	_, _, err = UploadFile(store, "/upload", "notes.txt", strings.NewReader(strings.Repeat("x", 20)), 10, true)
+	assert.True(t, errors.Is(err, ErrFileTooLarge))
+	assertRemoteContent(t, store, "/upload/notes.txt", "version 3")
+
+	entries, err := store.Client.ReadDir("/upload")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	for _, name := range []string{"", "..", "a/b", `a\b`} {
+		_, _, err = UploadFile(store, "/upload", name, strings.NewReader("x"), 1024, false)
+		assert.Error(t, err, "nom %q", name)
+	}
+
+	_, _, err = UploadFile(store, "/upload/notes.txt", "x.txt", strings.NewReader("x"), 1024, false)
+	assert.Error(t, err, "la destination doit être un répertoire")
+}
+
+func assertRemoteContent(t *testing.T, store SFTPStore, p, want string) {
+	t.Helper()
+	f, err := store.Open(p)
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(data))
+}
+
+func TestLocalStore_Upload(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "existant.txt"), []byte("ancien"), 0644))
+
+	target, _, err := UploadFile(LocalStore{}, dir, "existant.txt", strings.NewReader("nouveau"), 1024, true)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "nouveau", string(data))
+
+	_, _, err = UploadFile(LocalStore{}, dir, "existant.txt", strings.NewReader("x"), 1024, false)
+	assert.True(t, errors.Is(err, ErrFileExists))
+}
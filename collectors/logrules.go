@@ -0,0 +1,360 @@
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-monitoring/pkg/security"
+	"go-monitoring/ssh"
+)
+
+const (
+	// Volume maximal lu par passage (au-delà, les lignes les plus anciennes sont ignorées)
+	maxLogReadBytes   = 1 << 20
+	maxLogReadEntries = 5000
+	// Nombre et longueur des lignes d'exemple jointes aux alertes
+	maxLogSamples      = 5
+	maxLogSampleLength = 512
+	logReadTimeout     = 30 * time.Second
+)
+
+// Curseur journald: s=...;i=...;b=...;m=...;t=...;x=...
+var journalCursorRegex = regexp.MustCompile(`^[A-Za-z0-9=;_-]{1,512}$`)
+
+// LogBatch contient les lignes écrites depuis le dernier curseur
+type LogBatch struct {
+	Lines   []string
+	Cursor  string // Position à conserver pour la lecture suivante (fichier: "inode:offset", journal: curseur, Docker: horodatage, Windows: RecordId)
+	Skipped bool   // Des lignes ont été ignorées (rotation, volume trop important)
+}
+
+// ReadNewLogLines lit les lignes apparues depuis cursor.
+// Un curseur vide positionne la lecture à la fin de la source, sans retourner de lignes :
+// l'historique existant ne déclenche pas d'alerte.
+func ReadNewLogLines(client ssh.SSHExecutor, source LogSource, cursor string) (LogBatch, error) {
+	switch source.Type {
+	case "file":
+		return readFileSince(client, source, cursor)
+	case "journal":
+		return readJournalSince(client, source, cursor)
+	case "docker":
+		return readDockerSince(client, source, cursor)
+	case "powershell":
+		return readWinEventsSince(client, source, cursor)
+	default:
+		return LogBatch{}, fmt.Errorf("type de log inconnu: %s", source.Type)
+	}
+}
+
+// readFileSince lit un fichier à partir d'un décalage en octets.
+// Un changement d'inode (rotation) ou une taille inférieure au décalage (troncature) relit depuis le début.
+func readFileSince(client ssh.SSHExecutor, source LogSource, cursor string) (LogBatch, error) {
+	if err := validateFileSource(source); err != nil {
+		return LogBatch{}, fmt.Errorf("source de log invalide: %w", err)
+	}
+	path := fmt.Sprintf("%q", source.Path)
+
+	var inode, offset int64 = -1, 0
+	if cursor != "" {
+		i, o, ok := strings.Cut(cursor, ":")
+		var err1, err2 error
+		inode, err1 = strconv.ParseInt(i, 10, 64)
+		offset, err2 = strconv.ParseInt(o, 10, 64)
+		if !ok || err1 != nil || err2 != nil || offset < 0 {
+			return LogBatch{}, fmt.Errorf("curseur fichier invalide: %s", cursor)
+		}
+	}
+
+	var cmd string
+	if inode < 0 {
+		cmd = "stat -c '%i %s' -- " + path
+	} else {
+		// Ligne d'en-tête "inode début fin", puis les octets [début, fin[
+		cmd = fmt.Sprintf(`s=$(stat -c '%%i %%s' -- %s) || exit 2; set -- $s; start=%d; `+
+			`{ [ "$1" = "%d" ] && [ "$2" -ge "$start" ]; } || start=0; `+
+			`[ $(($2 - start)) -gt %d ] && start=$(($2 - %d)); `+
+			`echo "$1 $start $2"; tail -c +$((start + 1)) -- %s | head -c $(($2 - start))`,
+			path, offset, inode, maxLogReadBytes, maxLogReadBytes, path)
+	}
+
+	res, err := client.ExecuteWithResult(cmd, logReadTimeout)
+	if err != nil {
+		return LogBatch{}, err
+	}
+	if res.ExitCode != 0 {
+		return LogBatch{}, fmt.Errorf("lecture de %s impossible: %s", source.Path, strings.TrimSpace(res.Stderr))
+	}
+
+	header, content, _ := strings.Cut(res.Stdout, "\n")
+	fields := strings.Fields(header)
+	if inode < 0 {
+		if len(fields) != 2 {
+			return LogBatch{}, fmt.Errorf("sortie stat inattendue: %q", header)
+		}
+		return LogBatch{Cursor: fields[0] + ":" + fields[1]}, nil
+	}
+	if len(fields) != 3 {
+		return LogBatch{}, fmt.Errorf("en-tête inattendu: %q", header)
+	}
+	start, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return LogBatch{}, fmt.Errorf("en-tête inattendu: %q", header)
+	}
+
+	batch := LogBatch{Skipped: fields[0] != strconv.FormatInt(inode, 10) || start != offset}
+
+	// Une ligne en cours d'écriture sera lue au passage suivant
+	consumed := len(content)
+	if i := strings.LastIndexByte(content, '\n'); i >= 0 {
+		consumed = i + 1
+	} else if len(content) < maxLogReadBytes {
+		consumed = 0
+	}
+	batch.Lines = splitLogLines(content[:consumed])
+	batch.Cursor = fields[0] + ":" + strconv.FormatInt(start+int64(consumed), 10)
+	return batch, nil
+}
+
+// readJournalSince lit les entrées journald postérieures au curseur
+func readJournalSince(client ssh.SSHExecutor, source LogSource, cursor string) (LogBatch, error) {
+	if !strings.HasPrefix(source.Cmd, "journalctl") {
+		return LogBatch{}, fmt.Errorf("commande journal inattendue: %s", source.Cmd)
+	}
+	base := strings.Split(source.Cmd, " -n")[0] + " --no-pager -o short-iso --show-cursor"
+
+	var cmd string
+	if cursor == "" {
+		cmd = base + " -n 1"
+	} else {
+		if !journalCursorRegex.MatchString(cursor) {
+			return LogBatch{}, fmt.Errorf("curseur journal invalide")
+		}
+		cmd = base + " --after-cursor " + security.ShellLiteral(cursor) + " | tail -n " + strconv.Itoa(maxLogReadEntries+2)
+	}
+
+	res, err := client.ExecuteWithResult(cmd, logReadTimeout)
+	if err != nil {
+		return LogBatch{}, err
+	}
+	if res.ExitCode != 0 {
+		return LogBatch{}, fmt.Errorf("lecture du journal impossible: %s", strings.TrimSpace(res.Stderr))
+	}
+
+	batch := LogBatch{Cursor: cursor}
+	var lines []string
+	for _, line := range splitLogLines(res.Stdout) {
+		if c, ok := strings.CutPrefix(line, "-- cursor: "); ok {
+			batch.Cursor = strings.TrimSpace(c)
+			continue
+		}
+		if strings.HasPrefix(line, "-- ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	// Premier passage: seul le curseur est conservé
+	if cursor == "" {
+		return batch, nil
+	}
+	if len(lines) > maxLogReadEntries {
+		lines = lines[len(lines)-maxLogReadEntries:]
+		batch.Skipped = true
+	}
+	batch.Lines = lines
+	return batch, nil
+}
+
+// readDockerSince lit les lignes d'un conteneur postérieures à l'horodatage du curseur
+func readDockerSince(client ssh.SSHExecutor, source LogSource, cursor string) (LogBatch, error) {
+	if err := security.ValidateServiceName(source.Container); err != nil {
+		return LogBatch{}, fmt.Errorf("conteneur invalide: %w", err)
+	}
+
+	var since time.Time
+	cmd := fmt.Sprintf("docker logs --timestamps --tail 1 %s 2>&1", source.Container)
+	if cursor != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, cursor); err != nil {
+			return LogBatch{}, fmt.Errorf("curseur docker invalide: %s", cursor)
+		}
+		cmd = fmt.Sprintf("docker logs --timestamps --since %s %s 2>&1 | tail -n %d", since.Format(time.RFC3339Nano), source.Container, maxLogReadEntries+1)
+	}
+
+	res, err := client.ExecuteWithResult(cmd, logReadTimeout)
+	if err != nil {
+		return LogBatch{}, err
+	}
+	if res.ExitCode != 0 {
+		return LogBatch{}, fmt.Errorf("lecture des logs du conteneur %s impossible: %s", source.Container, strings.TrimSpace(res.Stdout+res.Stderr))
+	}
+
+	// Conteneur sans logs: tout ce qui suivra est nouveau
+	batch := LogBatch{Cursor: time.Unix(0, 0).UTC().Format(time.RFC3339Nano)}
+	if cursor != "" {
+		batch.Cursor = cursor
+	}
+	for _, line := range splitLogLines(res.Stdout) {
+		// Chaque ligne commence par l'horodatage RFC3339Nano du démon Docker
+		first, _, _ := strings.Cut(line, " ")
+		ts, err := time.Parse(time.RFC3339Nano, first)
+		if err != nil || (cursor != "" && !ts.After(since)) {
+			continue
+		}
+		batch.Cursor = first
+		if cursor != "" {
+			batch.Lines = append(batch.Lines, line)
+		}
+	}
+	if len(batch.Lines) > maxLogReadEntries {
+		batch.Lines = batch.Lines[len(batch.Lines)-maxLogReadEntries:]
+		batch.Skipped = true
+	}
+	return batch, nil
+}
+
+// readWinEventsSince lit les événements Windows dont le RecordId est supérieur au curseur
+func readWinEventsSince(client ssh.SSHExecutor, source LogSource, cursor string) (LogBatch, error) {
+	if source.LogName == "" {
+		return LogBatch{}, fmt.Errorf("journal Windows non précisé pour %s", source.ID)
+	}
+	logName := "-LogName " + security.PowerShellLiteral(source.LogName)
+
+	var script string
+	var last int64
+	if cursor == "" {
+		script = "(Get-WinEvent " + logName + " -MaxEvents 1).RecordId"
+	} else {
+		var err error
+		if last, err = strconv.ParseInt(cursor, 10, 64); err != nil || last < 0 {
+			return LogBatch{}, fmt.Errorf("curseur Windows invalide: %s", cursor)
+		}
+		// -MaxEvents retourne les plus récents: un dépassement signifie des événements ignorés
+		script = fmt.Sprintf("Get-WinEvent %s -FilterXPath '*[System[EventRecordID>%d]]' -MaxEvents %d -ErrorAction SilentlyContinue"+
+			" | Sort-Object RecordId"+
+			` | ForEach-Object { Write-Output ('{0}|{1} [{2}] {3}: {4}' -f $_.RecordId, $_.TimeCreated.ToUniversalTime().ToString('o'), $_.LevelDisplayName, $_.ProviderName, ($_.Message -replace '\s+',' ')) }`,
+			logName, last, maxLogReadEntries+1)
+	}
+
+	res, err := client.ExecuteWithResult(security.PowerShellEncodedCommand(script), logReadTimeout)
+	if err != nil {
+		return LogBatch{}, err
+	}
+	if res.ExitCode != 0 {
+		return LogBatch{}, fmt.Errorf("lecture du journal %s impossible: %s", source.LogName, strings.TrimSpace(res.Stderr))
+	}
+
+	if cursor == "" {
+		id := strings.TrimSpace(res.Stdout)
+		if id == "" {
+			// Journal vide: on repart de zéro
+			return LogBatch{Cursor: "0"}, nil
+		}
+		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+			return LogBatch{}, fmt.Errorf("RecordId inattendu: %q", id)
+		}
+		return LogBatch{Cursor: id}, nil
+	}
+
+	batch := LogBatch{}
+	for _, line := range splitLogLines(res.Stdout) {
+		idStr, text, ok := strings.Cut(line, "|")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		last = max(last, id)
+		batch.Lines = append(batch.Lines, text)
+	}
+	if len(batch.Lines) > maxLogReadEntries {
+		batch.Lines = batch.Lines[len(batch.Lines)-maxLogReadEntries:]
+		batch.Skipped = true
+	}
+	batch.Cursor = strconv.FormatInt(last, 10)
+	return batch, nil
+}
+
+func splitLogLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// MatchLogLines retourne les lignes correspondant à l'expression
+func MatchLogLines(re *regexp.Regexp, lines []string) []string {
+	var matched []string
+	for _, line := range lines {
+		if re.MatchString(line) {
+			matched = append(matched, line)
+		}
+	}
+	return matched
+}
+
+// LogMatchWindow compte les correspondances d'une règle sur une fenêtre glissante
+type LogMatchWindow struct {
+	window  time.Duration
+	batches []logMatchBatch
+}
+
+type logMatchBatch struct {
+	at      time.Time
+	count   int
+	samples []string
+}
+
+// NewLogMatchWindow crée une fenêtre de la durée donnée
+func NewLogMatchWindow(window time.Duration) *LogMatchWindow {
+	return &LogMatchWindow{window: window}
+}
+
+// Add enregistre les lignes correspondantes lues à l'instant now
+func (w *LogMatchWindow) Add(now time.Time, matched []string) {
+	if len(matched) == 0 {
+		return
+	}
+	samples := matched[max(0, len(matched)-maxLogSamples):]
+	b := logMatchBatch{at: now, count: len(matched), samples: make([]string, len(samples))}
+	for i, s := range samples {
+		if len(s) > maxLogSampleLength {
+			s = s[:maxLogSampleLength] + "…"
+		}
+		b.samples[i] = s
+	}
+	w.batches = append(w.batches, b)
+}
+
+// Count retourne le nombre de correspondances dans la fenêtre se terminant à now
+func (w *LogMatchWindow) Count(now time.Time) int {
+	w.prune(now)
+	total := 0
+	for _, b := range w.batches {
+		total += b.count
+	}
+	return total
+}
+
+// Samples retourne les dernières lignes correspondantes de la fenêtre
+func (w *LogMatchWindow) Samples() []string {
+	var samples []string
+	for _, b := range w.batches {
+		samples = append(samples, b.samples...)
+	}
+	return samples[max(0, len(samples)-maxLogSamples):]
+}
+
+func (w *LogMatchWindow) prune(now time.Time) {
+	cutoff := now.Add(-w.window)
+	i := 0
+	for i < len(w.batches) && !w.batches[i].at.After(cutoff) {
+		i++
+	}
+	w.batches = w.batches[i:]
+}
@@ -0,0 +1,144 @@
+package collectors
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shellExecutor exécute les commandes avec sh en local, en redirigeant un chemin
+// de la liste blanche vers un fichier temporaire
+type shellExecutor struct {
+	*ssh.MockClient
+	from, to string
+}
+
+func (e *shellExecutor) ExecuteWithResult(cmd string, timeout time.Duration) (*ssh.CommandResult, error) {
+	c := exec.Command("sh", "-c", strings.ReplaceAll(cmd, e.from, e.to))
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	res := &ssh.CommandResult{}
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		res.ExitCode = exitErr.ExitCode()
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	return res, nil
+}
+
+func TestReadNewLogLines_File(t *testing.T) {
+	if _, err := exec.LookPath("stat"); err != nil {
+		t.Skip("stat indisponible")
+	}
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("ancienne ligne\n"), 0644))
+
+	executor := &shellExecutor{MockClient: ssh.NewMockClientLinux(), from: "/var/log/test-logrules.log", to: path}
+	source := fileLogSource("/var/log/test-logrules.log")
+
+	// Premier passage: positionnement en fin de fichier
+	batch, err := ReadNewLogLines(executor, source, "")
+	require.NoError(t, err)
+	assert.Empty(t, batch.Lines)
+	require.NotEmpty(t, batch.Cursor)
+
+	// Ligne complète + ligne en cours d'écriture
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	f.WriteString("Out of memory: Killed process 42\npartiel")
+	f.Close()
+
+	batch, err = ReadNewLogLines(executor, source, batch.Cursor)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Out of memory: Killed process 42"}, batch.Lines)
+	assert.False(t, batch.Skipped)
+
+	f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("le\n")
+	f.Close()
+
+	batch, err = ReadNewLogLines(executor, source, batch.Cursor)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"partielle"}, batch.Lines)
+
+	// Rotation: nouveau fichier (nouvel inode), relu depuis le début
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.WriteFile(path, []byte("après rotation\n"), 0644))
+	batch, err = ReadNewLogLines(executor, source, batch.Cursor)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"après rotation"}, batch.Lines)
+	assert.True(t, batch.Skipped)
+
+	_, err = ReadNewLogLines(executor, fileLogSource("/etc/shadow"), "")
+	assert.Error(t, err)
+}
+
+func TestReadNewLogLines_Journal(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	source := journalSystemSource
+
+	client.SetResponse("journalctl --no-pager -o short-iso --show-cursor -n 1",
+		"2026-01-05T10:00:00+0000 host kernel: boot\n-- cursor: s=abc;i=1\n")
+	batch, err := ReadNewLogLines(client, source, "")
+	require.NoError(t, err)
+	assert.Empty(t, batch.Lines)
+	assert.Equal(t, "s=abc;i=1", batch.Cursor)
+
+	// Le curseur (qui contient des ';') n'apparaît jamais en clair dans la commande
+	client.SetResponse(`journalctl --no-pager -o short-iso --show-cursor --after-cursor "$(printf %s cz1hYmM7aT0x | base64 -d)" | tail -n 5002`,
+		"2026-01-05T10:01:00+0000 host kernel: segfault at 0\n2026-01-05T10:01:02+0000 host cron: ok\n-- cursor: s=abc;i=3\n")
+	batch, err = ReadNewLogLines(client, source, batch.Cursor)
+	require.NoError(t, err)
+	assert.Len(t, batch.Lines, 2)
+	assert.Equal(t, "s=abc;i=3", batch.Cursor)
+
+	_, err = ReadNewLogLines(client, source, "x'; reboot #")
+	assert.Error(t, err)
+}
+
+func TestReadNewLogLines_Windows(t *testing.T) {
+	client := ssh.NewMockClientWindows()
+	source := windowsLogSources[0]
+
+	_, err := ReadNewLogLines(client, source, "12; Remove-Item")
+	assert.Error(t, err)
+	assert.Empty(t, client.ExecutedCommands)
+}
+
+func TestLogMatchWindow(t *testing.T) {
+	re := regexp.MustCompile(`Out of memory`)
+	w := NewLogMatchWindow(5 * time.Minute)
+	start := time.Now()
+
+	lines := []string{"Out of memory: 1", "ok", "Out of memory: 2"}
+	w.Add(start, MatchLogLines(re, lines))
+	assert.Equal(t, 2, w.Count(start))
+
+	var many []string
+	for i := 0; i < 10; i++ {
+		many = append(many, "Out of memory: "+strings.Repeat("x", i))
+	}
+	w.Add(start.Add(2*time.Minute), many)
+	assert.Equal(t, 12, w.Count(start.Add(2*time.Minute)))
+	assert.Len(t, w.Samples(), maxLogSamples)
+	assert.Equal(t, many[9], w.Samples()[maxLogSamples-1])
+
+	// Le premier lot sort de la fenêtre
+	assert.Equal(t, 10, w.Count(start.Add(6*time.Minute)))
+	assert.Equal(t, 0, w.Count(start.Add(8*time.Minute)))
+	assert.Empty(t, w.Samples())
+}
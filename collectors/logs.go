@@ -2,8 +2,10 @@ package collectors
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
+	"go-monitoring/config"
 	"go-monitoring/pkg/security"
 	"go-monitoring/ssh"
 )
@@ -12,63 +14,190 @@ import (
 type LogSource struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
-	Type string `json:"type"`           // file, journal, docker
+	Type string `json:"type"`           // file, journal, docker, powershell
 	Path string `json:"path,omitempty"` // Pour les fichiers
 	Cmd  string `json:"cmd,omitempty"`  // Pour journalctl ou docker logs
+	// Journal d'événements Windows (System, Application), utilisé pour le suivi en direct
+	LogName string `json:"log_name,omitempty"`
+	// Conteneur Docker
+	Container string `json:"container,omitempty"`
+	// Chemin ou motif glob configuré d'une source personnalisée : le fichier doit y correspondre
+	// (hors liste blanche, voir validateFileSource)
+	Pattern string `json:"-"`
 }
 
-// GetAvailableLogSources retourne la liste des sources de logs pour un OS donné
-func GetAvailableLogSources(client *ssh.Client, osType string) ([]LogSource, error) {
+// Nombre maximal de fichiers retenus pour un motif glob
+const maxGlobFiles = 50
+
+// Nombre d'événements Windows (-Newest / -MaxEvents) dans les commandes PowerShell
+var winEventCountRegex = regexp.MustCompile(`(-Newest|-MaxEvents) \d+`)
+
+// Fichiers de logs Linux proposés (liste blanche, voir security.ValidateLogSource)
+var linuxLogFiles = []string{
+	"/var/log/syslog",
+	"/var/log/messages",
+	"/var/log/auth.log",
+	"/var/log/kern.log",
+	"/var/log/nginx/error.log",
+	"/var/log/nginx/access.log",
+	"/var/log/apache2/error.log",
+	"/var/log/mysql/error.log",
+}
+
+// Sources journald et Windows fixes
+var (
+	journalSystemSource = LogSource{
+		ID:   "journal-sys",
+		Name: "Journalctl (System)",
+		Type: "journal",
+		Cmd:  "journalctl -n 100 --no-pager",
+	}
+	journalDockerSource = LogSource{
+		ID:   "journal-docker",
+		Name: "Journalctl (Docker Service)",
+		Type: "journal",
+		Cmd:  "journalctl -u docker -n 100 --no-pager",
+	}
+	windowsLogSources = []LogSource{
+		{
+			ID:      "win-system",
+			Name:    "Windows System Events",
+			Type:    "powershell",
+			Cmd:     "Get-EventLog -LogName System -Newest 100 | Format-Table -AutoSize | Out-String -Width 120",
+			LogName: "System",
+		},
+		{
+			ID:      "win-app",
+			Name:    "Windows Application Events",
+			Type:    "powershell",
+			Cmd:     "Get-EventLog -LogName Application -Newest 100 | Format-Table -AutoSize | Out-String -Width 120",
+			LogName: "Application",
+		},
+	}
+)
+
+// fileLogSource construit la source associée à un fichier de logs
+func fileLogSource(path string) LogSource {
+	return LogSource{
+		ID:   "file-" + strings.ReplaceAll(path, "/", "-"),
+		Name: path,
+		Type: "file",
+		Path: path,
+	}
+}
+
+// customLogSource convertit une source déclarée dans la configuration (hors motifs glob)
+func customLogSource(c config.LogSourceConfig) LogSource {
+	var s LogSource
+	switch c.Type {
+	case config.LogSourceFile:
+		s = fileLogSource(c.Path)
+		s.Pattern = c.Path
+	case config.LogSourceJournal:
+		s = LogSource{
+			ID:   "journal-unit-" + c.Unit,
+			Name: "Journalctl (" + c.Unit + ")",
+			Type: "journal",
+			Cmd:  "journalctl -u " + c.Unit + " -n 100 --no-pager",
+		}
+	case config.LogSourceDocker:
+		s = LogSource{
+			ID:        "docker-" + c.Container,
+			Name:      "Docker (" + c.Container + ")",
+			Type:      "docker",
+			Container: c.Container,
+		}
+	case config.LogSourceEventLog:
+		s = LogSource{
+			ID:      "win-" + strings.ToLower(strings.NewReplacer(" ", "-", "/", "-").Replace(c.Channel)),
+			Name:    c.Channel,
+			Type:    "powershell",
+			Cmd:     "Get-WinEvent -LogName " + security.PowerShellLiteral(c.Channel) + " -MaxEvents 100 | Format-Table TimeCreated, Id, LevelDisplayName, Message -AutoSize | Out-String -Width 120",
+			LogName: c.Channel,
+		}
+	}
+	if c.Name != "" {
+		s.Name = c.Name
+	}
+	return s
+}
+
+// validateFileSource vérifie le chemin d'une source fichier : liste blanche pour les fichiers standards,
+// chemin ou motif configuré pour une source personnalisée
+func validateFileSource(s LogSource) error {
+	if s.Pattern != "" {
+		return security.ValidateCustomLogSource(s.Path, s.Pattern)
+	}
+	return security.ValidateLogSource(s.Path)
+}
+
+// isLogGlob indique si le chemin d'une source fichier est un motif glob
+func isLogGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// expandCustomLogSources retourne les sources personnalisées présentes sur la machine.
+// Les motifs glob sont développés à distance, chaque fichier obtenu est revalidé.
+func expandCustomLogSources(client *ssh.Client, custom []config.LogSourceConfig) []LogSource {
+	var sources []LogSource
+	for _, c := range custom {
+		if c.Type != config.LogSourceFile {
+			sources = append(sources, customLogSource(c))
+			continue
+		}
+		if err := config.ValidateLogSourceConfig(c, "linux"); err != nil {
+			continue
+		}
+
+		if !isLogGlob(c.Path) {
+			if _, err := client.Execute(fmt.Sprintf("test -f %q", c.Path)); err == nil {
+				sources = append(sources, customLogSource(c))
+			}
+			continue
+		}
+
+		// Le motif (jeu de caractères restreint par la configuration) n'est pas entre guillemets pour être développé
+		out, err := client.Execute(fmt.Sprintf(`for f in %s; do [ -f "$f" ] && echo "$f"; done | head -n %d`, c.Path, maxGlobFiles))
+		if err != nil {
+			continue
+		}
+		for _, f := range strings.Split(out, "\n") {
+			f = strings.TrimSpace(f)
+			if f == "" || security.ValidateCustomLogSource(f, c.Path) != nil {
+				continue
+			}
+			s := fileLogSource(f)
+			s.Pattern = c.Path
+			if c.Name != "" {
+				s.Name = c.Name + " (" + f + ")"
+			}
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
+// GetAvailableLogSources retourne la liste des sources de logs pour un OS donné,
+// complétée par les sources personnalisées de la machine
+func GetAvailableLogSources(client *ssh.Client, osType string, custom []config.LogSourceConfig) ([]LogSource, error) {
 	var sources []LogSource
 
 	if osType == "windows" {
 		// Windows Event Logs
-		sources = append(sources, LogSource{
-			ID:   "win-system",
-			Name: "Windows System Events",
-			Type: "powershell",
-			Cmd:  "Get-EventLog -LogName System -Newest 100 | Format-Table -AutoSize | Out-String -Width 120",
-		})
-		sources = append(sources, LogSource{
-			ID:   "win-app",
-			Name: "Windows Application Events",
-			Type: "powershell",
-			Cmd:  "Get-EventLog -LogName Application -Newest 100 | Format-Table -AutoSize | Out-String -Width 120",
-		})
+		sources = append(sources, windowsLogSources...)
 	} else {
 		// Linux Standard Logs
-		files := []string{
-			"/var/log/syslog",
-			"/var/log/messages",
-			"/var/log/auth.log",
-			"/var/log/kern.log",
-			"/var/log/nginx/error.log",
-			"/var/log/nginx/access.log",
-			"/var/log/apache2/error.log",
-			"/var/log/mysql/error.log",
-		}
-
 		// Vérifier l'existence des fichiers
-		for _, f := range files {
+		for _, f := range linuxLogFiles {
 			// Test simple avec 'test -f'
 			_, err := client.Execute("test -f " + f)
 			if err == nil {
-				sources = append(sources, LogSource{
-					ID:   "file-" + strings.ReplaceAll(f, "/", "-"),
-					Name: f,
-					Type: "file",
-					Path: f,
-				})
+				sources = append(sources, fileLogSource(f))
 			}
 		}
 
 		// Ajout Systemd Journal
-		sources = append(sources, LogSource{
-			ID:   "journal-sys",
-			Name: "Journalctl (System)",
-			Type: "journal",
-			Cmd:  "journalctl -n 100 --no-pager",
-		})
+		sources = append(sources, journalSystemSource)
 
 		// Ajout Docker Logs (si docker existe)
 		_, err := client.Execute("which docker")
@@ -76,18 +205,52 @@ func GetAvailableLogSources(client *ssh.Client, osType string) ([]LogSource, err
 			// Lister les containers pour offrir leurs logs?
 			// Pour l'instant on met juste une commande générique ou on laisse l'utilisateur choisir?
 			// On va faire simple: juste les logs du daemon docker s'ils sont dispo via journalctl
-			sources = append(sources, LogSource{
-				ID:   "journal-docker",
-				Name: "Journalctl (Docker Service)",
-				Type: "journal",
-				Cmd:  "journalctl -u docker -n 100 --no-pager",
-			})
+			sources = append(sources, journalDockerSource)
+		}
+	}
+
+	// Sources personnalisées (un ID déjà présent n'est pas dupliqué)
+	seen := make(map[string]bool, len(sources))
+	for _, s := range sources {
+		seen[s.ID] = true
+	}
+	for _, s := range expandCustomLogSources(client, custom) {
+		if !seen[s.ID] {
+			seen[s.ID] = true
+			sources = append(sources, s)
 		}
 	}
 
 	return sources, nil
 }
 
+// ResolveLogSource retourne la source de la liste blanche ou personnalisée correspondant à un ID,
+// sans vérifier son existence sur la machine (utilisé par les règles d'alerte).
+// Les motifs glob ne sont pas résolus.
+func ResolveLogSource(id, osType string, custom []config.LogSourceConfig) (LogSource, bool) {
+	var candidates []LogSource
+	if osType == "windows" {
+		candidates = windowsLogSources
+	} else {
+		for _, f := range linuxLogFiles {
+			candidates = append(candidates, fileLogSource(f))
+		}
+		candidates = append(candidates, journalSystemSource, journalDockerSource)
+	}
+	for _, c := range custom {
+		if c.Type != config.LogSourceFile || !isLogGlob(c.Path) {
+			candidates = append(candidates, customLogSource(c))
+		}
+	}
+
+	for _, s := range candidates {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return LogSource{}, false
+}
+
 // FetchLogContent récupère le contenu des logs
 func FetchLogContent(client *ssh.Client, source LogSource, lines int) (string, error) {
 	var cmd string
@@ -98,7 +261,7 @@ func FetchLogContent(client *ssh.Client, source LogSource, lines int) (string, e
 
 	if source.Type == "file" {
 		// SÉCURITÉ: Valider le chemin du fichier log
-		if err := security.ValidateLogSource(source.Path); err != nil {
+		if err := validateFileSource(source); err != nil {
 			return "", fmt.Errorf("source de log invalide: %w", err)
 		}
 		cmd = fmt.Sprintf("tail -n %d %q", lines, source.Path)
@@ -120,10 +283,13 @@ func FetchLogContent(client *ssh.Client, source LogSource, lines int) (string, e
 			cmd = source.Cmd
 		}
 	} else if source.Type == "powershell" {
-		// Pour windows, changer le -Newest X
-		// C'est un peu complexe de parser la cmd string, on va juste exécuter tel quel pour l'instant
-		// ou reconstruire si on est sûr du format.
-		cmd = source.Cmd
+		// Pour windows, adapter le nombre d'événements (-Newest / -MaxEvents)
+		cmd = winEventCountRegex.ReplaceAllString(source.Cmd, fmt.Sprintf("${1} %d", lines))
+	} else if source.Type == "docker" {
+		if err := security.ValidateServiceName(source.Container); err != nil {
+			return "", fmt.Errorf("conteneur invalide: %w", err)
+		}
+		cmd = fmt.Sprintf("docker logs --timestamps --tail %d %s 2>&1", lines, source.Container)
 	} else {
 		return "", fmt.Errorf("type de log inconnu: %s", source.Type)
 	}
@@ -0,0 +1,98 @@
+package collectors
+
+import (
+	"testing"
+
+	"go-monitoring/config"
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLogSource_Custom(t *testing.T) {
+	custom := []config.LogSourceConfig{
+		{Type: config.LogSourceFile, Path: "/opt/app/logs/app.log", Name: "Application"},
+		{Type: config.LogSourceFile, Path: "/opt/app/logs/*.log"},
+		{Type: config.LogSourceJournal, Unit: "worker@1.service"},
+		{Type: config.LogSourceDocker, Container: "api"},
+	}
+
+	s, ok := ResolveLogSource("file--opt-app-logs-app.log", "linux", custom)
+	require.True(t, ok)
+	assert.Equal(t, "Application", s.Name)
+	assert.Equal(t, "/opt/app/logs/app.log", s.Path)
+
+	s, ok = ResolveLogSource("journal-unit-worker@1.service", "linux", custom)
+	require.True(t, ok)
+	assert.Equal(t, "journalctl -u worker@1.service -n 100 --no-pager", s.Cmd)
+
+	s, ok = ResolveLogSource("docker-api", "linux", custom)
+	require.True(t, ok)
+	assert.Equal(t, "api", s.Container)
+
+	// Les sources standards restent disponibles
+	_, ok = ResolveLogSource("journal-sys", "linux", custom)
+	assert.True(t, ok)
+	_, ok = ResolveLogSource("file--opt-app-logs-*.log", "linux", custom)
+	assert.False(t, ok)
+
+	win, ok := ResolveLogSource("win-microsoft-windows-powershell-operational", "windows",
+		[]config.LogSourceConfig{{Type: config.LogSourceEventLog, Channel: "Microsoft-Windows-PowerShell/Operational"}})
+	require.True(t, ok)
+	assert.Equal(t, "Microsoft-Windows-PowerShell/Operational", win.LogName)
+	assert.Contains(t, win.Cmd, "Get-WinEvent -LogName 'Microsoft-Windows-PowerShell/Operational' -MaxEvents 100")
+}
+
+func TestWinEventCount(t *testing.T) {
+	assert.Equal(t,
+		"Get-EventLog -LogName System -Newest 250 | Format-Table -AutoSize | Out-String -Width 120",
+		winEventCountRegex.ReplaceAllString(windowsLogSources[0].Cmd, "${1} 250"))
+	assert.Contains(t,
+		winEventCountRegex.ReplaceAllString(customLogSource(config.LogSourceConfig{Type: config.LogSourceEventLog, Channel: "Setup"}).Cmd, "${1} 20"),
+		"-MaxEvents 20 ")
+}
+
+func TestDockerLogCommands(t *testing.T) {
+	source := customLogSource(config.LogSourceConfig{Type: config.LogSourceDocker, Container: "api"})
+
+	cmd, err := TailCommand(source, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "docker logs -f --timestamps --tail 10 api 2>&1", cmd)
+
+	_, err = TailCommand(LogSource{Type: "docker", Container: "api; reboot"}, 10)
+	assert.Error(t, err)
+
+	client := ssh.NewMockClientLinux()
+	client.SetResponse("docker logs --timestamps --tail 1 api 2>&1", "2026-01-05T10:00:00.123456789Z started\n")
+	batch, err := ReadNewLogLines(client, source, "")
+	require.NoError(t, err)
+	assert.Empty(t, batch.Lines)
+	assert.Equal(t, "2026-01-05T10:00:00.123456789Z", batch.Cursor)
+
+	client.SetResponse("docker logs --timestamps --since 2026-01-05T10:00:00.123456789Z api 2>&1 | tail -n 5001",
+		"2026-01-05T10:00:00.123456789Z started\n2026-01-05T10:00:05Z panic: nil map\n")
+	batch, err = ReadNewLogLines(client, source, batch.Cursor)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2026-01-05T10:00:05Z panic: nil map"}, batch.Lines)
+	assert.Equal(t, "2026-01-05T10:00:05Z", batch.Cursor)
+}
+
+func TestCustomFileSourceValidation(t *testing.T) {
+	custom := config.LogSourceConfig{Type: config.LogSourceFile, Path: "/opt/app/logs/app.log"}
+
+	// Source personnalisée : autorisée pour son chemin configuré
+	cmd, err := TailCommand(customLogSource(custom), 10)
+	require.NoError(t, err)
+	assert.Equal(t, `tail -n 10 -F "/opt/app/logs/app.log"`, cmd)
+
+	// Même chemin sans la configuration : hors liste blanche
+	_, err = TailCommand(fileLogSource("/opt/app/logs/app.log"), 10)
+	assert.Error(t, err)
+
+	// Fichier obtenu d'un motif : doit correspondre au motif
+	s := fileLogSource("/opt/app/config.yml")
+	s.Pattern = "/opt/app/logs/*.log"
+	_, err = TailCommand(s, 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,237 @@
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-monitoring/pkg/security"
+	"go-monitoring/ssh"
+)
+
+// Délai maximal d'une recherche sur une source
+const logSearchTimeout = 30 * time.Second
+
+// LogSearchQuery décrit une recherche dans les logs
+type LogSearchQuery struct {
+	Pattern string
+	Since   time.Time
+	Until   time.Time
+	Limit   int // Nombre maximal de lignes par source
+}
+
+// LogSearchHit représente une ligne trouvée
+type LogSearchHit struct {
+	MachineID string    `json:"machine_id"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"` // Zéro si la ligne n'est pas horodatée
+	Line      string    `json:"line"`
+}
+
+// SearchLogs recherche le motif dans les sources données (issues de GetAvailableLogSources).
+// Le motif ne transite jamais en clair dans une ligne de commande shell.
+func SearchLogs(client ssh.SSHExecutor, machineID string, sources []LogSource, q LogSearchQuery) ([]LogSearchHit, error) {
+	if err := security.ValidateSearchPattern(q.Pattern); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = 200
+	}
+
+	var hits []LogSearchHit
+	var errs []string
+	for _, source := range sources {
+		cmd, err := searchCommand(source, q)
+		if err != nil {
+			errs = append(errs, source.ID+": "+err.Error())
+			continue
+		}
+
+		res, err := client.ExecuteWithResult(cmd, logSearchTimeout)
+		if err != nil {
+			errs = append(errs, source.ID+": "+err.Error())
+			continue
+		}
+		// grep et journalctl --grep retournent 1 lorsqu'aucune ligne ne correspond.
+		// Avec "| tail", seule la sortie d'erreur signale un fichier illisible.
+		stderr := strings.TrimSpace(res.Stderr)
+		if res.ExitCode > 1 || (stderr != "" && strings.TrimSpace(res.Stdout) == "") {
+			errs = append(errs, source.ID+": "+stderr)
+			continue
+		}
+
+		hits = append(hits, parseSearchOutput(machineID, source, res.Stdout, q)...)
+	}
+
+	SortLogHits(hits)
+	if len(errs) > 0 && len(hits) == 0 {
+		return nil, fmt.Errorf("recherche échouée: %s", strings.Join(errs, "; "))
+	}
+	return hits, nil
+}
+
+// searchCommand construit la commande de recherche d'une source
+func searchCommand(source LogSource, q LogSearchQuery) (string, error) {
+	limit := strconv.Itoa(q.Limit)
+
+	switch source.Type {
+	case "file":
+		if err := validateFileSource(source); err != nil {
+			return "", fmt.Errorf("source de log invalide: %w", err)
+		}
+		// La plage horaire est appliquée ensuite sur l'horodatage de chaque ligne
+		return "grep -E -h -e " + security.ShellLiteral(q.Pattern) + " -- " + fmt.Sprintf("%q", source.Path) + " | tail -n " + limit, nil
+
+	case "journal":
+		if !strings.HasPrefix(source.Cmd, "journalctl") {
+			return "", fmt.Errorf("commande journal inattendue: %s", source.Cmd)
+		}
+		base := strings.Split(source.Cmd, " -n")[0]
+		cmd := base + " --no-pager -o short-iso -n " + limit + " --grep " + security.ShellLiteral(q.Pattern)
+		// Format "@epoch" : indépendant du fuseau horaire de la machine distante
+		if !q.Since.IsZero() {
+			cmd += " --since @" + strconv.FormatInt(q.Since.Unix(), 10)
+		}
+		if !q.Until.IsZero() {
+			cmd += " --until @" + strconv.FormatInt(q.Until.Unix(), 10)
+		}
+		return cmd, nil
+
+	case "docker":
+		if err := security.ValidateServiceName(source.Container); err != nil {
+			return "", fmt.Errorf("conteneur invalide: %w", err)
+		}
+		cmd := "docker logs --timestamps"
+		if !q.Since.IsZero() {
+			cmd += " --since " + strconv.FormatInt(q.Since.Unix(), 10)
+		}
+		if !q.Until.IsZero() {
+			cmd += " --until " + strconv.FormatInt(q.Until.Unix(), 10)
+		}
+		return cmd + " " + source.Container + " 2>&1 | grep -E -e " + security.ShellLiteral(q.Pattern) + " | tail -n " + limit, nil
+
+	case "powershell":
+		if source.LogName == "" {
+			return "", fmt.Errorf("journal Windows non précisé pour %s", source.ID)
+		}
+		filter := "LogName=" + security.PowerShellLiteral(source.LogName)
+		if !q.Since.IsZero() {
+			filter += "; StartTime=[DateTime]::Parse(" + security.PowerShellLiteral(q.Since.UTC().Format(time.RFC3339)) + ").ToLocalTime()"
+		}
+		if !q.Until.IsZero() {
+			filter += "; EndTime=[DateTime]::Parse(" + security.PowerShellLiteral(q.Until.UTC().Format(time.RFC3339)) + ").ToLocalTime()"
+		}
+		script := "Get-WinEvent -FilterHashtable @{" + filter + "} -ErrorAction SilentlyContinue" +
+			" | Where-Object { $_.Message -match " + security.PowerShellLiteral(q.Pattern) + " }" +
+			" | Select-Object -First " + limit +
+			` | ForEach-Object { Write-Output ('{0} [{1}] {2}: {3}' -f $_.TimeCreated.ToUniversalTime().ToString('o'), $_.LevelDisplayName, $_.ProviderName, ($_.Message -replace '\s+',' ')) }`
+		return security.PowerShellEncodedCommand(script), nil
+
+	default:
+		return "", fmt.Errorf("type de log inconnu: %s", source.Type)
+	}
+}
+
+// parseSearchOutput convertit la sortie en résultats horodatés, filtrés sur la plage demandée
+func parseSearchOutput(machineID string, source LogSource, output string, q LogSearchQuery) []LogSearchHit {
+	var hits []LogSearchHit
+	now := time.Now()
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "-- ") {
+			// "-- No entries --" et autres marqueurs de journalctl
+			continue
+		}
+
+		ts, ok := ParseLogTimestamp(line, now)
+		if ok {
+			if (!q.Since.IsZero() && ts.Before(q.Since)) || (!q.Until.IsZero() && ts.After(q.Until)) {
+				continue
+			}
+		}
+
+		hits = append(hits, LogSearchHit{
+			MachineID: machineID,
+			Source:    source.ID,
+			Timestamp: ts,
+			Line:      line,
+		})
+	}
+	return hits
+}
+
+// Formats d'horodatage reconnus en début de ligne (ou entre crochets)
+var (
+	isoLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05-0700",
+		"2006-01-02T15:04:05.999999-0700",
+	}
+	prefixLayouts = []string{
+		"2006-01-02 15:04:05", // MySQL, PostgreSQL
+		"2006/01/02 15:04:05", // Nginx error.log
+	}
+	bracketLayouts = []string{
+		"02/Jan/2006:15:04:05 -0700",      // Access logs (combined)
+		"Mon Jan 02 15:04:05.000000 2006", // Apache error.log
+		"Mon Jan 02 15:04:05 2006",
+	}
+)
+
+// ParseLogTimestamp extrait l'horodatage d'une ligne de log.
+// Les formats sans année (syslog) ou sans fuseau sont interprétés dans le fuseau local du serveur.
+func ParseLogTimestamp(line string, now time.Time) (time.Time, bool) {
+	if first, _, _ := strings.Cut(line, " "); first != "" {
+		for _, layout := range isoLayouts {
+			if t, err := time.Parse(layout, first); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	for _, layout := range prefixLayouts {
+		if len(line) >= len(layout) {
+			if t, err := time.ParseInLocation(layout, line[:len(layout)], time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	// Syslog: "Jan  2 15:04:05" (année courante, ou précédente si la date est dans le futur)
+	if len(line) >= 15 {
+		if t, err := time.ParseInLocation("Jan _2 15:04:05", line[:15], time.Local); err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			return t, true
+		}
+	}
+
+	if start := strings.IndexByte(line, '['); start >= 0 {
+		if end := strings.IndexByte(line[start:], ']'); end > 0 {
+			inner := line[start+1 : start+end]
+			for _, layout := range bracketLayouts {
+				if t, err := time.ParseInLocation(layout, inner, time.Local); err == nil {
+					return t, true
+				}
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// SortLogHits trie les résultats par horodatage (les lignes non horodatées en dernier)
+func SortLogHits(hits []LogSearchHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i].Timestamp, hits[j].Timestamp
+		if a.IsZero() != b.IsZero() {
+			return !a.IsZero()
+		}
+		return a.Before(b)
+	})
+}
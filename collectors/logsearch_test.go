@@ -0,0 +1,103 @@
+package collectors
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogTimestamp(t *testing.T) {
+	now := time.Date(2026, time.January, 5, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		line     string
+		expected time.Time
+		ok       bool
+	}{
+		{"journal short-iso", "2026-01-05T10:15:00+0000 host sshd[42]: Failed password", time.Date(2026, 1, 5, 10, 15, 0, 0, time.UTC), true},
+		{"rfc3339", "2026-01-05T10:15:00.123Z [Error] Service: boom", time.Date(2026, 1, 5, 10, 15, 0, 123000000, time.UTC), true},
+		{"syslog", "Jan  5 09:00:01 host CRON[1]: job", time.Date(2026, 1, 5, 9, 0, 1, 0, time.Local), true},
+		{"syslog previous year", "Dec 31 23:59:59 host kernel: x", time.Date(2025, 12, 31, 23, 59, 59, 0, time.Local), true},
+		{"nginx error", "2026/01/05 08:00:00 [error] 12#12: upstream timed out", time.Date(2026, 1, 5, 8, 0, 0, 0, time.Local), true},
+		{"access log", `10.0.0.1 - - [05/Jan/2026:07:30:00 +0100] "GET / HTTP/1.1" 401 12`, time.Date(2026, 1, 5, 6, 30, 0, 0, time.UTC), true},
+		{"no timestamp", "plain message", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseLogTimestamp(tt.line, now)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.True(t, tt.expected.Equal(got), "got %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSearchCommand_PatternNeverInterpolated(t *testing.T) {
+	pattern := `'; rm -rf / #$(id)`
+	q := LogSearchQuery{Pattern: pattern, Limit: 50, Since: time.Unix(1700000000, 0)}
+
+	fileCmd, err := searchCommand(LogSource{Type: "file", Path: "/var/log/auth.log"}, q)
+	require.NoError(t, err)
+	assert.NotContains(t, fileCmd, "rm -rf")
+	assert.True(t, strings.HasPrefix(fileCmd, "grep -E -h -e \"$(printf %s "))
+
+	journalCmd, err := searchCommand(LogSource{Type: "journal", Cmd: "journalctl -u docker -n 100 --no-pager"}, q)
+	require.NoError(t, err)
+	assert.NotContains(t, journalCmd, "rm -rf")
+	assert.Contains(t, journalCmd, "journalctl -u docker --no-pager -o short-iso -n 50 --grep ")
+	assert.Contains(t, journalCmd, "--since @1700000000")
+
+	winCmd, err := searchCommand(LogSource{ID: "win-system", Type: "powershell", LogName: "System"}, q)
+	require.NoError(t, err)
+	assert.NotContains(t, winCmd, "rm -rf")
+	assert.True(t, strings.HasPrefix(winCmd, "powershell -NoProfile -NonInteractive -EncodedCommand "))
+
+	_, err = searchCommand(LogSource{Type: "file", Path: "/etc/shadow"}, q)
+	assert.Error(t, err)
+}
+
+func TestSearchLogs_MergesAndFiltersByTime(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	now := time.Now().UTC()
+	q := LogSearchQuery{Pattern: "Failed password", Since: now.Add(-2 * time.Hour), Limit: 100}
+
+	journal := LogSource{ID: "journal-sys", Type: "journal", Cmd: "journalctl -n 100 --no-pager"}
+	file := LogSource{ID: "file--var-log-auth.log", Type: "file", Path: "/var/log/auth.log"}
+	nginx := LogSource{ID: "file--var-log-nginx-error.log", Type: "file", Path: "/var/log/nginx/error.log"}
+
+	ts := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+
+	journalCmd, _ := searchCommand(journal, q)
+	client.SetResponse(journalCmd, ts(30*time.Minute)+" host sshd[1]: Failed password for root\n-- No entries --\n")
+
+	fileCmd, _ := searchCommand(file, q)
+	client.SetResponse(fileCmd, ts(3*time.Hour)+" host sshd[2]: Failed password (trop ancien)\n"+ts(90*time.Minute)+" host sshd[3]: Failed password for admin\n")
+
+	// Aucune correspondance: grep retourne 1 sans message d'erreur
+	nginxCmd, _ := searchCommand(nginx, q)
+	client.SetResponse(nginxCmd, "")
+	client.SetExitCode(nginxCmd, 1)
+
+	hits, err := SearchLogs(client, "srv-1", []LogSource{journal, file, nginx}, q)
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	assert.Contains(t, hits[0].Line, "admin")
+	assert.Contains(t, hits[1].Line, "root")
+	assert.Equal(t, "srv-1", hits[0].MachineID)
+	assert.Equal(t, "journal-sys", hits[1].Source)
+}
+
+func TestSearchLogs_InvalidPattern(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	_, err := SearchLogs(client, "srv-1", nil, LogSearchQuery{Pattern: "(unclosed"})
+	assert.Error(t, err)
+	assert.Empty(t, client.ExecutedCommands)
+}
@@ -0,0 +1,145 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go-monitoring/pkg/security"
+)
+
+// Longueur maximale des expressions de filtre (RE2: temps linéaire, mais on borne la compilation)
+const maxFilterLength = 256
+
+// ErrInvalidFilter indique une expression de filtre invalide
+var ErrInvalidFilter = errors.New("filtre invalide")
+
+// TailCommand construit la commande de suivi en direct d'une source de logs.
+// La commande reste active jusqu'à la fermeture de la session SSH.
+func TailCommand(source LogSource, initialLines int) (string, error) {
+	if initialLines < 0 {
+		initialLines = 0
+	}
+
+	switch source.Type {
+	case "file":
+		// SÉCURITÉ: Valider le chemin du fichier log
+		if err := validateFileSource(source); err != nil {
+			return "", fmt.Errorf("source de log invalide: %w", err)
+		}
+		return fmt.Sprintf("tail -n %d -F %q", initialLines, source.Path), nil
+
+	case "journal":
+		if !strings.HasPrefix(source.Cmd, "journalctl") {
+			return "", fmt.Errorf("commande journal inattendue: %s", source.Cmd)
+		}
+		base := strings.Split(source.Cmd, " -n")[0]
+		return fmt.Sprintf("%s -f -n %d --no-pager", base, initialLines), nil
+
+	case "docker":
+		if err := security.ValidateServiceName(source.Container); err != nil {
+			return "", fmt.Errorf("conteneur invalide: %w", err)
+		}
+		return fmt.Sprintf("docker logs -f --timestamps --tail %d %s 2>&1", initialLines, source.Container), nil
+
+	case "powershell":
+		if source.LogName == "" {
+			return "", fmt.Errorf("journal Windows non précisé pour %s", source.ID)
+		}
+		// Get-WinEvent n'a pas de mode "follow" : interrogation toutes les 2s des nouveaux RecordId
+		logName := "-LogName " + security.PowerShellLiteral(source.LogName)
+		emit := `ForEach-Object { $last = $_.RecordId; Write-Output ('{0} [{1}] {2}: {3}' -f $_.TimeCreated.ToString('s'), $_.LevelDisplayName, $_.ProviderName, ($_.Message -replace '\s+',' ')) }`
+		initial := "$last = (Get-WinEvent " + logName + " -MaxEvents 1).RecordId"
+		if initialLines > 0 {
+			initial = fmt.Sprintf("$last = 0; Get-WinEvent %s -MaxEvents %d | Sort-Object RecordId | %s", logName, initialLines, emit)
+		}
+		return `powershell -Command "` + initial + `; while ($true) { Start-Sleep -Seconds 2; Get-WinEvent ` + logName +
+			` -MaxEvents 200 | Where-Object { $_.RecordId -gt $last } | Sort-Object RecordId | ` + emit + ` }"`, nil
+
+	default:
+		return "", fmt.Errorf("type de log inconnu: %s", source.Type)
+	}
+}
+
+// LogFilter filtre les lignes avec des expressions régulières d'inclusion et d'exclusion
+type LogFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// NewLogFilter compile les expressions (vides = pas de filtre)
+func NewLogFilter(include, exclude string) (*LogFilter, error) {
+	f := &LogFilter{}
+	var err error
+	if f.include, err = compileFilter(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compileFilter(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func compileFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	if len(expr) > maxFilterLength {
+		return nil, fmt.Errorf("%w: expression trop longue (max %d caractères)", ErrInvalidFilter, maxFilterLength)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	return re, nil
+}
+
+// Match indique si la ligne doit être transmise
+func (f *LogFilter) Match(line string) bool {
+	if f == nil {
+		return true
+	}
+	if f.include != nil && !f.include.MatchString(line) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(line) {
+		return false
+	}
+	return true
+}
+
+// LineLimiter plafonne le nombre de lignes transmises par seconde (fenêtre fixe)
+type LineLimiter struct {
+	rate        int
+	windowStart time.Time
+	count       int
+	dropped     int
+}
+
+// NewLineLimiter crée un limiteur à rate lignes par seconde
+func NewLineLimiter(rate int) *LineLimiter {
+	return &LineLimiter{rate: rate}
+}
+
+// Allow indique si une ligne peut être transmise à l'instant now
+func (l *LineLimiter) Allow(now time.Time) bool {
+	if now.Sub(l.windowStart) >= time.Second {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count < l.rate {
+		l.count++
+		return true
+	}
+	l.dropped++
+	return false
+}
+
+// TakeDropped retourne le nombre de lignes écartées depuis le dernier appel
+func (l *LineLimiter) TakeDropped() int {
+	n := l.dropped
+	l.dropped = 0
+	return n
+}
@@ -0,0 +1,74 @@
+package collectors
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailCommand(t *testing.T) {
+	cmd, err := TailCommand(LogSource{Type: "file", Path: "/var/log/syslog"}, 20)
+	require.NoError(t, err)
+	assert.Equal(t, `tail -n 20 -F "/var/log/syslog"`, cmd)
+
+	cmd, err = TailCommand(LogSource{Type: "journal", Cmd: "journalctl -u docker -n 100 --no-pager"}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "journalctl -u docker -f -n 10 --no-pager", cmd)
+
+	cmd, err = TailCommand(LogSource{ID: "win-system", Type: "powershell", LogName: "System"}, 0)
+	require.NoError(t, err)
+	assert.Contains(t, cmd, "Get-WinEvent -LogName 'System'")
+	assert.Contains(t, cmd, "while ($true)")
+
+	_, err = TailCommand(LogSource{Type: "file", Path: "/etc/shadow"}, 10)
+	assert.Error(t, err)
+
+	_, err = TailCommand(LogSource{Type: "journal", Cmd: "rm -rf /"}, 10)
+	assert.Error(t, err)
+
+	_, err = TailCommand(LogSource{ID: "win-x", Type: "powershell"}, 10)
+	assert.Error(t, err)
+}
+
+func TestLogFilter(t *testing.T) {
+	f, err := NewLogFilter(`(?i)error|warn`, `healthcheck`)
+	require.NoError(t, err)
+
+	assert.True(t, f.Match("ERROR: disk full"))
+	assert.True(t, f.Match("warning: retry"))
+	assert.False(t, f.Match("info: started"))
+	assert.False(t, f.Match("error in healthcheck"))
+
+	// Sans expression, tout passe
+	empty, err := NewLogFilter("", "")
+	require.NoError(t, err)
+	assert.True(t, empty.Match("anything"))
+
+	_, err = NewLogFilter("(unclosed", "")
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+
+	_, err = NewLogFilter(strings.Repeat("a", maxFilterLength+1), "")
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+}
+
+func TestLineLimiter(t *testing.T) {
+	l := NewLineLimiter(3)
+	start := time.Now()
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.Allow(start.Add(time.Duration(i) * time.Millisecond)) {
+			allowed++
+		}
+	}
+	assert.Equal(t, 3, allowed)
+	assert.Equal(t, 7, l.TakeDropped())
+	assert.Equal(t, 0, l.TakeDropped())
+
+	// Nouvelle fenêtre d'une seconde
+	assert.True(t, l.Allow(start.Add(1100*time.Millisecond)))
+}
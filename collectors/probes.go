@@ -0,0 +1,293 @@
+package collectors
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/pkg/security"
+	"go-monitoring/ssh"
+)
+
+// Code de vérification openssl d'un certificat expiré (X509_V_ERR_CERT_HAS_EXPIRED) :
+// l'expiration est signalée par evaluateProbe
+const opensslCertExpired = 10
+
+var opensslVerifyRegex = regexp.MustCompile(`Verify return code: (\d+) \((.*)\)`)
+
+// ProbeRunner exécute les sondes synthétiques, localement ou via SSH
+type ProbeRunner struct {
+	// RootCAs remplace les autorités système pour la vérification TLS (nil = système)
+	RootCAs *x509.CertPool
+}
+
+// RunProbe exécute une sonde avec les autorités de certification du système.
+// Si client est nil, la sonde est exécutée depuis le serveur.
+func RunProbe(client ssh.SSHExecutor, probe config.ProbeConfig) models.ProbeResult {
+	return (&ProbeRunner{}).Run(client, probe)
+}
+
+// Run exécute une sonde et évalue son état (latence, code HTTP, expiration du certificat)
+func (pr *ProbeRunner) Run(client ssh.SSHExecutor, probe config.ProbeConfig) models.ProbeResult {
+	result := models.ProbeResult{
+		Name:      probe.Name,
+		Type:      probe.Type,
+		Target:    probe.Target,
+		Via:       probe.Via,
+		Timestamp: time.Now(),
+	}
+
+	timeout := time.Duration(probe.Timeout) * time.Second
+	var err error
+	if client == nil {
+		err = pr.runLocal(&result, probe, timeout)
+	} else {
+		err = runRemote(client, &result, probe, timeout)
+	}
+
+	switch {
+	case err != nil:
+		result.State = CheckCritical
+		result.Message = err.Error()
+	default:
+		result.State, result.Message = evaluateProbe(result, probe, time.Now())
+	}
+
+	result.StateName = CheckStateName(result.State)
+	return result
+}
+
+// evaluateProbe compare une mesure réussie aux seuils de la sonde
+func evaluateProbe(r models.ProbeResult, probe config.ProbeConfig, now time.Time) (int, string) {
+	latency := fmt.Sprintf("%.0f ms", r.LatencyMs)
+
+	switch probe.Type {
+	case config.ProbeHTTP:
+		if r.StatusCode != probe.ExpectStatus {
+			return CheckCritical, fmt.Sprintf("HTTP %d (attendu %d) en %s", r.StatusCode, probe.ExpectStatus, latency)
+		}
+	case config.ProbeTLS:
+		if r.CertExpiry == nil {
+			return CheckUnknown, "Date d'expiration du certificat introuvable"
+		}
+		days := int(r.CertExpiry.Sub(now).Hours() / 24)
+		if r.CertExpiry.Before(now) {
+			return CheckCritical, "Certificat expiré le " + r.CertExpiry.Format("02/01/2006")
+		}
+		if days < probe.CertExpiryDays {
+			return CheckWarning, fmt.Sprintf("Certificat expire dans %d jour(s) (%s)", days, r.CertExpiry.Format("02/01/2006"))
+		}
+	}
+
+	if probe.MaxLatencyMs > 0 && r.LatencyMs > float64(probe.MaxLatencyMs) {
+		return CheckWarning, fmt.Sprintf("Latence %s supérieure au seuil de %d ms", latency, probe.MaxLatencyMs)
+	}
+
+	switch probe.Type {
+	case config.ProbeHTTP:
+		return CheckOK, fmt.Sprintf("HTTP %d en %s", r.StatusCode, latency)
+	case config.ProbeTLS:
+		return CheckOK, "Certificat valide jusqu'au " + r.CertExpiry.Format("02/01/2006")
+	default:
+		return CheckOK, "Port ouvert (" + latency + ")"
+	}
+}
+
+// runLocal exécute la sonde depuis le serveur GoMonitoring
+func (pr *ProbeRunner) runLocal(r *models.ProbeResult, probe config.ProbeConfig, timeout time.Duration) error {
+	start := time.Now()
+
+	switch probe.Type {
+	case config.ProbeHTTP:
+		client := &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pr.RootCAs}},
+		}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get(probe.Target)
+		if err != nil {
+			return fmt.Errorf("requête HTTP échouée: %w", err)
+		}
+		resp.Body.Close()
+		r.LatencyMs = msSince(start)
+		r.StatusCode = resp.StatusCode
+
+	case config.ProbeTCP:
+		conn, err := net.DialTimeout("tcp", probe.Target, timeout)
+		if err != nil {
+			return fmt.Errorf("connexion TCP échouée: %w", err)
+		}
+		conn.Close()
+		r.LatencyMs = msSince(start)
+
+	case config.ProbeTLS:
+		// Chaîne vérifiée après la négociation : un certificat expiré ou non approuvé garde sa date d'expiration
+		host, _, _ := net.SplitHostPort(probe.Target)
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", probe.Target, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err != nil {
+			return fmt.Errorf("négociation TLS échouée: %w", err)
+		}
+		defer conn.Close()
+		r.LatencyMs = msSince(start)
+
+		certs := conn.ConnectionState().PeerCertificates
+		if len(certs) == 0 {
+			return errors.New("aucun certificat présenté")
+		}
+		expiry := certs[0].NotAfter
+		r.CertExpiry = &expiry
+		if err := pr.verifyChain(certs, host); err != nil {
+			return fmt.Errorf("certificat non approuvé: %w", err)
+		}
+
+	default:
+		return fmt.Errorf("type de sonde inconnu '%s'", probe.Type)
+	}
+
+	return nil
+}
+
+// verifyChain vérifie le certificat présenté (autorités, nom d'hôte). Un certificat expiré est vérifié
+// à sa date d'expiration : l'expiration est signalée par evaluateProbe avec sa date.
+func (pr *ProbeRunner) verifyChain(certs []*x509.Certificate, host string) error {
+	leaf := certs[0]
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         pr.RootCAs,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   time.Now(),
+	}
+	if opts.CurrentTime.After(leaf.NotAfter) {
+		opts.CurrentTime = leaf.NotAfter
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := leaf.Verify(opts)
+	return err
+}
+
+// runRemote exécute la sonde depuis une machine surveillée (curl, nc, openssl).
+// La cible est transmise sous forme littérale (security.ShellLiteral).
+func runRemote(client ssh.SSHExecutor, r *models.ProbeResult, probe config.ProbeConfig, timeout time.Duration) error {
+	secs := strconv.Itoa(probe.Timeout)
+	var cmd string
+
+	switch probe.Type {
+	case config.ProbeHTTP:
+		cmd = "curl -sS -o /dev/null -w '%{http_code} %{time_total}' --max-time " + secs + " " + security.ShellLiteral(probe.Target)
+	case config.ProbeTCP:
+		host, port, _ := net.SplitHostPort(probe.Target)
+		cmd = "nc -z -w " + secs + " " + security.ShellLiteral(host) + " " + port
+	case config.ProbeTLS:
+		// Sortie lue deux fois : date d'expiration, puis résultat de la vérification (sans échec de la négociation)
+		host, _, _ := net.SplitHostPort(probe.Target)
+		h := security.ShellLiteral(host)
+		cmd = "out=$(echo | timeout " + secs + " openssl s_client -connect " + security.ShellLiteral(probe.Target) +
+			" -servername " + h + " -verify_hostname " + h + ` 2>/dev/null); printf '%s\n' "$out" | openssl x509 -noout -enddate` +
+			` && printf '%s\n' "$out" | grep 'Verify return code'`
+	default:
+		return fmt.Errorf("type de sonde inconnu '%s'", probe.Type)
+	}
+
+	// Marge pour l'établissement de la session SSH
+	res, err := client.ExecuteWithResult(cmd, timeout+5*time.Second)
+	if err != nil {
+		return fmt.Errorf("exécution via %s échouée: %w", probe.Via, err)
+	}
+	if res.ExitCode != 0 {
+		msg := strings.TrimSpace(res.Stderr)
+		if msg == "" && probe.Type == config.ProbeTCP {
+			msg = "port fermé ou injoignable"
+		} else if msg == "" {
+			msg = "code de sortie " + strconv.Itoa(res.ExitCode)
+		}
+		return fmt.Errorf("sonde via %s échouée: %s", probe.Via, msg)
+	}
+
+	switch probe.Type {
+	case config.ProbeHTTP:
+		code, latency, err := parseCurlOutput(res.Stdout)
+		if err != nil {
+			return err
+		}
+		r.StatusCode = code
+		r.LatencyMs = latency
+	case config.ProbeTCP:
+		// nc ne mesure pas la latence : durée totale de la commande distante
+		r.LatencyMs = float64(res.Duration.Microseconds()) / 1000
+	case config.ProbeTLS:
+		expiry, err := parseOpenSSLEndDate(res.Stdout)
+		if err != nil {
+			return err
+		}
+		r.CertExpiry = &expiry
+		r.LatencyMs = float64(res.Duration.Microseconds()) / 1000
+		if err := parseOpenSSLVerify(res.Stdout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseCurlOutput interprète la sortie "-w '%{http_code} %{time_total}'" de curl
+func parseCurlOutput(output string) (int, float64, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("sortie curl inattendue: %q", output)
+	}
+	code, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("code HTTP invalide: %q", fields[0])
+	}
+	// time_total peut utiliser la virgule selon la locale
+	seconds, err := strconv.ParseFloat(strings.Replace(fields[1], ",", ".", 1), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("durée invalide: %q", fields[1])
+	}
+	return code, seconds * 1000, nil
+}
+
+// parseOpenSSLEndDate interprète la sortie "notAfter=Mar 10 12:00:00 2026 GMT"
+func parseOpenSSLEndDate(output string) (time.Time, error) {
+	for _, line := range strings.Split(output, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "notAfter=")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse("Jan _2 15:04:05 2006 MST", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("date d'expiration invalide: %q", value)
+		}
+		return t, nil
+	}
+	return time.Time{}, errors.New("date d'expiration absente de la sortie openssl")
+}
+
+// parseOpenSSLVerify interprète la ligne "Verify return code: 0 (ok)" de openssl s_client
+func parseOpenSSLVerify(output string) error {
+	m := opensslVerifyRegex.FindStringSubmatch(output)
+	if m == nil {
+		return errors.New("résultat de la vérification absent de la sortie openssl")
+	}
+	code, _ := strconv.Atoi(m[1])
+	if code != 0 && code != opensslCertExpired {
+		return fmt.Errorf("certificat non approuvé: %s", m[2])
+	}
+	return nil
+}
+
+func msSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
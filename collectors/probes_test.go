@@ -0,0 +1,217 @@
+package collectors
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/pkg/security"
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunProbe_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	probe := config.ProbeConfig{Name: "portal", Type: config.ProbeHTTP, Timeout: 2, ExpectStatus: 200}
+
+	probe.Target = srv.URL + "/"
+	result := RunProbe(nil, probe)
+	assert.Equal(t, CheckOK, result.State, result.Message)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Greater(t, result.LatencyMs, 0.0)
+
+	probe.Target = srv.URL + "/down"
+	result = RunProbe(nil, probe)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+
+	probe.Target = srv.URL + "/slow"
+	probe.MaxLatencyMs = 10
+	result = RunProbe(nil, probe)
+	assert.Equal(t, CheckWarning, result.State)
+	assert.Contains(t, result.Message, "Latence")
+}
+
+func TestRunProbe_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	probe := config.ProbeConfig{Name: "db", Type: config.ProbeTCP, Target: addr, Timeout: 2}
+	result := RunProbe(nil, probe)
+	assert.Equal(t, CheckOK, result.State, result.Message)
+
+	// Port fermé après arrêt de l'écoute
+	ln.Close()
+	result = RunProbe(nil, probe)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Equal(t, "critical", result.StateName)
+}
+
+func TestRunProbe_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	runner := &ProbeRunner{RootCAs: pool}
+
+	// Le certificat de test est émis pour "example.com" et 127.0.0.1
+	target := strings.TrimPrefix(srv.URL, "https://")
+	probe := config.ProbeConfig{Name: "portal-cert", Type: config.ProbeTLS, Target: target, Timeout: 2, CertExpiryDays: 21}
+
+	result := runner.Run(nil, probe)
+	require.Equal(t, CheckOK, result.State, result.Message)
+	require.NotNil(t, result.CertExpiry)
+	assert.Equal(t, srv.Certificate().NotAfter, *result.CertExpiry)
+
+	// Seuil supérieur à la durée de validité restante
+	probe.CertExpiryDays = 1_000_000
+	result = runner.Run(nil, probe)
+	assert.Equal(t, CheckWarning, result.State)
+
+	// Autorité inconnue: certificat non approuvé
+	result = RunProbe(nil, probe)
+	assert.Equal(t, CheckCritical, result.State)
+}
+
+func TestEvaluateProbe_ExpiredCert(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	r := models.ProbeResult{CertExpiry: &expired}
+
+	state, msg := evaluateProbe(r, config.ProbeConfig{Type: config.ProbeTLS, CertExpiryDays: 21}, now)
+	assert.Equal(t, CheckCritical, state)
+	assert.Contains(t, msg, "expiré")
+}
+
+func TestRunProbe_ViaSSH(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	lit := security.ShellLiteral
+
+	// Paramètres de requête : transmis littéralement à curl
+	httpProbe := config.ProbeConfig{Name: "portal", Type: config.ProbeHTTP, Target: "https://intranet.local/health?a=1&b=2", Via: "srv-1", Timeout: 5, ExpectStatus: 200, MaxLatencyMs: 500}
+	client.SetResponse("curl -sS -o /dev/null -w '%{http_code} %{time_total}' --max-time 5 "+lit("https://intranet.local/health?a=1&b=2"), "200 0,123")
+	result := RunProbe(client, httpProbe)
+	assert.Equal(t, CheckOK, result.State, result.Message)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.InDelta(t, 123.0, result.LatencyMs, 0.001)
+
+	tcpProbe := config.ProbeConfig{Name: "pg", Type: config.ProbeTCP, Target: "db.local:5432", Via: "srv-1", Timeout: 3}
+	client.SetResponse("nc -z -w 3 "+lit("db.local")+" 5432", "")
+	client.SetExitCode("nc -z -w 3 "+lit("db.local")+" 5432", 1)
+	result = RunProbe(client, tcpProbe)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Contains(t, result.Message, "port fermé")
+
+	tlsProbe := config.ProbeConfig{Name: "cert", Type: config.ProbeTLS, Target: "intranet.local:443", Via: "srv-1", Timeout: 5, CertExpiryDays: 21}
+	tlsCmd := "out=$(echo | timeout 5 openssl s_client -connect " + lit("intranet.local:443") + " -servername " + lit("intranet.local") +
+		" -verify_hostname " + lit("intranet.local") + ` 2>/dev/null); printf '%s\n' "$out" | openssl x509 -noout -enddate` +
+		` && printf '%s\n' "$out" | grep 'Verify return code'`
+	expiry := time.Now().Add(10 * 24 * time.Hour).UTC()
+	client.SetResponse(tlsCmd, "notAfter="+expiry.Format("Jan _2 15:04:05 2006")+" GMT\n    Verify return code: 0 (ok)\n")
+	result = RunProbe(client, tlsProbe)
+	assert.Equal(t, CheckWarning, result.State, result.Message)
+	require.NotNil(t, result.CertExpiry)
+
+	// Certificat expiré : signalé avec sa date
+	expired := time.Now().Add(-48 * time.Hour).UTC()
+	client.SetResponse(tlsCmd, "notAfter="+expired.Format("Jan _2 15:04:05 2006")+" GMT\n    Verify return code: 10 (certificate has expired)\n")
+	result = RunProbe(client, tlsProbe)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Contains(t, result.Message, "Certificat expiré")
+
+	// Autorité inconnue
+	client.SetResponse(tlsCmd, "notAfter="+expiry.Format("Jan _2 15:04:05 2006")+" GMT\n    Verify return code: 19 (self-signed certificate in certificate chain)\n")
+	result = RunProbe(client, tlsProbe)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Contains(t, result.Message, "non approuvé")
+	require.NotNil(t, result.CertExpiry)
+}
+
+func TestRunProbe_TLSExpired(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	notAfter := time.Now().Add(-24 * time.Hour).Truncate(time.Second).UTC()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "expired.local"},
+		NotBefore:             notAfter.Add(-30 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	target := strings.TrimPrefix(srv.URL, "https://")
+	probe := config.ProbeConfig{Name: "expired", Type: config.ProbeTLS, Target: target, Timeout: 2, CertExpiryDays: 21}
+
+	// Certificat approuvé mais expiré : date d'expiration relevée
+	result := (&ProbeRunner{RootCAs: pool}).Run(nil, probe)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Contains(t, result.Message, "Certificat expiré le")
+	require.NotNil(t, result.CertExpiry)
+	assert.True(t, notAfter.Equal(*result.CertExpiry))
+
+	// Autorité inconnue : refusé, la date reste connue
+	result = RunProbe(nil, probe)
+	assert.Equal(t, CheckCritical, result.State)
+	assert.Contains(t, result.Message, "non approuvé")
+	require.NotNil(t, result.CertExpiry)
+}
+
+func TestParseOpenSSLEndDate(t *testing.T) {
+	got, err := parseOpenSSLEndDate("notAfter=Mar  9 08:00:00 2027 GMT\n")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2027, time.March, 9, 8, 0, 0, 0, time.UTC), got.UTC())
+
+	_, err = parseOpenSSLEndDate("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package collectors
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/ssh"
+)
+
+// RunRunbook exécute les étapes d'un runbook dans l'ordre et s'arrête à la première étape en échec
+// (sauf continue_on_error). Retourne le résultat des étapes exécutées et false si une étape a échoué.
+func RunRunbook(client ssh.SSHExecutor, rb config.Runbook, osType string) ([]models.RunbookStepResult, bool) {
+	var results []models.RunbookStepResult
+	ok := true
+	for _, step := range rb.Steps {
+		res := RunRunbookStep(client, step, osType)
+		results = append(results, res)
+		if res.Failed() {
+			ok = false
+			if !step.ContinueOnError {
+				break
+			}
+		}
+	}
+	return results, ok
+}
+
+// RunRunbookStep exécute une étape : action sur un service (via ServiceAction) ou commande
+func RunRunbookStep(client ssh.SSHExecutor, step config.RunbookStep, osType string) models.RunbookStepResult {
+	if step.Service != "" {
+		res := models.RunbookStepResult{Name: step.Name, Command: step.Action + " " + step.Service}
+		start := time.Now()
+		if err := ServiceAction(client, step.Service, step.Action, osType); err != nil {
+			res.ExitCode = -1
+			res.Error = err.Error()
+		}
+		res.DurationMs = time.Since(start).Milliseconds()
+		if res.Name == "" {
+			res.Name = res.Command
+		}
+		return res
+	}
+
+	cmd := step.Command
+	if osType == "windows" {
+		cmd = step.WindowsCommand
+	}
+	res := models.RunbookStepResult{Name: step.Name, Command: cmd}
+	if res.Name == "" {
+		res.Name = cmd
+	}
+	if cmd == "" {
+		res.ExitCode = -1
+		res.Error = "étape non disponible pour ce système"
+		return res
+	}
+
+	out := RunCommand(client, "", cmd, time.Duration(step.Timeout)*time.Second)
+	res.Stdout, res.Stderr = out.Stdout, out.Stderr
+	res.ExitCode, res.Error = out.ExitCode, out.Error
+	res.Truncated, res.DurationMs = out.Truncated, out.DurationMs
+	return res
+}
+
+// RunbookLimiter limite les exécutions automatiques des runbooks : délai minimal entre deux
+// exécutions sur une même machine et nombre maximal d'exécutions par heure, toutes machines confondues
+type RunbookLimiter struct {
+	mu   sync.Mutex
+	runs map[string][]runbookStart // Par runbook, du plus ancien au plus récent
+}
+
+type runbookStart struct {
+	machineID string
+	at        time.Time
+}
+
+// NewRunbookLimiter crée un limiteur vide
+func NewRunbookLimiter() *RunbookLimiter {
+	return &RunbookLimiter{runs: make(map[string][]runbookStart)}
+}
+
+// Allow réserve une exécution automatique de rb sur la machine si les limites le permettent,
+// sinon retourne la raison du refus
+func (l *RunbookLimiter) Allow(rb config.Runbook, machineID string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cooldown := time.Duration(rb.Cooldown) * time.Second
+	keep := max(time.Hour, cooldown)
+
+	var recent []runbookStart
+	lastHour := 0
+	var lastOnMachine time.Time
+	for _, s := range l.runs[rb.Name] {
+		if now.Sub(s.at) >= keep {
+			continue
+		}
+		recent = append(recent, s)
+		if now.Sub(s.at) < time.Hour {
+			lastHour++
+		}
+		if s.machineID == machineID {
+			lastOnMachine = s.at
+		}
+	}
+	l.runs[rb.Name] = recent
+
+	if !lastOnMachine.IsZero() && now.Sub(lastOnMachine) < cooldown {
+		return fmt.Errorf("déjà exécuté sur %s il y a %s (délai minimal %s)",
+			machineID, now.Sub(lastOnMachine).Round(time.Second), cooldown)
+	}
+	if rb.MaxRunsPerHour > 0 && lastHour >= rb.MaxRunsPerHour {
+		return fmt.Errorf("limite de %d exécution(s) par heure atteinte", rb.MaxRunsPerHour)
+	}
+
+	l.runs[rb.Name] = append(recent, runbookStart{machineID: machineID, at: now})
+	return nil
+}
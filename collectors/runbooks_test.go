@@ -0,0 +1,87 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"go-monitoring/config"
+	"go-monitoring/ssh"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRunbook(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+	client.SetResponse("journalctl --vacuum-size=200M", "Vacuuming done, freed 1.2G\n")
+	client.SetResponse("sudo -n systemctl restart rsyslog", "")
+	client.SetExitCode("find /var/log -name '*.gz' -mtime +7 -delete", 1)
+
+	rb := config.Runbook{Name: "nettoyage-logs", Steps: []config.RunbookStep{
+		{Name: "Purge du journal", Command: "journalctl --vacuum-size=200M", Timeout: 30},
+		{Service: "rsyslog", Action: "restart"},
+	}}
+	steps, ok := RunRunbook(client, rb, "linux")
+	require.Len(t, steps, 2)
+	assert.True(t, ok)
+	assert.Equal(t, "Purge du journal", steps[0].Name)
+	assert.Contains(t, steps[0].Stdout, "freed")
+	assert.Equal(t, "restart rsyslog", steps[1].Name)
+	assert.Equal(t, 0, steps[1].ExitCode)
+
+	// Une étape en échec interrompt le runbook, sauf continue_on_error
+	rb.Steps = []config.RunbookStep{
+		{Command: "find /var/log -name '*.gz' -mtime +7 -delete", Timeout: 30},
+		{Service: "rsyslog", Action: "restart"},
+	}
+	steps, ok = RunRunbook(client, rb, "linux")
+	assert.False(t, ok)
+	require.Len(t, steps, 1)
+	assert.Equal(t, 1, steps[0].ExitCode)
+
+	rb.Steps[0].ContinueOnError = true
+	steps, ok = RunRunbook(client, rb, "linux")
+	assert.False(t, ok)
+	assert.Len(t, steps, 2)
+}
+
+func TestRunRunbookStep_Errors(t *testing.T) {
+	client := ssh.NewMockClientLinux()
+
+	// Pas de variante Windows
+	res := RunRunbookStep(client, config.RunbookStep{Command: "df -h"}, "windows")
+	assert.True(t, res.Failed())
+	assert.Contains(t, res.Error, "non disponible")
+
+	// Échec de l'action sur le service
+	res = RunRunbookStep(client, config.RunbookStep{Service: "nginx", Action: "restart"}, "linux")
+	assert.True(t, res.Failed())
+	assert.Equal(t, -1, res.ExitCode)
+	assert.NotEmpty(t, res.Error)
+}
+
+func TestRunbookLimiter(t *testing.T) {
+	l := NewRunbookLimiter()
+	rb := config.Runbook{Name: "nettoyage-logs", Cooldown: 600, MaxRunsPerHour: 2}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, l.Allow(rb, "srv-1", now))
+
+	// Délai minimal par machine
+	err := l.Allow(rb, "srv-1", now.Add(5*time.Minute))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "délai minimal")
+
+	// Autre machine : autorisé, puis plafond horaire atteint
+	require.NoError(t, l.Allow(rb, "srv-2", now.Add(5*time.Minute)))
+	err = l.Allow(rb, "srv-3", now.Add(6*time.Minute))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "par heure")
+
+	// Une heure plus tard, les exécutions précédentes ne comptent plus
+	assert.NoError(t, l.Allow(rb, "srv-1", now.Add(61*time.Minute)))
+
+	// Les limites sont propres à chaque runbook
+	other := config.Runbook{Name: "redemarrage-app", Cooldown: 600, MaxRunsPerHour: 1}
+	assert.NoError(t, l.Allow(other, "srv-1", now.Add(61*time.Minute)))
+}
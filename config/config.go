@@ -3,9 +3,17 @@ package config
 import (
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
 	"go-monitoring/pkg/crypto"
+	"go-monitoring/pkg/security"
 	"gopkg.in/yaml.v3"
 )
 
@@ -14,6 +22,19 @@ type Config struct {
 	Machines []MachineConfig `yaml:"machines"`
 	Settings Settings        `yaml:"settings"`
 	Users    []UserConfig    `yaml:"users"`
+	Probes   []ProbeConfig   `yaml:"probes,omitempty"`
+	// Commandes prédéfinies exécutables sur plusieurs machines (fichier de configuration uniquement)
+	Commands []CommandTemplate `yaml:"commands,omitempty"`
+	// Runbooks de remédiation associés aux alertes (fichier de configuration uniquement)
+	Runbooks []Runbook `yaml:"runbooks,omitempty"`
+	// Capacités accordées à chaque rôle (hors admin), éventuellement limitées à des groupes de machines
+	Permissions map[string][]PermissionGrant `yaml:"permissions,omitempty"`
+	// Annuaire LDAP / Active Directory (fichier de configuration uniquement, pris en compte au démarrage)
+	LDAP *LDAPConfig `yaml:"ldap,omitempty"`
+	// Fournisseur OpenID Connect (SSO) (fichier de configuration uniquement, pris en compte au démarrage)
+	OIDC *OIDCConfig `yaml:"oidc,omitempty"`
+	// Transfert du journal d'audit vers un SIEM (fichier de configuration uniquement, pris en compte au démarrage)
+	Audit *AuditConfig `yaml:"audit,omitempty"`
 }
 
 // UserConfig représente un utilisateur
@@ -23,6 +44,104 @@ type UserConfig struct {
 	Role     string `yaml:"role"`
 }
 
+// RoleAdmin est le rôle d'administration : il possède toutes les capacités
+const RoleAdmin = "admin"
+
+// Capacités attribuables aux rôles. CapabilityAdmin (utilisateurs, configuration,
+// audit, enregistrements) est réservée au rôle admin et ne peut pas être accordée.
+const (
+	CapabilityTerminal = "terminal"
+	CapabilityFiles    = "files"
+	CapabilityLogs     = "logs"
+	CapabilityServices = "services"
+	CapabilityCommands = "commands" // Exécution des commandes prédéfinies
+	CapabilityAdmin    = "admin"
+)
+
+// PermissionGrant accorde des capacités à un rôle sur toutes les machines
+// ou seulement sur celles des groupes listés
+type PermissionGrant struct {
+	Capabilities []string `yaml:"capabilities" json:"capabilities"`
+	Groups       []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// LDAPConfig décrit l'annuaire utilisé pour authentifier les utilisateurs absents de la base locale :
+// recherche de l'utilisateur avec le compte de service, puis bind avec son mot de passe
+type LDAPConfig struct {
+	URL                string `yaml:"url"`                            // ldaps://ad.example.com:636 ou ldap://ad.example.com:389
+	StartTLS           bool   `yaml:"start_tls,omitempty"`            // ldap:// puis StartTLS
+	CACert             string `yaml:"ca_cert,omitempty"`              // Certificat de l'autorité (PEM), sinon magasin système
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // Tests uniquement
+	BindDN             string `yaml:"bind_dn,omitempty"`              // Compte de service (vide = recherche anonyme)
+	BindPassword       string `yaml:"bind_password,omitempty"`        // Chiffrable (ENC:...), comme les mots de passe SSH
+	BaseDN             string `yaml:"base_dn"`
+	UserFilter         string `yaml:"user_filter,omitempty"`     // %s = nom d'utilisateur (échappé)
+	GroupAttribute     string `yaml:"group_attribute,omitempty"` // Attribut listant les groupes de l'utilisateur
+	// Groupes de l'annuaire et rôle attribué : le premier groupe correspondant l'emporte
+	GroupMappings []LDAPGroupMapping `yaml:"group_mappings"`
+	DefaultRole   string             `yaml:"default_role,omitempty"` // Rôle sans groupe correspondant (vide = connexion refusée)
+	CacheTTL      int                `yaml:"cache_ttl,omitempty"`    // secondes : durée de validité des groupes lus
+	Timeout       int                `yaml:"timeout,omitempty"`      // secondes
+}
+
+// LDAPGroupMapping associe un groupe de l'annuaire (DN) à un rôle ; les permissions sur les groupes
+// de machines sont celles du rôle (section permissions)
+type LDAPGroupMapping struct {
+	Group string `yaml:"group"`
+	Role  string `yaml:"role"`
+}
+
+// OIDCConfig décrit le fournisseur OpenID Connect (Keycloak...) : connexion par code d'autorisation
+// avec PKCE, comptes créés à la première connexion et rôle déduit des claims du jeton d'identité
+type OIDCConfig struct {
+	Issuer       string   `yaml:"issuer"`                  // https://sso.corp.local/realms/infra
+	ClientID     string   `yaml:"client_id"`               // Client confidentiel ou public
+	ClientSecret string   `yaml:"client_secret,omitempty"` // Chiffrable (ENC:...) ; vide pour un client public
+	RedirectURL  string   `yaml:"redirect_url"`            // https://monitoring.corp.local/auth/oidc/callback
+	Scopes       []string `yaml:"scopes,omitempty"`
+	CACert       string   `yaml:"ca_cert,omitempty"` // Autorité du fournisseur (PEM), sinon magasin système
+	// Claims lus dans le jeton d'identité ; un chemin pointé désigne un claim imbriqué (realm_access.roles)
+	UsernameClaim string `yaml:"username_claim,omitempty"`
+	RolesClaim    string `yaml:"roles_claim,omitempty"`
+	// Valeurs du claim de rôles et rôle attribué : la première valeur correspondante l'emporte
+	RoleMappings []OIDCRoleMapping `yaml:"role_mappings"`
+	DefaultRole  string            `yaml:"default_role,omitempty"` // Sans valeur correspondante (vide = connexion refusée)
+	ButtonLabel  string            `yaml:"button_label,omitempty"` // Libellé du bouton sur la page de connexion
+}
+
+// OIDCRoleMapping associe une valeur du claim de rôles (groupe ou rôle du fournisseur) à un rôle
+type OIDCRoleMapping struct {
+	Value string `yaml:"value"`
+	Role  string `yaml:"role"`
+}
+
+// AuditConfig décrit les destinations du journal d'audit en plus de la table SQLite. Chaque destination
+// a sa file d'attente : une panne du serveur syslog ne bloque pas les requêtes.
+type AuditConfig struct {
+	Syslog     *AuditSyslogConfig `yaml:"syslog,omitempty"`
+	File       *AuditFileConfig   `yaml:"file,omitempty"`
+	BufferSize int                `yaml:"buffer_size,omitempty"` // Événements en attente par destination (défaut: 1000)
+}
+
+// AuditSyslogConfig décrit l'envoi des événements d'audit en syslog RFC 5424
+type AuditSyslogConfig struct {
+	Network  string `yaml:"network,omitempty"`  // udp (défaut), tcp ou tls
+	Address  string `yaml:"address"`            // siem.corp.local:514
+	CACert   string `yaml:"ca_cert,omitempty"`  // Autorité du serveur (PEM) en tls, sinon magasin système
+	Facility string `yaml:"facility,omitempty"` // défaut: authpriv
+	AppName  string `yaml:"app_name,omitempty"` // défaut: go-monitoring
+	Hostname string `yaml:"hostname,omitempty"` // défaut: nom de la machine
+	// Délimitation des messages en tcp/tls : octet-counting (RFC 6587, défaut) ou newline
+	Framing string `yaml:"framing,omitempty"`
+}
+
+// AuditFileConfig décrit le fichier JSON lines (un événement par ligne, ajout seul) et sa rotation
+type AuditFileConfig struct {
+	Path       string `yaml:"path"`                  // /var/log/go-monitoring/audit.jsonl
+	MaxSizeMB  int    `yaml:"max_size_mb,omitempty"` // Taille avant rotation (défaut: 100)
+	MaxBackups int    `yaml:"max_backups,omitempty"` // Fichiers conservés : audit.jsonl.1 à .N (défaut: 10, -1 pour aucun)
+}
+
 // MachineConfig représente la configuration d'une machine
 type MachineConfig struct {
 	ID       string   `yaml:"id" json:"id"`
@@ -35,6 +154,130 @@ type MachineConfig struct {
 	Group    string   `yaml:"group,omitempty" json:"group,omitempty"`
 	OS       string   `yaml:"os,omitempty" json:"os,omitempty"` // "linux", "windows"
 	Services []string `yaml:"services,omitempty" json:"services,omitempty"`
+	// Les checks ne proviennent que du fichier de configuration, jamais de l'API
+	Checks []CheckConfig `yaml:"checks,omitempty" json:"-"`
+	// Chemins de certificats à surveiller (Linux: fichiers/répertoires, Windows: magasin Cert:\...)
+	CertPaths []string `yaml:"cert_paths,omitempty" json:"-"`
+	// Règles d'alerte sur les logs (fichier de configuration uniquement)
+	LogRules []LogRuleConfig `yaml:"log_rules,omitempty" json:"-"`
+	// Sources de logs supplémentaires (modifiables uniquement par un administrateur)
+	LogSources []LogSourceConfig `yaml:"log_sources,omitempty" json:"-"`
+}
+
+// Types de sources de logs personnalisées
+const (
+	LogSourceFile     = "file"     // Fichier ou motif glob (Linux)
+	LogSourceJournal  = "journal"  // Unité systemd (Linux)
+	LogSourceDocker   = "docker"   // Conteneur Docker (Linux)
+	LogSourceEventLog = "eventlog" // Canal du journal d'événements (Windows)
+)
+
+// LogSourceConfig décrit une source de logs propre à une machine
+type LogSourceConfig struct {
+	Type      string `yaml:"type" json:"type"`
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
+	Path      string `yaml:"path,omitempty" json:"path,omitempty"`           // file: chemin ou glob (ex: /opt/app/logs/*.log)
+	Unit      string `yaml:"unit,omitempty" json:"unit,omitempty"`           // journal: unité systemd
+	Container string `yaml:"container,omitempty" json:"container,omitempty"` // docker: nom du conteneur
+	Channel   string `yaml:"channel,omitempty" json:"channel,omitempty"`     // eventlog: ex "Microsoft-Windows-PowerShell/Operational"
+}
+
+// CheckConfig décrit un check personnalisé (compatible plugins Nagios)
+type CheckConfig struct {
+	Name     string `yaml:"name" json:"name"`
+	Command  string `yaml:"command" json:"-"`
+	Interval int    `yaml:"interval,omitempty" json:"interval"` // secondes
+	Timeout  int    `yaml:"timeout,omitempty" json:"timeout"`   // secondes
+}
+
+// LogRuleConfig décrit une règle d'alerte sur les logs : plus de Threshold lignes
+// correspondant à Pattern dans la fenêtre Window déclenchent une alerte.
+type LogRuleConfig struct {
+	Name      string `yaml:"name" json:"name"`
+	Source    string `yaml:"source" json:"source"`   // ID de source (ex: file--var-log-kern.log, journal-sys, win-system)
+	Pattern   string `yaml:"pattern" json:"pattern"` // Expression régulière (RE2)
+	Threshold int    `yaml:"threshold,omitempty" json:"threshold"`
+	Window    int    `yaml:"window,omitempty" json:"window"`     // secondes
+	Interval  int    `yaml:"interval,omitempty" json:"interval"` // secondes
+	Severity  string `yaml:"severity,omitempty" json:"severity"` // warning, critical
+}
+
+// CommandTemplate décrit une commande prédéfinie (lecture seule de préférence) que les rôles
+// ayant la capacité commands peuvent lancer sur les machines de leurs groupes.
+// Les administrateurs peuvent en plus lancer des commandes libres.
+type CommandTemplate struct {
+	Name           string `yaml:"name" json:"name"`
+	Description    string `yaml:"description,omitempty" json:"description,omitempty"`
+	Command        string `yaml:"command" json:"command"`                                     // Linux (sh)
+	WindowsCommand string `yaml:"windows_command,omitempty" json:"windows_command,omitempty"` // Windows (shell OpenSSH)
+	Timeout        int    `yaml:"timeout,omitempty" json:"timeout"`                           // secondes, par machine
+}
+
+// Runbook est une procédure de remédiation : une suite de commandes ou d'actions sur des services
+// exécutées sur la machine d'une alerte, automatiquement ou à la demande depuis la page des alertes
+type Runbook struct {
+	Name        string           `yaml:"name" json:"name"`
+	Description string           `yaml:"description,omitempty" json:"description,omitempty"`
+	Steps       []RunbookStep    `yaml:"steps" json:"steps"`
+	Triggers    []RunbookTrigger `yaml:"triggers,omitempty" json:"triggers,omitempty"`
+	// Exécutions automatiques : délai minimal entre deux exécutions sur une même machine (secondes)
+	// et nombre maximal d'exécutions par heure, toutes machines confondues
+	Cooldown       int `yaml:"cooldown,omitempty" json:"cooldown"`
+	MaxRunsPerHour int `yaml:"max_runs_per_hour,omitempty" json:"max_runs_per_hour"`
+}
+
+// RunbookStep est une étape de runbook : une commande (Command/WindowsCommand)
+// ou une action sur un service (Service + Action : start, stop, restart)
+type RunbookStep struct {
+	Name            string `yaml:"name,omitempty" json:"name,omitempty"`
+	Command         string `yaml:"command,omitempty" json:"command,omitempty"`
+	WindowsCommand  string `yaml:"windows_command,omitempty" json:"windows_command,omitempty"`
+	Service         string `yaml:"service,omitempty" json:"service,omitempty"`
+	Action          string `yaml:"action,omitempty" json:"action,omitempty"`
+	Timeout         int    `yaml:"timeout,omitempty" json:"timeout"`                               // secondes (commandes)
+	ContinueOnError bool   `yaml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"` // Poursuivre si l'étape échoue
+}
+
+// RunbookTrigger associe un runbook aux alertes d'une règle : source (check, log, probe, certificate, forecast)
+// et nom de la règle (check, règle de logs, point de montage...), vide pour toutes les alertes de la source
+type RunbookTrigger struct {
+	Source   string   `yaml:"source" json:"source"`
+	Name     string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Severity string   `yaml:"severity,omitempty" json:"severity,omitempty"` // critical : alertes critiques uniquement
+	Groups   []string `yaml:"groups,omitempty" json:"groups,omitempty"`     // Limiter à des groupes de machines
+	Auto     bool     `yaml:"auto,omitempty" json:"auto"`                   // Exécution automatique (sinon bouton sur l'alerte)
+}
+
+// Matches indique si une alerte levée sur une machine du groupe donné déclenche le trigger
+func (t RunbookTrigger) Matches(source, name, severity, group string) bool {
+	if t.Source != source || (t.Name != "" && t.Name != name) {
+		return false
+	}
+	if t.Severity == "critical" && severity != "critical" {
+		return false
+	}
+	return len(t.Groups) == 0 || slices.Contains(t.Groups, group)
+}
+
+// Types de sondes synthétiques
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeTLS  = "tls"
+)
+
+// ProbeConfig décrit une sonde synthétique (HTTP(S), connexion TCP, certificat TLS).
+// Sans "via", la sonde est exécutée depuis le serveur GoMonitoring.
+type ProbeConfig struct {
+	Name           string `yaml:"name" json:"name"`
+	Type           string `yaml:"type" json:"type"`                                   // "http", "tcp", "tls"
+	Target         string `yaml:"target" json:"target"`                               // URL (http) ou host:port (tcp, tls)
+	Via            string `yaml:"via,omitempty" json:"via,omitempty"`                 // ID de la machine qui exécute la sonde
+	Interval       int    `yaml:"interval,omitempty" json:"interval"`                 // secondes
+	Timeout        int    `yaml:"timeout,omitempty" json:"timeout"`                   // secondes
+	ExpectStatus   int    `yaml:"expect_status,omitempty" json:"expect_status"`       // http: code attendu (défaut 200)
+	MaxLatencyMs   int    `yaml:"max_latency_ms,omitempty" json:"max_latency_ms"`     // avertissement si dépassé
+	CertExpiryDays int    `yaml:"cert_expiry_days,omitempty" json:"cert_expiry_days"` // tls: avertissement si expiration < N jours
 }
 
 // Thresholds contient les seuils d'alerte pour la conformité
@@ -42,6 +285,10 @@ type Thresholds struct {
 	DiskMinPercent   float64 `yaml:"disk_min_percent"`   // Alerte si espace libre < X%
 	MemoryMinPercent float64 `yaml:"memory_min_percent"` // Alerte si mémoire libre < X%
 	CPUMaxPercent    float64 `yaml:"cpu_max_percent"`    // Alerte si CPU > X%
+	CertWarningDays  int     `yaml:"cert_warning_days"`  // Alerte si un certificat expire dans moins de X jours
+	// Prévision de remplissage des disques : alerte si le disque sera plein dans moins de X jours
+	DiskFullWarningDays  int `yaml:"disk_full_warning_days"`
+	DiskFullCriticalDays int `yaml:"disk_full_critical_days"`
 }
 
 // Settings contient les paramètres généraux
@@ -49,6 +296,32 @@ type Settings struct {
 	RefreshInterval int        `yaml:"refresh_interval"`
 	SSHTimeout      int        `yaml:"ssh_timeout"`
 	Thresholds      Thresholds `yaml:"thresholds,omitempty"`
+	// Répertoire des enregistrements de sessions terminal (défaut: recordings)
+	RecordingsDir string `yaml:"recordings_dir,omitempty"`
+	// Limites des transferts de fichiers en Mo (défaut: 100 en téléchargement, 20 en envoi)
+	MaxDownloadMB int `yaml:"max_download_mb,omitempty"`
+	MaxUploadMB   int `yaml:"max_upload_mb,omitempty"`
+	// Exécution de commandes sur plusieurs machines : connexions simultanées et délai par machine (secondes)
+	CommandConcurrency int `yaml:"command_concurrency,omitempty"`
+	CommandTimeout     int `yaml:"command_timeout,omitempty"`
+	// Sessions de connexion (minutes) : expiration après inactivité et durée maximale (défaut: 480 et 1440)
+	SessionIdleTimeout     int `yaml:"session_idle_timeout,omitempty"`
+	SessionAbsoluteTimeout int `yaml:"session_absolute_timeout,omitempty"`
+	// Rôles tenus d'activer la double authentification (TOTP), ex: [admin]
+	RequireTOTPRoles []string `yaml:"require_totp_roles,omitempty"`
+	// Règles des mots de passe des comptes locaux
+	PasswordPolicy PasswordPolicy `yaml:"password_policy,omitempty"`
+	// Conservation du journal d'audit en jours (défaut: 365, -1 pour tout conserver)
+	AuditRetentionDays int `yaml:"audit_retention_days,omitempty"`
+}
+
+// PasswordPolicy encadre les mots de passe locaux (création, changement et réinitialisation).
+// Les mots de passe courants (liste embarquée) et ceux contenant le nom d'utilisateur sont toujours refusés.
+type PasswordPolicy struct {
+	MinLength  int `yaml:"min_length,omitempty"`  // Défaut: 12
+	MinClasses int `yaml:"min_classes,omitempty"` // Types parmi minuscules, majuscules, chiffres et symboles (défaut: 3)
+	History    int `yaml:"history,omitempty"`     // Derniers mots de passe non réutilisables (défaut: 5, -1 pour désactiver)
+	ExpiryDays int `yaml:"expiry_days,omitempty"` // Changement imposé après N jours (défaut: 0, pas d'expiration)
 }
 
 // LoadConfig charge la configuration depuis un fichier YAML
@@ -70,6 +343,31 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Settings.SSHTimeout == 0 {
 		cfg.Settings.SSHTimeout = 10
 	}
+	if cfg.Settings.RecordingsDir == "" {
+		cfg.Settings.RecordingsDir = "recordings"
+	}
+	if cfg.Settings.MaxDownloadMB == 0 {
+		cfg.Settings.MaxDownloadMB = 100
+	}
+	if cfg.Settings.MaxUploadMB == 0 {
+		cfg.Settings.MaxUploadMB = 20
+	}
+	if cfg.Settings.CommandConcurrency <= 0 {
+		cfg.Settings.CommandConcurrency = 10
+	}
+	if cfg.Settings.CommandTimeout <= 0 {
+		cfg.Settings.CommandTimeout = 30
+	}
+	if cfg.Settings.SessionIdleTimeout <= 0 {
+		cfg.Settings.SessionIdleTimeout = 480
+	}
+	if cfg.Settings.SessionAbsoluteTimeout <= 0 {
+		cfg.Settings.SessionAbsoluteTimeout = 1440
+	}
+	cfg.Settings.PasswordPolicy = normalizePasswordPolicy(cfg.Settings.PasswordPolicy)
+	if cfg.Settings.AuditRetentionDays == 0 {
+		cfg.Settings.AuditRetentionDays = 365 // Négatif : pas de purge (conservé tel quel à l'enregistrement)
+	}
 	// Seuils par défaut pour la conformité
 	if cfg.Settings.Thresholds.DiskMinPercent == 0 {
 		cfg.Settings.Thresholds.DiskMinPercent = 10 // Alerte si < 10% libre
@@ -80,6 +378,15 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Settings.Thresholds.CPUMaxPercent == 0 {
 		cfg.Settings.Thresholds.CPUMaxPercent = 90 // Alerte si > 90%
 	}
+	if cfg.Settings.Thresholds.CertWarningDays == 0 {
+		cfg.Settings.Thresholds.CertWarningDays = 30 // Alerte si expiration < 30 jours
+	}
+	if cfg.Settings.Thresholds.DiskFullWarningDays == 0 {
+		cfg.Settings.Thresholds.DiskFullWarningDays = 7 // Alerte si plein dans < 7 jours
+	}
+	if cfg.Settings.Thresholds.DiskFullCriticalDays == 0 {
+		cfg.Settings.Thresholds.DiskFullCriticalDays = 2 // Critique si plein dans < 2 jours
+	}
 
 	// Valeurs par défaut pour les machines et déchiffrement des passwords
 	for i := range cfg.Machines {
@@ -87,6 +394,11 @@ func LoadConfig(path string) (*Config, error) {
 			cfg.Machines[i].Port = 22
 		}
 
+		cfg.Machines[i].Checks = normalizeChecks(cfg.Machines[i].ID, cfg.Machines[i].Checks)
+		cfg.Machines[i].LogRules = normalizeLogRules(cfg.Machines[i].ID, cfg.Machines[i].LogRules)
+		cfg.Machines[i].CertPaths = normalizeCertPaths(cfg.Machines[i])
+		cfg.Machines[i].LogSources = normalizeLogSources(cfg.Machines[i])
+
 		// Déchiffrer le password s'il est chiffré
 		if cfg.Machines[i].Password != "" {
 			if crypto.IsEncrypted(cfg.Machines[i].Password) {
@@ -101,9 +413,557 @@ func LoadConfig(path string) (*Config, error) {
 		}
 	}
 
+	cfg.Probes = normalizeProbes(cfg.Probes)
+	cfg.Commands = normalizeCommands(cfg.Commands, cfg.Settings.CommandTimeout)
+	cfg.Runbooks = normalizeRunbooks(cfg.Runbooks, cfg.Settings.CommandTimeout)
+	cfg.Permissions = normalizePermissions(cfg.Permissions)
+	cfg.LDAP = normalizeLDAP(cfg.LDAP, &cfg)
+	cfg.OIDC = normalizeOIDC(cfg.OIDC, &cfg)
+	cfg.Audit = normalizeAudit(cfg.Audit)
+
 	return &cfg, nil
 }
 
+// normalizePasswordPolicy applique les valeurs par défaut ; après normalisation, History 0 désactive
+// le contrôle de réutilisation
+func normalizePasswordPolicy(p PasswordPolicy) PasswordPolicy {
+	if p.MinLength <= 0 {
+		p.MinLength = 12
+	}
+	if p.MinLength > 72 { // Limite de bcrypt
+		p.MinLength = 72
+	}
+	if p.MinClasses <= 0 {
+		p.MinClasses = 3
+	}
+	if p.MinClasses > 4 {
+		p.MinClasses = 4
+	}
+	switch {
+	case p.History == 0:
+		p.History = 5
+	case p.History < 0:
+		p.History = 0
+	case p.History > 24:
+		p.History = 24
+	}
+	if p.ExpiryDays < 0 {
+		p.ExpiryDays = 0
+	}
+	return p
+}
+
+// normalizePermissions écarte les capacités inconnues et applique les permissions par défaut
+// (lecture des logs pour le rôle user) lorsque la section est absente
+func normalizePermissions(perms map[string][]PermissionGrant) map[string][]PermissionGrant {
+	if perms == nil {
+		return map[string][]PermissionGrant{
+			"user": {{Capabilities: []string{CapabilityLogs}}},
+		}
+	}
+
+	valid := make(map[string][]PermissionGrant, len(perms))
+	for role, grants := range perms {
+		if role == RoleAdmin || security.ValidateServiceName(role) != nil {
+			log.Printf("AVERTISSEMENT: Permissions du rôle '%s' ignorées (rôle réservé ou nom invalide)", role)
+			continue
+		}
+		valid[role] = []PermissionGrant{}
+		for _, g := range grants {
+			var caps []string
+			for _, c := range g.Capabilities {
+				switch c {
+				case CapabilityTerminal, CapabilityFiles, CapabilityLogs, CapabilityServices, CapabilityCommands:
+					caps = append(caps, c)
+				default:
+					log.Printf("AVERTISSEMENT: Capacité '%s' ignorée pour le rôle '%s'", c, role)
+				}
+			}
+			if len(caps) > 0 {
+				valid[role] = append(valid[role], PermissionGrant{Capabilities: caps, Groups: g.Groups})
+			}
+		}
+	}
+	return valid
+}
+
+// normalizeLDAP applique les valeurs par défaut (Active Directory) et écarte les associations
+// vers des rôles inconnus ; sans URL ni base de recherche, l'annuaire est désactivé
+func normalizeLDAP(l *LDAPConfig, cfg *Config) *LDAPConfig {
+	if l == nil {
+		return nil
+	}
+	if l.URL == "" || l.BaseDN == "" {
+		log.Printf("AVERTISSEMENT: Annuaire LDAP ignoré (url et base_dn obligatoires)")
+		return nil
+	}
+	if l.UserFilter == "" {
+		l.UserFilter = "(&(objectClass=user)(sAMAccountName=%s))"
+	}
+	if l.GroupAttribute == "" {
+		l.GroupAttribute = "memberOf"
+	}
+	if l.CacheTTL <= 0 {
+		l.CacheTTL = 300
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = 5
+	}
+
+	var mappings []LDAPGroupMapping
+	for _, m := range l.GroupMappings {
+		if m.Group == "" || !cfg.HasRole(m.Role) {
+			log.Printf("AVERTISSEMENT: Association LDAP '%s' -> '%s' ignorée (groupe vide ou rôle inconnu)", m.Group, m.Role)
+			continue
+		}
+		mappings = append(mappings, m)
+	}
+	l.GroupMappings = mappings
+	if l.DefaultRole != "" && !cfg.HasRole(l.DefaultRole) {
+		log.Printf("AVERTISSEMENT: Rôle LDAP par défaut '%s' ignoré (rôle inconnu)", l.DefaultRole)
+		l.DefaultRole = ""
+	}
+	return l
+}
+
+// normalizeOIDC applique les valeurs par défaut (Keycloak) et écarte les associations vers des rôles
+// inconnus ; sans émetteur, client ou URL de retour, le SSO est désactivé
+func normalizeOIDC(o *OIDCConfig, cfg *Config) *OIDCConfig {
+	if o == nil {
+		return nil
+	}
+	if o.Issuer == "" || o.ClientID == "" || o.RedirectURL == "" {
+		log.Printf("AVERTISSEMENT: SSO OIDC ignoré (issuer, client_id et redirect_url obligatoires)")
+		return nil
+	}
+	if !slices.Contains(o.Scopes, "openid") {
+		o.Scopes = append([]string{"openid"}, o.Scopes...)
+	}
+	if len(o.Scopes) == 1 {
+		o.Scopes = append(o.Scopes, "profile", "email")
+	}
+	if o.UsernameClaim == "" {
+		o.UsernameClaim = "preferred_username"
+	}
+	if o.RolesClaim == "" {
+		o.RolesClaim = "groups"
+	}
+	if o.ButtonLabel == "" {
+		o.ButtonLabel = "Connexion SSO"
+	}
+
+	var mappings []OIDCRoleMapping
+	for _, m := range o.RoleMappings {
+		if m.Value == "" || !cfg.HasRole(m.Role) {
+			log.Printf("AVERTISSEMENT: Association OIDC '%s' -> '%s' ignorée (valeur vide ou rôle inconnu)", m.Value, m.Role)
+			continue
+		}
+		mappings = append(mappings, m)
+	}
+	o.RoleMappings = mappings
+	if o.DefaultRole != "" && !cfg.HasRole(o.DefaultRole) {
+		log.Printf("AVERTISSEMENT: Rôle OIDC par défaut '%s' ignoré (rôle inconnu)", o.DefaultRole)
+		o.DefaultRole = ""
+	}
+	return o
+}
+
+// normalizeAudit applique les valeurs par défaut des destinations du journal d'audit et écarte
+// celles qui sont incomplètes ou invalides
+func normalizeAudit(a *AuditConfig) *AuditConfig {
+	if a == nil {
+		return nil
+	}
+	if s := a.Syslog; s != nil {
+		if s.Network == "" {
+			s.Network = "udp"
+		}
+		if s.Framing == "" {
+			s.Framing = "octet-counting"
+		}
+		if s.Facility == "" {
+			s.Facility = "authpriv"
+		}
+		if s.AppName == "" {
+			s.AppName = "go-monitoring"
+		}
+		switch {
+		case s.Address == "":
+			log.Printf("AVERTISSEMENT: Transfert syslog de l'audit ignoré (address obligatoire)")
+			a.Syslog = nil
+		case s.Network != "udp" && s.Network != "tcp" && s.Network != "tls":
+			log.Printf("AVERTISSEMENT: Transfert syslog de l'audit ignoré (network '%s' : udp, tcp ou tls)", s.Network)
+			a.Syslog = nil
+		case s.Framing != "octet-counting" && s.Framing != "newline":
+			log.Printf("AVERTISSEMENT: Transfert syslog de l'audit ignoré (framing '%s' : octet-counting ou newline)", s.Framing)
+			a.Syslog = nil
+		}
+	}
+	if f := a.File; f != nil {
+		if f.MaxSizeMB <= 0 {
+			f.MaxSizeMB = 100
+		}
+		// Après normalisation, MaxBackups 0 : le fichier est vidé à la rotation, sans copie
+		switch {
+		case f.MaxBackups == 0:
+			f.MaxBackups = 10
+		case f.MaxBackups < 0:
+			f.MaxBackups = 0
+		}
+		if f.Path == "" {
+			log.Printf("AVERTISSEMENT: Fichier d'audit JSON ignoré (path obligatoire)")
+			a.File = nil
+		}
+	}
+	if a.BufferSize <= 0 {
+		a.BufferSize = 1000
+	}
+	if a.Syslog == nil && a.File == nil {
+		return nil
+	}
+	return a
+}
+
+// Roles retourne les rôles attribuables aux utilisateurs (admin, user et rôles déclarés)
+func (c *Config) Roles() []string {
+	roles := []string{RoleAdmin, "user"}
+	var declared []string
+	for role := range c.Permissions {
+		if role != "user" {
+			declared = append(declared, role)
+		}
+	}
+	sort.Strings(declared)
+	return append(roles, declared...)
+}
+
+// HasRole indique si un rôle est attribuable
+func (c *Config) HasRole(role string) bool {
+	for _, r := range c.Roles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxCommandTimeout est le délai maximal d'exécution d'une commande sur une machine (secondes)
+const MaxCommandTimeout = 600
+
+// normalizeCommands écarte les commandes prédéfinies invalides et applique le délai par défaut
+func normalizeCommands(commands []CommandTemplate, defaultTimeout int) []CommandTemplate {
+	var valid []CommandTemplate
+	seen := make(map[string]bool)
+	for _, c := range commands {
+		if err := security.ValidateServiceName(c.Name); err != nil || seen[c.Name] {
+			log.Printf("AVERTISSEMENT: Commande '%s' ignorée (nom invalide ou dupliqué)", c.Name)
+			continue
+		}
+		if strings.TrimSpace(c.Command) == "" && strings.TrimSpace(c.WindowsCommand) == "" {
+			log.Printf("AVERTISSEMENT: Commande '%s' ignorée (commande vide)", c.Name)
+			continue
+		}
+		seen[c.Name] = true
+
+		if c.Timeout <= 0 {
+			c.Timeout = defaultTimeout
+		}
+		c.Timeout = min(c.Timeout, MaxCommandTimeout)
+		valid = append(valid, c)
+	}
+	return valid
+}
+
+// Valeurs par défaut des limites d'exécution automatique des runbooks
+const (
+	DefaultRunbookCooldown       = 900 // secondes
+	DefaultRunbookMaxRunsPerHour = 3
+)
+
+// normalizeRunbooks écarte les runbooks et étapes invalides et applique les limites par défaut
+func normalizeRunbooks(runbooks []Runbook, defaultTimeout int) []Runbook {
+	var valid []Runbook
+	seen := make(map[string]bool)
+	for _, rb := range runbooks {
+		if err := security.ValidateServiceName(rb.Name); err != nil || seen[rb.Name] {
+			log.Printf("AVERTISSEMENT: Runbook '%s' ignoré (nom invalide ou dupliqué)", rb.Name)
+			continue
+		}
+
+		var steps []RunbookStep
+		for i, st := range rb.Steps {
+			if err := validateRunbookStep(st); err != nil {
+				log.Printf("AVERTISSEMENT: Runbook '%s': étape %d ignorée: %v", rb.Name, i+1, err)
+				continue
+			}
+			if st.Timeout <= 0 {
+				st.Timeout = defaultTimeout
+			}
+			st.Timeout = min(st.Timeout, MaxCommandTimeout)
+			steps = append(steps, st)
+		}
+		if len(steps) == 0 {
+			log.Printf("AVERTISSEMENT: Runbook '%s' ignoré (aucune étape valide)", rb.Name)
+			continue
+		}
+		rb.Steps = steps
+
+		var triggers []RunbookTrigger
+		for _, t := range rb.Triggers {
+			if t.Source == "" || (t.Severity != "" && t.Severity != "warning" && t.Severity != "critical") {
+				log.Printf("AVERTISSEMENT: Runbook '%s': trigger ignoré (source manquante ou sévérité invalide)", rb.Name)
+				continue
+			}
+			triggers = append(triggers, t)
+		}
+		rb.Triggers = triggers
+
+		if rb.Cooldown <= 0 {
+			rb.Cooldown = DefaultRunbookCooldown
+		}
+		if rb.MaxRunsPerHour <= 0 {
+			rb.MaxRunsPerHour = DefaultRunbookMaxRunsPerHour
+		}
+		seen[rb.Name] = true
+		valid = append(valid, rb)
+	}
+	return valid
+}
+
+// validateRunbookStep vérifie qu'une étape est soit une commande, soit une action sur un service valide
+func validateRunbookStep(st RunbookStep) error {
+	hasCommand := strings.TrimSpace(st.Command) != "" || strings.TrimSpace(st.WindowsCommand) != ""
+	switch {
+	case hasCommand && st.Service != "":
+		return fmt.Errorf("commande et service sont exclusifs")
+	case hasCommand:
+		return nil
+	case st.Service == "":
+		return fmt.Errorf("commande ou service requis")
+	}
+	if err := security.ValidateServiceName(st.Service); err != nil {
+		return err
+	}
+	return security.ValidateAction(st.Action)
+}
+
+// normalizeProbes applique les valeurs par défaut et écarte les sondes invalides
+func normalizeProbes(probes []ProbeConfig) []ProbeConfig {
+	var valid []ProbeConfig
+	seen := make(map[string]bool)
+	for _, p := range probes {
+		if err := security.ValidateServiceName(p.Name); err != nil || seen[p.Name] {
+			log.Printf("AVERTISSEMENT: Sonde '%s' ignorée (nom invalide ou dupliqué)", p.Name)
+			continue
+		}
+		if err := validateProbeTarget(p); err != nil {
+			log.Printf("AVERTISSEMENT: Sonde '%s' ignorée: %v", p.Name, err)
+			continue
+		}
+		seen[p.Name] = true
+
+		if p.Interval <= 0 {
+			p.Interval = 60
+		}
+		if p.Timeout <= 0 {
+			p.Timeout = 10
+		}
+		if p.Type == ProbeHTTP && p.ExpectStatus == 0 {
+			p.ExpectStatus = 200
+		}
+		if p.Type == ProbeTLS && p.CertExpiryDays == 0 {
+			p.CertExpiryDays = 21
+		}
+		valid = append(valid, p)
+	}
+	return valid
+}
+
+// validateProbeTarget vérifie la forme de la cible d'une sonde (les sondes exécutées via SSH
+// la transmettent à curl/nc/openssl sous forme littérale, voir security.ShellLiteral)
+func validateProbeTarget(p ProbeConfig) error {
+	switch p.Type {
+	case ProbeHTTP:
+		u, err := url.Parse(p.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("URL invalide '%s'", p.Target)
+		}
+	case ProbeTCP, ProbeTLS:
+		host, port, err := net.SplitHostPort(p.Target)
+		if err != nil || host == "" {
+			return fmt.Errorf("cible invalide '%s' (attendu host:port)", p.Target)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("port invalide '%s'", port)
+		}
+	default:
+		return fmt.Errorf("type inconnu '%s'", p.Type)
+	}
+	return nil
+}
+
+// windowsCertStore accepte uniquement les magasins de certificats PowerShell (ex: Cert:\LocalMachine\My)
+var windowsCertStore = regexp.MustCompile(`^Cert:\\(LocalMachine|CurrentUser)\\[A-Za-z]+$`)
+
+var (
+	// Les globs sont développés par le shell distant : jeu de caractères restreint, sans espace ni guillemet
+	logGlobRegex = regexp.MustCompile(`^/[A-Za-z0-9._/*?\[\]-]+$`)
+	// Unités systemd (ex: worker@1.service)
+	logUnitRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9@._:-]{0,255}$`)
+	// Canaux Windows (ex: Microsoft-Windows-PowerShell/Operational)
+	logChannelRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._/-]{0,255}$`)
+)
+
+// ValidateLogSourceConfig vérifie une source de logs personnalisée pour le système donné
+func ValidateLogSourceConfig(s LogSourceConfig, osType string) error {
+	windows := osType == "windows"
+	switch s.Type {
+	case LogSourceFile:
+		if windows {
+			return fmt.Errorf("les sources de type fichier ne sont disponibles que sous Linux")
+		}
+		// Hors de la liste blanche : seuls les fichiers correspondant à ce chemin ou motif seront lus
+		if err := security.ValidatePath(s.Path); err != nil || !logGlobRegex.MatchString(s.Path) {
+			return fmt.Errorf("chemin de log invalide '%s'", s.Path)
+		}
+	case LogSourceJournal:
+		if windows || !logUnitRegex.MatchString(s.Unit) {
+			return fmt.Errorf("unité systemd invalide '%s'", s.Unit)
+		}
+	case LogSourceDocker:
+		if windows || security.ValidateServiceName(s.Container) != nil {
+			return fmt.Errorf("conteneur Docker invalide '%s'", s.Container)
+		}
+	case LogSourceEventLog:
+		if !windows || !logChannelRegex.MatchString(s.Channel) {
+			return fmt.Errorf("canal d'événements Windows invalide '%s'", s.Channel)
+		}
+	default:
+		return fmt.Errorf("type de source de logs inconnu '%s'", s.Type)
+	}
+	if len(s.Name) > 128 || strings.ContainsAny(s.Name, "\r\n") {
+		return fmt.Errorf("nom de source de logs invalide")
+	}
+	return nil
+}
+
+// normalizeLogSources écarte les sources de logs personnalisées invalides
+func normalizeLogSources(m MachineConfig) []LogSourceConfig {
+	var valid []LogSourceConfig
+	for _, s := range m.LogSources {
+		if err := ValidateLogSourceConfig(s, m.OS); err != nil {
+			log.Printf("AVERTISSEMENT: Source de logs ignorée pour %s: %v", m.ID, err)
+			continue
+		}
+		valid = append(valid, s)
+	}
+	return valid
+}
+
+// SetLogSources remplace les sources de logs personnalisées d'une machine
+func (c *Config) SetLogSources(machineID string, sources []LogSourceConfig) error {
+	m := c.GetMachine(machineID)
+	if m == nil {
+		return fmt.Errorf("machine '%s' non trouvée", machineID)
+	}
+	for _, s := range sources {
+		if err := ValidateLogSourceConfig(s, m.OS); err != nil {
+			return err
+		}
+	}
+	m.LogSources = sources
+	return nil
+}
+
+// normalizeCertPaths écarte les chemins de certificats invalides.
+// Les chemins sont passés entre apostrophes à find/PowerShell : les apostrophes sont refusées.
+func normalizeCertPaths(m MachineConfig) []string {
+	var valid []string
+	for _, p := range m.CertPaths {
+		var err error
+		if m.OS == "windows" {
+			if !windowsCertStore.MatchString(p) {
+				err = fmt.Errorf("magasin de certificats invalide")
+			}
+		} else {
+			err = security.ValidatePath(p)
+			if err == nil && strings.ContainsAny(p, "'\"") {
+				err = security.ErrInvalidPath
+			}
+		}
+		if err != nil {
+			log.Printf("AVERTISSEMENT: Chemin de certificats '%s' ignoré pour %s: %v", p, m.ID, err)
+			continue
+		}
+		valid = append(valid, p)
+	}
+	return valid
+}
+
+// GetProbe retourne la configuration d'une sonde par son nom
+func (c *Config) GetProbe(name string) *ProbeConfig {
+	for i := range c.Probes {
+		if c.Probes[i].Name == name {
+			return &c.Probes[i]
+		}
+	}
+	return nil
+}
+
+// normalizeChecks applique les valeurs par défaut et écarte les checks invalides
+func normalizeChecks(machineID string, checks []CheckConfig) []CheckConfig {
+	var valid []CheckConfig
+	seen := make(map[string]bool)
+	for _, c := range checks {
+		if err := security.ValidateServiceName(c.Name); err != nil || c.Command == "" || seen[c.Name] {
+			log.Printf("AVERTISSEMENT: Check '%s' ignoré pour %s (nom invalide, dupliqué ou commande vide)", c.Name, machineID)
+			continue
+		}
+		seen[c.Name] = true
+
+		if c.Interval <= 0 {
+			c.Interval = 60
+		}
+		if c.Timeout <= 0 {
+			c.Timeout = 10
+		}
+		valid = append(valid, c)
+	}
+	return valid
+}
+
+// normalizeLogRules applique les valeurs par défaut et écarte les règles invalides
+func normalizeLogRules(machineID string, rules []LogRuleConfig) []LogRuleConfig {
+	var valid []LogRuleConfig
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		if err := security.ValidateServiceName(r.Name); err != nil || r.Source == "" || seen[r.Name] {
+			log.Printf("AVERTISSEMENT: Règle de logs '%s' ignorée pour %s (nom invalide, dupliqué ou source vide)", r.Name, machineID)
+			continue
+		}
+		if err := security.ValidateSearchPattern(r.Pattern); err != nil {
+			log.Printf("AVERTISSEMENT: Règle de logs '%s' ignorée pour %s (motif invalide)", r.Name, machineID)
+			continue
+		}
+		seen[r.Name] = true
+
+		if r.Threshold <= 0 {
+			r.Threshold = 1
+		}
+		if r.Window <= 0 {
+			r.Window = 300
+		}
+		if r.Interval <= 0 {
+			r.Interval = 60
+		}
+		if r.Severity != "critical" {
+			r.Severity = "warning"
+		}
+		valid = append(valid, r)
+	}
+	return valid
+}
+
 // GetMachine retourne la configuration d'une machine par son ID
 func (c *Config) GetMachine(id string) *MachineConfig {
 	for i := range c.Machines {
@@ -114,6 +974,26 @@ func (c *Config) GetMachine(id string) *MachineConfig {
 	return nil
 }
 
+// GetCommand retourne une commande prédéfinie par son nom
+func (c *Config) GetCommand(name string) *CommandTemplate {
+	for i := range c.Commands {
+		if c.Commands[i].Name == name {
+			return &c.Commands[i]
+		}
+	}
+	return nil
+}
+
+// GetRunbook retourne un runbook par son nom (nil s'il n'existe pas)
+func (c *Config) GetRunbook(name string) *Runbook {
+	for i := range c.Runbooks {
+		if c.Runbooks[i].Name == name {
+			return &c.Runbooks[i]
+		}
+	}
+	return nil
+}
+
 // SaveConfig sauvegarde la configuration dans un fichier YAML
 func SaveConfig(path string, cfg *Config) error {
 	data, err := yaml.Marshal(cfg)
@@ -164,6 +1044,12 @@ func (c *Config) UpdateMachine(machine MachineConfig) error {
 				machine.Port = 22
 			}
 
+			// Les checks, règles et sources de logs et chemins de certificats ne sont pas modifiables via cette API
+			machine.Checks = c.Machines[i].Checks
+			machine.LogRules = c.Machines[i].LogRules
+			machine.LogSources = c.Machines[i].LogSources
+			machine.CertPaths = c.Machines[i].CertPaths
+
 			// Chiffrer le password s'il est en clair (nouveau password)
 			if machine.Password != "" && !crypto.IsEncrypted(machine.Password) {
 				encrypted, err := crypto.Encrypt(machine.Password)
@@ -0,0 +1,140 @@
+// Package forecast estime la date de remplissage des systèmes de fichiers
+// à partir de leur historique d'occupation (régression de Theil-Sen).
+package forecast
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// Conditions minimales pour qu'une prévision soit publiée
+const (
+	MinSamples = 6
+	MinSpan    = 6 * time.Hour
+	// Au-delà, la prévision n'a plus de sens : le disque est considéré stable
+	MaxHorizonDays = 5 * 365
+	// Nombre de points conservés pour la régression (coût quadratique)
+	maxPoints = 200
+	// Écart de capacité au-delà duquel le système de fichiers est considéré redimensionné
+	resizeTolerance = 0.01
+)
+
+// ErrInsufficientData indique un historique trop court pour estimer une tendance
+var ErrInsufficientData = errors.New("historique insuffisant")
+
+// Disk calcule la prévision de remplissage d'un système de fichiers.
+// Seuls les relevés postérieurs au dernier redimensionnement sont pris en compte ;
+// la pente est robuste aux valeurs aberrantes (pics ponctuels, fichiers temporaires).
+func Disk(points []models.DiskUsagePoint, now time.Time) (models.DiskForecast, error) {
+	points = sinceLastResize(points)
+	if len(points) < MinSamples {
+		return models.DiskForecast{}, ErrInsufficientData
+	}
+	first, last := points[0], points[len(points)-1]
+	if last.Timestamp.Sub(first.Timestamp) < MinSpan {
+		return models.DiskForecast{}, ErrInsufficientData
+	}
+
+	points = downsample(points, maxPoints)
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		xs[i] = p.Timestamp.Sub(first.Timestamp).Hours() / 24
+		ys[i] = float64(p.Used)
+	}
+	slope, _ := TheilSen(xs, ys)
+
+	fc := models.DiskForecast{
+		Used:         last.Used,
+		Total:        last.Total,
+		GrowthPerDay: slope,
+		Samples:      len(points),
+		Since:        first.Timestamp,
+	}
+	if slope <= 0 {
+		return fc, nil
+	}
+
+	// Projection depuis le dernier relevé, ramenée à l'instant présent
+	days := float64(last.Total-min(last.Used, last.Total))/slope - now.Sub(last.Timestamp).Hours()/24
+	if days < 0 {
+		days = 0
+	}
+	if days > MaxHorizonDays {
+		return fc, nil
+	}
+
+	fc.Filling = true
+	fc.DaysToFull = days
+	fullAt := now.Add(time.Duration(days * 24 * float64(time.Hour)))
+	fc.FullAt = &fullAt
+	return fc, nil
+}
+
+// TheilSen retourne la pente (médiane des pentes entre toutes les paires de points)
+// et l'ordonnée à l'origine (médiane des y - pente*x)
+func TheilSen(xs, ys []float64) (slope, intercept float64) {
+	n := len(xs)
+	if n == 0 || n != len(ys) {
+		return 0, 0
+	}
+
+	slopes := make([]float64, 0, n*(n-1)/2)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if dx := xs[j] - xs[i]; dx != 0 {
+				slopes = append(slopes, (ys[j]-ys[i])/dx)
+			}
+		}
+	}
+	if len(slopes) == 0 {
+		return 0, median(ys)
+	}
+	slope = median(slopes)
+
+	residuals := make([]float64, n)
+	for i := range xs {
+		residuals[i] = ys[i] - slope*xs[i]
+	}
+	return slope, median(residuals)
+}
+
+// median trie values en place et retourne sa médiane
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// sinceLastResize ne garde que les relevés de même capacité que le plus récent
+func sinceLastResize(points []models.DiskUsagePoint) []models.DiskUsagePoint {
+	if len(points) == 0 {
+		return points
+	}
+	total := float64(points[len(points)-1].Total)
+	start := len(points) - 1
+	for start > 0 && math.Abs(float64(points[start-1].Total)-total) <= total*resizeTolerance {
+		start--
+	}
+	return points[start:]
+}
+
+// downsample réduit la série à n points régulièrement répartis (premier et dernier inclus)
+func downsample(points []models.DiskUsagePoint, n int) []models.DiskUsagePoint {
+	if len(points) <= n || n < 2 {
+		return points
+	}
+	out := make([]models.DiskUsagePoint, n)
+	step := float64(len(points)-1) / float64(n-1)
+	for i := range out {
+		out[i] = points[int(math.Round(float64(i)*step))]
+	}
+	return out
+}
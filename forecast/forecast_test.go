@@ -0,0 +1,126 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"go-monitoring/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gib = 1024 * 1024 * 1024
+
+// linearHistory génère un relevé par heure avec une croissance constante (octets/jour)
+func linearHistory(start time.Time, hours int, used0, total uint64, perDay float64) []models.DiskUsagePoint {
+	points := make([]models.DiskUsagePoint, hours)
+	for i := range points {
+		points[i] = models.DiskUsagePoint{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Used:      used0 + uint64(perDay*float64(i)/24),
+			Total:     total,
+		}
+	}
+	return points
+}
+
+func TestTheilSen(t *testing.T) {
+	xs := []float64{0, 1, 2, 3, 4, 5, 6}
+	ys := []float64{1, 3, 5, 7, 9, 11, 13}
+	slope, intercept := TheilSen(xs, ys)
+	assert.InDelta(t, 2, slope, 1e-9)
+	assert.InDelta(t, 1, intercept, 1e-9)
+
+	// Une valeur aberrante ne fausse pas la pente (contrairement aux moindres carrés)
+	ys[3] = 1000
+	slope, _ = TheilSen(xs, ys)
+	assert.InDelta(t, 2, slope, 1e-9)
+}
+
+func TestDisk_Filling(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := linearHistory(start, 72, 50*gib, 100*gib, 2*gib)
+	// Pic ponctuel (fichier temporaire supprimé ensuite)
+	points[40].Used += 20 * gib
+
+	last := points[len(points)-1]
+	fc, err := Disk(points, last.Timestamp)
+	require.NoError(t, err)
+
+	assert.True(t, fc.Filling)
+	assert.InDelta(t, 2*gib, fc.GrowthPerDay, 0.01*gib)
+	expected := float64(last.Total-last.Used) / (2 * gib)
+	assert.InDelta(t, expected, fc.DaysToFull, 0.1)
+	require.NotNil(t, fc.FullAt)
+	assert.Equal(t, last.Used, fc.Used)
+	assert.Equal(t, start, fc.Since)
+
+	// Le temps écoulé depuis le dernier relevé est déduit
+	later, err := Disk(points, last.Timestamp.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.InDelta(t, fc.DaysToFull-1, later.DaysToFull, 0.01)
+}
+
+func TestDisk_StableOrShrinking(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fc, err := Disk(linearHistory(start, 48, 60*gib, 100*gib, -gib), start.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, fc.Filling)
+	assert.Less(t, fc.GrowthPerDay, 0.0)
+	assert.Nil(t, fc.FullAt)
+
+	// Croissance infime : remplissage au-delà de l'horizon
+	fc, err = Disk(linearHistory(start, 48, 60*gib, 100*gib, 1024), start.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, fc.Filling)
+}
+
+func TestDisk_AlreadyFull(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := linearHistory(start, 24, 99*gib, 100*gib, gib)
+	fc, err := Disk(points, start.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, fc.Filling)
+	assert.Equal(t, 0.0, fc.DaysToFull)
+}
+
+func TestDisk_InsufficientData(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := Disk(linearHistory(start, MinSamples-1, gib, 10*gib, gib), start)
+	assert.ErrorIs(t, err, ErrInsufficientData)
+
+	// Assez de relevés mais sur une période trop courte
+	points := linearHistory(start, 10, gib, 10*gib, gib)
+	for i := range points {
+		points[i].Timestamp = start.Add(time.Duration(i) * time.Minute)
+	}
+	_, err = Disk(points, start)
+	assert.ErrorIs(t, err, ErrInsufficientData)
+}
+
+func TestDisk_IgnoresHistoryBeforeResize(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Avant extension : disque de 50 Go qui se remplissait vite
+	before := linearHistory(start, 48, 10*gib, 50*gib, 10*gib)
+	// Après extension à 200 Go : croissance de 1 Go/jour
+	after := linearHistory(start.Add(48*time.Hour), 24, 30*gib, 200*gib, gib)
+
+	fc, err := Disk(append(before, after...), after[len(after)-1].Timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, len(after), fc.Samples)
+	assert.InDelta(t, gib, fc.GrowthPerDay, 0.01*gib)
+	assert.Equal(t, uint64(200*gib), fc.Total)
+}
+
+func TestDownsample(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := linearHistory(start, 1000, 0, gib, gib)
+
+	out := downsample(points, maxPoints)
+	require.Len(t, out, maxPoints)
+	assert.Equal(t, points[0], out[0])
+	assert.Equal(t, points[len(points)-1], out[len(out)-1])
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+
+	"go-monitoring/alerts"
+	"go-monitoring/auth"
+	"go-monitoring/config"
+	"go-monitoring/middleware"
+	"go-monitoring/models"
+	"go-monitoring/storage"
+)
+
+// alertRunbook est un runbook proposé sur une alerte
+type alertRunbook struct {
+	Name        string
+	Description string
+	Auto        bool // Exécuté automatiquement à la levée de l'alerte
+}
+
+// recentRunbookRuns est le nombre d'exécutions de runbooks affichées sur la page des alertes
+const recentRunbookRuns = 20
+
+// AlertsPage affiche les alertes actives de toutes les sources, les runbooks associés
+// et les dernières exécutions de runbooks
+func AlertsPage(cm *ConfigManager, db *storage.DB, am *auth.AuthManager, alertManager *alerts.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := template.New("base.html").Funcs(templateFuncs).ParseFiles(
+			"templates/layout/base.html",
+			"templates/alerts.html",
+		)
+		if err != nil {
+			http.Error(w, "Erreur chargement template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Associer le nom de chaque machine pour l'affichage
+		cfg := cm.GetConfig()
+		machineNames := make(map[string]string, len(cfg.Machines))
+		for _, m := range cfg.Machines {
+			machineNames[m.ID] = m.Name
+		}
+
+		// Runbooks proposés sur chaque alerte (capacité services sur la machine)
+		active := visibleAlerts(r, am, alertManager.Active())
+		runbooks := make(map[string][]alertRunbook)
+		for _, a := range active {
+			if a.MachineID == "" || !am.Can(r, config.CapabilityServices, a.MachineID) {
+				continue
+			}
+			matched, auto := runbooksForAlert(cfg, a)
+			for i, rb := range matched {
+				runbooks[a.Key] = append(runbooks[a.Key], alertRunbook{Name: rb.Name, Description: rb.Description, Auto: auto[i]})
+			}
+		}
+
+		var runs []models.RunbookRun
+		if len(cfg.Runbooks) > 0 {
+			all, err := db.ListRunbookRuns("", "", recentRunbookRuns)
+			if err != nil {
+				log.Printf("Erreur lecture des exécutions de runbooks: %v", err)
+			}
+			runs = visibleRunbookRuns(r, am, all)
+		}
+
+		data := struct {
+			Title        string
+			Status       string
+			Role         string
+			Username     string
+			CSRFToken    string
+			Alerts       []alerts.Alert
+			MachineNames map[string]string
+			Runbooks     map[string][]alertRunbook // Par clé d'alerte
+			RunbookRuns  []models.RunbookRun
+		}{
+			Title:        "Alertes",
+			Status:       "OK",
+			Role:         am.GetUserRole(r),
+			Username:     am.GetUsername(r),
+			CSRFToken:    middleware.GetCSRFToken(r),
+			Alerts:       active,
+			MachineNames: machineNames,
+			Runbooks:     runbooks,
+			RunbookRuns:  runs,
+		}
+
+		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+			http.Error(w, "Erreur rendu template: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// visibleAlerts retire les alertes des machines que l'utilisateur ne voit pas
+// (les alertes sans machine restent visibles, comme dans le flux temps réel)
+func visibleAlerts(r *http.Request, am *auth.AuthManager, list []alerts.Alert) []alerts.Alert {
+	visible := am.Visible(r)
+	filtered := make([]alerts.Alert, 0, len(list))
+	for _, a := range list {
+		if a.MachineID == "" || visible(a.MachineID) {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
+
+// ListAlerts retourne les alertes actives en JSON (filtrables par machine)
+func ListAlerts(alertManager *alerts.Manager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var active []alerts.Alert
+		if machineID := r.URL.Query().Get("machine"); machineID != "" {
+			active = alertManager.ForMachine(machineID)
+		} else {
+			active = alertManager.Active()
+		}
+		active = visibleAlerts(r, am, active)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(active)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-monitoring/auth"
+	"go-monitoring/storage"
+)
+
+// Taille des pages du journal d'audit (page et API)
+const (
+	auditPerPage    = 50
+	auditMaxPerPage = 500
+)
+
+// auditQuery est un filtre du journal d'audit lu dans l'URL, avec sa pagination
+type auditQuery struct {
+	Filter  storage.AuditFilter
+	Page    int
+	PerPage int
+}
+
+// parseAuditQuery lit les filtres user, action, target, since, until et la pagination page, per_page.
+// since et until acceptent une durée relative (24h), une date (2006-01-02, until inclus) ou une date et heure.
+func parseAuditQuery(q url.Values) (auditQuery, error) {
+	aq := auditQuery{
+		Filter: storage.AuditFilter{
+			User:   q.Get("user"),
+			Action: q.Get("action"),
+			Target: q.Get("target"),
+		},
+		Page:    1,
+		PerPage: auditPerPage,
+	}
+
+	now := time.Now()
+	var err error
+	if aq.Filter.Since, err = parseAuditTime(q.Get("since"), now, false); err != nil {
+		return aq, fmt.Errorf("since invalide: %w", err)
+	}
+	if aq.Filter.Until, err = parseAuditTime(q.Get("until"), now, true); err != nil {
+		return aq, fmt.Errorf("until invalide: %w", err)
+	}
+
+	if v := q.Get("page"); v != "" {
+		if aq.Page, err = strconv.Atoi(v); err != nil || aq.Page < 1 {
+			return aq, fmt.Errorf("page invalide: %s", v)
+		}
+	}
+	if v := q.Get("per_page"); v != "" {
+		if aq.PerPage, err = strconv.Atoi(v); err != nil || aq.PerPage < 1 {
+			return aq, fmt.Errorf("per_page invalide: %s", v)
+		}
+		aq.PerPage = min(aq.PerPage, auditMaxPerPage)
+	}
+	aq.Filter.Limit = aq.PerPage
+	aq.Filter.Offset = (aq.Page - 1) * aq.PerPage
+	return aq, nil
+}
+
+// parseAuditTime complète parseSearchTime par les formats des champs date du navigateur (heure locale).
+// Une date seule en borne de fin inclut toute la journée.
+func parseAuditTime(value string, now time.Time, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	return parseSearchTime(value, now, time.Time{})
+}
+
+// ListAuditLogs retourne une page du journal d'audit filtré (plus récents d'abord)
+func ListAuditLogs(db *storage.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aq, err := parseAuditQuery(r.URL.Query())
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logs, total, err := db.SearchAuditLogs(aq.Filter)
+		if err != nil {
+			jsonError(w, "Erreur lecture du journal: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if logs == nil {
+			logs = []storage.AuditLog{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"logs":     logs,
+			"total":    total,
+			"page":     aq.Page,
+			"per_page": aq.PerPage,
+		})
+	}
+}
+
+// ExportAuditLogs exporte le journal filtré en CSV ou JSON (format=csv|json), dans l'ordre chronologique
+// et avec les hash de la chaîne pour une vérification hors ligne. L'export est lui-même audité.
+func ExportAuditLogs(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aq, err := parseAuditQuery(r.URL.Query())
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter := aq.Filter
+		filter.Limit, filter.Offset = 0, 0
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		if format != "csv" && format != "json" {
+			jsonError(w, "Format invalide (csv ou json)", http.StatusBadRequest)
+			return
+		}
+		filename := "audit_" + time.Now().Format("20060102-150405") + "." + format
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			err = exportAuditJSON(w, db, filter)
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = exportAuditCSV(w, db, filter)
+		}
+		// L'en-tête est déjà envoyé : l'export est tronqué
+		if err != nil {
+			log.Printf("Erreur export audit: %v", err)
+		}
+		am.Audit(r, "AUDIT_EXPORT", "audit", r.URL.RawQuery)
+	}
+}
+
+func exportAuditCSV(w http.ResponseWriter, db *storage.DB, filter storage.AuditFilter) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "timestamp", "user", "action", "target", "details", "ip_address", "prev_hash", "hash"})
+	err := db.EachAuditLog(filter, func(l storage.AuditLog) error {
+		return cw.Write([]string{
+			strconv.FormatInt(l.ID, 10), l.Timestamp.UTC().Format(time.RFC3339Nano),
+			l.User, l.Action, l.Target, l.Details, l.IPAddress, l.PrevHash, l.Hash,
+		})
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// exportAuditJSON écrit un tableau JSON ligne par ligne, sans charger tout le journal en mémoire
+func exportAuditJSON(w http.ResponseWriter, db *storage.DB, filter storage.AuditFilter) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+	first := true
+	err := db.EachAuditLog(filter, func(l storage.AuditLog) error {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		if !first {
+			w.Write([]byte(",\n"))
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("]\n"))
+	return err
+}
+
+// VerifyAuditLogs vérifie la chaîne de hash du journal (lignes supprimées, insérées ou modifiées)
+func VerifyAuditLogs(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := db.VerifyAuditChain()
+		if err != nil {
+			jsonError(w, "Erreur vérification du journal: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !result.Valid {
+			log.Printf("AVERTISSEMENT: journal d'audit altéré à la ligne %d (%s)", result.BrokenID, result.Reason)
+			am.Audit(r, "AUDIT_TAMPERED", fmt.Sprintf("id=%d", result.BrokenID), result.Reason)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"go-monitoring/auth"
@@ -11,37 +13,78 @@ import (
 	"go-monitoring/storage"
 )
 
-// AuditPage gère la page d'affichage des logs d'audit
+// auditBadgeClass retourne la classe du badge d'une action (connexion, création, modification, suppression ou refus)
+func auditBadgeClass(action string) string {
+	switch {
+	case action == "LOGIN":
+		return "action-login"
+	case action == "LOGOUT":
+		return "action-logout"
+	case action == "LOGIN_FAILED" || action == "ACCESS_DENIED" || action == "AUDIT_TAMPERED" ||
+		strings.HasSuffix(action, "_DELETE"):
+		return "action-delete"
+	case strings.HasSuffix(action, "_CREATE"):
+		return "action-create"
+	case strings.HasSuffix(action, "_UPDATE") || strings.HasPrefix(action, "PASSWORD_") ||
+		action == "USER_ROLE" || action == "USER_STATUS":
+		return "action-update"
+	}
+	return "action-other"
+}
+
+// AuditPage gère la page d'affichage des logs d'audit (filtres et pagination côté serveur)
 func AuditPage(cfg *config.Config, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Vérification Admin
 		role := am.GetUserRole(r)
-		if role != "admin" {
-			http.Redirect(w, r, "/", http.StatusSeeOther)
+
+		q := r.URL.Query()
+		aq, err := parseAuditQuery(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logs, total, err := db.SearchAuditLogs(aq.Filter)
+		if err != nil {
+			http.Error(w, "Erreur récupération logs: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Récupérer les logs (limit 100 pour l'instant)
-		logs, err := db.GetAuditLogs(100)
+		// Listes des filtres : utilisateurs et actions présents dans le journal
+		users, actions, err := db.GetAuditFacets()
 		if err != nil {
 			http.Error(w, "Erreur récupération logs: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Extraire les utilisateurs uniques pour le filtre
-		userMap := make(map[string]bool)
-		for _, log := range logs {
-			userMap[log.User] = true
+		// Filtres courants, repris dans les liens de pagination et d'export
+		filters := url.Values{}
+		for _, key := range []string{"user", "action", "target", "since", "until"} {
+			if v := q.Get(key); v != "" {
+				filters.Set(key, v)
+			}
+		}
+		pageURL := func(page int) string {
+			v := url.Values{}
+			for k, values := range filters {
+				v[k] = values
+			}
+			v.Set("page", strconv.Itoa(page))
+			return "/audit?" + v.Encode()
+		}
+		pages := max(1, (total+aq.PerPage-1)/aq.PerPage)
+		var prevURL, nextURL string
+		if aq.Page > 1 {
+			prevURL = pageURL(min(aq.Page-1, pages))
 		}
-		users := make([]string, 0, len(userMap))
-		for user := range userMap {
-			users = append(users, user)
+		if aq.Page < pages {
+			nextURL = pageURL(aq.Page + 1)
 		}
 
 		// Charger les templates
 		tmpl, err := template.New("base.html").Funcs(template.FuncMap{
-			"lower": strings.ToLower,
-			"upper": strings.ToUpper,
+			"lower":      strings.ToLower,
+			"upper":      strings.ToUpper,
+			"badgeClass": auditBadgeClass,
 		}).ParseFiles(
 			"templates/layout/base.html",
 			"templates/audit.html",
@@ -61,6 +104,15 @@ func AuditPage(cfg *config.Config, db *storage.DB, am *auth.AuthManager) http.Ha
 			CSRFToken string
 			Logs      []storage.AuditLog
 			Users     []string
+			Actions   []string
+			Filter    map[string]string
+			Filtered  bool
+			Total     int
+			Page      int
+			Pages     int
+			PrevURL   string
+			NextURL   string
+			Query     string // Filtres encodés pour l'export
 		}{
 			Title:     "Journal d'Audit",
 			Status:    "OK",
@@ -69,6 +121,18 @@ func AuditPage(cfg *config.Config, db *storage.DB, am *auth.AuthManager) http.Ha
 			CSRFToken: middleware.GetCSRFToken(r),
 			Logs:      logs,
 			Users:     users,
+			Actions:   actions,
+			Filter: map[string]string{
+				"user": q.Get("user"), "action": q.Get("action"), "target": q.Get("target"),
+				"since": q.Get("since"), "until": q.Get("until"),
+			},
+			Filtered: len(filters) > 0,
+			Total:    total,
+			Page:     aq.Page,
+			Pages:    pages,
+			PrevURL:  prevURL,
+			NextURL:  nextURL,
+			Query:    filters.Encode(),
 		}
 
 		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/auth"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/middleware"
+	"go-monitoring/models"
+)
+
+// machineCertificates contient le résultat du dernier scan d'une machine
+type machineCertificates struct {
+	Certs     []models.CertificateInfo
+	ScannedAt time.Time
+	Error     string
+}
+
+// CertScanner scanne périodiquement les certificats des machines et garde le dernier résultat en mémoire
+type CertScanner struct {
+	cm      *ConfigManager
+	alerts  *alerts.Manager
+	results map[string]machineCertificates
+	mu      sync.RWMutex
+}
+
+// NewCertScanner crée un scanner de certificats
+func NewCertScanner(cm *ConfigManager, am *alerts.Manager) *CertScanner {
+	return &CertScanner{
+		cm:      cm,
+		alerts:  am,
+		results: make(map[string]machineCertificates),
+	}
+}
+
+// ScanAll scanne toutes les machines ayant des chemins de certificats configurés
+func (s *CertScanner) ScanAll() {
+	cfg := s.cm.GetConfig()
+
+	var wg sync.WaitGroup
+	for _, mc := range cfg.Machines {
+		if len(mc.CertPaths) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(mc config.MachineConfig) {
+			defer wg.Done()
+			s.ScanMachine(&mc)
+		}(mc)
+	}
+	wg.Wait()
+
+	// Oublier les machines supprimées ou sans chemins configurés
+	s.mu.Lock()
+	for id := range s.results {
+		if m := cfg.GetMachine(id); m == nil || len(m.CertPaths) == 0 {
+			delete(s.results, id)
+		}
+	}
+	s.mu.Unlock()
+	s.syncAlerts(cfg)
+}
+
+// ScanMachine scanne les certificats d'une machine
+func (s *CertScanner) ScanMachine(mc *config.MachineConfig) {
+	var certs []models.CertificateInfo
+	var err error
+
+	if collectors.IsLocalHost(mc.Host) {
+		certs, err = collectors.CollectLocalCertificates(mc.CertPaths)
+	} else {
+		_, pool, _ := s.cm.GetConfigPoolAndCache()
+		client, clientErr := pool.GetClient(mc.ID)
+		if clientErr != nil {
+			err = clientErr
+		} else {
+			certs, err = collectors.CollectCertificates(client, mc.CertPaths, mc.OS)
+		}
+	}
+
+	result := machineCertificates{ScannedAt: time.Now()}
+	if err != nil {
+		log.Printf("Erreur scan certificats %s: %v", mc.ID, err)
+		// Conserver les certificats du scan précédent en cas d'erreur passagère
+		s.mu.RLock()
+		result.Certs = s.results[mc.ID].Certs
+		s.mu.RUnlock()
+		result.Error = err.Error()
+	} else {
+		for i := range certs {
+			certs[i].MachineID = mc.ID
+		}
+		result.Certs = certs
+	}
+
+	s.mu.Lock()
+	s.results[mc.ID] = result
+	s.mu.Unlock()
+}
+
+// syncAlerts lève une alerte par certificat expiré ou proche de l'expiration
+func (s *CertScanner) syncAlerts(cfg *config.Config) {
+	if s.alerts == nil {
+		return
+	}
+
+	threshold := cfg.Settings.Thresholds.CertWarningDays
+	raised := make(map[string]bool)
+
+	for _, c := range s.Expiring(threshold) {
+		key := "cert:" + c.MachineID + ":" + c.Source + ":" + c.Fingerprint
+		raised[key] = true
+
+		severity := alerts.SeverityWarning
+		msg := fmt.Sprintf("%s (%s) expire dans %d jour(s)", c.Subject, c.Source, c.DaysLeft)
+		if c.Expired {
+			severity = alerts.SeverityCritical
+			msg = fmt.Sprintf("%s (%s) a expiré le %s", c.Subject, c.Source, c.NotAfter.Format("02/01/2006"))
+		}
+
+		s.alerts.Raise(alerts.Alert{
+			Key:       key,
+			MachineID: c.MachineID,
+			Source:    "certificate",
+			Name:      c.Subject,
+			Severity:  severity,
+			Message:   msg,
+		})
+	}
+
+	for _, a := range s.alerts.Active() {
+		if a.Source == "certificate" && !raised[a.Key] {
+			s.alerts.Resolve(a.Key)
+		}
+	}
+}
+
+// All retourne les certificats de toutes les machines, triés par date d'expiration
+func (s *CertScanner) All() []models.CertificateInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var certs []models.CertificateInfo
+	for _, r := range s.results {
+		certs = append(certs, r.Certs...)
+	}
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+	return certs
+}
+
+// Expiring retourne les certificats expirant dans moins de days jours (ou déjà expirés)
+func (s *CertScanner) Expiring(days int) []models.CertificateInfo {
+	limit := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+	var expiring []models.CertificateInfo
+	for _, c := range s.All() {
+		if c.NotAfter.Before(limit) {
+			expiring = append(expiring, c)
+		}
+	}
+	return expiring
+}
+
+// Machine retourne le dernier scan d'une machine
+func (s *CertScanner) Machine(id string) (machineCertificates, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.results[id]
+	return r, ok
+}
+
+// scanErrors retourne les erreurs du dernier scan, par machine
+func (s *CertScanner) scanErrors() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	errs := make(map[string]string)
+	for id, r := range s.results {
+		if r.Error != "" {
+			errs[id] = r.Error
+		}
+	}
+	return errs
+}
+
+// certDaysParam lit le paramètre "days" (défaut: seuil de la configuration)
+func certDaysParam(r *http.Request, cfg *config.Config) int {
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 {
+		return d
+	}
+	return cfg.Settings.Thresholds.CertWarningDays
+}
+
+// CertificatesPage affiche la vue globale des certificats expirant bientôt
+func CertificatesPage(cm *ConfigManager, am *auth.AuthManager, scanner *CertScanner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := template.New("base.html").Funcs(templateFuncs).ParseFiles(
+			"templates/layout/base.html",
+			"templates/certificates.html",
+		)
+		if err != nil {
+			http.Error(w, "Erreur chargement template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		cfg := cm.GetConfig()
+		days := certDaysParam(r, cfg)
+		showAll := r.URL.Query().Get("all") == "1"
+
+		certs := scanner.Expiring(days)
+		if showAll {
+			certs = scanner.All()
+		}
+		visible := am.Visible(r)
+		certs = visibleCertificates(certs, visible)
+		scanErrors := scanner.scanErrors()
+		for id := range scanErrors {
+			if !visible(id) {
+				delete(scanErrors, id)
+			}
+		}
+
+		machineNames := make(map[string]string, len(cfg.Machines))
+		for _, m := range cfg.Machines {
+			machineNames[m.ID] = m.Name
+		}
+
+		data := struct {
+			Title        string
+			Status       string
+			Role         string
+			Username     string
+			CSRFToken    string
+			Certificates []models.CertificateInfo
+			Days         int
+			ShowAll      bool
+			MachineNames map[string]string
+			ScanErrors   map[string]string
+		}{
+			Title:        "Certificats",
+			Status:       "OK",
+			Role:         am.GetUserRole(r),
+			Username:     am.GetUsername(r),
+			CSRFToken:    middleware.GetCSRFToken(r),
+			Certificates: certs,
+			Days:         days,
+			ShowAll:      showAll,
+			MachineNames: machineNames,
+			ScanErrors:   scanErrors,
+		}
+
+		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+			http.Error(w, "Erreur rendu template: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// visibleCertificates retire les certificats des machines que l'utilisateur ne voit pas
+func visibleCertificates(certs []models.CertificateInfo, visible func(string) bool) []models.CertificateInfo {
+	filtered := make([]models.CertificateInfo, 0, len(certs))
+	for _, c := range certs {
+		if visible(c.MachineID) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// ListExpiringCertificates retourne les certificats expirant bientôt sur les machines visibles du parc
+func ListExpiringCertificates(cm *ConfigManager, am *auth.AuthManager, scanner *CertScanner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		certs := scanner.Expiring(certDaysParam(r, cm.GetConfig()))
+		if r.URL.Query().Get("all") == "1" {
+			certs = scanner.All()
+		}
+		certs = visibleCertificates(certs, am.Visible(r))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(certs)
+	}
+}
+
+// ListMachineCertificates retourne les certificats d'une machine (dernier scan)
+func ListMachineCertificates(cm *ConfigManager, scanner *CertScanner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		if cm.GetConfig().GetMachine(machineID) == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+
+		result, _ := scanner.Machine(machineID)
+		certs := result.Certs
+		if certs == nil {
+			certs = []models.CertificateInfo{}
+		}
+
+		resp := struct {
+			Certificates []models.CertificateInfo `json:"certificates"`
+			ScannedAt    *time.Time               `json:"scanned_at,omitempty"`
+			Error        string                   `json:"error,omitempty"`
+		}{Certificates: certs, Error: result.Error}
+		if !result.ScannedAt.IsZero() {
+			resp.ScannedAt = &result.ScannedAt
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
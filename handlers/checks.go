@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/storage"
+)
+
+// CheckScheduler exécute périodiquement les checks personnalisés définis dans la configuration
+type CheckScheduler struct {
+	cm      *ConfigManager
+	db      *storage.DB
+	alerts  *alerts.Manager
+	lastRun map[string]time.Time
+	running map[string]bool
+	mu      sync.Mutex
+}
+
+// NewCheckScheduler crée un ordonnanceur de checks
+func NewCheckScheduler(cm *ConfigManager, db *storage.DB, am *alerts.Manager) *CheckScheduler {
+	return &CheckScheduler{
+		cm:      cm,
+		db:      db,
+		alerts:  am,
+		lastRun: make(map[string]time.Time),
+		running: make(map[string]bool),
+	}
+}
+
+// RunDue lance en arrière-plan les checks dont l'intervalle est écoulé.
+// Un check encore en cours n'est jamais relancé en parallèle.
+func (s *CheckScheduler) RunDue() {
+	cfg, pool, _ := s.cm.GetConfigPoolAndCache()
+	now := time.Now()
+	configured := make(map[string]bool)
+
+	for _, mc := range cfg.Machines {
+		for _, check := range mc.Checks {
+			key := mc.ID + "/" + check.Name
+			configured["check:"+mc.ID+":"+check.Name] = true
+
+			s.mu.Lock()
+			due := !s.running[key] && now.Sub(s.lastRun[key]) >= time.Duration(check.Interval)*time.Second
+			if due {
+				s.running[key] = true
+				s.lastRun[key] = now
+			}
+			s.mu.Unlock()
+
+			if !due {
+				continue
+			}
+
+			go func(machineID string, check config.CheckConfig, key string) {
+				defer func() {
+					s.mu.Lock()
+					delete(s.running, key)
+					s.mu.Unlock()
+				}()
+
+				client, err := pool.GetClient(machineID)
+				if err != nil {
+					log.Printf("Check %s: client SSH indisponible: %v", key, err)
+					return
+				}
+
+				result := collectors.RunCheck(client, machineID, check)
+				if err := s.db.SaveCheckResult(result); err != nil {
+					log.Printf("Erreur sauvegarde check %s: %v", key, err)
+				}
+				s.updateAlert(result.MachineID, result.Name, result.State, result.Output)
+			}(mc.ID, check, key)
+		}
+	}
+
+	// Résoudre les alertes des checks retirés de la configuration
+	if s.alerts != nil {
+		for _, a := range s.alerts.Active() {
+			if a.Source == "check" && !configured[a.Key] {
+				s.alerts.Resolve(a.Key)
+			}
+		}
+	}
+}
+
+// updateAlert synchronise l'état d'un check avec le gestionnaire d'alertes
+func (s *CheckScheduler) updateAlert(machineID, name string, state int, output string) {
+	if s.alerts == nil {
+		return
+	}
+
+	key := "check:" + machineID + ":" + name
+	if state == collectors.CheckOK {
+		s.alerts.Resolve(key)
+		return
+	}
+
+	severity := alerts.SeverityUnknown
+	switch state {
+	case collectors.CheckWarning:
+		severity = alerts.SeverityWarning
+	case collectors.CheckCritical:
+		severity = alerts.SeverityCritical
+	}
+
+	s.alerts.Raise(alerts.Alert{
+		Key:       key,
+		MachineID: machineID,
+		Source:    "check",
+		Name:      name,
+		Severity:  severity,
+		Message:   output,
+	})
+}
+
+// machineCheckResults retourne les derniers résultats des checks configurés d'une machine
+func machineCheckResults(db *storage.DB, mc *config.MachineConfig) []models.CheckResult {
+	if db == nil || len(mc.Checks) == 0 {
+		return nil
+	}
+
+	latest, err := db.GetLatestCheckResults(mc.ID)
+	if err != nil {
+		log.Printf("Erreur lecture checks %s: %v", mc.ID, err)
+		return nil
+	}
+
+	byName := make(map[string]models.CheckResult, len(latest))
+	for _, r := range latest {
+		byName[r.Name] = r
+	}
+
+	// Respecter l'ordre de la configuration et ignorer les checks supprimés
+	results := make([]models.CheckResult, 0, len(mc.Checks))
+	for _, c := range mc.Checks {
+		r, ok := byName[c.Name]
+		if !ok {
+			r = models.CheckResult{MachineID: mc.ID, Name: c.Name, State: collectors.CheckUnknown, Output: "En attente de la première exécution"}
+		}
+		r.StateName = collectors.CheckStateName(r.State)
+		results = append(results, r)
+	}
+	return results
+}
+
+// ListMachineChecks retourne l'état courant des checks d'une machine
+func ListMachineChecks(cm *ConfigManager, db *storage.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+
+		cfg := cm.GetConfig()
+		machineConfig := cfg.GetMachine(machineID)
+		if machineConfig == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+
+		results := machineCheckResults(db, machineConfig)
+		if results == nil {
+			results = []models.CheckResult{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+// GetCheckHistory retourne l'historique des perfdata d'un check
+func GetCheckHistory(db *storage.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		checkName := r.PathValue("check")
+		if machineID == "" || checkName == "" {
+			jsonError(w, "Paramètres manquants", http.StatusBadRequest)
+			return
+		}
+
+		// Durée par défaut : 24h
+		duration := 24 * time.Hour
+		if d, err := time.ParseDuration(r.URL.Query().Get("duration")); err == nil {
+			duration = d
+		}
+
+		points, err := db.GetPerfDataHistory(machineID, checkName, duration)
+		if err != nil {
+			jsonError(w, "Erreur récupération historique: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if points == nil {
+			points = []models.PerfPoint{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(points)
+	}
+}
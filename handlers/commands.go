@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-monitoring/auth"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/middleware"
+	"go-monitoring/models"
+	"go-monitoring/recording"
+	"go-monitoring/ssh"
+	"go-monitoring/storage"
+
+	"github.com/gorilla/websocket"
+)
+
+// Durée pendant laquelle une exécution terminée reste suivie en mémoire (reconnexion du navigateur)
+const commandRunRetention = 5 * time.Minute
+
+// CommandEvent est transmis au navigateur pendant une exécution (WebSocket).
+// "start" : la commande démarre sur une machine ; "result" : résultat d'une machine ;
+// "done" : toutes les machines ont répondu.
+type CommandEvent struct {
+	Type      string                    `json:"type"`
+	MachineID string                    `json:"machine_id,omitempty"`
+	Result    *models.CommandHostResult `json:"result,omitempty"`
+	Run       *models.CommandRun        `json:"run,omitempty"`
+}
+
+// commandRun est une exécution en cours : les événements sont conservés pour les observateurs
+// qui se connectent en retard
+type commandRun struct {
+	mu          sync.Mutex
+	run         models.CommandRun
+	events      []CommandEvent
+	subscribers map[chan CommandEvent]struct{}
+	done        bool
+}
+
+// publish diffuse un événement. Les files sont dimensionnées pour l'exécution complète :
+// un observateur qui ne suit pas est déconnecté.
+func (cr *commandRun) publish(ev CommandEvent) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.events = append(cr.events, ev)
+	if ev.Type == "done" {
+		cr.done = true
+	}
+	for ch := range cr.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			close(ch)
+			delete(cr.subscribers, ch)
+			continue
+		}
+		if cr.done {
+			close(ch)
+			delete(cr.subscribers, ch)
+		}
+	}
+}
+
+// subscribe retourne les événements déjà émis et, si l'exécution continue, le canal des suivants
+func (cr *commandRun) subscribe() ([]CommandEvent, chan CommandEvent) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	replay := append([]CommandEvent(nil), cr.events...)
+	if cr.done {
+		return replay, nil
+	}
+	ch := make(chan CommandEvent, 2*len(cr.run.Targets)+1)
+	cr.subscribers[ch] = struct{}{}
+	return replay, ch
+}
+
+func (cr *commandRun) unsubscribe(ch chan CommandEvent) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if _, ok := cr.subscribers[ch]; ok {
+		close(ch)
+		delete(cr.subscribers, ch)
+	}
+}
+
+// commandRegistry référence les exécutions en cours ou récemment terminées
+type commandRegistry struct {
+	mu   sync.Mutex
+	runs map[string]*commandRun
+}
+
+var commandRuns = &commandRegistry{runs: make(map[string]*commandRun)}
+
+func (reg *commandRegistry) add(cr *commandRun) {
+	reg.mu.Lock()
+	reg.runs[cr.run.ID] = cr
+	reg.mu.Unlock()
+}
+
+func (reg *commandRegistry) get(id string) *commandRun {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.runs[id]
+}
+
+func (reg *commandRegistry) remove(id string) {
+	reg.mu.Lock()
+	delete(reg.runs, id)
+	reg.mu.Unlock()
+}
+
+// commandRequest est le corps de POST /api/commands/runs
+type commandRequest struct {
+	Template string   `json:"template"` // Commande prédéfinie
+	Command  string   `json:"command"`  // Commande libre (Admin seulement)
+	Machines []string `json:"machines"`
+	Group    string   `json:"group"`
+	Timeout  int      `json:"timeout"` // secondes (commande libre)
+}
+
+// resolveCommandTargets retourne les machines visées (liste explicite et/ou groupe), dans l'ordre de la configuration
+func resolveCommandTargets(cfg *config.Config, machines []string, group string) ([]config.MachineConfig, error) {
+	wanted := make(map[string]bool, len(machines))
+	for _, id := range machines {
+		if cfg.GetMachine(id) == nil {
+			return nil, fmt.Errorf("machine inconnue: %s", id)
+		}
+		wanted[id] = true
+	}
+
+	var targets []config.MachineConfig
+	for _, mc := range cfg.Machines {
+		if wanted[mc.ID] || (group != "" && mc.Group == group) {
+			targets = append(targets, mc)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("aucune machine ciblée")
+	}
+	return targets, nil
+}
+
+// commandForMachine choisit la variante Linux ou Windows d'une commande prédéfinie
+func commandForMachine(tpl *config.CommandTemplate, mc config.MachineConfig) string {
+	if mc.OS == "windows" {
+		return tpl.WindowsCommand
+	}
+	return tpl.Command
+}
+
+// StartCommandRun lance une commande sur plusieurs machines (audité).
+// Les commandes libres sont réservées aux administrateurs ; les commandes prédéfinies demandent
+// la capacité commands sur chaque machine ciblée. Le suivi se fait via /api/commands/runs/{run}/stream.
+func StartCommandRun(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req commandRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			jsonError(w, "Requête invalide", http.StatusBadRequest)
+			return
+		}
+		req.Command = strings.TrimSpace(req.Command)
+
+		cfg, pool, _ := cm.GetConfigPoolAndCache()
+		targets, err := resolveCommandTargets(cfg, req.Machines, req.Group)
+		if err != nil {
+			jsonError(w, "Cibles invalides: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var tpl *config.CommandTemplate
+		timeout := cfg.Settings.CommandTimeout
+		switch {
+		case req.Template != "" && req.Command != "":
+			jsonError(w, "Choisir une commande prédéfinie ou une commande libre, pas les deux", http.StatusBadRequest)
+			return
+		case req.Template != "":
+			if tpl = cfg.GetCommand(req.Template); tpl == nil {
+				jsonError(w, "Commande prédéfinie inconnue", http.StatusNotFound)
+				return
+			}
+			timeout = tpl.Timeout
+			for _, mc := range targets {
+				if !am.Can(r, config.CapabilityCommands, mc.ID) {
+					jsonError(w, "Commande non autorisée sur "+mc.ID, http.StatusForbidden)
+					return
+				}
+			}
+		case req.Command != "":
+			if !am.Can(r, config.CapabilityAdmin, "") {
+				jsonError(w, "Commandes libres réservées aux administrateurs", http.StatusForbidden)
+				return
+			}
+			if req.Timeout > 0 {
+				timeout = min(req.Timeout, config.MaxCommandTimeout)
+			}
+		default:
+			jsonError(w, "Commande manquante", http.StatusBadRequest)
+			return
+		}
+
+		id, err := recording.NewID()
+		if err != nil {
+			jsonError(w, "Erreur interne", http.StatusInternalServerError)
+			return
+		}
+		run := models.CommandRun{
+			ID:        id,
+			Username:  am.GetUsername(r),
+			Command:   req.Command,
+			StartedAt: time.Now(),
+		}
+		if tpl != nil {
+			run.Template = tpl.Name
+			run.Command = tpl.Command
+			if tpl.Command == "" {
+				run.Command = tpl.WindowsCommand
+			}
+		}
+		for _, mc := range targets {
+			run.Targets = append(run.Targets, mc.ID)
+		}
+
+		if err := db.CreateCommandRun(run); err != nil {
+			jsonError(w, "Erreur enregistrement de l'exécution", http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "COMMAND_RUN", run.ID,
+			fmt.Sprintf("template=%s targets=%s command=%q", run.Template, strings.Join(run.Targets, ","), run.Command))
+
+		cr := &commandRun{run: run, subscribers: make(map[chan CommandEvent]struct{})}
+		commandRuns.add(cr)
+		go executeCommandRun(cr, db, pool, targets, tpl, time.Duration(timeout)*time.Second, cfg.Settings.CommandConcurrency)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(run)
+	}
+}
+
+// executeCommandRun exécute la commande sur chaque machine (au plus concurrency à la fois)
+// et enregistre les résultats au fil de l'eau
+func executeCommandRun(cr *commandRun, db *storage.DB, pool *ssh.Pool, targets []config.MachineConfig, tpl *config.CommandTemplate, timeout time.Duration, concurrency int) {
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+
+	for _, mc := range targets {
+		wg.Add(1)
+		go func(mc config.MachineConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			cr.publish(CommandEvent{Type: "start", MachineID: mc.ID})
+
+			cmd := cr.run.Command
+			if tpl != nil {
+				cmd = commandForMachine(tpl, mc)
+			}
+
+			var result models.CommandHostResult
+			if cmd == "" {
+				result = models.CommandHostResult{MachineID: mc.ID, ExitCode: -1, Error: "commande non disponible pour ce système"}
+			} else if client, err := pool.GetClient(mc.ID); err != nil {
+				result = models.CommandHostResult{MachineID: mc.ID, ExitCode: -1, Error: "connexion SSH impossible: " + err.Error()}
+			} else {
+				result = collectors.RunCommand(client, mc.ID, cmd, timeout)
+			}
+
+			if err := db.SaveCommandResult(cr.run.ID, result); err != nil {
+				log.Printf("Erreur sauvegarde résultat %s/%s: %v", cr.run.ID, mc.ID, err)
+			}
+			cr.publish(CommandEvent{Type: "result", MachineID: mc.ID, Result: &result})
+		}(mc)
+	}
+	wg.Wait()
+
+	endedAt := time.Now()
+	if err := db.FinishCommandRun(cr.run.ID, endedAt); err != nil {
+		log.Printf("Erreur fin d'exécution %s: %v", cr.run.ID, err)
+	}
+	summary := cr.run
+	summary.EndedAt = &endedAt
+	cr.publish(CommandEvent{Type: "done", Run: &summary})
+
+	time.AfterFunc(commandRunRetention, func() { commandRuns.remove(cr.run.ID) })
+}
+
+// canViewCommandRun : l'auteur d'une exécution et les administrateurs peuvent la consulter
+func canViewCommandRun(am *auth.AuthManager, r *http.Request, run *models.CommandRun) bool {
+	return run.Username == am.GetUsername(r) || am.Can(r, config.CapabilityAdmin, "")
+}
+
+// StreamCommandRun diffuse les résultats d'une exécution au fil de l'eau (WebSocket).
+// Une exécution terminée est rejouée depuis la base.
+func StreamCommandRun(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runID := r.PathValue("run")
+
+		var replay []CommandEvent
+		var live chan CommandEvent
+		cr := commandRuns.get(runID)
+		if cr != nil {
+			if !canViewCommandRun(am, r, &cr.run) {
+				jsonError(w, "Accès refusé", http.StatusForbidden)
+				return
+			}
+			replay, live = cr.subscribe()
+			if live != nil {
+				defer cr.unsubscribe(live)
+			}
+		} else {
+			run, err := db.GetCommandRun(runID)
+			if err != nil || run == nil {
+				jsonError(w, "Exécution introuvable", http.StatusNotFound)
+				return
+			}
+			if !canViewCommandRun(am, r, run) {
+				jsonError(w, "Accès refusé", http.StatusForbidden)
+				return
+			}
+			for i := range run.Results {
+				replay = append(replay, CommandEvent{Type: "result", MachineID: run.Results[i].MachineID, Result: &run.Results[i]})
+			}
+			summary := *run
+			summary.Results = nil
+			replay = append(replay, CommandEvent{Type: "done", Run: &summary})
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("Commandes: Erreur upgrade WS: %v", err)
+			return
+		}
+		defer ws.Close()
+
+		// Détecter la fermeture côté navigateur
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for _, ev := range replay {
+			if err := ws.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+		if live == nil {
+			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+
+		for {
+			select {
+			case ev, ok := <-live:
+				if !ok {
+					ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return
+				}
+				ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				if err := ws.WriteJSON(ev); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}
+}
+
+// GetCommandRun retourne une exécution et ses résultats (auteur ou administrateur)
+func GetCommandRun(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		run, err := db.GetCommandRun(r.PathValue("run"))
+		if err != nil || run == nil {
+			jsonError(w, "Exécution introuvable", http.StatusNotFound)
+			return
+		}
+		if !canViewCommandRun(am, r, run) {
+			jsonError(w, "Accès refusé", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(run)
+	}
+}
+
+// ListCommandRuns retourne l'historique des exécutions : toutes pour un administrateur (?user= pour filtrer),
+// les siennes pour les autres utilisateurs
+func ListCommandRuns(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+
+		username := am.GetUsername(r)
+		if am.Can(r, config.CapabilityAdmin, "") {
+			username = r.URL.Query().Get("user")
+		}
+
+		runs, err := db.ListCommandRuns(username, limit)
+		if err != nil {
+			jsonError(w, "Erreur récupération des exécutions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if runs == nil {
+			runs = []models.CommandRun{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(runs)
+	}
+}
+
+// CommandsPage affiche le lanceur de commandes : commandes prédéfinies et machines autorisées,
+// commande libre pour les administrateurs
+func CommandsPage(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := template.New("base.html").Funcs(templateFuncs).ParseFiles(
+			"templates/layout/base.html",
+			"templates/commands.html",
+		)
+		if err != nil {
+			http.Error(w, "Erreur chargement template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		cfg := cm.GetConfig()
+		var machines []config.MachineConfig
+		var groups []string
+		seenGroups := make(map[string]bool)
+		for _, mc := range cfg.Machines {
+			if !am.Can(r, config.CapabilityCommands, mc.ID) {
+				continue
+			}
+			machines = append(machines, mc)
+			if mc.Group != "" && !seenGroups[mc.Group] {
+				seenGroups[mc.Group] = true
+				groups = append(groups, mc.Group)
+			}
+		}
+
+		data := struct {
+			Title     string
+			Status    string
+			Role      string
+			Username  string
+			CSRFToken string
+			IsAdmin   bool
+			Commands  []config.CommandTemplate
+			Machines  []config.MachineConfig
+			Groups    []string
+		}{
+			Title:     "Commandes",
+			Status:    "OK",
+			Role:      am.GetUserRole(r),
+			Username:  am.GetUsername(r),
+			CSRFToken: middleware.GetCSRFToken(r),
+			IsAdmin:   am.Can(r, config.CapabilityAdmin, ""),
+			Commands:  cfg.Commands,
+			Machines:  machines,
+			Groups:    groups,
+		}
+
+		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+			http.Error(w, "Erreur rendu template: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -20,8 +20,11 @@ import (
 
 // Fonctions de template pour le dashboard
 var dashboardFuncs = template.FuncMap{
-	"lower": strings.ToLower,
-	"upper": strings.ToUpper,
+	"lower":        strings.ToLower,
+	"upper":        strings.ToUpper,
+	"fullIn":       formatFullIn,
+	"formatGrowth": formatGrowth,
+	"nextDiskFull": nextDiskFull,
 }
 
 // Dashboard gère la page d'accueil avec la liste des machines
@@ -40,6 +43,17 @@ func Dashboard(cfg *config.Config, pool *ssh.Pool, cache *cache.MetricsCache, am
 		// Collecter les infos des machines en parallèle avec timeout et cache
 		machines := CollectAllMachines(cfg, pool, cache, 1*time.Second, false)
 
+		// Seules les machines visibles par l'utilisateur sont affichées (même règle que le flux WebSocket)
+		if am != nil {
+			visible := machines[:0]
+			for _, m := range machines {
+				if am.CanView(r, m.ID) {
+					visible = append(visible, m)
+				}
+			}
+			machines = visible
+		}
+
 		log.Printf("Dashboard: %d machines chargées", len(machines))
 
 		// Grouper les machines
@@ -297,6 +311,11 @@ func CollectAllMachines(cfg *config.Config, pool *ssh.Pool, cache *cache.Metrics
 	}
 
 	wg.Wait()
+
+	// Prévisions de remplissage des disques (calculées en tâche de fond)
+	for i := range machines {
+		attachDiskForecasts(&machines[i])
+	}
 	return machines
 }
 
@@ -3,7 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"go-monitoring/collectors"
 	"go-monitoring/config"
@@ -146,13 +150,19 @@ func BrowseDirectory(cfg *config.Config, pool *ssh.Pool) http.HandlerFunc {
 		if collectors.IsLocalHost(machineConfig.Host) {
 			listing, err = collectors.BrowseLocalDirectory(path)
 		} else {
-			// Machine distante via SSH
+			// Machine distante via SFTP
 			client, clientErr := pool.GetClient(machineID)
 			if clientErr != nil {
 				jsonError(w, "Erreur connexion SSH", http.StatusInternalServerError)
 				return
 			}
-			listing, err = collectors.BrowseDirectory(client, path)
+			sftpClient, sftpErr := client.NewSFTP()
+			if sftpErr != nil {
+				jsonError(w, "Erreur ouverture SFTP: "+sftpErr.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer sftpClient.Close()
+			listing, err = collectors.BrowseDirectory(collectors.SFTPStore{Client: sftpClient}, path)
 		}
 
 		if err != nil {
@@ -166,12 +176,15 @@ func BrowseDirectory(cfg *config.Config, pool *ssh.Pool) http.HandlerFunc {
 }
 
 // isAllowedPath vérifie si le chemin est autorisé
-func isAllowedPath(path string) bool {
+func isAllowedPath(p string) bool {
 	// Interdire les chemins avec ..
-	if strings.Contains(path, "..") {
+	if strings.Contains(p, "..") {
 		return false
 	}
 
+	// Comparer sur la forme normalisée (//proc, /proc/. ou \proc désignent /proc)
+	p = path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))
+
 	// Liste des chemins sensibles interdits
 	forbiddenPaths := []string{
 		"/etc/shadow",
@@ -183,7 +196,7 @@ func isAllowedPath(path string) bool {
 	}
 
 	for _, forbidden := range forbiddenPaths {
-		if strings.HasPrefix(path, forbidden) {
+		if strings.HasPrefix(p, forbidden) {
 			return false
 		}
 	}
@@ -216,6 +229,119 @@ func DiskDetailsWithCM(cm *ConfigManager) http.HandlerFunc {
 	}
 }
 
+// diskUsageTTL est la durée de validité d'une analyse d'occupation disque
+const diskUsageTTL = 15 * time.Minute
+
+// diskUsageCache conserve les analyses récentes (par machine, chemin et profondeur)
+// et évite de lancer deux fois la même analyse en parallèle.
+type diskUsageCache struct {
+	mu      sync.Mutex
+	entries map[string]*diskUsageEntry
+}
+
+type diskUsageEntry struct {
+	done   chan struct{} // Fermé à la fin de l'analyse
+	report *models.DiskUsageReport
+	err    error
+}
+
+var diskUsageResults = &diskUsageCache{entries: make(map[string]*diskUsageEntry)}
+
+// get retourne l'analyse en cache si elle est récente, sinon lance run (une seule fois par clé).
+// Le booléen indique si le résultat provient du cache.
+func (c *diskUsageCache) get(key string, refresh bool, run func() (*models.DiskUsageReport, error)) (*models.DiskUsageReport, bool, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		select {
+		case <-e.done:
+			if !refresh && e.err == nil && time.Since(e.report.GeneratedAt) < diskUsageTTL {
+				c.mu.Unlock()
+				return e.report, true, nil
+			}
+		default:
+			// Analyse déjà en cours : attendre son résultat
+			c.mu.Unlock()
+			<-e.done
+			return e.report, false, e.err
+		}
+	}
+
+	c.evictLocked()
+	e := &diskUsageEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.report, e.err = run()
+	close(e.done)
+	return e.report, false, e.err
+}
+
+// evictLocked retire les analyses terminées en erreur ou plus anciennes que diskUsageTTL.
+// Les analyses en cours sont conservées. Doit être appelée avec c.mu verrouillé.
+func (c *diskUsageCache) evictLocked() {
+	for key, e := range c.entries {
+		select {
+		case <-e.done:
+			if e.err != nil || time.Since(e.report.GeneratedAt) >= diskUsageTTL {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+}
+
+// DiskUsageWithCM analyse l'occupation des répertoires sous un chemin (du borné, résultat en cache).
+// Paramètres : path (défaut /), depth (1 à 6, défaut 3), refresh=1 pour relancer l'analyse.
+func DiskUsageWithCM(cm *ConfigManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg, pool, _ := cm.GetConfigPoolAndCache()
+		machineID := r.PathValue("id")
+		root := r.URL.Query().Get("path")
+		if root == "" {
+			root = "/"
+		}
+		depth, _ := strconv.Atoi(r.URL.Query().Get("depth"))
+		if depth <= 0 || depth > collectors.MaxDiskUsageDepth {
+			depth = collectors.DefaultDiskUsageDepth
+		}
+
+		if !isAllowedPath(root) {
+			jsonError(w, "Chemin non autorisé", http.StatusForbidden)
+			return
+		}
+
+		machineConfig := cfg.GetMachine(machineID)
+		if machineConfig == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+
+		key := machineID + "|" + strconv.Itoa(depth) + "|" + path.Clean(strings.ReplaceAll(root, "\\", "/"))
+		report, cached, err := diskUsageResults.get(key, r.URL.Query().Get("refresh") == "1", func() (*models.DiskUsageReport, error) {
+			if collectors.IsLocalHost(machineConfig.Host) {
+				return collectors.AnalyzeLocalDiskUsage(root, depth, collectors.DefaultDiskUsageTimeout)
+			}
+			client, err := pool.GetClient(machineID)
+			if err != nil {
+				return nil, err
+			}
+			return collectors.AnalyzeDiskUsage(client, root, machineConfig.OS, depth, collectors.DefaultDiskUsageTimeout)
+		})
+		if err != nil {
+			jsonError(w, "Erreur analyse disque: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := struct {
+			*models.DiskUsageReport
+			Cached bool
+		}{report, cached}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
 // BrowseDirectoryWithCM permet de naviguer avec ConfigManager
 func BrowseDirectoryWithCM(cm *ConfigManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-monitoring/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsageCache_EvictsStaleEntries(t *testing.T) {
+	c := &diskUsageCache{entries: make(map[string]*diskUsageEntry)}
+	report := func(age time.Duration) func() (*models.DiskUsageReport, error) {
+		return func() (*models.DiskUsageReport, error) {
+			return &models.DiskUsageReport{GeneratedAt: time.Now().Add(-age)}, nil
+		}
+	}
+
+	_, _, err := c.get("expiree", false, report(2*diskUsageTTL))
+	require.NoError(t, err)
+	_, _, err = c.get("echec", false, func() (*models.DiskUsageReport, error) { return nil, errors.New("ssh") })
+	require.Error(t, err)
+	_, _, err = c.get("recente", false, report(0))
+	require.NoError(t, err)
+
+	// Analyse en cours : conservée même si une insertion a lieu pendant son exécution
+	started, release := make(chan struct{}), make(chan struct{})
+	go c.get("en-cours", false, func() (*models.DiskUsageReport, error) {
+		close(started)
+		<-release
+		return &models.DiskUsageReport{GeneratedAt: time.Now()}, nil
+	})
+	<-started
+	_, _, err = c.get("nouvelle", false, report(0))
+	require.NoError(t, err)
+	close(release)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	assert.NotContains(t, c.entries, "expiree")
+	assert.NotContains(t, c.entries, "echec")
+	assert.Contains(t, c.entries, "recente")
+	assert.Contains(t, c.entries, "en-cours")
+	assert.Contains(t, c.entries, "nouvelle")
+}
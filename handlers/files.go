@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"go-monitoring/auth"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/pkg/security"
+)
+
+// openFileStore ouvre l'accès aux fichiers d'une machine : disque local ou SFTP.
+// Le disque local est celui du serveur de supervision (base, config.yaml, enregistrements des terminaux) :
+// il n'est ouvert qu'avec localAllowed (administrateurs). L'appelant doit fermer le FileStore retourné.
+func openFileStore(cm *ConfigManager, machineID string, localAllowed bool) (collectors.FileStore, int, error) {
+	cfg, pool, _ := cm.GetConfigPoolAndCache()
+	mc := cfg.GetMachine(machineID)
+	if mc == nil {
+		return nil, http.StatusNotFound, errors.New("Machine non trouvée")
+	}
+	if collectors.IsLocalHost(mc.Host) {
+		if !localAllowed {
+			return nil, http.StatusForbidden, errors.New("Fichiers du serveur de supervision réservés aux administrateurs")
+		}
+		return collectors.LocalStore{}, 0, nil
+	}
+
+	client, err := pool.GetClient(machineID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Erreur connexion SSH")
+	}
+	sftpClient, err := client.NewSFTP()
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("Erreur ouverture SFTP: %v", err)
+	}
+	return collectors.SFTPStore{Client: sftpClient}, 0, nil
+}
+
+// fileRequestPath valide le chemin d'un fichier demandé (traversée, chemins système sensibles).
+// En cas de refus, l'erreur est déjà envoyée au client.
+func fileRequestPath(w http.ResponseWriter, raw string) (string, bool) {
+	cleanPath, err := collectors.CleanRemotePath(raw)
+	if err != nil {
+		jsonError(w, "Chemin invalide: "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	if !isAllowedPath(cleanPath) || security.IsSensitivePath(cleanPath) {
+		jsonError(w, "Chemin non autorisé", http.StatusForbidden)
+		return "", false
+	}
+	return cleanPath, true
+}
+
+// fileErrorStatus associe une erreur d'accès fichier à un code HTTP
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, collectors.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, collectors.ErrBinaryFile):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, collectors.ErrNotRegular):
+		return http.StatusBadRequest
+	case errors.Is(err, collectors.ErrFileExists):
+		return http.StatusConflict
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// transferLimit convertit une limite en Mo de la configuration (valeur par défaut si absente)
+func transferLimit(mb int, def int64) int64 {
+	if mb <= 0 {
+		return def
+	}
+	return int64(mb) * 1024 * 1024
+}
+
+// DownloadFile télécharge un fichier de la machine en flux continu, dans la limite configurée (audité)
+func DownloadFile(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		p, ok := fileRequestPath(w, r.URL.Query().Get("path"))
+		if !ok {
+			return
+		}
+
+		store, code, err := openFileStore(cm, machineID, am.Can(r, config.CapabilityAdmin, ""))
+		if err != nil {
+			jsonError(w, err.Error(), code)
+			return
+		}
+		defer store.Close()
+
+		maxSize := transferLimit(cm.GetConfig().Settings.MaxDownloadMB, collectors.DefaultDownloadMaxSize)
+		f, info, err := collectors.OpenFile(store, p, maxSize)
+		if err != nil {
+			jsonError(w, "Téléchargement impossible: "+err.Error(), fileErrorStatus(err))
+			return
+		}
+		defer f.Close()
+
+		am.Audit(r, "FILE_DOWNLOAD", machineID, fmt.Sprintf("path=%s size=%d", p, info.Size()))
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-store")
+
+		// Taille figée à celle annoncée, même si le fichier grossit pendant le transfert
+		if _, err := io.Copy(w, io.LimitReader(f, info.Size())); err != nil {
+			log.Printf("Téléchargement interrompu %s:%s: %v", machineID, p, err)
+		}
+	}
+}
+
+// PreviewFile retourne le contenu d'un petit fichier texte avec le langage détecté (audité)
+func PreviewFile(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		p, ok := fileRequestPath(w, r.URL.Query().Get("path"))
+		if !ok {
+			return
+		}
+
+		store, code, err := openFileStore(cm, machineID, am.Can(r, config.CapabilityAdmin, ""))
+		if err != nil {
+			jsonError(w, err.Error(), code)
+			return
+		}
+		defer store.Close()
+
+		preview, err := collectors.PreviewFile(store, p, collectors.DefaultPreviewMaxSize)
+		if err != nil {
+			jsonError(w, "Aperçu impossible: "+err.Error(), fileErrorStatus(err))
+			return
+		}
+
+		am.Audit(r, "FILE_PREVIEW", machineID, "path="+p)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+	}
+}
+
+// UploadFile envoie un fichier vers un répertoire de la machine (Admin seulement, audité).
+// Formulaire multipart : champs "path" (répertoire cible) et "overwrite" avant la partie "file".
+func UploadFile(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		maxSize := transferLimit(cm.GetConfig().Settings.MaxUploadMB, collectors.DefaultUploadMaxSize)
+
+		// Marge pour les en-têtes multipart ; la taille du fichier est contrôlée à l'écriture
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+1024*1024)
+		mr, err := r.MultipartReader()
+		if err != nil {
+			jsonError(w, "Formulaire multipart attendu", http.StatusBadRequest)
+			return
+		}
+
+		var dir string
+		overwrite := false
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				jsonError(w, "Fichier manquant", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				jsonError(w, "Formulaire invalide: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			switch part.FormName() {
+			case "path", "overwrite":
+				value, err := io.ReadAll(io.LimitReader(part, 4096))
+				if err != nil {
+					jsonError(w, "Formulaire invalide", http.StatusBadRequest)
+					return
+				}
+				if part.FormName() == "path" {
+					dir = string(value)
+				} else {
+					overwrite = string(value) == "true" || string(value) == "1"
+				}
+				continue
+			case "file":
+			default:
+				continue
+			}
+
+			if dir == "" {
+				jsonError(w, "Répertoire cible manquant (champ path avant le fichier)", http.StatusBadRequest)
+				return
+			}
+			cleanDir, ok := fileRequestPath(w, dir)
+			if !ok {
+				return
+			}
+
+			// Certains navigateurs transmettent un chemin complet
+			name := path.Base(strings.ReplaceAll(part.FileName(), "\\", "/"))
+			if name == "" || name == "." || name == ".." || name == "/" {
+				jsonError(w, "Nom de fichier invalide", http.StatusBadRequest)
+				return
+			}
+			// Le fichier cible est soumis aux mêmes restrictions que le téléchargement (/etc/shadow...)
+			if _, ok := fileRequestPath(w, path.Join(cleanDir, name)); !ok {
+				return
+			}
+
+			// Route réservée aux administrateurs
+			store, code, err := openFileStore(cm, machineID, true)
+			if err != nil {
+				jsonError(w, err.Error(), code)
+				return
+			}
+			defer store.Close()
+
+			target, size, err := collectors.UploadFile(store, cleanDir, name, part, maxSize, overwrite)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					err = collectors.ErrFileTooLarge
+				}
+				jsonError(w, "Envoi impossible: "+err.Error(), fileErrorStatus(err))
+				return
+			}
+
+			am.Audit(r, "FILE_UPLOAD", machineID, fmt.Sprintf("path=%s size=%d overwrite=%t", target, size, overwrite))
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"path":    target,
+				"size":    size,
+			})
+			return
+		}
+	}
+}
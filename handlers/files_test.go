@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uploadRequest(t *testing.T, dir, filename string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("path", dir))
+	require.NoError(t, mw.WriteField("overwrite", "1"))
+	fw, err := mw.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	fw.Write([]byte("root::0:0:99999:7:::\n"))
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/machine/srv-1/files/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.SetPathValue("id", "srv-1")
+	return req
+}
+
+func TestUploadFile_SensitiveTarget(t *testing.T) {
+	handler := UploadFile(newTestConfigManager(), nil)
+
+	tests := []struct {
+		name     string
+		dir      string
+		filename string
+		want     int
+	}{
+		{"fichier sensible dans un répertoire autorisé", "/etc", "shadow", http.StatusForbidden},
+		{"clé SSH", "/root", ".ssh", http.StatusForbidden},
+		{"chemin complet du navigateur", "/etc", `C:\temp\sudoers`, http.StatusForbidden},
+		{"nom réservé", "/tmp", "..", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, uploadRequest(t, tt.dir, tt.filename))
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestOpenFileStore_LocalRequiresAdmin(t *testing.T) {
+	cm := newTestConfigManager()
+	cm.cfg.Machines = []config.MachineConfig{{ID: "local", Host: "localhost"}}
+
+	_, code, err := openFileStore(cm, "local", false)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	store, _, err := openFileStore(cm, "local", true)
+	require.NoError(t, err)
+	assert.IsType(t, collectors.LocalStore{}, store)
+	store.Close()
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/config"
+	"go-monitoring/forecast"
+	"go-monitoring/models"
+	"go-monitoring/storage"
+)
+
+const (
+	// diskForecastWindow est la période d'historique prise en compte pour la tendance
+	diskForecastWindow = 14 * 24 * time.Hour
+	// diskForecastMaxAge : au-delà, le système de fichiers n'est plus relevé (démonté, machine hors ligne)
+	diskForecastMaxAge = 2 * time.Hour
+	// dashboardForecastDays limite l'affichage sur le dashboard aux échéances proches
+	dashboardForecastDays = 90
+)
+
+// forecastStore conserve les dernières prévisions par machine et point de montage
+type forecastStore struct {
+	mu        sync.RWMutex
+	byMachine map[string]map[string]models.DiskForecast
+}
+
+var diskForecasts = &forecastStore{byMachine: make(map[string]map[string]models.DiskForecast)}
+
+func (s *forecastStore) set(byMachine map[string]map[string]models.DiskForecast) {
+	s.mu.Lock()
+	s.byMachine = byMachine
+	s.mu.Unlock()
+}
+
+// machine retourne les prévisions d'une machine, triées par échéance (les disques qui se remplissent d'abord)
+func (s *forecastStore) machine(id string) []models.DiskForecast {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]models.DiskForecast, 0, len(s.byMachine[id]))
+	for _, fc := range s.byMachine[id] {
+		list = append(list, fc)
+	}
+	sortForecasts(list)
+	return list
+}
+
+// all retourne toutes les prévisions du parc
+func (s *forecastStore) all() []models.DiskForecast {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []models.DiskForecast
+	for _, m := range s.byMachine {
+		for _, fc := range m {
+			list = append(list, fc)
+		}
+	}
+	sortForecasts(list)
+	return list
+}
+
+func sortForecasts(list []models.DiskForecast) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Filling != list[j].Filling {
+			return list[i].Filling
+		}
+		if list[i].Filling && list[i].DaysToFull != list[j].DaysToFull {
+			return list[i].DaysToFull < list[j].DaysToFull
+		}
+		if list[i].MachineID != list[j].MachineID {
+			return list[i].MachineID < list[j].MachineID
+		}
+		return list[i].MountPoint < list[j].MountPoint
+	})
+}
+
+// attachDiskForecasts associe à chaque disque de la machine sa dernière prévision.
+// Les disques sont copiés : la liste d'origine peut être partagée avec le cache.
+func attachDiskForecasts(m *models.Machine) {
+	diskForecasts.mu.RLock()
+	defer diskForecasts.mu.RUnlock()
+
+	forecasts := diskForecasts.byMachine[m.ID]
+	if len(forecasts) == 0 || len(m.Disks) == 0 {
+		return
+	}
+	disks := make([]models.DiskInfo, len(m.Disks))
+	copy(disks, m.Disks)
+	for i := range disks {
+		if fc, ok := forecasts[disks[i].MountPoint]; ok {
+			disks[i].Forecast = &fc
+		}
+	}
+	m.Disks = disks
+}
+
+// DiskForecaster calcule périodiquement les prévisions de remplissage à partir de l'historique disque
+// et lève une alerte lorsqu'un disque sera plein avant les seuils configurés
+type DiskForecaster struct {
+	cm     *ConfigManager
+	db     *storage.DB
+	alerts *alerts.Manager
+}
+
+// NewDiskForecaster crée le calculateur de prévisions disque
+func NewDiskForecaster(cm *ConfigManager, db *storage.DB, am *alerts.Manager) *DiskForecaster {
+	return &DiskForecaster{cm: cm, db: db, alerts: am}
+}
+
+// Refresh recalcule les prévisions de toutes les machines puis synchronise les alertes
+func (f *DiskForecaster) Refresh() {
+	cfg := f.cm.GetConfig()
+	now := time.Now()
+	thresholds := cfg.Settings.Thresholds
+
+	results := make(map[string]map[string]models.DiskForecast)
+	for _, mc := range cfg.Machines {
+		history, err := f.db.GetDiskHistory(mc.ID, diskForecastWindow)
+		if err != nil {
+			log.Printf("Erreur historique disque %s: %v", mc.ID, err)
+			continue
+		}
+
+		for mount, points := range history {
+			if len(points) == 0 || now.Sub(points[len(points)-1].Timestamp) > diskForecastMaxAge {
+				continue
+			}
+			fc, err := forecast.Disk(points, now)
+			if err != nil {
+				continue
+			}
+			fc.MachineID = mc.ID
+			fc.MountPoint = mount
+			fc.Severity = forecastSeverity(fc, thresholds)
+
+			if results[mc.ID] == nil {
+				results[mc.ID] = make(map[string]models.DiskForecast)
+			}
+			results[mc.ID][mount] = fc
+		}
+	}
+
+	diskForecasts.set(results)
+	f.syncAlerts()
+}
+
+// forecastSeverity évalue une prévision par rapport aux seuils d'alerte
+func forecastSeverity(fc models.DiskForecast, t config.Thresholds) string {
+	switch {
+	case !fc.Filling:
+		return ""
+	case fc.DaysToFull < float64(t.DiskFullCriticalDays):
+		return string(alerts.SeverityCritical)
+	case fc.DaysToFull < float64(t.DiskFullWarningDays):
+		return string(alerts.SeverityWarning)
+	}
+	return ""
+}
+
+// syncAlerts lève une alerte par disque dont le remplissage est prévu avant le seuil
+func (f *DiskForecaster) syncAlerts() {
+	if f.alerts == nil {
+		return
+	}
+
+	raised := make(map[string]bool)
+	for _, fc := range diskForecasts.all() {
+		if fc.Severity == "" {
+			continue
+		}
+		key := "forecast:" + fc.MachineID + ":" + fc.MountPoint
+		raised[key] = true
+
+		f.alerts.Raise(alerts.Alert{
+			Key:       key,
+			MachineID: fc.MachineID,
+			Source:    "forecast",
+			Name:      fc.MountPoint,
+			Severity:  alerts.Severity(fc.Severity),
+			Message:   fmt.Sprintf("%s sera plein %s (%s)", fc.MountPoint, formatFullIn(fc.DaysToFull), formatGrowth(fc.GrowthPerDay)),
+		})
+	}
+
+	for _, a := range f.alerts.Active() {
+		if a.Source == "forecast" && !raised[a.Key] {
+			f.alerts.Resolve(a.Key)
+		}
+	}
+}
+
+// formatFullIn formate l'échéance de remplissage ("dans ~12 jours")
+func formatFullIn(days float64) string {
+	switch {
+	case days < 1:
+		return "dans moins d'un jour"
+	case days < 60:
+		return fmt.Sprintf("dans ~%d jours", int(math.Round(days)))
+	case days < 730:
+		return fmt.Sprintf("dans ~%d mois", int(math.Round(days/30)))
+	}
+	return fmt.Sprintf("dans ~%d ans", int(math.Round(days/365)))
+}
+
+// formatGrowth formate une croissance en octets/jour ("+1.5 GiB/jour")
+func formatGrowth(perDay float64) string {
+	if perDay < 0 {
+		return "-" + formatBytes(uint64(-perDay)) + "/jour"
+	}
+	return "+" + formatBytes(uint64(perDay)) + "/jour"
+}
+
+// nextDiskFull retourne le disque de la machine qui se remplira le plus tôt (dashboard)
+func nextDiskFull(m models.Machine) *models.DiskForecast {
+	var next *models.DiskForecast
+	for _, d := range m.Disks {
+		fc := d.Forecast
+		if fc == nil || !fc.Filling || fc.DaysToFull > dashboardForecastDays {
+			continue
+		}
+		if next == nil || fc.DaysToFull < next.DaysToFull {
+			next = fc
+		}
+	}
+	return next
+}
+
+// GetDiskForecast retourne les prévisions de remplissage des disques d'une machine
+func GetDiskForecast(cm *ConfigManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		if cm.GetConfig().GetMachine(machineID) == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(diskForecasts.machine(machineID))
+	}
+}
+
+// GetDiskHistory retourne l'historique d'occupation des disques d'une machine (paramètre days, défaut 14)
+func GetDiskHistory(cm *ConfigManager, db *storage.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		if cm.GetConfig().GetMachine(machineID) == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+
+		duration := diskForecastWindow
+		if days, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && days > 0 && days <= 30 {
+			duration = time.Duration(days) * 24 * time.Hour
+		}
+
+		history, err := db.GetDiskHistory(machineID, duration)
+		if err != nil {
+			jsonError(w, "Erreur lecture historique disque", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
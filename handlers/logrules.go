@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/storage"
+)
+
+// LogRuleScheduler lit périodiquement les nouvelles lignes de logs (depuis un curseur
+// persistant) et déclenche une alerte lorsque le nombre de correspondances dépasse le seuil
+type LogRuleScheduler struct {
+	cm      *ConfigManager
+	db      *storage.DB
+	alerts  *alerts.Manager
+	lastRun map[string]time.Time
+	running map[string]bool
+	windows map[string]*collectors.LogMatchWindow
+	mu      sync.Mutex
+}
+
+// NewLogRuleScheduler crée un ordonnanceur de règles de logs
+func NewLogRuleScheduler(cm *ConfigManager, db *storage.DB, am *alerts.Manager) *LogRuleScheduler {
+	return &LogRuleScheduler{
+		cm:      cm,
+		db:      db,
+		alerts:  am,
+		lastRun: make(map[string]time.Time),
+		running: make(map[string]bool),
+		windows: make(map[string]*collectors.LogMatchWindow),
+	}
+}
+
+// RunDue lance en arrière-plan les règles dont l'intervalle est écoulé
+func (s *LogRuleScheduler) RunDue() {
+	cfg := s.cm.GetConfig()
+	now := time.Now()
+	configured := make(map[string]bool)
+
+	for _, mc := range cfg.Machines {
+		for _, rule := range mc.LogRules {
+			key := mc.ID + "/" + rule.Name
+			configured["log:"+mc.ID+":"+rule.Name] = true
+
+			s.mu.Lock()
+			due := !s.running[key] && now.Sub(s.lastRun[key]) >= time.Duration(rule.Interval)*time.Second
+			if due {
+				s.running[key] = true
+				s.lastRun[key] = now
+			}
+			s.mu.Unlock()
+
+			if due {
+				go func(mc config.MachineConfig, rule config.LogRuleConfig, key string) {
+					defer func() {
+						s.mu.Lock()
+						delete(s.running, key)
+						s.mu.Unlock()
+					}()
+					s.runRule(mc, rule, key)
+				}(mc, rule, key)
+			}
+		}
+	}
+
+	// Résoudre les alertes des règles retirées de la configuration
+	if s.alerts != nil {
+		for _, a := range s.alerts.Active() {
+			if a.Source == "log" && !configured[a.Key] {
+				s.alerts.Resolve(a.Key)
+			}
+		}
+	}
+}
+
+// runRule lit les nouvelles lignes d'une règle et met à jour son alerte
+func (s *LogRuleScheduler) runRule(mc config.MachineConfig, rule config.LogRuleConfig, key string) {
+	osType := mc.OS
+	if osType == "" {
+		osType = "linux"
+	}
+
+	source, ok := collectors.ResolveLogSource(rule.Source, osType, mc.LogSources)
+	if !ok {
+		s.raise(mc.ID, rule, alerts.SeverityUnknown, fmt.Sprintf("Source de logs inconnue: %s", rule.Source), nil)
+		return
+	}
+	// Le motif est validé au chargement de la configuration
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return
+	}
+
+	_, pool, _ := s.cm.GetConfigPoolAndCache()
+	client, err := pool.GetClient(mc.ID)
+	if err != nil {
+		log.Printf("Règle de logs %s: client SSH indisponible: %v", key, err)
+		return
+	}
+
+	cursor, err := s.db.GetLogCursor(mc.ID, rule.Name, rule.Source)
+	if err != nil {
+		log.Printf("Règle de logs %s: lecture du curseur: %v", key, err)
+		return
+	}
+
+	batch, err := collectors.ReadNewLogLines(client, source, cursor)
+	if err != nil {
+		s.raise(mc.ID, rule, alerts.SeverityUnknown, "Lecture des logs impossible: "+err.Error(), nil)
+		return
+	}
+	if batch.Skipped {
+		log.Printf("Règle de logs %s: rotation ou volume important, des lignes ont pu être ignorées", key)
+	}
+	if batch.Cursor != "" && batch.Cursor != cursor {
+		if err := s.db.SaveLogCursor(mc.ID, rule.Name, rule.Source, batch.Cursor); err != nil {
+			log.Printf("Règle de logs %s: sauvegarde du curseur: %v", key, err)
+		}
+	}
+
+	now := time.Now()
+	window := time.Duration(rule.Window) * time.Second
+
+	s.mu.Lock()
+	w, ok := s.windows[key]
+	if !ok {
+		w = collectors.NewLogMatchWindow(window)
+		s.windows[key] = w
+	}
+	w.Add(now, collectors.MatchLogLines(re, batch.Lines))
+	count := w.Count(now)
+	samples := w.Samples()
+	s.mu.Unlock()
+
+	if count < rule.Threshold {
+		if s.alerts != nil {
+			s.alerts.Resolve("log:" + mc.ID + ":" + rule.Name)
+		}
+		return
+	}
+
+	severity := alerts.SeverityWarning
+	if rule.Severity == "critical" {
+		severity = alerts.SeverityCritical
+	}
+	s.raise(mc.ID, rule, severity, fmt.Sprintf("%d ligne(s) correspondant à %q dans %s sur %s (seuil: %d)",
+		count, rule.Pattern, source.Name, window, rule.Threshold), samples)
+}
+
+func (s *LogRuleScheduler) raise(machineID string, rule config.LogRuleConfig, severity alerts.Severity, message string, samples []string) {
+	if s.alerts == nil {
+		return
+	}
+	s.alerts.Raise(alerts.Alert{
+		Key:       "log:" + machineID + ":" + rule.Name,
+		MachineID: machineID,
+		Source:    "log",
+		Name:      rule.Name,
+		Severity:  severity,
+		Message:   message,
+		Samples:   samples,
+	})
+}
@@ -7,7 +7,7 @@ import (
 
 	"go-monitoring/auth"
 	"go-monitoring/collectors"
-	"go-monitoring/storage"
+	"go-monitoring/config"
 )
 
 // ListLogSources retourne les sources de logs disponibles
@@ -33,7 +33,7 @@ func ListLogSources(cm *ConfigManager) http.HandlerFunc {
 			osType = "linux"
 		}
 
-		sources, err := collectors.GetAvailableLogSources(client, osType)
+		sources, err := collectors.GetAvailableLogSources(client, osType, machineConfig.LogSources)
 		if err != nil {
 			http.Error(w, "Erreur récupération sources: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -45,7 +45,7 @@ func ListLogSources(cm *ConfigManager) http.HandlerFunc {
 }
 
 // GetLogContent retourne le contenu d'un log
-func GetLogContent(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+func GetLogContent(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		machineID := r.PathValue("id")
 
@@ -80,7 +80,7 @@ func GetLogContent(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http
 		// Récupérer la définition de la source
 		// Note: C'est un peu inefficace de tout relister, mais ça sécurise l'input
 		// car on ne construit la commande qu'à partir de la liste "autorisée" générée côté serveur.
-		sources, err := collectors.GetAvailableLogSources(client, osType)
+		sources, err := collectors.GetAvailableLogSources(client, osType, machineConfig.LogSources)
 		if err != nil {
 			http.Error(w, "Erreur: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -108,10 +108,73 @@ func GetLogContent(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http
 			return
 		}
 
-		// Audit (Lecture seule, mais bon à savoir)
-		// db.LogAction(am.GetUsername(r), "VIEW_LOG", machineID+":"+sourceID, "Lines: "+linesStr, r.RemoteAddr)
+		// Audit (lecture seule, mais les journaux peuvent contenir des données sensibles)
+		am.Audit(r, "LOG_VIEW", machineID, "source="+sourceID+" lines="+strconv.Itoa(lines))
 
 		w.Header().Set("Content-Type", "text/plain")
 		w.Write([]byte(content))
 	}
 }
+
+// GetMachineLogSources retourne les sources de logs personnalisées d'une machine (admin seulement)
+func GetMachineLogSources(cm *ConfigManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineConfig := cm.GetConfig().GetMachine(r.PathValue("id"))
+		if machineConfig == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+
+		sources := machineConfig.LogSources
+		if sources == nil {
+			sources = []config.LogSourceConfig{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sources)
+	}
+}
+
+// UpdateMachineLogSources remplace les sources de logs personnalisées d'une machine (admin seulement)
+func UpdateMachineLogSources(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		var sources []config.LogSourceConfig
+		if err := json.NewDecoder(r.Body).Decode(&sources); err != nil {
+			jsonError(w, "Données invalides: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+
+		machine := cm.cfg.GetMachine(machineID)
+		if machine == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+		previous := machine.LogSources
+
+		if err := cm.cfg.SetLogSources(machineID, sources); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := config.SaveConfig(cm.path, cm.cfg); err != nil {
+			// Rollback
+			cm.cfg.SetLogSources(machineID, previous)
+			jsonError(w, "Erreur sauvegarde: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		details, _ := json.Marshal(sources)
+		am.Audit(r, "UPDATE_LOG_SOURCES", machineID, string(details))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Sources de logs mises à jour",
+			"sources": sources,
+		})
+	}
+}
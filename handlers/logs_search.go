@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-monitoring/auth"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/pkg/security"
+	"go-monitoring/ssh"
+)
+
+const (
+	// Nombre de machines interrogées simultanément
+	logSearchConcurrency = 8
+	// Nombre de lignes retournées par défaut et au maximum
+	defaultSearchLimit = 200
+	maxSearchLimit     = 1000
+)
+
+// LogSearchResponse est la réponse de l'API de recherche
+type LogSearchResponse struct {
+	Results   []collectors.LogSearchHit `json:"results"`
+	Errors    map[string]string         `json:"errors,omitempty"`
+	Truncated bool                      `json:"truncated"`
+}
+
+// SearchLogs recherche un motif dans les logs de plusieurs machines en parallèle.
+// Paramètres: q (motif), machines (IDs séparés par des virgules), group, sources,
+// since (durée "2h" ou RFC3339, défaut 1h), until (RFC3339), limit.
+func SearchLogs(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		pattern := query.Get("q")
+		if err := security.ValidateSearchPattern(pattern); err != nil {
+			jsonError(w, "Motif de recherche invalide (expression régulière, 256 caractères max)", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		since, err := parseSearchTime(query.Get("since"), now, now.Add(-time.Hour))
+		if err != nil {
+			jsonError(w, "Paramètre 'since' invalide", http.StatusBadRequest)
+			return
+		}
+		until, err := parseSearchTime(query.Get("until"), now, time.Time{})
+		if err != nil {
+			jsonError(w, "Paramètre 'until' invalide", http.StatusBadRequest)
+			return
+		}
+
+		limit := defaultSearchLimit
+		if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+			limit = min(l, maxSearchLimit)
+		}
+
+		cfg, pool, _ := cm.GetConfigPoolAndCache()
+		var machines []config.MachineConfig
+		for _, mc := range selectSearchMachines(cfg, splitList(query.Get("machines")), query.Get("group")) {
+			// Seules les machines dont l'utilisateur peut lire les logs sont interrogées
+			if am.Can(r, config.CapabilityLogs, mc.ID) {
+				machines = append(machines, mc)
+			}
+		}
+		if len(machines) == 0 {
+			jsonError(w, "Aucune machine ne correspond à la sélection", http.StatusBadRequest)
+			return
+		}
+
+		wanted := make(map[string]bool)
+		for _, id := range splitList(query.Get("sources")) {
+			wanted[id] = true
+		}
+
+		q := collectors.LogSearchQuery{Pattern: pattern, Since: since, Until: until, Limit: limit}
+		resp := LogSearchResponse{Errors: make(map[string]string)}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, logSearchConcurrency)
+
+		for _, mc := range machines {
+			wg.Add(1)
+			go func(mc config.MachineConfig) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				hits, err := searchMachine(pool, mc, wanted, q)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					resp.Errors[mc.ID] = err.Error()
+					return
+				}
+				resp.Results = append(resp.Results, hits...)
+			}(mc)
+		}
+		wg.Wait()
+
+		// Fusion chronologique: on conserve les lignes les plus récentes
+		collectors.SortLogHits(resp.Results)
+		if len(resp.Results) > limit {
+			resp.Results = resp.Results[len(resp.Results)-limit:]
+			resp.Truncated = true
+		}
+		if resp.Results == nil {
+			resp.Results = []collectors.LogSearchHit{}
+		}
+
+		ids := make([]string, len(machines))
+		for i, m := range machines {
+			ids[i] = m.ID
+		}
+		am.Audit(r, "LOG_SEARCH", strings.Join(ids, ","), "pattern="+pattern+" since="+since.Format(time.RFC3339))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// searchMachine recherche dans les sources autorisées d'une machine
+func searchMachine(pool *ssh.Pool, mc config.MachineConfig, wanted map[string]bool, q collectors.LogSearchQuery) ([]collectors.LogSearchHit, error) {
+	client, err := pool.GetClient(mc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	osType := mc.OS
+	if osType == "" {
+		osType = "linux"
+	}
+
+	// Seules les sources de la liste blanche sont interrogées
+	available, err := collectors.GetAvailableLogSources(client, osType, mc.LogSources)
+	if err != nil {
+		return nil, err
+	}
+	var sources []collectors.LogSource
+	for _, s := range available {
+		if len(wanted) == 0 || wanted[s.ID] {
+			sources = append(sources, s)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	return collectors.SearchLogs(client, mc.ID, sources, q)
+}
+
+// selectSearchMachines retourne les machines visées (IDs explicites, groupe, ou toutes)
+func selectSearchMachines(cfg *config.Config, ids []string, group string) []config.MachineConfig {
+	var selected []config.MachineConfig
+	for _, mc := range cfg.Machines {
+		if len(ids) > 0 && !slices.Contains(ids, mc.ID) {
+			continue
+		}
+		if group != "" && mc.Group != group {
+			continue
+		}
+		selected = append(selected, mc)
+	}
+	return selected
+}
+
+// parseSearchTime accepte une durée relative ("2h", "30m") ou une date RFC3339
+func parseSearchTime(value string, now, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
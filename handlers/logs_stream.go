@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-monitoring/collectors"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// Débit par défaut et maximal du suivi en direct (lignes/s)
+	defaultTailRate = 50
+	maxTailRate     = 500
+	// Longueur maximale d'une ligne transmise
+	maxTailLineLength = 4096
+)
+
+// LogStreamMessage définit le protocole du suivi de logs en direct.
+// Client -> serveur: "pause", "resume", "filter" (include/exclude).
+// Serveur -> client: "line", "dropped", "status", "error".
+type LogStreamMessage struct {
+	Type    string `json:"type"`
+	Data    string `json:"data,omitempty"`
+	Count   int    `json:"count,omitempty"`
+	Include string `json:"include,omitempty"`
+	Exclude string `json:"exclude,omitempty"`
+}
+
+// StreamLogs diffuse une source de logs en direct (tail -F, journalctl -f, Get-WinEvent) via WebSocket
+func StreamLogs(cm *ConfigManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		query := r.URL.Query()
+
+		filter, err := collectors.NewLogFilter(query.Get("include"), query.Get("exclude"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rate := defaultTailRate
+		if v, err := strconv.Atoi(query.Get("rate")); err == nil && v > 0 {
+			rate = min(v, maxTailRate)
+		}
+
+		cfg, pool, _ := cm.GetConfigPoolAndCache()
+		machineConfig := cfg.GetMachine(machineID)
+		if machineConfig == nil {
+			http.Error(w, "Machine introuvable", http.StatusNotFound)
+			return
+		}
+
+		client, err := pool.GetClient(machineID)
+		if err != nil {
+			http.Error(w, "Erreur connexion SSH: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		osType := machineConfig.OS
+		if osType == "" {
+			osType = "linux"
+		}
+
+		// Comme pour GetLogContent, la commande n'est construite qu'à partir des sources autorisées
+		sources, err := collectors.GetAvailableLogSources(client, osType, machineConfig.LogSources)
+		if err != nil {
+			http.Error(w, "Erreur: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var source *collectors.LogSource
+		for i := range sources {
+			if sources[i].ID == query.Get("source") {
+				source = &sources[i]
+				break
+			}
+		}
+		if source == nil {
+			http.Error(w, "Source de log introuvable ou inaccessible", http.StatusBadRequest)
+			return
+		}
+
+		cmd, err := collectors.TailCommand(*source, 50)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("Logs live: Erreur upgrade WS: %v", err)
+			return
+		}
+		defer ws.Close()
+
+		session, err := client.NewSession()
+		if err != nil {
+			ws.WriteJSON(LogStreamMessage{Type: "error", Data: "Impossible de créer la session SSH: " + err.Error()})
+			return
+		}
+		// Le PTY garantit que le processus distant reçoit SIGHUP à la fermeture du canal
+		defer func() {
+			session.Signal(ssh.SIGTERM)
+			session.Close()
+		}()
+
+		stdout, err := session.StdoutPipe()
+		if err != nil {
+			return
+		}
+		if err := session.RequestPty("dumb", 40, 512, ssh.TerminalModes{ssh.ECHO: 0}); err != nil {
+			ws.WriteJSON(LogStreamMessage{Type: "error", Data: "RequestPty failed"})
+			return
+		}
+		if err := session.Start(cmd); err != nil {
+			ws.WriteJSON(LogStreamMessage{Type: "error", Data: "Démarrage du suivi échoué: " + err.Error()})
+			return
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+
+		// Goroutine: sortie SSH -> lignes
+		lines := make(chan string, 256)
+		go func() {
+			defer close(lines)
+			reader := bufio.NewReaderSize(stdout, 64*1024)
+			for {
+				line, err := reader.ReadString('\n')
+				if line = strings.TrimRight(line, "\r\n"); line != "" {
+					if len(line) > maxTailLineLength {
+						line = line[:maxTailLineLength]
+					}
+					select {
+					case lines <- line:
+					case <-done:
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		// Goroutine: messages de contrôle du navigateur
+		controls := make(chan LogStreamMessage)
+		go func() {
+			defer close(controls)
+			for {
+				var msg LogStreamMessage
+				if err := ws.ReadJSON(&msg); err != nil {
+					var closeErr *websocket.CloseError
+					if !errors.As(err, &closeErr) {
+						log.Printf("Logs live %s: lecture WS: %v", machineID, err)
+					}
+					return
+				}
+				select {
+				case controls <- msg:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		limiter := collectors.NewLineLimiter(rate)
+		paused := false
+		skipped := 0
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		lastPing := time.Now()
+
+		// Boucle principale: seul écrivain sur la WebSocket
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					ws.WriteJSON(LogStreamMessage{Type: "status", Data: "ended"})
+					return
+				}
+				if !filter.Match(line) {
+					continue
+				}
+				if paused {
+					skipped++
+					continue
+				}
+				if !limiter.Allow(time.Now()) {
+					continue
+				}
+				if err := ws.WriteJSON(LogStreamMessage{Type: "line", Data: line}); err != nil {
+					return
+				}
+
+			case msg, ok := <-controls:
+				if !ok {
+					// Navigateur déconnecté: les defers arrêtent le processus distant
+					return
+				}
+				switch msg.Type {
+				case "pause":
+					paused = true
+					ws.WriteJSON(LogStreamMessage{Type: "status", Data: "paused"})
+				case "resume":
+					paused = false
+					ws.WriteJSON(LogStreamMessage{Type: "status", Data: "resumed", Count: skipped})
+					skipped = 0
+				case "filter":
+					newFilter, err := collectors.NewLogFilter(msg.Include, msg.Exclude)
+					if err != nil {
+						ws.WriteJSON(LogStreamMessage{Type: "error", Data: err.Error()})
+						continue
+					}
+					filter = newFilter
+					ws.WriteJSON(LogStreamMessage{Type: "status", Data: "filter"})
+				}
+
+			case <-ticker.C:
+				if n := limiter.TakeDropped(); n > 0 {
+					if err := ws.WriteJSON(LogStreamMessage{Type: "dropped", Count: n}); err != nil {
+						return
+					}
+				}
+				if time.Since(lastPing) >= pingPeriod {
+					lastPing = time.Now()
+					if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+						return
+					}
+				}
+			}
+		}
+	}
+}
@@ -15,11 +15,14 @@ import (
 	"go-monitoring/middleware"
 	"go-monitoring/models"
 	"go-monitoring/ssh"
+	"go-monitoring/storage"
 )
 
 // Fonctions de formatage pour les templates
 var templateFuncs = template.FuncMap{
 	"formatBytes":   formatBytes,
+	"fullIn":        formatFullIn,
+	"formatGrowth":  formatGrowth,
 	"formatPercent": formatPercent,
 	"formatRate":    formatRate,
 	"lower":         strings.ToLower,
@@ -27,7 +30,7 @@ var templateFuncs = template.FuncMap{
 }
 
 // MachineDetail gère la page de détail d'une machine
-func MachineDetail(cfg *config.Config, pool *ssh.Pool, cache *cache.MetricsCache, am *auth.AuthManager) http.HandlerFunc {
+func MachineDetail(cfg *config.Config, pool *ssh.Pool, cache *cache.MetricsCache, am *auth.AuthManager, db *storage.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		machineID := r.PathValue("id")
 		if machineID == "" {
@@ -55,6 +58,7 @@ func MachineDetail(cfg *config.Config, pool *ssh.Pool, cache *cache.MetricsCache
 
 		// Collecter les infos avec timeout et cache
 		machine = collectMachineDetailWithTimeout(machine, machineConfig, cfg, pool, cache, 1*time.Second)
+		attachDiskForecasts(&machine)
 
 		// Charger les templates avec les fonctions personnalisées
 		tmpl, err := template.New("base.html").Funcs(templateFuncs).ParseFiles(
@@ -69,19 +73,23 @@ func MachineDetail(cfg *config.Config, pool *ssh.Pool, cache *cache.MetricsCache
 
 		role := ""
 		username := ""
+		capabilities := map[string]bool{}
 		if am != nil {
 			role = am.GetUserRole(r)
 			username = am.GetUsername(r)
+			capabilities = am.Capabilities(r, machineID)
 		}
 
 		// Préparer les données
 		data := models.MachineDetailData{
-			Machine:   machine,
-			Time:      time.Now().Format("15:04:05"),
-			Status:    "OK",
-			Role:      role,
-			Username:  username,
-			CSRFToken: middleware.GetCSRFToken(r),
+			Machine:      machine,
+			Checks:       machineCheckResults(db, machineConfig),
+			Time:         time.Now().Format("15:04:05"),
+			Status:       "OK",
+			Role:         role,
+			Username:     username,
+			CSRFToken:    middleware.GetCSRFToken(r),
+			Capabilities: capabilities,
 		}
 
 		// Rendre le template
@@ -244,10 +252,10 @@ func collectMachineDetailWithTimeout(machine models.Machine, machineConfig *conf
 }
 
 // MachineDetailWithCM gère la page de détail avec ConfigManager
-func MachineDetailWithCM(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+func MachineDetailWithCM(cm *ConfigManager, am *auth.AuthManager, db *storage.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cfg, pool, cache := cm.GetConfigPoolAndCache()
-		MachineDetail(cfg, pool, cache, am)(w, r)
+		MachineDetail(cfg, pool, cache, am, db)(w, r)
 	}
 }
 
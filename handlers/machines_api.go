@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 
 	"go-monitoring/auth"
 	"go-monitoring/cache"
 	"go-monitoring/config"
+	"go-monitoring/pkg/crypto"
 	"go-monitoring/ssh"
 )
 
@@ -75,7 +78,7 @@ func (cm *ConfigManager) GetConfigPoolAndCache() (*config.Config, *ssh.Pool, *ca
 }
 
 // AddMachine ajoute une machine via l'API
-func AddMachine(cm *ConfigManager) http.HandlerFunc {
+func AddMachine(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println("API: Received AddMachine request")
 		var machine config.MachineConfig
@@ -126,6 +129,9 @@ func AddMachine(cm *ConfigManager) http.HandlerFunc {
 
 		// Recréer le pool SSH pour inclure la nouvelle machine
 		cm.pool = ssh.NewPool(cm.cfg.Machines, cm.cfg.Settings.SSHTimeout)
+		if added := cm.cfg.GetMachine(machine.ID); added != nil {
+			am.Audit(r, "MACHINE_CREATE", added.ID, fmt.Sprintf("host=%s port=%d user=%s group=%s", added.Host, added.Port, added.User, added.Group))
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
@@ -138,7 +144,7 @@ func AddMachine(cm *ConfigManager) http.HandlerFunc {
 }
 
 // UpdateMachine met à jour une machine via l'API
-func UpdateMachine(cm *ConfigManager) http.HandlerFunc {
+func UpdateMachine(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		machineID := r.PathValue("id")
 		if machineID == "" {
@@ -166,6 +172,10 @@ func UpdateMachine(cm *ConfigManager) http.HandlerFunc {
 		defer cm.mu.Unlock()
 
 		// Mise à jour
+		var changes string
+		if old := cm.cfg.GetMachine(machineID); old != nil {
+			changes = machineChanges(*old, machine)
+		}
 		if err := cm.cfg.UpdateMachine(machine); err != nil {
 			jsonError(w, err.Error(), http.StatusNotFound)
 			return
@@ -179,6 +189,7 @@ func UpdateMachine(cm *ConfigManager) http.HandlerFunc {
 
 		// Refresh Pool
 		cm.pool = ssh.NewPool(cm.cfg.Machines, cm.cfg.Settings.SSHTimeout)
+		am.Audit(r, "MACHINE_UPDATE", machineID, "champs="+changes)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -190,7 +201,7 @@ func UpdateMachine(cm *ConfigManager) http.HandlerFunc {
 }
 
 // RemoveMachine supprime une machine via l'API
-func RemoveMachine(cm *ConfigManager) http.HandlerFunc {
+func RemoveMachine(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		machineID := r.PathValue("id")
 		if machineID == "" {
@@ -215,6 +226,7 @@ func RemoveMachine(cm *ConfigManager) http.HandlerFunc {
 
 		// Recréer le pool SSH sans la machine supprimée
 		cm.pool = ssh.NewPool(cm.cfg.Machines, cm.cfg.Settings.SSHTimeout)
+		am.Audit(r, "MACHINE_DELETE", machineID, "")
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -224,16 +236,63 @@ func RemoveMachine(cm *ConfigManager) http.HandlerFunc {
 	}
 }
 
+// machineChanges liste les champs modifiés d'une machine pour l'audit (sans les valeurs des secrets)
+func machineChanges(old, updated config.MachineConfig) string {
+	port := updated.Port
+	if port == 0 {
+		port = 22
+	}
+	var fields []string
+	for _, f := range []struct {
+		name    string
+		changed bool
+	}{
+		{"name", old.Name != updated.Name},
+		{"host", old.Host != updated.Host},
+		{"port", old.Port != port},
+		{"user", old.User != updated.User},
+		{"key_path", old.KeyPath != updated.KeyPath},
+		{"password", passwordChanged(old.Password, updated.Password)},
+		{"group", old.Group != updated.Group},
+		{"os", old.OS != updated.OS},
+		{"services", strings.Join(old.Services, ",") != strings.Join(updated.Services, ",")},
+	} {
+		if f.changed {
+			fields = append(fields, f.name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// passwordChanged compare deux mots de passe, l'ancien pouvant être chiffré (ENC:...) ;
+// seul le fait qu'il ait changé est audité, jamais sa valeur
+func passwordChanged(old, updated string) bool {
+	if old == updated {
+		return false
+	}
+	if crypto.IsEncrypted(old) && !crypto.IsEncrypted(updated) {
+		if plain, err := crypto.Decrypt(old); err == nil {
+			return plain != updated
+		}
+	}
+	return true
+}
+
 // ListMachines retourne la liste des machines configurées
-func ListMachines(cm *ConfigManager) http.HandlerFunc {
+func ListMachines(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		visible := am.Visible(r)
+
 		cm.mu.RLock()
 		defer cm.mu.RUnlock()
 
-		// Ne pas exposer les mots de passe
-		machines := make([]map[string]interface{}, len(cm.cfg.Machines))
-		for i, m := range cm.cfg.Machines {
-			machines[i] = map[string]interface{}{
+		// Ne pas exposer les mots de passe ; seules les machines visibles par l'utilisateur sont listées
+		machines := make([]map[string]interface{}, 0, len(cm.cfg.Machines))
+		for _, m := range cm.cfg.Machines {
+			if !visible(m.ID) {
+				continue
+			}
+			machines = append(machines, map[string]interface{}{
 				"id":       m.ID,
 				"name":     m.Name,
 				"host":     m.Host,
@@ -241,7 +300,7 @@ func ListMachines(cm *ConfigManager) http.HandlerFunc {
 				"user":     m.User,
 				"has_key":  m.KeyPath != "",
 				"has_pass": m.Password != "",
-			}
+			})
 		}
 
 		w.Header().Set("Content-Type", "application/json")
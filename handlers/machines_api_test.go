@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"go-monitoring/cache"
 	"go-monitoring/config"
@@ -26,20 +29,21 @@ func newTestConfigManager() *ConfigManager {
 	}
 
 	pool := ssh.NewPool([]config.MachineConfig{}, 10)
-	metricsCache := cache.NewMetricsCache()
+	metricsCache := cache.NewMetricsCache(10 * time.Second)
 
-	return NewConfigManager(cfg, pool, metricsCache, "test_config.yaml")
+	return NewConfigManager(cfg, pool, metricsCache, filepath.Join(os.TempDir(), "go-monitoring-test_config.yaml"))
 }
 
 func TestAddMachine_Success(t *testing.T) {
 	cm := newTestConfigManager()
 
 	newMachine := config.MachineConfig{
-		ID:   "test-machine-1",
-		Name: "Test Machine",
-		Host: "192.168.1.100",
-		Port: 22,
-		User: "testuser",
+		ID:       "test-machine-1",
+		Name:     "Test Machine",
+		Host:     "192.168.1.100",
+		Port:     22,
+		User:     "testuser",
+		Password: "secret",
 	}
 
 	body, err := json.Marshal(newMachine)
@@ -49,10 +53,10 @@ func TestAddMachine_Success(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	handler := AddMachine(cm)
+	handler := AddMachine(cm, nil)
 	handler(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code, "Expected status OK")
+	assert.Equal(t, http.StatusCreated, w.Code, "Expected status Created")
 
 	// Vérifier la réponse JSON
 	var response map[string]interface{}
@@ -108,7 +112,7 @@ func TestAddMachine_ValidationErrors(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
-			handler := AddMachine(cm)
+			handler := AddMachine(cm, nil)
 			handler(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code, "Expected status %d", tt.expectedStatus)
@@ -130,7 +134,7 @@ func TestAddMachine_InvalidJSON(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	handler := AddMachine(cm)
+	handler := AddMachine(cm, nil)
 	handler(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected BadRequest for invalid JSON")
@@ -173,7 +177,7 @@ func TestAddMachine_DuplicateID(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	handler := AddMachine(cm)
+	handler := AddMachine(cm, nil)
 	handler(w, req)
 
 	// Devrait retourner une erreur (le comportement exact dépend de l'implémentation)
@@ -208,10 +212,11 @@ func TestUpdateMachine_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	req := httptest.NewRequest("PUT", "/api/machines/update-test", bytes.NewBuffer(body))
+	req.SetPathValue("id", "update-test")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	handler := UpdateMachine(cm)
+	handler := UpdateMachine(cm, nil)
 	handler(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Expected status OK")
@@ -233,9 +238,10 @@ func TestDeleteMachine_Success(t *testing.T) {
 	cfg.Machines = append(cfg.Machines, existingMachine)
 
 	req := httptest.NewRequest("DELETE", "/api/machines/delete-test", nil)
+	req.SetPathValue("id", "delete-test")
 	w := httptest.NewRecorder()
 
-	handler := DeleteMachine(cm)
+	handler := RemoveMachine(cm, nil)
 	handler(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Expected status OK")
@@ -249,15 +255,34 @@ func TestDeleteMachine_NotFound(t *testing.T) {
 	cm := newTestConfigManager()
 
 	req := httptest.NewRequest("DELETE", "/api/machines/nonexistent", nil)
+	req.SetPathValue("id", "nonexistent")
 	w := httptest.NewRecorder()
 
-	handler := DeleteMachine(cm)
+	handler := RemoveMachine(cm, nil)
 	handler(w, req)
 
 	// Devrait retourner une erreur NotFound ou BadRequest
 	assert.NotEqual(t, http.StatusOK, w.Code, "Should not succeed for nonexistent machine")
 }
 
+func TestMachineChanges(t *testing.T) {
+	old := config.MachineConfig{ID: "srv-1", Name: "Web", Host: "10.0.0.1", Port: 22, User: "root", Password: "secret"}
+
+	updated := old
+	updated.Port = 0 // Port par défaut
+	assert.Equal(t, "", machineChanges(old, updated), "mot de passe inchangé")
+
+	updated.Name = "Web 1"
+	updated.Password = "nouveau"
+	changes := machineChanges(old, updated)
+	assert.Equal(t, "name,password", changes)
+	assert.NotContains(t, changes, "nouveau")
+
+	updated = old
+	updated.Password = ""
+	assert.Equal(t, "password", machineChanges(old, updated), "mot de passe retiré")
+}
+
 func TestConfigManager_ThreadSafety(t *testing.T) {
 	cm := newTestConfigManager()
 
@@ -307,20 +332,6 @@ func TestJSONError(t *testing.T) {
 	assert.Equal(t, "Test error message", response["error"])
 }
 
-func TestJSONSuccess(t *testing.T) {
-	w := httptest.NewRecorder()
-
-	jsonSuccess(w, "Test success message")
-
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
-
-	var response map[string]interface{}
-	err := json.NewDecoder(w.Body).Decode(&response)
-	require.NoError(t, err)
-	assert.Equal(t, "Test success message", response["message"])
-}
-
 // Benchmark tests
 
 func BenchmarkAddMachine(b *testing.B) {
@@ -342,7 +353,7 @@ func BenchmarkAddMachine(b *testing.B) {
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 
-		handler := AddMachine(cm)
+		handler := AddMachine(cm, nil)
 		handler(w, req)
 
 		// Reset pour le prochain benchmark
@@ -26,12 +26,14 @@ func RenderPage(pageName string, config *config.Config, role string, username st
 			Role      string
 			Username  string
 			CSRFToken string
+			Roles     []string // Rôles attribuables (jetons de service, page paramètres)
 		}{
 			Title:     pageName,
 			Status:    "OK",
 			Role:      role,
 			Username:  username,
 			CSRFToken: middleware.GetCSRFToken(r),
+			Roles:     config.Roles(),
 		}
 
 		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
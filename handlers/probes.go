@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/auth"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/middleware"
+	"go-monitoring/models"
+	"go-monitoring/ssh"
+	"go-monitoring/storage"
+)
+
+// ProbeScheduler exécute périodiquement les sondes synthétiques définies dans la configuration
+type ProbeScheduler struct {
+	cm      *ConfigManager
+	db      *storage.DB
+	alerts  *alerts.Manager
+	lastRun map[string]time.Time
+	running map[string]bool
+	mu      sync.Mutex
+}
+
+// NewProbeScheduler crée un ordonnanceur de sondes
+func NewProbeScheduler(cm *ConfigManager, db *storage.DB, am *alerts.Manager) *ProbeScheduler {
+	return &ProbeScheduler{
+		cm:      cm,
+		db:      db,
+		alerts:  am,
+		lastRun: make(map[string]time.Time),
+		running: make(map[string]bool),
+	}
+}
+
+// RunDue lance en arrière-plan les sondes dont l'intervalle est écoulé
+func (s *ProbeScheduler) RunDue() {
+	cfg, pool, _ := s.cm.GetConfigPoolAndCache()
+	now := time.Now()
+	configured := make(map[string]bool)
+
+	for _, probe := range cfg.Probes {
+		configured["probe:"+probe.Name] = true
+
+		s.mu.Lock()
+		due := !s.running[probe.Name] && now.Sub(s.lastRun[probe.Name]) >= time.Duration(probe.Interval)*time.Second
+		if due {
+			s.running[probe.Name] = true
+			s.lastRun[probe.Name] = now
+		}
+		s.mu.Unlock()
+
+		if !due {
+			continue
+		}
+
+		go func(probe config.ProbeConfig) {
+			defer func() {
+				s.mu.Lock()
+				delete(s.running, probe.Name)
+				s.mu.Unlock()
+			}()
+
+			result := runProbeVia(cfg, pool, probe)
+			if err := s.db.SaveProbeResult(result); err != nil {
+				log.Printf("Erreur sauvegarde sonde %s: %v", probe.Name, err)
+			}
+			s.updateAlert(result)
+		}(probe)
+	}
+
+	// Résoudre les alertes des sondes retirées de la configuration
+	if s.alerts != nil {
+		for _, a := range s.alerts.Active() {
+			if a.Source == "probe" && !configured[a.Key] {
+				s.alerts.Resolve(a.Key)
+			}
+		}
+	}
+}
+
+// runProbeVia exécute la sonde depuis le serveur ou depuis la machine indiquée par "via"
+func runProbeVia(cfg *config.Config, pool *ssh.Pool, probe config.ProbeConfig) models.ProbeResult {
+	if probe.Via == "" {
+		return collectors.RunProbe(nil, probe)
+	}
+
+	unknown := func(msg string) models.ProbeResult {
+		return models.ProbeResult{
+			Name: probe.Name, Type: probe.Type, Target: probe.Target, Via: probe.Via,
+			State: collectors.CheckUnknown, StateName: collectors.CheckStateName(collectors.CheckUnknown),
+			Message: msg, Timestamp: time.Now(),
+		}
+	}
+
+	mc := cfg.GetMachine(probe.Via)
+	if mc == nil {
+		return unknown("Machine '" + probe.Via + "' introuvable")
+	}
+	if mc.OS == "windows" {
+		return unknown("Sondes via SSH non supportées sur Windows")
+	}
+
+	client, err := pool.GetClient(probe.Via)
+	if err != nil {
+		return unknown("Client SSH indisponible: " + err.Error())
+	}
+	return collectors.RunProbe(client, probe)
+}
+
+// updateAlert synchronise l'état d'une sonde avec le gestionnaire d'alertes
+func (s *ProbeScheduler) updateAlert(r models.ProbeResult) {
+	if s.alerts == nil {
+		return
+	}
+
+	key := "probe:" + r.Name
+	if r.State == collectors.CheckOK {
+		s.alerts.Resolve(key)
+		return
+	}
+
+	severity := alerts.SeverityUnknown
+	switch r.State {
+	case collectors.CheckWarning:
+		severity = alerts.SeverityWarning
+	case collectors.CheckCritical:
+		severity = alerts.SeverityCritical
+	}
+
+	s.alerts.Raise(alerts.Alert{
+		Key:       key,
+		MachineID: r.Via,
+		Source:    "probe",
+		Name:      r.Name,
+		Severity:  severity,
+		Message:   r.Target + " : " + r.Message,
+	})
+}
+
+// probeResults retourne le dernier résultat de chaque sonde configurée visible par l'utilisateur,
+// dans l'ordre de la configuration
+func probeResults(cfg *config.Config, db *storage.DB, visible func(string) bool) []models.ProbeResult {
+	results := make([]models.ProbeResult, 0, len(cfg.Probes))
+	if len(cfg.Probes) == 0 {
+		return results
+	}
+
+	latest, err := db.GetLatestProbeResults()
+	if err != nil {
+		log.Printf("Erreur lecture sondes: %v", err)
+	}
+
+	byName := make(map[string]models.ProbeResult, len(latest))
+	for _, r := range latest {
+		byName[r.Name] = r
+	}
+
+	for _, p := range cfg.Probes {
+		if !probeVisible(p, visible) {
+			continue
+		}
+		r, ok := byName[p.Name]
+		if !ok {
+			r = models.ProbeResult{Name: p.Name, State: collectors.CheckUnknown, Message: "En attente de la première exécution"}
+		}
+		// La configuration fait foi (la cible a pu changer depuis la dernière exécution)
+		r.Type, r.Target, r.Via = p.Type, p.Target, p.Via
+		r.StateName = collectors.CheckStateName(r.State)
+		results = append(results, r)
+	}
+	return results
+}
+
+// probeVisible indique si une sonde est visible : les sondes exécutées depuis le serveur le sont par tous,
+// celles exécutées via une machine suivent la visibilité de la machine
+func probeVisible(p config.ProbeConfig, visible func(string) bool) bool {
+	return p.Via == "" || visible(p.Via)
+}
+
+// ProbesPage affiche l'état des sondes synthétiques
+func ProbesPage(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := template.New("base.html").Funcs(templateFuncs).ParseFiles(
+			"templates/layout/base.html",
+			"templates/probes.html",
+		)
+		if err != nil {
+			http.Error(w, "Erreur chargement template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data := struct {
+			Title     string
+			Status    string
+			Role      string
+			Username  string
+			CSRFToken string
+			Probes    []models.ProbeResult
+		}{
+			Title:     "Sondes",
+			Status:    "OK",
+			Role:      am.GetUserRole(r),
+			Username:  am.GetUsername(r),
+			CSRFToken: middleware.GetCSRFToken(r),
+			Probes:    probeResults(cm.GetConfig(), db, am.Visible(r)),
+		}
+
+		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+			http.Error(w, "Erreur rendu template: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// ListProbes retourne l'état courant des sondes
+func ListProbes(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(probeResults(cm.GetConfig(), db, am.Visible(r)))
+	}
+}
+
+// GetProbeHistory retourne l'historique de latence et d'état d'une sonde
+func GetProbeHistory(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if p := cm.GetConfig().GetProbe(name); p == nil || !probeVisible(*p, am.Visible(r)) {
+			jsonError(w, "Sonde non trouvée", http.StatusNotFound)
+			return
+		}
+
+		// Durée par défaut : 24h
+		duration := 24 * time.Hour
+		if d, err := time.ParseDuration(r.URL.Query().Get("duration")); err == nil {
+			duration = d
+		}
+
+		history, err := db.GetProbeHistory(name, duration)
+		if err != nil {
+			jsonError(w, "Erreur récupération historique: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if history == nil {
+			history = []models.ProbeResult{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
@@ -2,9 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"go-monitoring/auth"
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/recording"
+	"go-monitoring/storage"
 )
 
 // UpdateSelfPassword permet à un utilisateur de changer son propre mot de passe
@@ -37,7 +44,196 @@ func UpdateSelfPassword(cm *ConfigManager, am *auth.AuthManager) http.HandlerFun
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		am.Audit(r, "PASSWORD_CHANGE", username, "changement depuis le profil")
+		// Les autres sessions de l'utilisateur sont déconnectées
+		revokeSessions(am, r, username)
 
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// Durée de validité maximale d'un jeton d'API (jours) ; 0 = sans expiration
+const maxTokenDays = 365
+
+// apiTokenRequest est le corps de POST /api/profile/tokens
+type apiTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+	Service       bool     `json:"service"` // Jeton de service (admin)
+	Role          string   `json:"role"`    // Rôle du jeton de service
+}
+
+// ListAPITokens retourne les jetons personnels de l'utilisateur (admin : tous les jetons)
+func ListAPITokens(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := am.GetUsername(r)
+		if am.Can(r, config.CapabilityAdmin, "") {
+			username = ""
+		}
+		tokens, err := db.ListAPITokens(username)
+		if err != nil {
+			jsonError(w, "Erreur lecture des jetons: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+	}
+}
+
+// CreateAPIToken crée un jeton personnel, ou un jeton de service (admin).
+// Le jeton n'est retourné qu'une fois : seule son empreinte est conservée.
+func CreateAPIToken(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req apiTokenRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&req); err != nil {
+			jsonError(w, "Requête invalide", http.StatusBadRequest)
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > 64 {
+			jsonError(w, "Nom requis (64 caractères maximum)", http.StatusBadRequest)
+			return
+		}
+		if len(req.Scopes) == 0 {
+			jsonError(w, "Au moins une portée est requise", http.StatusBadRequest)
+			return
+		}
+		for _, s := range req.Scopes {
+			if !auth.ValidScope(s) {
+				jsonError(w, "Portée inconnue: "+s, http.StatusBadRequest)
+				return
+			}
+		}
+		if req.ExpiresInDays < 0 || req.ExpiresInDays > maxTokenDays {
+			jsonError(w, fmt.Sprintf("Durée de validité entre 0 et %d jours", maxTokenDays), http.StatusBadRequest)
+			return
+		}
+
+		username := am.GetUsername(r)
+		t := models.APIToken{
+			Name:      req.Name,
+			Kind:      models.APITokenPersonal,
+			Username:  username,
+			Scopes:    req.Scopes,
+			CreatedAt: time.Now(),
+		}
+		if req.Service {
+			if !am.Can(r, config.CapabilityAdmin, "") {
+				jsonError(w, "Accès refusé", http.StatusForbidden)
+				return
+			}
+			if !cm.GetConfig().HasRole(req.Role) {
+				jsonError(w, "Rôle inconnu", http.StatusBadRequest)
+				return
+			}
+			t.Kind, t.Role = models.APITokenService, req.Role
+		}
+		if req.ExpiresInDays > 0 {
+			expiresAt := t.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+			t.ExpiresAt = &expiresAt
+		}
+
+		id, err := recording.NewID()
+		if err != nil {
+			jsonError(w, "Erreur création du jeton", http.StatusInternalServerError)
+			return
+		}
+		token, hash := auth.NewAPIToken()
+		t.ID, t.Hash, t.Prefix = id, hash, token[:len(auth.APITokenPrefix)+6]
+		if err := db.CreateAPIToken(t); err != nil {
+			jsonError(w, "Erreur création du jeton: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "TOKEN_CREATE", t.Name,
+			fmt.Sprintf("id=%s kind=%s role=%s scopes=%s", t.ID, t.Kind, t.Role, strings.Join(t.Scopes, ",")))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			Token string          `json:"token"`
+			Info  models.APIToken `json:"api_token"`
+		}{token, t})
+	}
+}
+
+// RevokeAPIToken révoque un jeton (le sien ; admin : n'importe lequel)
+func RevokeAPIToken(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := db.GetAPIToken(r.PathValue("token"))
+		if err != nil || t == nil {
+			jsonError(w, "Jeton introuvable", http.StatusNotFound)
+			return
+		}
+		username := am.GetUsername(r)
+		owner := t.Kind == models.APITokenPersonal && t.Username == username
+		if !owner && !am.Can(r, config.CapabilityAdmin, "") {
+			jsonError(w, "Jeton introuvable", http.StatusNotFound)
+			return
+		}
+
+		if err := db.DeleteAPIToken(t.ID); err != nil {
+			jsonError(w, "Erreur révocation du jeton: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "TOKEN_REVOKE", t.Name, fmt.Sprintf("id=%s kind=%s owner=%s", t.ID, t.Kind, t.Username))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListSessions retourne les sessions actives de l'utilisateur
+func ListSessions(am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessions, err := am.UserSessions(r)
+		if err != nil {
+			jsonError(w, "Erreur lecture des sessions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sessions)
+	}
+}
+
+// RevokeSession révoque une des sessions de l'utilisateur
+func RevokeSession(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := am.GetUsername(r)
+		s, err := db.GetSession(r.PathValue("session"))
+		if err != nil || s == nil || s.Username != username {
+			jsonError(w, "Session introuvable", http.StatusNotFound)
+			return
+		}
+
+		if err := db.DeleteSession(s.ID); err != nil {
+			jsonError(w, "Erreur révocation de la session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "SESSION_REVOKE", s.ID, fmt.Sprintf("ip=%s", s.IP))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RevokeOtherSessions révoque toutes les sessions de l'utilisateur sauf la session courante
+func RevokeOtherSessions(am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := am.GetUsername(r)
+		current := am.CurrentSession(r)
+		if current == nil {
+			jsonError(w, "Session introuvable", http.StatusNotFound)
+			return
+		}
+
+		n, err := am.RevokeUserSessions(username, current.ID)
+		if err != nil {
+			jsonError(w, "Erreur révocation des sessions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "SESSION_REVOKE", "autres", fmt.Sprintf("sessions=%d", n))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"revoked": n})
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go-monitoring/auth"
+	"go-monitoring/middleware"
+	"go-monitoring/models"
+	"go-monitoring/recording"
+	"go-monitoring/storage"
+)
+
+// recordingIDRegex valide un identifiant d'enregistrement (voir recording.NewID)
+var recordingIDRegex = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// startRecording crée le fichier et l'entrée d'index d'une session de terminal, puis l'audite.
+// L'adresse et le navigateur du client sont conservés pour l'audit de fin de session, qui n'a plus de requête.
+func startRecording(cm *ConfigManager, db *storage.DB, username, machineID, remoteAddr, userAgent string) (*recording.Recorder, models.TerminalRecording, error) {
+	var info models.TerminalRecording
+
+	id, err := recording.NewID()
+	if err != nil {
+		return nil, info, err
+	}
+	dir := cm.GetConfig().Settings.RecordingsDir
+	if dir == "" {
+		dir = "recordings"
+	}
+
+	now := time.Now()
+	info = models.TerminalRecording{
+		ID:         id,
+		Username:   username,
+		MachineID:  machineID,
+		RemoteAddr: remoteAddr,
+		UserAgent:  userAgent,
+		StartedAt:  now,
+		Path:       filepath.Join(dir, now.Format("2006-01"), id+".cast"),
+	}
+
+	// Taille initiale identique à celle demandée pour le PTY
+	rec, err := recording.Create(info.Path, recording.Header{
+		Width:     80,
+		Height:    24,
+		Timestamp: now.Unix(),
+		Title:     username + "@" + machineID,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	if err := db.CreateRecording(info); err != nil {
+		rec.Close()
+		os.Remove(info.Path)
+		return nil, info, fmt.Errorf("indexation de l'enregistrement: %w", err)
+	}
+
+	auditRecording(db, info, "TERMINAL_START", "recording="+id, storage.AuditSuccess)
+	return rec, info, nil
+}
+
+// finishRecording ferme l'enregistrement, complète l'index et audite la fin de session
+func finishRecording(db *storage.DB, rec *recording.Recorder, info models.TerminalRecording) {
+	closeErr := rec.Close()
+	duration := rec.Duration()
+	size := rec.Size()
+
+	if err := db.FinishRecording(info.ID, time.Now(), duration, size); err != nil {
+		log.Printf("Terminal: Erreur indexation fin d'enregistrement %s: %v", info.ID, err)
+	}
+
+	details := fmt.Sprintf("recording=%s duration=%s size=%d", info.ID, duration.Round(time.Second), size)
+	outcome := storage.AuditSuccess
+	if closeErr != nil {
+		details += " erreur=" + closeErr.Error()
+		outcome = storage.AuditFailure
+	}
+	auditRecording(db, info, "TERMINAL_END", details, outcome)
+}
+
+// auditRecording audite un événement de session avec l'adresse et le navigateur capturés à l'ouverture
+func auditRecording(db *storage.DB, info models.TerminalRecording, action, details, outcome string) {
+	db.RecordAudit(storage.AuditLog{
+		User:      info.Username,
+		Action:    action,
+		Target:    info.MachineID,
+		Details:   details,
+		IPAddress: info.RemoteAddr,
+		UserAgent: info.UserAgent,
+		Outcome:   outcome,
+	})
+}
+
+// RecordingsPage affiche la liste des sessions de terminal enregistrées et le lecteur (Admin seulement)
+func RecordingsPage(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := template.New("base.html").Funcs(templateFuncs).ParseFiles(
+			"templates/layout/base.html",
+			"templates/recordings.html",
+		)
+		if err != nil {
+			http.Error(w, "Erreur chargement template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		cfg := cm.GetConfig()
+		machines := make([]string, 0, len(cfg.Machines))
+		for _, mc := range cfg.Machines {
+			machines = append(machines, mc.ID)
+		}
+
+		data := struct {
+			Title     string
+			Status    string
+			Role      string
+			Username  string
+			CSRFToken string
+			Machines  []string
+		}{
+			Title:     "Enregistrements",
+			Status:    "OK",
+			Role:      am.GetUserRole(r),
+			Username:  am.GetUsername(r),
+			CSRFToken: middleware.GetCSRFToken(r),
+			Machines:  machines,
+		}
+
+		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+			http.Error(w, "Erreur rendu template: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// ListRecordings retourne l'index des enregistrements, filtrable par machine et utilisateur (Admin seulement)
+func ListRecordings(db *storage.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 200
+		if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+
+		recs, err := db.ListRecordings(r.URL.Query().Get("machine"), r.URL.Query().Get("user"), limit)
+		if err != nil {
+			jsonError(w, "Erreur récupération enregistrements: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if recs == nil {
+			recs = []models.TerminalRecording{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recs)
+	}
+}
+
+// GetRecordingCast sert le fichier asciicast d'un enregistrement (Admin seulement, audité)
+func GetRecordingCast(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if !recordingIDRegex.MatchString(id) {
+			jsonError(w, "Identifiant d'enregistrement invalide", http.StatusBadRequest)
+			return
+		}
+
+		// Le chemin provient de l'index, jamais de la requête
+		rec, err := db.GetRecording(id)
+		if err != nil {
+			jsonError(w, "Erreur récupération enregistrement: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rec == nil {
+			jsonError(w, "Enregistrement introuvable", http.StatusNotFound)
+			return
+		}
+
+		f, err := os.Open(rec.Path)
+		if err != nil {
+			jsonError(w, "Fichier d'enregistrement indisponible", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		am.Audit(r, "RECORDING_VIEW", rec.MachineID, "recording="+id)
+
+		w.Header().Set("Content-Type", "application/x-asciicast")
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeContent(w, r, id+".cast", rec.StartedAt, f)
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/auth"
+	"go-monitoring/collectors"
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/recording"
+	"go-monitoring/storage"
+)
+
+// runbookSystemUser est l'auteur enregistré dans l'audit pour les exécutions automatiques
+const runbookSystemUser = "system"
+
+// errRunbookBusy : le runbook est déjà en cours d'exécution sur la machine
+var errRunbookBusy = errors.New("runbook déjà en cours sur cette machine")
+
+// RunbookRunner exécute les runbooks de remédiation : automatiquement à la levée d'une alerte
+// associée à un trigger auto (dans les limites configurées), ou à la demande depuis la page des alertes
+type RunbookRunner struct {
+	cm      *ConfigManager
+	db      *storage.DB
+	limiter *collectors.RunbookLimiter
+
+	mu      sync.Mutex
+	running map[string]bool // runbook@machine
+}
+
+// NewRunbookRunner crée l'exécuteur de runbooks et l'abonne aux alertes
+func NewRunbookRunner(cm *ConfigManager, db *storage.DB, am *alerts.Manager) *RunbookRunner {
+	r := &RunbookRunner{
+		cm:      cm,
+		db:      db,
+		limiter: collectors.NewRunbookLimiter(),
+		running: make(map[string]bool),
+	}
+	if am != nil {
+		am.Subscribe(func(e alerts.Event) {
+			// Nouvelle alerte ou aggravation uniquement ; les abonnés ne doivent pas bloquer
+			if e.Type == alerts.EventRaised && e.Alert.MachineID != "" {
+				go r.handleAlert(e.Alert)
+			}
+		})
+	}
+	return r
+}
+
+// runbooksForAlert retourne les runbooks dont un trigger correspond à l'alerte.
+// auto indique, pour chacun, si l'un des triggers correspondants est automatique.
+func runbooksForAlert(cfg *config.Config, a alerts.Alert) (matched []config.Runbook, auto []bool) {
+	mc := cfg.GetMachine(a.MachineID)
+	if mc == nil {
+		return nil, nil
+	}
+	for _, rb := range cfg.Runbooks {
+		found, isAuto := false, false
+		for _, t := range rb.Triggers {
+			if t.Matches(a.Source, a.Name, string(a.Severity), mc.Group) {
+				found = true
+				isAuto = isAuto || t.Auto
+			}
+		}
+		if found {
+			matched = append(matched, rb)
+			auto = append(auto, isAuto)
+		}
+	}
+	return matched, auto
+}
+
+// handleAlert lance les runbooks automatiques associés à une alerte
+func (r *RunbookRunner) handleAlert(a alerts.Alert) {
+	cfg := r.cm.GetConfig()
+	runbooks, auto := runbooksForAlert(cfg, a)
+	for i, rb := range runbooks {
+		if !auto[i] {
+			continue
+		}
+		if err := r.limiter.Allow(rb, a.MachineID, time.Now()); err != nil {
+			log.Printf("Runbook %s non exécuté sur %s: %v", rb.Name, a.MachineID, err)
+			r.db.RecordAudit(storage.AuditLog{
+				User:    runbookSystemUser,
+				Action:  "RUNBOOK_SKIPPED",
+				Target:  a.MachineID,
+				Details: fmt.Sprintf("runbook=%s alert=%s: %v", rb.Name, a.Key, err),
+				Outcome: storage.AuditFailure,
+			})
+			continue
+		}
+		if _, err := r.Start(rb, a.MachineID, a.Key, models.RunbookTriggerAuto, "", ""); err != nil {
+			log.Printf("Erreur lancement runbook %s sur %s: %v", rb.Name, a.MachineID, err)
+		}
+	}
+}
+
+// Start enregistre puis lance l'exécution d'un runbook sur une machine (en arrière-plan)
+func (r *RunbookRunner) Start(rb config.Runbook, machineID, alertKey, trigger, username, ip string) (models.RunbookRun, error) {
+	cfg, pool, _ := r.cm.GetConfigPoolAndCache()
+	mc := cfg.GetMachine(machineID)
+	if mc == nil {
+		return models.RunbookRun{}, fmt.Errorf("machine inconnue: %s", machineID)
+	}
+
+	busyKey := rb.Name + "@" + machineID
+	r.mu.Lock()
+	if r.running[busyKey] {
+		r.mu.Unlock()
+		return models.RunbookRun{}, errRunbookBusy
+	}
+	r.running[busyKey] = true
+	r.mu.Unlock()
+
+	release := func() {
+		r.mu.Lock()
+		delete(r.running, busyKey)
+		r.mu.Unlock()
+	}
+
+	id, err := recording.NewID()
+	if err != nil {
+		release()
+		return models.RunbookRun{}, err
+	}
+	run := models.RunbookRun{
+		ID:        id,
+		Runbook:   rb.Name,
+		MachineID: machineID,
+		AlertKey:  alertKey,
+		Trigger:   trigger,
+		Username:  username,
+		Status:    models.RunbookRunning,
+		StartedAt: time.Now(),
+	}
+	if err := r.db.CreateRunbookRun(run); err != nil {
+		release()
+		return models.RunbookRun{}, err
+	}
+
+	actor := username
+	if actor == "" {
+		actor = runbookSystemUser
+	}
+	r.db.LogAction(actor, "RUNBOOK_RUN", machineID,
+		fmt.Sprintf("runbook=%s trigger=%s alert=%s run=%s", rb.Name, trigger, alertKey, run.ID), ip)
+
+	go func() {
+		defer release()
+
+		done := run
+		ok := false
+		if client, err := pool.GetClient(machineID); err != nil {
+			done.Steps = []models.RunbookStepResult{{Name: "connexion", ExitCode: -1, Error: "connexion SSH impossible: " + err.Error()}}
+		} else {
+			done.Steps, ok = collectors.RunRunbook(client, rb, mc.OS)
+		}
+
+		done.Status = models.RunbookFailed
+		if ok {
+			done.Status = models.RunbookSuccess
+		}
+		endedAt := time.Now()
+		done.EndedAt = &endedAt
+		if err := r.db.FinishRunbookRun(done); err != nil {
+			log.Printf("Erreur fin d'exécution du runbook %s: %v", done.ID, err)
+		}
+		outcome := storage.AuditSuccess
+		if !ok {
+			outcome = storage.AuditFailure
+		}
+		r.db.RecordAudit(storage.AuditLog{
+			User:      actor,
+			Action:    "RUNBOOK_END",
+			Target:    machineID,
+			Details:   fmt.Sprintf("runbook=%s run=%s status=%s steps=%d/%d", rb.Name, done.ID, done.Status, len(done.Steps), len(rb.Steps)),
+			IPAddress: ip,
+			Outcome:   outcome,
+		})
+	}()
+
+	return run, nil
+}
+
+// runbookRequest est le corps de POST /api/runbooks/{name}/run
+type runbookRequest struct {
+	Machine string `json:"machine"`
+	Alert   string `json:"alert"` // Alerte à l'origine de l'exécution (facultatif)
+}
+
+// RunRunbook lance un runbook à la demande sur une machine (capacité services sur la machine).
+// L'exécution se suit via GET /api/runbooks/runs/{run}.
+func RunRunbook(cm *ConfigManager, am *auth.AuthManager, runner *RunbookRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req runbookRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&req); err != nil {
+			jsonError(w, "Requête invalide", http.StatusBadRequest)
+			return
+		}
+
+		cfg := cm.GetConfig()
+		rb := cfg.GetRunbook(r.PathValue("name"))
+		if rb == nil {
+			jsonError(w, "Runbook inconnu", http.StatusNotFound)
+			return
+		}
+		if cfg.GetMachine(req.Machine) == nil {
+			jsonError(w, "Machine non trouvée", http.StatusNotFound)
+			return
+		}
+		if !am.Can(r, config.CapabilityServices, req.Machine) {
+			jsonError(w, "Accès refusé", http.StatusForbidden)
+			return
+		}
+
+		run, err := runner.Start(*rb, req.Machine, req.Alert, models.RunbookTriggerManual, am.GetUsername(r), r.RemoteAddr)
+		switch {
+		case errors.Is(err, errRunbookBusy):
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			jsonError(w, "Erreur lancement du runbook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(run)
+	}
+}
+
+// GetRunbookRun retourne une exécution de runbook et le résultat de ses étapes
+func GetRunbookRun(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		run, err := db.GetRunbookRun(r.PathValue("run"))
+		if err != nil || run == nil {
+			jsonError(w, "Exécution introuvable", http.StatusNotFound)
+			return
+		}
+		if !am.Can(r, config.CapabilityServices, run.MachineID) {
+			jsonError(w, "Accès refusé", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(run)
+	}
+}
+
+// ListRunbookRuns retourne les dernières exécutions de runbooks (?machine=&runbook=&limit=),
+// limitées aux machines sur lesquelles l'utilisateur a la capacité services
+func ListRunbookRuns(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+			limit = l
+		}
+
+		runs, err := db.ListRunbookRuns(r.URL.Query().Get("machine"), r.URL.Query().Get("runbook"), limit)
+		if err != nil {
+			jsonError(w, "Erreur récupération des exécutions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(visibleRunbookRuns(r, am, runs))
+	}
+}
+
+// visibleRunbookRuns filtre les exécutions sur les machines autorisées (capacité services)
+func visibleRunbookRuns(r *http.Request, am *auth.AuthManager, runs []models.RunbookRun) []models.RunbookRun {
+	visible := []models.RunbookRun{}
+	for _, run := range runs {
+		if am.Can(r, config.CapabilityServices, run.MachineID) {
+			visible = append(visible, run)
+		}
+	}
+	return visible
+}
@@ -7,11 +7,10 @@ import (
 
 	"go-monitoring/auth"
 	"go-monitoring/collectors"
-	"go-monitoring/storage"
 )
 
 // HandleServiceAction gère les actions sur les services (start, stop, restart)
-func HandleServiceAction(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+func HandleServiceAction(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Vérifier méthode POST
 		if r.Method != http.MethodPost {
@@ -19,14 +18,7 @@ func HandleServiceAction(cm *ConfigManager, db *storage.DB, am *auth.AuthManager
 			return
 		}
 
-		// Vérifier Admin
-		role := am.GetUserRole(r)
-		user := am.GetUsername(r)
-		if role != "admin" {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
+		// La capacité "services" est vérifiée par le middleware de la route
 		machineID := r.PathValue("id")
 		serviceName := r.PathValue("service")
 		action := r.PathValue("action")
@@ -82,13 +74,7 @@ func HandleServiceAction(cm *ConfigManager, db *storage.DB, am *auth.AuthManager
 		}
 
 		// Enregistrer dans l'audit
-		db.LogAction(
-			user,
-			strings.ToUpper(action)+"_SERVICE",
-			machineID+":"+serviceName,
-			status+" "+details,
-			r.RemoteAddr,
-		)
+		am.AuditResult(r, strings.ToUpper(action)+"_SERVICE", machineID+":"+serviceName, status+" "+details, err)
 
 		if err != nil {
 			http.Error(w, "Erreur exécution: "+err.Error(), http.StatusInternalServerError)
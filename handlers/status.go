@@ -5,18 +5,29 @@ import (
 	"net/http"
 	"time"
 
+	"go-monitoring/auth"
 	"go-monitoring/cache"
 	"go-monitoring/config"
 	"go-monitoring/ssh"
 )
 
-// GetStatus retourne l'état actuel de toutes les machines au format JSON
-func GetStatus(cfg *config.Config, pool *ssh.Pool, cache *cache.MetricsCache) http.HandlerFunc {
+// GetStatus retourne l'état actuel des machines visibles au format JSON
+func GetStatus(cfg *config.Config, pool *ssh.Pool, cache *cache.MetricsCache, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Collecte rapide (timeout court car c'est pour l'UI temps réel)
 		// On utilise le cache si disponible
 		machines := CollectAllMachines(cfg, pool, cache, 2*time.Second, false)
 
+		// Seules les machines visibles par l'utilisateur sont retournées
+		visible := am.Visible(r)
+		filtered := machines[:0]
+		for _, m := range machines {
+			if visible(m.ID) {
+				filtered = append(filtered, m)
+			}
+		}
+		machines = filtered
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(machines)
 	}
@@ -1,27 +1,34 @@
 package handlers
 
 import (
-	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"sync"
-	"time"
+
+	"go-monitoring/auth"
+	"go-monitoring/config"
+	"go-monitoring/storage"
 
 	"github.com/gorilla/websocket"
 	"golang.org/x/crypto/ssh"
 )
 
-// TerminalMessage définit le protocole de communication
+// TerminalMessage définit le protocole de communication (navigateur -> serveur).
+// Dans l'autre sens, la sortie du shell circule en trames texte et l'état de la
+// session partagée (TerminalSessionInfo) en trames binaires JSON.
 type TerminalMessage struct {
-	Type string `json:"type"` // "input", "resize"
-	Data string `json:"data,omitempty"`
-	Cols int    `json:"cols,omitempty"`
-	Rows int    `json:"rows,omitempty"`
+	Type     string `json:"type"` // "input", "resize", "grant"
+	Data     string `json:"data,omitempty"`
+	Cols     int    `json:"cols,omitempty"`
+	Rows     int    `json:"rows,omitempty"`
+	Username string `json:"username,omitempty"` // Destinataire du contrôle ("grant")
 }
 
-// WebTerminalHandler gère la connexion WebSocket pour le terminal
-func WebTerminalHandler(cm *ConfigManager) http.HandlerFunc {
+// WebTerminalHandler gère la connexion WebSocket pour le terminal.
+// Chaque session est enregistrée (asciicast v2) et auditée : sans enregistrement, pas de shell.
+// Avec ?join=<session>, la connexion rejoint une session en cours en lecture seule.
+func WebTerminalHandler(cm *ConfigManager, db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 		if id == "" {
@@ -29,6 +36,11 @@ func WebTerminalHandler(cm *ConfigManager) http.HandlerFunc {
 			return
 		}
 
+		if sessionID := r.URL.Query().Get("join"); sessionID != "" {
+			joinTerminalSession(am, w, r, id, sessionID)
+			return
+		}
+
 		// Upgrade en WebSocket
 		ws, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -79,17 +91,34 @@ func WebTerminalHandler(cm *ConfigManager) http.HandlerFunc {
 			return
 		}
 
+		// Enregistrement de la session (avant le démarrage du shell)
+		username := am.GetUsername(r)
+		rec, recInfo, err := startRecording(cm, db, username, id, r.RemoteAddr, r.UserAgent())
+		if err != nil {
+			log.Printf("Terminal: Enregistrement impossible pour %s: %v", id, err)
+			ws.WriteMessage(websocket.TextMessage, []byte("Erreur: Enregistrement de la session impossible, connexion refusée\r\n"))
+			return
+		}
+		defer finishRecording(db, rec, recInfo)
+
 		// Start shell
 		if err := session.Shell(); err != nil {
 			ws.WriteMessage(websocket.TextMessage, []byte("Erreur: Shell failed\r\n"))
 			return
 		}
 
-		// Channel pour synchronisation
-		done := make(chan struct{})
+		// Session partagée : d'autres utilisateurs autorisés peuvent l'observer
+		ts := newTerminalSession(recInfo, rec, stdin, func(cols, rows int) error {
+			return session.WindowChange(rows, cols)
+		})
+		terminalSessions.add(ts)
+		defer terminalSessions.remove(recInfo.ID)
+
+		owner, _ := ts.join(ws, username, am.Can(r, config.CapabilityAdmin, ""))
+
 		var wg sync.WaitGroup
 
-		// Goroutine: SSH Stdout -> WS
+		// Goroutine: SSH Stdout -> participants
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -102,16 +131,12 @@ func WebTerminalHandler(cm *ConfigManager) http.HandlerFunc {
 					}
 					break
 				}
-				// Envoyer les données brutes
-				if err := ws.WriteMessage(websocket.TextMessage, buf[:n]); err != nil {
-					log.Printf("Terminal: WS Write error: %v", err)
-					break
-				}
+				ts.output(buf[:n])
 			}
-			close(done)
+			ts.close("Session terminée")
 		}()
 
-		// Goroutine: SSH Stderr -> WS (mixed)
+		// Goroutine: SSH Stderr -> participants (mixed)
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -121,46 +146,18 @@ func WebTerminalHandler(cm *ConfigManager) http.HandlerFunc {
 				if err != nil {
 					break
 				}
-				ws.WriteMessage(websocket.TextMessage, buf[:n])
+				ts.output(buf[:n])
 			}
 		}()
 
 		// Loop: WS -> SSH Stdin (Main Loop)
-		// Send ping
-		go func() {
-			ticker := time.NewTicker(30 * time.Second)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-done:
-					return
-				case <-ticker.C:
-					ws.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(time.Second))
-				}
-			}
-		}()
+		ts.serve(owner, am, r)
 
-		for {
-			_, msg, err := ws.ReadMessage()
-			if err != nil {
-				break
-			}
-
-			// Parse JSON message
-			var termMsg TerminalMessage
-			if err := json.Unmarshal(msg, &termMsg); err != nil {
-				// Fallback: treat as raw input if not JSON? No, strictly JSON from our client
-				continue
-			}
-
-			switch termMsg.Type {
-			case "input":
-				stdin.Write([]byte(termMsg.Data))
-			case "resize":
-				session.WindowChange(termMsg.Rows, termMsg.Cols)
-			}
-		}
+		// Les observateurs peuvent rester après le départ du propriétaire
+		<-ts.done
 
+		// Plus personne : fermer la session pour débloquer les lectures SSH
+		session.Close()
 		wg.Wait()
 	}
 }
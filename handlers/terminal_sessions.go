@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go-monitoring/auth"
+	"go-monitoring/config"
+	"go-monitoring/models"
+	"go-monitoring/recording"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Sortie rejouée aux observateurs qui rejoignent une session en cours
+	terminalScrollback = 64 << 10
+	// Messages en attente par participant ; au-delà, le participant trop lent est déconnecté
+	terminalSendQueue = 256
+)
+
+// TerminalSessionInfo décrit une session de terminal en cours
+type TerminalSessionInfo struct {
+	ID         string    `json:"id"`
+	MachineID  string    `json:"machine_id"`
+	Owner      string    `json:"owner"`
+	Controller string    `json:"controller"` // vide : personne n'a le clavier
+	Viewers    []string  `json:"viewers"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// terminalState est envoyé (trame binaire) à chaque participant quand la session change
+type terminalState struct {
+	Type string `json:"type"` // "session"
+	TerminalSessionInfo
+	You      string `json:"you"`
+	CanGrant bool   `json:"can_grant"`
+}
+
+type wsFrame struct {
+	kind int
+	data []byte
+}
+
+// terminalClient est une connexion WebSocket participant à une session
+type terminalClient struct {
+	ws       *websocket.Conn
+	username string
+	admin    bool
+	send     chan wsFrame
+	warned   bool // Avertissement "lecture seule" déjà envoyé
+}
+
+// terminalSession est un shell partagé entre son propriétaire et des observateurs.
+// Seul le détenteur du contrôle peut saisir ; la sortie est diffusée à tous.
+type terminalSession struct {
+	info   models.TerminalRecording
+	rec    *recording.Recorder
+	stdin  io.Writer
+	resize func(cols, rows int) error
+
+	mu         sync.Mutex
+	inputMu    sync.Mutex // Écritures sur stdin, hors de mu pour ne pas bloquer la diffusion
+	clients    map[*terminalClient]bool
+	controller string
+	scrollback []byte
+	closed     bool
+	done       chan struct{} // Fermé à la fin de la session
+}
+
+// terminalRegistry référence les sessions en cours
+type terminalRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*terminalSession
+}
+
+var terminalSessions = &terminalRegistry{sessions: make(map[string]*terminalSession)}
+
+func (reg *terminalRegistry) add(ts *terminalSession) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.sessions[ts.info.ID] = ts
+}
+
+func (reg *terminalRegistry) remove(id string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.sessions, id)
+}
+
+func (reg *terminalRegistry) get(id string) *terminalSession {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.sessions[id]
+}
+
+// list retourne les sessions en cours d'une machine, des plus anciennes aux plus récentes
+func (reg *terminalRegistry) list(machineID string) []TerminalSessionInfo {
+	reg.mu.Lock()
+	var sessions []*terminalSession
+	for _, ts := range reg.sessions {
+		if ts.info.MachineID == machineID {
+			sessions = append(sessions, ts)
+		}
+	}
+	reg.mu.Unlock()
+
+	infos := make([]TerminalSessionInfo, 0, len(sessions))
+	for _, ts := range sessions {
+		infos = append(infos, ts.snapshot())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartedAt.Before(infos[j].StartedAt) })
+	return infos
+}
+
+func newTerminalSession(info models.TerminalRecording, rec *recording.Recorder, stdin io.Writer, resize func(cols, rows int) error) *terminalSession {
+	return &terminalSession{
+		info:       info,
+		rec:        rec,
+		stdin:      stdin,
+		resize:     resize,
+		clients:    make(map[*terminalClient]bool),
+		controller: info.Username,
+		done:       make(chan struct{}),
+	}
+}
+
+// join ajoute un participant ; il reçoit la sortie récente puis le flux en direct.
+// Retourne false si la session est déjà terminée.
+func (ts *terminalSession) join(ws *websocket.Conn, username string, admin bool) (*terminalClient, bool) {
+	c := &terminalClient{ws: ws, username: username, admin: admin, send: make(chan wsFrame, terminalSendQueue)}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.closed {
+		return nil, false
+	}
+	ts.clients[c] = true
+	go c.writePump()
+
+	if len(ts.scrollback) > 0 {
+		c.send <- wsFrame{websocket.TextMessage, append([]byte(nil), ts.scrollback...)}
+	}
+	if username == ts.info.Username && len(ts.clients) == 1 {
+		ts.queueLocked(c, noticeFrame("Cette session est enregistrée"))
+	} else {
+		ts.rec.Marker("arrivée: " + username)
+		ts.noticeLocked(username + " a rejoint la session")
+	}
+	ts.broadcastStateLocked()
+	return c, true
+}
+
+// leave retire un participant. Le contrôle revient au propriétaire s'il est encore connecté ;
+// la session se termine quand il n'y a plus personne.
+func (ts *terminalSession) leave(c *terminalClient) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if !ts.clients[c] {
+		return
+	}
+	ts.dropLocked(c)
+	if len(ts.clients) == 0 {
+		ts.closeLocked("")
+		return
+	}
+	ts.rec.Marker("départ: " + c.username)
+	ts.noticeLocked(c.username + " a quitté la session")
+	ts.broadcastStateLocked()
+}
+
+// output diffuse une sortie du shell sans jamais attendre un participant
+func (ts *terminalSession) output(data []byte) {
+	ts.rec.Output(data)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.scrollback = append(ts.scrollback, data...)
+	if len(ts.scrollback) > terminalScrollback {
+		cut := len(ts.scrollback) - terminalScrollback
+		for cut < len(ts.scrollback) && !utf8.RuneStart(ts.scrollback[cut]) {
+			cut++
+		}
+		ts.scrollback = append([]byte(nil), ts.scrollback[cut:]...)
+	}
+
+	var slow []string
+	frame := wsFrame{websocket.TextMessage, append([]byte(nil), data...)}
+	for c := range ts.clients {
+		if !ts.queueLocked(c, frame) {
+			slow = append(slow, c.username)
+		}
+	}
+	if len(slow) > 0 && len(ts.clients) == 0 {
+		ts.closeLocked("")
+		return
+	}
+	for _, username := range slow {
+		ts.noticeLocked(username + " a été déconnecté (connexion trop lente)")
+	}
+	if len(slow) > 0 {
+		ts.broadcastStateLocked()
+	}
+}
+
+// input transmet une saisie au shell si le participant a le contrôle
+func (ts *terminalSession) input(c *terminalClient, data string) {
+	if !ts.canInput(c) {
+		return
+	}
+
+	ts.inputMu.Lock()
+	defer ts.inputMu.Unlock()
+	ts.rec.Input(data)
+	ts.stdin.Write([]byte(data))
+}
+
+// canInput indique si le participant a le contrôle ; sinon il est averti une fois qu'il est en lecture seule
+func (ts *terminalSession) canInput(c *terminalClient) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if !ts.closed && ts.clients[c] && c.username == ts.controller {
+		return true
+	}
+	if !c.warned {
+		c.warned = true
+		ts.queueLocked(c, noticeFrame("Lecture seule : demandez le contrôle à "+ts.controllerLabelLocked()))
+	}
+	return false
+}
+
+// resizeFrom applique la taille du terminal du détenteur du contrôle
+func (ts *terminalSession) resizeFrom(c *terminalClient, cols, rows int) {
+	ts.mu.Lock()
+	allowed := !ts.closed && c.username == ts.controller
+	ts.mu.Unlock()
+	if allowed && cols > 0 && rows > 0 {
+		ts.rec.Resize(cols, rows)
+		ts.resize(cols, rows)
+	}
+}
+
+// grant passe le contrôle à un participant connecté. Autorisé au propriétaire,
+// au détenteur actuel du contrôle et aux administrateurs.
+func (ts *terminalSession) grant(c *terminalClient, username string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if c.username != ts.info.Username && c.username != ts.controller && !c.admin {
+		return errors.New("seul le propriétaire ou le détenteur du contrôle peut le céder")
+	}
+	if !ts.connectedLocked(username) {
+		return errors.New("utilisateur absent de la session")
+	}
+
+	ts.controller = username
+	for cl := range ts.clients {
+		cl.warned = false
+	}
+	ts.rec.Marker("contrôle: " + username)
+	ts.noticeLocked(c.username + " a donné le contrôle à " + username)
+	ts.broadcastStateLocked()
+	return nil
+}
+
+// close met fin à la session et déconnecte tous les participants
+func (ts *terminalSession) close(reason string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.closeLocked(reason)
+}
+
+func (ts *terminalSession) closeLocked(reason string) {
+	if ts.closed {
+		return
+	}
+	ts.closed = true
+	if reason != "" {
+		ts.rec.Marker(reason)
+		ts.noticeLocked(reason)
+	}
+	for c := range ts.clients {
+		ts.dropLocked(c)
+	}
+	close(ts.done)
+}
+
+func (ts *terminalSession) snapshot() TerminalSessionInfo {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.snapshotLocked()
+}
+
+func (ts *terminalSession) snapshotLocked() TerminalSessionInfo {
+	seen := make(map[string]bool)
+	viewers := []string{}
+	for c := range ts.clients {
+		if !seen[c.username] {
+			seen[c.username] = true
+			viewers = append(viewers, c.username)
+		}
+	}
+	sort.Strings(viewers)
+	return TerminalSessionInfo{
+		ID:         ts.info.ID,
+		MachineID:  ts.info.MachineID,
+		Owner:      ts.info.Username,
+		Controller: ts.controller,
+		Viewers:    viewers,
+		StartedAt:  ts.info.StartedAt,
+	}
+}
+
+func (ts *terminalSession) connectedLocked(username string) bool {
+	for c := range ts.clients {
+		if c.username == username {
+			return true
+		}
+	}
+	return false
+}
+
+func (ts *terminalSession) controllerLabelLocked() string {
+	if ts.controller == "" {
+		return "un administrateur"
+	}
+	return ts.controller
+}
+
+// queueLocked met une trame en file sans bloquer ; un participant dont la file est pleine est retiré.
+// Un participant déjà retiré (file fermée) est ignoré.
+func (ts *terminalSession) queueLocked(c *terminalClient, f wsFrame) bool {
+	if !ts.clients[c] {
+		return false
+	}
+	select {
+	case c.send <- f:
+		return true
+	default:
+		ts.dropLocked(c)
+		return false
+	}
+}
+
+// dropLocked retire un participant et ferme sa file (ce qui ferme sa WebSocket)
+func (ts *terminalSession) dropLocked(c *terminalClient) {
+	if !ts.clients[c] {
+		return
+	}
+	delete(ts.clients, c)
+	close(c.send)
+
+	if c.username == ts.controller && !ts.connectedLocked(c.username) {
+		ts.controller = ""
+		if ts.connectedLocked(ts.info.Username) {
+			ts.controller = ts.info.Username
+		}
+	}
+}
+
+func (ts *terminalSession) noticeLocked(msg string) {
+	f := noticeFrame(msg)
+	for c := range ts.clients {
+		ts.queueLocked(c, f)
+	}
+}
+
+func (ts *terminalSession) broadcastStateLocked() {
+	info := ts.snapshotLocked()
+	for c := range ts.clients {
+		data, err := json.Marshal(terminalState{
+			Type:                "session",
+			TerminalSessionInfo: info,
+			You:                 c.username,
+			CanGrant:            c.username == ts.info.Username || c.username == ts.controller || c.admin,
+		})
+		if err == nil {
+			ts.queueLocked(c, wsFrame{websocket.BinaryMessage, data})
+		}
+	}
+}
+
+// serve lit les messages d'un participant jusqu'à sa déconnexion
+func (ts *terminalSession) serve(c *terminalClient, am *auth.AuthManager, r *http.Request) {
+	defer ts.leave(c)
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var termMsg TerminalMessage
+		if err := json.Unmarshal(msg, &termMsg); err != nil {
+			continue
+		}
+
+		switch termMsg.Type {
+		case "input":
+			ts.input(c, termMsg.Data)
+		case "resize":
+			ts.resizeFrom(c, termMsg.Cols, termMsg.Rows)
+		case "grant":
+			if err := ts.grant(c, termMsg.Username); err != nil {
+				ts.mu.Lock()
+				ts.queueLocked(c, noticeFrame("Erreur: "+err.Error()))
+				ts.mu.Unlock()
+				continue
+			}
+			am.AuditAs(r, c.username, "TERMINAL_CONTROL", ts.info.MachineID, "recording="+ts.info.ID+" to="+termMsg.Username)
+		}
+	}
+}
+
+// writePump est le seul écrivain de la WebSocket d'un participant
+func (c *terminalClient) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.ws.Close()
+	}()
+
+	for {
+		select {
+		case f, ok := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.ws.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.ws.WriteMessage(f.kind, f.data); err != nil {
+				// Connexion perdue : la lecture échouera et retirera le participant
+				c.ws.Close()
+				for range c.send {
+				}
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.ws.Close()
+				for range c.send {
+				}
+				return
+			}
+		}
+	}
+}
+
+func noticeFrame(msg string) wsFrame {
+	return wsFrame{websocket.TextMessage, []byte("\r\n\x1b[2m[" + msg + "]\x1b[0m\r\n")}
+}
+
+// joinTerminalSession rattache un observateur à une session en cours (lecture seule jusqu'au passage de contrôle)
+func joinTerminalSession(am *auth.AuthManager, w http.ResponseWriter, r *http.Request, machineID, sessionID string) {
+	ts := terminalSessions.get(sessionID)
+	if ts == nil || ts.info.MachineID != machineID {
+		http.Error(w, "Session introuvable", http.StatusNotFound)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Terminal: Erreur upgrade WS: %v", err)
+		return
+	}
+
+	username := am.GetUsername(r)
+	c, ok := ts.join(ws, username, am.Can(r, config.CapabilityAdmin, ""))
+	if !ok {
+		ws.WriteMessage(websocket.TextMessage, []byte("Erreur: Session terminée\r\n"))
+		ws.Close()
+		return
+	}
+
+	am.AuditAs(r, username, "TERMINAL_JOIN", machineID, "recording="+sessionID+" owner="+ts.info.Username)
+	ts.serve(c, am, r)
+}
+
+// ListTerminalSessions retourne les sessions de terminal en cours sur une machine
+func ListTerminalSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(terminalSessions.list(r.PathValue("id")))
+	}
+}
+
+// TerminateTerminalSession met fin de force à une session de terminal (Admin seulement, audité)
+func TerminateTerminalSession(am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		machineID := r.PathValue("id")
+		sessionID := r.PathValue("session")
+
+		ts := terminalSessions.get(sessionID)
+		if ts == nil || ts.info.MachineID != machineID {
+			jsonError(w, "Session introuvable", http.StatusNotFound)
+			return
+		}
+
+		username := am.GetUsername(r)
+		ts.close("Session terminée par " + username)
+		am.Audit(r, "TERMINAL_KILL", machineID, "recording="+sessionID+" owner="+ts.info.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "terminated"})
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"go-monitoring/models"
+	"go-monitoring/recording"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminalSession_DroppedClientInput(t *testing.T) {
+	rec, err := recording.Create(filepath.Join(t.TempDir(), "session.cast"), recording.Header{Width: 80, Height: 24})
+	require.NoError(t, err)
+	defer rec.Close()
+
+	var stdin bytes.Buffer
+	ts := newTerminalSession(models.TerminalRecording{ID: "s1", MachineID: "srv-1", Username: "alice"}, rec, &stdin,
+		func(cols, rows int) error { return nil })
+	owner := &terminalClient{username: "alice", send: make(chan wsFrame, terminalSendQueue)}
+	viewer := &terminalClient{username: "bob", send: make(chan wsFrame, 1)}
+	ts.clients[owner] = true
+	ts.clients[viewer] = true
+
+	// Observateur trop lent : retiré (file fermée) par la diffusion de la sortie
+	ts.output([]byte("ligne 1\r\n"))
+	ts.output([]byte("ligne 2\r\n"))
+	_, open := <-viewer.send
+	require.True(t, open)
+	_, open = <-viewer.send
+	require.False(t, open, "file de l'observateur retiré fermée")
+
+	// Ses messages encore en lecture ne doivent ni paniquer ni bloquer la session
+	assert.NotPanics(t, func() { ts.input(viewer, "rm -rf /\r") })
+	assert.NotPanics(t, func() {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		ts.queueLocked(viewer, noticeFrame("Erreur: utilisateur absent de la session"))
+	})
+
+	ts.input(owner, "ls\r")
+	assert.Equal(t, "ls\r", stdin.String())
+
+	// Même chose après une fin de session forcée
+	ts.close("Session terminée par admin")
+	assert.NotPanics(t, func() { ts.input(owner, "id\r") })
+	assert.Equal(t, "ls\r", stdin.String())
+	ts.output([]byte("fin\r\n"))
+}
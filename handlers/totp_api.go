@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go-monitoring/auth"
+	"go-monitoring/pkg/qrcode"
+)
+
+// totpCodeRequest est le corps des actions confirmées par un code (TOTP ou code de secours)
+type totpCodeRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// GetTOTPStatus retourne l'état de la double authentification de l'utilisateur
+func GetTOTPStatus(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := am.GetUsername(r)
+		enabled, remaining, err := am.UserManager.TOTPStatus(username)
+		if err != nil {
+			jsonError(w, "Erreur lecture 2FA: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled":                  enabled,
+			"required":                 auth.TOTPRequired(cm.GetConfig(), am.GetUserRole(r)),
+			"recovery_codes_remaining": remaining,
+		})
+	}
+}
+
+// SetupTOTP démarre l'enrôlement : nouveau secret, URI otpauth:// et QR code (SVG généré localement)
+func SetupTOTP(am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := am.GetUsername(r)
+		secret, err := am.UserManager.BeginTOTPEnrollment(username)
+		if errors.Is(err, auth.ErrTOTPAlreadyEnabled) {
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			jsonError(w, "Erreur enrôlement 2FA: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		uri := auth.TOTPURI(username, secret)
+		code, err := qrcode.Encode(uri)
+		if err != nil {
+			jsonError(w, "Erreur génération du QR code", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"secret": secret,
+			"uri":    uri,
+			"qr_svg": code.SVG(),
+		})
+	}
+}
+
+// EnableTOTP confirme l'enrôlement par un premier code et retourne les codes de secours
+func EnableTOTP(am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req totpCodeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&req); err != nil {
+			jsonError(w, "Requête invalide", http.StatusBadRequest)
+			return
+		}
+
+		username := am.GetUsername(r)
+		codes, err := am.UserManager.ConfirmTOTPEnrollment(username, req.Code)
+		switch {
+		case errors.Is(err, auth.ErrInvalidCode):
+			jsonError(w, "Code invalide", http.StatusBadRequest)
+			return
+		case errors.Is(err, auth.ErrTOTPAlreadyEnabled), errors.Is(err, auth.ErrTOTPNoEnrollment):
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			jsonError(w, "Erreur activation 2FA: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "TOTP_ENABLE", username, "")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+	}
+}
+
+// DisableTOTP désactive la double authentification (mot de passe et code requis),
+// sauf si la politique l'impose au rôle de l'utilisateur
+func DisableTOTP(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req totpCodeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&req); err != nil {
+			jsonError(w, "Requête invalide", http.StatusBadRequest)
+			return
+		}
+
+		username := am.GetUsername(r)
+		if auth.TOTPRequired(cm.GetConfig(), am.GetUserRole(r)) {
+			jsonError(w, "Double authentification obligatoire pour votre rôle", http.StatusForbidden)
+			return
+		}
+		if _, err := am.UserManager.Authenticate(username, req.Password); err != nil {
+			jsonError(w, "Mot de passe incorrect", http.StatusUnauthorized)
+			return
+		}
+		if err := am.UserManager.VerifyTOTP(username, req.Code); err != nil {
+			jsonError(w, "Code invalide", http.StatusUnauthorized)
+			return
+		}
+
+		if err := am.UserManager.DisableTOTP(username); err != nil {
+			jsonError(w, "Erreur désactivation 2FA: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "TOTP_DISABLE", username, "")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RegenerateRecoveryCodes remplace les codes de secours (code requis)
+func RegenerateRecoveryCodes(am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req totpCodeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&req); err != nil {
+			jsonError(w, "Requête invalide", http.StatusBadRequest)
+			return
+		}
+
+		username := am.GetUsername(r)
+		if err := am.UserManager.VerifyTOTP(username, req.Code); err != nil {
+			jsonError(w, "Code invalide", http.StatusUnauthorized)
+			return
+		}
+		codes, err := am.UserManager.RegenerateRecoveryCodes(username)
+		if err != nil {
+			jsonError(w, "Erreur génération des codes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "TOTP_RECOVERY_REGENERATE", username, "")
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+	}
+}
@@ -2,22 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"go-monitoring/auth"
+	"go-monitoring/storage"
 )
 
 // ListUsers retourne la liste des utilisateurs (admin seulement)
 func ListUsers(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessionCookie, _ := r.Cookie("session_token")
-		if sessionCookie == nil || !am.IsAdminSession(sessionCookie.Value) {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
 		users := cm.userManager.GetAllUsers()
+		cfg := cm.GetConfig()
 
 		// Filtrer les données sensibles
 		type UserResponse struct {
@@ -26,6 +25,11 @@ func ListUsers(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 			IsActive    bool   `json:"is_active"`
 			LockedUntil string `json:"locked_until,omitempty"`
 			IsLocked    bool   `json:"is_locked"`
+			TOTPEnabled bool   `json:"totp_enabled"`
+			TOTPMissing bool   `json:"totp_missing"` // 2FA imposée au rôle mais non configurée
+			Source      string `json:"source"`       // local, ldap ou oidc (rôle et mot de passe gérés hors de GoMonitoring)
+			// Mot de passe à changer à la prochaine connexion (imposé ou expiré)
+			PasswordChange bool `json:"password_change"`
 		}
 
 		response := make([]UserResponse, len(users))
@@ -42,6 +46,11 @@ func ListUsers(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 				IsActive:    u.IsActive,
 				LockedUntil: lockedStr,
 				IsLocked:    isLocked,
+				TOTPEnabled: u.TOTPEnabled,
+				TOTPMissing: !u.TOTPEnabled && auth.TOTPRequired(cfg, u.Role),
+				Source:      u.Source,
+
+				PasswordChange: u.PasswordChange,
 			}
 		}
 
@@ -53,16 +62,11 @@ func ListUsers(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 // CreateUser crée un nouvel utilisateur
 func CreateUser(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessionCookie, _ := r.Cookie("session_token")
-		if sessionCookie == nil || !am.IsAdminSession(sessionCookie.Value) {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
 		var req struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
-			Role     string `json:"role"`
+			Username   string `json:"username"`
+			Password   string `json:"password"`
+			Role       string `json:"role"`
+			MustChange bool   `json:"must_change"` // Mot de passe à changer à la première connexion
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -75,15 +79,26 @@ func CreateUser(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 			return
 		}
 
-		if req.Role != "admin" && req.Role != "user" {
+		if !cm.GetConfig().HasRole(req.Role) {
 			req.Role = "user" // Default
 		}
 
 		if err := cm.userManager.AddUser(req.Username, req.Password, req.Role); err != nil {
-			http.Error(w, err.Error(), http.StatusConflict) // ex: user exists
+			status := http.StatusConflict // ex: user exists
+			if errors.Is(err, auth.ErrWeakPassword) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
 			return
 		}
 		// No implicit save needed, DB is immediate
+		if req.MustChange {
+			if err := cm.userManager.RequirePasswordChange(req.Username); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		am.Audit(r, "USER_CREATE", req.Username, fmt.Sprintf("role=%s must_change=%t", req.Role, req.MustChange))
 
 		w.WriteHeader(http.StatusCreated)
 	}
@@ -92,12 +107,6 @@ func CreateUser(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 // DeleteUser supprime un utilisateur
 func DeleteUser(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessionCookie, _ := r.Cookie("session_token")
-		if sessionCookie == nil || !am.IsAdminSession(sessionCookie.Value) {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
 		username := r.PathValue("username")
 		if username == "" {
 			http.Error(w, "Username requis", http.StatusBadRequest)
@@ -108,6 +117,7 @@ func DeleteUser(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		am.Audit(r, "USER_DELETE", username, "")
 
 		w.WriteHeader(http.StatusOK)
 	}
@@ -116,26 +126,23 @@ func DeleteUser(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 // UpdateUserPassword modifie le mot de passe
 func UpdateUserPassword(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessionCookie, _ := r.Cookie("session_token")
-		if sessionCookie == nil || !am.IsAdminSession(sessionCookie.Value) {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
 		username := r.PathValue("username")
 
 		var req struct {
-			Password string `json:"password"`
+			Password   string `json:"password"`
+			MustChange bool   `json:"must_change"` // Mot de passe temporaire, à changer à la prochaine connexion
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "JSON invalide", http.StatusBadRequest)
 			return
 		}
 
-		if err := cm.userManager.UpdatePassword(username, req.Password); err != nil {
+		if err := cm.userManager.ResetPassword(username, req.Password, req.MustChange); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		am.Audit(r, "PASSWORD_RESET", username, fmt.Sprintf("must_change=%t", req.MustChange))
+		revokeSessions(am, r, username)
 
 		w.WriteHeader(http.StatusOK)
 	}
@@ -144,12 +151,6 @@ func UpdateUserPassword(cm *ConfigManager, am *auth.AuthManager) http.HandlerFun
 // UpdateUserRole modifie le rôle
 func UpdateUserRole(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessionCookie, _ := r.Cookie("session_token")
-		if sessionCookie == nil || !am.IsAdminSession(sessionCookie.Value) {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
 		username := r.PathValue("username")
 
 		var req struct {
@@ -160,15 +161,19 @@ func UpdateUserRole(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 			return
 		}
 
-		if req.Role != "admin" && req.Role != "user" {
+		// Rôles attribuables : admin, user et ceux déclarés dans la section permissions
+		if !cm.GetConfig().HasRole(req.Role) {
 			http.Error(w, "Rôle invalide", http.StatusBadRequest)
 			return
 		}
 
+		previous := cm.userManager.GetUserRole(username)
 		if err := cm.userManager.UpdateUserRole(username, req.Role); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		am.Audit(r, "USER_ROLE", username, fmt.Sprintf("role=%s ancien=%s", req.Role, previous))
+		revokeSessions(am, r, username)
 
 		w.WriteHeader(http.StatusOK)
 	}
@@ -177,12 +182,6 @@ func UpdateUserRole(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 // ToggleUserStatus active/désactive un user
 func ToggleUserStatus(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessionCookie, _ := r.Cookie("session_token")
-		if sessionCookie == nil || !am.IsAdminSession(sessionCookie.Value) {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
 		username := r.PathValue("username")
 		var req struct {
 			Active bool `json:"active"`
@@ -196,6 +195,10 @@ func ToggleUserStatus(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		am.Audit(r, "USER_STATUS", username, fmt.Sprintf("active=%t", req.Active))
+		if !req.Active {
+			revokeSessions(am, r, username)
+		}
 
 		w.WriteHeader(http.StatusOK)
 	}
@@ -204,19 +207,66 @@ func ToggleUserStatus(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc
 // UnlockUser déverrouille un user
 func UnlockUser(cm *ConfigManager, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sessionCookie, _ := r.Cookie("session_token")
-		if sessionCookie == nil || !am.IsAdminSession(sessionCookie.Value) {
-			http.Error(w, "Accès refusé", http.StatusForbidden)
-			return
-		}
-
 		username := r.PathValue("username")
 
 		if err := cm.userManager.UnlockUser(username); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		am.Audit(r, "USER_UNLOCK", username, "")
 
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// ForceLogoutUser révoque toutes les sessions d'un utilisateur
+func ForceLogoutUser(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		if _, err := db.GetUser(username); err != nil {
+			jsonError(w, "Utilisateur introuvable", http.StatusNotFound)
+			return
+		}
+
+		n, err := am.RevokeUserSessions(username, "")
+		if err != nil {
+			jsonError(w, "Erreur révocation des sessions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "FORCE_LOGOUT", username, fmt.Sprintf("sessions=%d", n))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"revoked": n})
+	}
+}
+
+// revokeSessions invalide les sessions d'un utilisateur après une modification de son compte
+// (mot de passe, rôle, désactivation). La session qui effectue la modification est conservée.
+func revokeSessions(am *auth.AuthManager, r *http.Request, username string) {
+	exceptID := ""
+	if s := am.CurrentSession(r); s != nil && s.Username == username {
+		exceptID = s.ID
+	}
+	if _, err := am.RevokeUserSessions(username, exceptID); err != nil {
+		log.Printf("Erreur révocation des sessions de %s: %v", username, err)
+	}
+}
+
+// ResetUserTOTP désactive la double authentification d'un utilisateur (téléphone perdu sans code de secours).
+// Si la politique l'impose à son rôle, l'enrôlement sera redemandé à la prochaine connexion.
+func ResetUserTOTP(db *storage.DB, am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		if _, err := db.GetUser(username); err != nil {
+			jsonError(w, "Utilisateur introuvable", http.StatusNotFound)
+			return
+		}
+
+		if err := am.UserManager.DisableTOTP(username); err != nil {
+			jsonError(w, "Erreur réinitialisation 2FA: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		am.Audit(r, "TOTP_RESET", username, "")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
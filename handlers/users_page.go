@@ -12,12 +12,7 @@ import (
 // UsersPage gère la page de gestion des utilisateurs
 func UsersPage(cfg *config.Config, am *auth.AuthManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Vérification explicite du rôle admin (bien que le middleware le fasse aussi si configuré)
 		role := am.GetUserRole(r)
-		if role != "admin" {
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-			return
-		}
 
 		// Prevent Caching
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -42,12 +37,17 @@ func UsersPage(cfg *config.Config, am *auth.AuthManager) http.HandlerFunc {
 			Role      string
 			Username  string
 			CSRFToken string
+			// Rôles déclarés dans la section permissions (en plus de admin et user)
+			CustomRoles  []string
+			PasswordHint string
 		}{
-			Title:     "Gestion des Utilisateurs",
-			Status:    "OK",
-			Role:      role,
-			Username:  username,
-			CSRFToken: middleware.GetCSRFToken(r),
+			Title:        "Gestion des Utilisateurs",
+			Status:       "OK",
+			Role:         role,
+			Username:     username,
+			CSRFToken:    middleware.GetCSRFToken(r),
+			CustomRoles:  cfg.Roles()[2:],
+			PasswordHint: am.UserManager.PasswordHint(),
 		}
 
 		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
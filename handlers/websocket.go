@@ -1,12 +1,13 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
-	"go-monitoring/models"
+	"go-monitoring/auth"
+	"go-monitoring/realtime"
 
 	"github.com/gorilla/websocket"
 )
@@ -52,111 +53,81 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// Global Hub instance
-var WSHub = NewHub()
-
-type Hub struct {
-	clients    map[*websocket.Conn]bool
-	broadcast  chan []models.Machine
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
-	mu         sync.Mutex
-}
-
-func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[*websocket.Conn]bool),
-		broadcast:  make(chan []models.Machine),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
-	}
-}
-
-func (h *Hub) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-			log.Println("Client WS connecté")
-
-		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				client.Close()
-			}
-			h.mu.Unlock()
-			log.Println("Client WS déconnecté")
-
-		case message := <-h.broadcast:
-			h.mu.Lock()
-			for client := range h.clients {
-				client.SetWriteDeadline(time.Now().Add(writeWait))
-				err := client.WriteJSON(message)
-				if err != nil {
-					log.Printf("WS Error: %v", err)
-					client.Close()
-					delete(h.clients, client)
-				}
-			}
-			h.mu.Unlock()
+// WSHub diffuse l'état des machines et des alertes aux clients WebSocket abonnés
+var WSHub = realtime.NewHub()
+
+// maxWSMessage borne la taille d'une demande d'abonnement
+const maxWSMessage = 4096
+
+// ServeWS gère la connexion WebSocket du dashboard (route protégée par le middleware d'authentification).
+// Le client s'abonne à des sujets ; chaque trame est filtrée selon les machines visibles par l'utilisateur
+// (rôle relu après un changement de droits), et la connexion est fermée à la fin de la session.
+func ServeWS(am *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session_token")
+		if err != nil {
+			http.Error(w, "Non authentifié", http.StatusUnauthorized)
+			return
+		}
+		token := cookie.Value
+		username, ok := am.SessionUser(token)
+		if !ok {
+			http.Error(w, "Non authentifié", http.StatusUnauthorized)
+			return
 		}
-	}
-}
-
-// Broadcast envoie les mises à jour
-func (h *Hub) Broadcast(machines []models.Machine) {
-	h.broadcast <- machines
-}
-
-// ServeWS gère la connexion WebSocket
-func ServeWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("WS Upgrade Error:", err)
-		return
-	}
 
-	WSHub.register <- conn
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Println("WS Upgrade Error:", err)
+			return
+		}
+		defer conn.Close()
 
-	// Configure pong handler
-	conn.SetReadDeadline(time.Now().Add(pongWait))
-	conn.SetPongHandler(func(string) error {
+		conn.SetReadLimit(maxWSMessage)
 		conn.SetReadDeadline(time.Now().Add(pongWait))
-		return nil
-	})
-
-	// Ping ticker to keep connection alive
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-		defer func() {
-			WSHub.unregister <- conn
-		}()
-
-		for {
-			select {
-			case <-ticker.C:
-				conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(pongWait))
+			return nil
+		})
+
+		// Ping périodique (WriteControl peut être appelé en parallèle de la goroutine d'écriture du client)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(pingPeriod)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+						return
+					}
+				case <-done:
 					return
 				}
 			}
-		}
-	}()
-
-	// Read loop to handle close and pong responses
-	go func() {
-		defer func() {
-			WSHub.unregister <- conn
 		}()
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				break
-			}
+
+		// Rôle lu une fois à la connexion, relu après un changement de droits
+		viewer := am.NewViewer(username)
+		access := realtime.Access{
+			CanView: viewer.CanView,
+			Valid: func() bool {
+				current, ok := am.SessionUser(token)
+				return ok && current == username
+			},
 		}
-	}()
+
+		log.Printf("Client WS connecté: %s", username)
+		WSHub.Serve(conn, access)
+		log.Printf("Client WS déconnecté: %s", username)
+	}
+}
+
+// WSStats retourne les compteurs du flux temps réel (clients connectés, trames abandonnées)
+func WSStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(WSHub.Stats())
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -150,6 +151,13 @@ func CSRFMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Jeton d'API : le navigateur n'envoie jamais cet en-tête de lui-même, et l'authentification
+		// ignore alors le cookie de session (voir auth.Middleware)
+		if strings.HasPrefix(strings.ToLower(r.Header.Get("Authorization")), "bearer ") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Méthodes safe - pas de vérification nécessaire
 		if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
 			next.ServeHTTP(w, r)
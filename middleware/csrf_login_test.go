@@ -40,7 +40,8 @@ func TestLoginCSRFProtection(t *testing.T) {
 	}
 
 	// Setup Auth
-	um := auth.NewUserManager(db, []config.UserConfig{})
+	// Le compte admin initial doit changer son mot de passe avant toute session : compte dédié
+	um := auth.NewUserManager(db, []config.UserConfig{{Username: "operator", Password: "Correct-Cheval-9", Role: "user"}})
 	am := auth.NewAuthManager(um)
 
 	// Setup Router
@@ -107,8 +108,8 @@ func TestLoginCSRFProtection(t *testing.T) {
 	// 2. POST /login WITHOUT CSRF token -> Should fail (403)
 	// We need to use the SAME client to keep the session cookie
 	form := url.Values{}
-	form.Add("username", "admin")
-	form.Add("password", "admin") // Default admin created by NewUserManager
+	form.Add("username", "operator")
+	form.Add("password", "Correct-Cheval-9")
 
 	resp, err = client.PostForm(ts.URL + "/login", form)
 	if err != nil {
@@ -79,7 +79,31 @@ type DiskInfo struct {
 	Used        uint64
 	Free        uint64
 	UsedPercent float64
-	DriveType   string // SSD, HDD, Unknown
+	DriveType   string        // SSD, HDD, Unknown
+	Forecast    *DiskForecast // Prévision de remplissage (nil si historique insuffisant)
+}
+
+// DiskUsagePoint est un relevé historique de l'occupation d'un système de fichiers
+type DiskUsagePoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Used      uint64    `json:"used"`
+	Total     uint64    `json:"total"`
+}
+
+// DiskForecast est la prévision de remplissage d'un système de fichiers,
+// calculée par régression robuste sur l'historique d'occupation
+type DiskForecast struct {
+	MachineID    string     `json:"machine_id"`
+	MountPoint   string     `json:"mount_point"`
+	Used         uint64     `json:"used"`
+	Total        uint64     `json:"total"`
+	GrowthPerDay float64    `json:"growth_per_day"` // Octets/jour (négatif si l'occupation baisse)
+	Filling      bool       `json:"filling"`        // Croissance significative : DaysToFull est renseigné
+	DaysToFull   float64    `json:"days_to_full"`
+	FullAt       *time.Time `json:"full_at,omitempty"`
+	Samples      int        `json:"samples"`
+	Since        time.Time  `json:"since"`              // Premier relevé pris en compte
+	Severity     string     `json:"severity,omitempty"` // warning, critical (seuils d'alerte atteints)
 }
 
 // Partition représente une partition avec ses options
@@ -110,6 +134,38 @@ type DirectoryEntry struct {
 	Group       string
 }
 
+// DiskUsageNode représente un répertoire et sa taille cumulée (octets)
+type DiskUsageNode struct {
+	Name     string
+	Path     string
+	Size     int64
+	Children []*DiskUsageNode // Triés du plus gros au plus petit
+	// Sous-répertoires regroupés au-delà des plus gros
+	OtherSize  int64
+	OtherCount int
+}
+
+// DiskUsageReport représente le résultat d'une analyse d'occupation disque
+type DiskUsageReport struct {
+	Root        string
+	Depth       int
+	GeneratedAt time.Time
+	Duration    time.Duration
+	Partial     bool   // Délai dépassé : tailles sous-estimées
+	Warning     string // Avertissement éventuel (délai, répertoires illisibles)
+	Tree        *DiskUsageNode
+}
+
+// FilePreview représente l'aperçu texte d'un petit fichier
+type FilePreview struct {
+	Path     string
+	Name     string
+	Size     int64
+	ModTime  time.Time
+	Language string // Langage détecté pour la coloration syntaxique
+	Content  string
+}
+
 // MachineGroup représente un groupe de machines
 type MachineGroup struct {
 	Name     string
@@ -142,12 +198,201 @@ type MetricPoint struct {
 	DiskWrite   float64   `json:"disk_write"`
 }
 
+// CheckResult représente le résultat d'un check personnalisé (sémantique Nagios)
+type CheckResult struct {
+	MachineID string        `json:"machine_id"`
+	Name      string        `json:"name"`
+	State     int           `json:"state"`      // 0=OK, 1=WARNING, 2=CRITICAL, 3=UNKNOWN
+	StateName string        `json:"state_name"` // "ok", "warning", "critical", "unknown"
+	Output    string        `json:"output"`     // Première ligne de la sortie du plugin
+	LongText  string        `json:"long_text,omitempty"`
+	PerfData  []PerfDatum   `json:"perfdata,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// PerfDatum représente une valeur de performance Nagios ('label'=value[UOM];warn;crit;min;max)
+type PerfDatum struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+	UOM   string  `json:"uom,omitempty"`
+	Warn  string  `json:"warn,omitempty"`
+	Crit  string  `json:"crit,omitempty"`
+	Min   string  `json:"min,omitempty"`
+	Max   string  `json:"max,omitempty"`
+}
+
+// PerfPoint représente un point d'une série de perfdata dans l'historique
+type PerfPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Label     string    `json:"label"`
+	Value     float64   `json:"value"`
+	UOM       string    `json:"uom,omitempty"`
+}
+
+// ProbeResult représente le résultat d'une sonde synthétique (HTTP, TCP, TLS)
+type ProbeResult struct {
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	Target     string     `json:"target"`
+	Via        string     `json:"via,omitempty"` // Machine ayant exécuté la sonde (vide = serveur)
+	State      int        `json:"state"`         // Mêmes codes que les checks (0=OK ... 3=UNKNOWN)
+	StateName  string     `json:"state_name"`
+	StatusCode int        `json:"status_code,omitempty"` // http uniquement
+	LatencyMs  float64    `json:"latency_ms"`
+	CertExpiry *time.Time `json:"cert_expiry,omitempty"` // tls uniquement
+	Message    string     `json:"message"`
+	Timestamp  time.Time  `json:"timestamp"`
+}
+
+// CertificateInfo décrit un certificat trouvé sur une machine (fichier PEM ou magasin Windows)
+type CertificateInfo struct {
+	MachineID   string    `json:"machine_id"`
+	Source      string    `json:"source"` // Fichier ou magasin de certificats
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	SANs        []string  `json:"sans,omitempty"`
+	NotAfter    time.Time `json:"not_after"`
+	DaysLeft    int       `json:"days_left"`
+	Expired     bool      `json:"expired"`
+	Fingerprint string    `json:"fingerprint"` // SHA-1 (empreinte Windows)
+}
+
 // MachineDetailData contient les données pour la page détail
 type MachineDetailData struct {
 	Machine   Machine
+	Checks    []CheckResult
 	Time      string
 	Status    string
 	Role      string
 	Username  string
 	CSRFToken string // Token CSRF pour les formulaires
+	// Capacités de l'utilisateur sur cette machine (terminal, files, logs, services, admin)
+	Capabilities map[string]bool
+}
+
+// TerminalRecording référence l'enregistrement d'une session de terminal (fichier asciicast v2)
+type TerminalRecording struct {
+	ID         string     `json:"id"`
+	Username   string     `json:"username"`
+	MachineID  string     `json:"machine_id"`
+	RemoteAddr string     `json:"remote_addr"`
+	UserAgent  string     `json:"-"` // Repris dans l'audit de fin de session, non indexé
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"` // nil tant que la session est ouverte
+	DurationMs int64      `json:"duration_ms"`
+	SizeBytes  int64      `json:"size_bytes"`
+	Path       string     `json:"-"`
+}
+
+// CommandRun est l'exécution d'une commande sur un ensemble de machines
+type CommandRun struct {
+	ID        string              `json:"id"`
+	Username  string              `json:"username"`
+	Template  string              `json:"template,omitempty"` // Commande prédéfinie (vide : commande libre)
+	Command   string              `json:"command"`
+	Targets   []string            `json:"targets"`
+	StartedAt time.Time           `json:"started_at"`
+	EndedAt   *time.Time          `json:"ended_at,omitempty"` // nil tant que l'exécution est en cours
+	Results   []CommandHostResult `json:"results,omitempty"`
+}
+
+// CommandHostResult est le résultat d'une commande sur une machine
+type CommandHostResult struct {
+	MachineID  string `json:"machine_id"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exit_code"`
+	Error      string `json:"error,omitempty"` // Connexion impossible, délai dépassé...
+	Truncated  bool   `json:"truncated,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Déclenchement d'une exécution de runbook
+const (
+	RunbookTriggerAuto   = "auto"   // Alerte associée à un trigger automatique
+	RunbookTriggerManual = "manual" // Bouton sur l'alerte ou API
+)
+
+// États d'une exécution de runbook
+const (
+	RunbookRunning = "running"
+	RunbookSuccess = "success"
+	RunbookFailed  = "failed"
+)
+
+// RunbookRun est l'exécution d'un runbook sur une machine
+type RunbookRun struct {
+	ID        string              `json:"id"`
+	Runbook   string              `json:"runbook"`
+	MachineID string              `json:"machine_id"`
+	AlertKey  string              `json:"alert_key,omitempty"` // Alerte à l'origine de l'exécution
+	Trigger   string              `json:"trigger"`             // auto, manual
+	Username  string              `json:"username,omitempty"`  // Vide pour une exécution automatique
+	Status    string              `json:"status"`              // running, success, failed
+	StartedAt time.Time           `json:"started_at"`
+	EndedAt   *time.Time          `json:"ended_at,omitempty"`
+	Steps     []RunbookStepResult `json:"steps,omitempty"`
+}
+
+// RunbookStepResult est le résultat d'une étape de runbook
+type RunbookStepResult struct {
+	Name       string `json:"name"`
+	Command    string `json:"command"` // Commande exécutée ou action sur le service (ex: restart nginx)
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	ExitCode   int    `json:"exit_code"`
+	Error      string `json:"error,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Failed indique si l'étape a échoué (erreur ou code de sortie non nul)
+func (r RunbookStepResult) Failed() bool {
+	return r.Error != "" || r.ExitCode != 0
+}
+
+// Types de jetons d'API
+const (
+	APITokenPersonal = "personal" // Agit au nom de son propriétaire
+	APITokenService  = "service"  // Compte technique créé par un administrateur, avec son propre rôle
+)
+
+// APIToken est un jeton d'API (seule son empreinte est conservée)
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Kind       string     `json:"kind"`           // personal, service
+	Username   string     `json:"username"`       // Propriétaire (personnel) ou créateur (service)
+	Role       string     `json:"role,omitempty"` // Rôle d'un jeton de service
+	Scopes     []string   `json:"scopes"`
+	Prefix     string     `json:"prefix"` // Début du jeton, pour le reconnaître
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+}
+
+// Expired indique si le jeton a expiré
+func (t APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(now)
+}
+
+// UserSession est une session de connexion (seule l'empreinte du cookie est conservée)
+type UserSession struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	Hash       string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"` // Expiration absolue
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Current    bool      `json:"current"` // Session de la requête (renseigné par l'API)
+}
+
+// Expired indique si la session a expiré (durée absolue ou inactivité)
+func (s UserSession) Expired(now time.Time, idle time.Duration) bool {
+	return !s.ExpiresAt.After(now) || !s.LastSeenAt.Add(idle).After(now)
 }
@@ -0,0 +1,482 @@
+// Package qrcode génère des QR codes (ISO/IEC 18004) localement, sans dépendance externe.
+// Limité au mode octet, niveau de correction M, versions 1 à 10 (213 octets au plus) :
+// suffisant pour les URI otpauth:// de l'enrôlement TOTP.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxVersion est la plus grande version prise en charge
+const MaxVersion = 10
+
+// ErrTooLong est retournée quand le texte dépasse la capacité de la version maximale
+var ErrTooLong = errors.New("qrcode: texte trop long")
+
+// blockLayout décrit les blocs de correction d'erreur d'une version (niveau M)
+type blockLayout struct {
+	ecPerBlock int
+	groups     [][2]int // {nombre de blocs, octets de données par bloc}
+}
+
+// Niveau M, versions 1 à 10
+var layouts = [MaxVersion + 1]blockLayout{
+	1:  {10, [][2]int{{1, 16}}},
+	2:  {16, [][2]int{{1, 28}}},
+	3:  {26, [][2]int{{1, 44}}},
+	4:  {18, [][2]int{{2, 32}}},
+	5:  {24, [][2]int{{2, 43}}},
+	6:  {16, [][2]int{{4, 27}}},
+	7:  {18, [][2]int{{4, 31}}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+// Centres des motifs d'alignement par version
+var alignments = [MaxVersion + 1][]int{
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// Code est un QR code : une matrice carrée de modules (true = sombre)
+type Code struct {
+	Version  int
+	Size     int
+	modules  [][]bool
+	function [][]bool // Modules réservés (motifs, format, version)
+}
+
+// Encode génère le QR code d'un texte, dans la plus petite version suffisante
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for v := 1; v <= MaxVersion; v++ {
+		if capacity(v) >= len(data) {
+			c := newCode(v)
+			c.drawFunctionPatterns()
+			c.drawCodewords(c.interleave(encodeData(data, v)))
+			c.applyBestMask()
+			return c, nil
+		}
+	}
+	return nil, ErrTooLong
+}
+
+// dataCodewords retourne le nombre d'octets de données d'une version
+func dataCodewords(v int) int {
+	n := 0
+	for _, g := range layouts[v].groups {
+		n += g[0] * g[1]
+	}
+	return n
+}
+
+// countBits est la taille du compteur de caractères en mode octet
+func countBits(v int) int {
+	if v < 10 {
+		return 8
+	}
+	return 16
+}
+
+// capacity retourne le nombre d'octets encodables dans une version
+func capacity(v int) int {
+	return (dataCodewords(v)*8 - 4 - countBits(v)) / 8
+}
+
+// encodeData construit le flux de données : mode octet, longueur, données, terminateur et bourrage
+func encodeData(data []byte, v int) []byte {
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 == 1)
+		}
+	}
+	appendBits(0x4, 4) // Mode octet
+	appendBits(len(data), countBits(v))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	total := dataCodewords(v) * 8
+	for i := 0; i < 4 && len(bits) < total; i++ {
+		bits = append(bits, false) // Terminateur
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	out := make([]byte, 0, dataCodewords(v))
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << (7 - j)
+			}
+		}
+		out = append(out, b)
+	}
+	for pad := byte(0xEC); len(out) < dataCodewords(v); pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// interleave découpe les données en blocs, calcule leur correction d'erreur et les entrelace
+func (c *Code) interleave(data []byte) []byte {
+	layout := layouts[c.Version]
+	divisor := rsDivisor(layout.ecPerBlock)
+
+	var blocks, ecBlocks [][]byte
+	offset := 0
+	for _, g := range layout.groups {
+		for i := 0; i < g[0]; i++ {
+			block := data[offset : offset+g[1]]
+			offset += g[1]
+			blocks = append(blocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+		}
+	}
+
+	var out []byte
+	maxLen := len(blocks[len(blocks)-1])
+	for i := 0; i < maxLen; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+func newCode(v int) *Code {
+	size := 17 + 4*v
+	c := &Code{Version: v, Size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+	return c
+}
+
+// Dark indique si le module (x, y) est sombre
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	// Motifs de synchronisation
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Motifs de positionnement (et séparateurs)
+	for _, p := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := p[0]+dx, p[1]+dy
+				if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+					continue
+				}
+				dist := max(abs(dx), abs(dy))
+				c.setFunction(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	// Motifs d'alignement (sauf ceux qui chevauchent les motifs de positionnement)
+	pos := alignments[c.Version]
+	for i, ay := range pos {
+		for j, ax := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Zones de format réservées (écrites après le choix du masque)
+	c.drawFormat(0)
+
+	// Information de version (versions 7 et plus)
+	if c.Version >= 7 {
+		bits := versionBits(c.Version)
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.setFunction(a, b, dark)
+			c.setFunction(b, a, dark)
+		}
+	}
+}
+
+// drawFormat écrit les deux copies de l'information de format (niveau M, masque)
+func (c *Code) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true) // Module sombre
+}
+
+// formatBits calcule l'information de format (BCH 15,5) pour le niveau M
+func formatBits(mask int) int {
+	data := 0<<3 | mask // Niveau M : 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits calcule l'information de version (BCH 18,6)
+func versionBits(v int) int {
+	rem := v
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return v<<12 | rem
+}
+
+// drawCodewords place les octets en zigzag, par paires de colonnes depuis le coin inférieur droit
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Colonne de synchronisation
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y][x] = (data[i>>3]>>(7-i&7))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// maskBit indique si le masque inverse le module (x, y)
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && maskBit(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask retient le masque de plus faible pénalité
+func (c *Code) applyBestMask() {
+	best, bestScore := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if score := c.penalty(); bestScore < 0 || score < bestScore {
+			best, bestScore = mask, score
+		}
+		c.applyMask(mask) // Annuler (XOR)
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+}
+
+// penalty évalue la lisibilité d'un masque (règles de la norme : séries, blocs, motifs, équilibre)
+func (c *Code) penalty() int {
+	score := 0
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i < c.Size; i++ {
+			if get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += 3 + run - 5
+			}
+			run = 1
+		}
+		if run >= 5 {
+			score += 3 + run - 5
+		}
+		// Motif 1:1:3:1:1 bordé de 4 modules clairs
+		pattern := []bool{true, false, true, true, true, false, true}
+		for i := 0; i+7 <= c.Size; i++ {
+			match := true
+			for k, p := range pattern {
+				if get(i+k) != p {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			lightBefore, lightAfter := i >= 4, i+11 <= c.Size
+			for k := 1; k <= 4; k++ {
+				lightBefore = lightBefore && !get(i-k)
+				lightAfter = lightAfter && !get(i+6+k)
+			}
+			if lightBefore || lightAfter {
+				score += 40
+			}
+		}
+	}
+	for y := 0; y < c.Size; y++ {
+		line(func(x int) bool { return c.modules[y][x] })
+	}
+	for x := 0; x < c.Size; x++ {
+		line(func(y int) bool { return c.modules[y][x] })
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				v := c.modules[y][x]
+				if c.modules[y-1][x] == v && c.modules[y][x-1] == v && c.modules[y-1][x-1] == v {
+					score += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + k*10
+}
+
+// SVG retourne le QR code en SVG (zone de silence de 4 modules, taille adaptée au conteneur)
+func (c *Code) SVG() string {
+	const quiet = 4
+	n := c.Size + 2*quiet
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+quiet, y+quiet)
+			}
+		}
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`, n, n, path.String())
+}
+
+// rsDivisor calcule le polynôme générateur Reed-Solomon de degré donné, sur GF(256)
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder calcule les octets de correction d'erreur d'un bloc
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplie dans GF(256) (polynôme 0x11D)
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
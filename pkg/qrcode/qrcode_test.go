@@ -0,0 +1,84 @@
+package qrcode
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Exemple de la norme : version 1-M, « HELLO WORLD »
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ec := rsRemainder(data, rsDivisor(10))
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ec)
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	want := []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	}
+	for mask, w := range want {
+		assert.Equal(t, w, strconv.FormatInt(int64(formatBits(mask)), 2), "masque %d", mask)
+	}
+	assert.Equal(t, 0x07C94, versionBits(7))
+}
+
+func TestEncodeVersions(t *testing.T) {
+	tests := []struct {
+		length, version int
+	}{
+		{14, 1}, {15, 2}, {100, 6}, {122, 7}, {213, 10},
+	}
+	for _, tt := range tests {
+		c, err := Encode(strings.Repeat("a", tt.length))
+		require.NoError(t, err)
+		assert.Equal(t, tt.version, c.Version, "%d octets", tt.length)
+		assert.Equal(t, 17+4*tt.version, c.Size)
+	}
+
+	_, err := Encode(strings.Repeat("a", 214))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestEncodeStructure(t *testing.T) {
+	c, err := Encode("otpauth://totp/MonitorGo:alice?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=MonitorGo")
+	require.NoError(t, err)
+
+	// Motifs de positionnement : anneau sombre, anneau clair, carré central sombre
+	for _, p := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+		assert.True(t, c.Dark(p[0], p[1]))
+		assert.False(t, c.Dark(p[0]+1, p[1]+1))
+		assert.True(t, c.Dark(p[0]+3, p[1]+3))
+	}
+	// Synchronisation et module sombre
+	for i := 8; i < c.Size-8; i++ {
+		assert.Equal(t, i%2 == 0, c.Dark(6, i))
+		assert.Equal(t, i%2 == 0, c.Dark(i, 6))
+	}
+	assert.True(t, c.Dark(8, c.Size-8))
+
+	// Les deux copies de l'information de format sont identiques
+	var first, second []bool
+	for i := 0; i <= 5; i++ {
+		first = append(first, c.Dark(8, i))
+	}
+	first = append(first, c.Dark(8, 7), c.Dark(8, 8), c.Dark(7, 8))
+	for i := 9; i < 15; i++ {
+		first = append(first, c.Dark(14-i, 8))
+	}
+	for i := 0; i < 8; i++ {
+		second = append(second, c.Dark(c.Size-1-i, 8))
+	}
+	for i := 8; i < 15; i++ {
+		second = append(second, c.Dark(8, c.Size-15+i))
+	}
+	assert.Equal(t, first, second)
+
+	svg := c.SVG()
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, "viewBox=\"0 0 "+strconv.Itoa(c.Size+8))
+}
@@ -0,0 +1,43 @@
+package security
+
+import (
+	"encoding/base64"
+	"strings"
+	"unicode/utf16"
+)
+
+// ShellLiteral retourne une expression sh qui produit exactement s, sans que le shell
+// distant n'interprète jamais son contenu : la valeur transite encodée en base64
+// et n'est décodée qu'à l'intérieur d'une substitution entre guillemets.
+// Les retours à la ligne finaux sont supprimés par la substitution.
+func ShellLiteral(s string) string {
+	return `"$(printf %s ` + base64.StdEncoding.EncodeToString([]byte(s)) + ` | base64 -d)"`
+}
+
+// PowerShellLiteral retourne s sous forme de chaîne PowerShell entre apostrophes
+// (aucune expansion de variable). PowerShell accepte aussi les apostrophes typographiques
+// comme délimiteurs : elles sont doublées comme l'apostrophe ASCII.
+func PowerShellLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// PowerShellEncodedCommand construit une commande powershell -EncodedCommand :
+// le script (UTF-16LE, base64) n'est jamais interprété par le shell SSH distant.
+func PowerShellEncodedCommand(script string) string {
+	units := utf16.Encode([]rune(script))
+	buf := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		buf = append(buf, byte(u), byte(u>>8))
+	}
+	return "powershell -NoProfile -NonInteractive -EncodedCommand " + base64.StdEncoding.EncodeToString(buf)
+}
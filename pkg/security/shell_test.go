@@ -0,0 +1,70 @@
+package security
+
+import (
+	"encoding/base64"
+	"os/exec"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestShellLiteral(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh indisponible")
+	}
+
+	inputs := []string{
+		"Failed password",
+		`$(touch /tmp/pwned); "x" 'y' | & > \ *`,
+		"`id`",
+		"-e pattern",
+		"accents é ü",
+	}
+
+	for _, in := range inputs {
+		out, err := exec.Command("sh", "-c", "printf %s "+ShellLiteral(in)).Output()
+		if err != nil {
+			t.Fatalf("sh -c failed for %q: %v", in, err)
+		}
+		if string(out) != in {
+			t.Errorf("ShellLiteral(%q) produced %q", in, out)
+		}
+	}
+}
+
+func TestPowerShellLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"simple", "'simple'"},
+		{"it's", "'it''s'"},
+		{"$env:PATH", "'$env:PATH'"},
+		{"smart ’ quote", "'smart ’’ quote'"},
+	}
+
+	for _, tt := range tests {
+		if got := PowerShellLiteral(tt.input); got != tt.expected {
+			t.Errorf("PowerShellLiteral(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestPowerShellEncodedCommand(t *testing.T) {
+	script := "Write-Output 'é'"
+	cmd := PowerShellEncodedCommand(script)
+
+	encoded := strings.TrimPrefix(cmd, "powershell -NoProfile -NonInteractive -EncodedCommand ")
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("base64 invalide: %v", err)
+	}
+
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = uint16(raw[2*i]) | uint16(raw[2*i+1])<<8
+	}
+	if got := string(utf16.Decode(units)); got != script {
+		t.Errorf("script décodé = %q, want %q", got, script)
+	}
+}
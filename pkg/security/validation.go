@@ -16,8 +16,14 @@ var (
 
 	// ErrInvalidLogSource indique que la source de log est invalide
 	ErrInvalidLogSource = errors.New("source de log invalide")
+
+	// ErrInvalidPattern indique que le motif de recherche est invalide
+	ErrInvalidPattern = errors.New("motif de recherche invalide")
 )
 
+// MaxPatternLength est la longueur maximale d'un motif de recherche
+const MaxPatternLength = 256
+
 // serviceNameRegex valide les noms de services (alphanumeric, tirets, underscores, points)
 var serviceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
 
@@ -88,6 +94,16 @@ func ValidatePath(path string) error {
 	}
 
 	// Bloquer les chemins système sensibles
+	if IsSensitivePath(cleanPath) {
+		return ErrInvalidPath
+	}
+
+	return nil
+}
+
+// IsSensitivePath indique si un chemin désigne un fichier système sensible (secrets, clés SSH)
+func IsSensitivePath(path string) bool {
+	cleanPath := filepath.Clean(path)
 	sensitivePaths := []string{
 		"/etc/shadow",
 		"/etc/passwd",
@@ -101,11 +117,10 @@ func ValidatePath(path string) error {
 		// Utiliser filepath.Match pour supporter les wildcards
 		matched, _ := filepath.Match(sensitive, cleanPath)
 		if matched || strings.HasPrefix(cleanPath, strings.TrimSuffix(sensitive, "/*")) {
-			return ErrInvalidPath
+			return true
 		}
 	}
-
-	return nil
+	return false
 }
 
 // ValidateLogSource valide une source de log (chemin de fichier log)
@@ -147,6 +162,39 @@ func ValidateLogSource(source string) error {
 	return ValidatePath(source)
 }
 
+// ValidateCustomLogSource valide le fichier d'une source de logs déclarée par un administrateur :
+// il doit correspondre au chemin ou au motif glob configuré, en plus des vérifications de ValidatePath
+func ValidateCustomLogSource(source, pattern string) error {
+	if source == "" || pattern == "" {
+		return ErrInvalidLogSource
+	}
+	if matched, err := filepath.Match(pattern, source); err != nil || !matched {
+		return ErrInvalidLogSource
+	}
+	return ValidatePath(source)
+}
+
+// ValidateSearchPattern valide un motif de recherche (expression régulière étendue).
+// Le motif doit compiler en RE2 (sous-ensemble commun à grep -E, journalctl --grep et -match)
+// et ne contenir aucun caractère de contrôle.
+func ValidateSearchPattern(pattern string) error {
+	if pattern == "" || len(pattern) > MaxPatternLength {
+		return ErrInvalidPattern
+	}
+
+	for _, r := range pattern {
+		if r < 0x20 || r == 0x7f {
+			return ErrInvalidPattern
+		}
+	}
+
+	if _, err := regexp.Compile(pattern); err != nil {
+		return ErrInvalidPattern
+	}
+
+	return nil
+}
+
 // SanitizeInput retire les caractères potentiellement dangereux d'une entrée utilisateur
 // À utiliser en dernier recours - la validation stricte est préférable
 func SanitizeInput(input string) string {
@@ -1,6 +1,7 @@
 package security
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -95,6 +96,7 @@ func TestValidateLogSource(t *testing.T) {
 		{"etc passwd", "/etc/passwd", true},
 		{"path traversal", "/var/log/../../../etc/shadow", true},
 		{"injection", "/var/log/nginx; cat /etc/passwd", true},
+		{"application outside var log", "/opt/app/logs/app.log", true},
 		{"empty", "", true},
 	}
 
@@ -108,6 +110,35 @@ func TestValidateLogSource(t *testing.T) {
 	}
 }
 
+func TestValidateCustomLogSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		pattern string
+		wantErr bool
+	}{
+		{"configured path", "/opt/app/logs/app.log", "/opt/app/logs/app.log", false},
+		{"glob match", "/srv/api/logs/access.log", "/srv/api/logs/*.log", false},
+		{"other file", "/opt/app/config.yml", "/opt/app/logs/app.log", true},
+		{"glob other directory", "/srv/api/secrets/key.log", "/srv/api/logs/*.log", true},
+		{"glob subdirectory", "/srv/api/logs/old/access.log", "/srv/api/logs/*.log", true},
+		{"glob traversal", "/srv/api/logs/../../../etc/shadow", "/srv/api/logs/*", true},
+		{"sensitive", "/etc/shadow", "/etc/*", true},
+		{"injection", "/opt/app/logs/a;reboot", "/opt/app/logs/*", true},
+		{"no pattern", "/var/log/syslog", "", true},
+		{"empty", "", "/opt/app/logs/*.log", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCustomLogSource(tt.source, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCustomLogSource(%q, %q) error = %v, wantErr %v", tt.source, tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateAction(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -163,6 +194,33 @@ func TestSanitizeInput(t *testing.T) {
 	}
 }
 
+func TestValidateSearchPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{"simple word", "Failed password", false},
+		{"alternation", "(?i)error|fail(ed|ure)", false},
+		{"shell characters", `$(reboot); "quoted" 'single' | & > \.`, false},
+		{"empty", "", true},
+		{"newline", "a\nb", true},
+		{"nul byte", "a\x00b", true},
+		{"invalid regex", "(unclosed", true},
+		{"backreference", `(a)\1`, true},
+		{"too long", strings.Repeat("a", MaxPatternLength+1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSearchPattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSearchPattern(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // Benchmark tests
 func BenchmarkValidateServiceName(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,441 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/models"
+)
+
+// Sujets auxquels un client peut s'abonner
+const (
+	TopicMachines = "machines" // Résumé de toutes les machines visibles
+	TopicGroup    = "group"    // Résumé des machines d'un groupe
+	TopicMachine  = "machine"  // Détail complet d'une machine
+	TopicAlerts   = "alerts"   // Alertes actives
+)
+
+// Types de trames envoyées au client
+const (
+	FrameUpdate     = "update"
+	FrameSubscribed = "subscribed"
+	FrameError      = "error"
+)
+
+const (
+	// writeWait est le délai accordé à l'écriture d'une trame
+	writeWait = 10 * time.Second
+
+	// sendBuffer est la taille de la file d'envoi de chaque client
+	sendBuffer = 16
+
+	// maxSkipped est le nombre de mises à jour consécutives non mises en file (file pleine)
+	// au-delà duquel un client trop lent est déconnecté
+	maxSkipped = 3
+)
+
+// Request est un message du client : {"type":"subscribe","topic":"group","group":"Production"}
+type Request struct {
+	Type    string `json:"type"` // subscribe, unsubscribe
+	Topic   string `json:"topic"`
+	Group   string `json:"group,omitempty"`
+	Machine string `json:"machine,omitempty"`
+}
+
+// MachineSummary est l'état d'une machine affiché sur le dashboard
+type MachineSummary struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	Group         string  `json:"group"`
+	Host          string  `json:"host"`
+	Status        string  `json:"status"`
+	OSType        string  `json:"os_type"`
+	Hostname      string  `json:"hostname"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
+}
+
+// Frame est une trame envoyée au client. Une trame update ne contient que ce qui a changé
+// depuis la trame précédente : machines ajoutées ou modifiées, machines retirées, alertes
+// levées ou modifiées, alertes résolues.
+type Frame struct {
+	Type           string           `json:"type"`
+	Machines       []MachineSummary `json:"machines,omitempty"`
+	Details        []models.Machine `json:"details,omitempty"`
+	Removed        []string         `json:"removed,omitempty"`         // Machines retirées des résumés
+	RemovedDetails []string         `json:"removed_details,omitempty"` // Machines dont le détail n'est plus suivi
+	Alerts         []alerts.Alert   `json:"alerts,omitempty"`
+	Resolved       []string         `json:"resolved,omitempty"` // Clés des alertes résolues
+	Topics         []string         `json:"topics,omitempty"`   // Abonnements en cours (trame subscribed)
+	Error          string           `json:"error,omitempty"`
+}
+
+// empty indique qu'une trame update ne contient aucun changement
+func (f Frame) empty() bool {
+	return len(f.Machines) == 0 && len(f.Details) == 0 && len(f.Removed) == 0 &&
+		len(f.RemovedDetails) == 0 && len(f.Alerts) == 0 && len(f.Resolved) == 0
+}
+
+// Snapshot est l'état diffusé à chaque cycle de collecte
+type Snapshot struct {
+	Machines []models.Machine
+	Alerts   []alerts.Alert
+}
+
+// Access décrit les droits du client ; les fonctions sont réévaluées à chaque trame
+// pour suivre les changements de rôle, de permissions et la fin de session. Elles sont appelées
+// par la boucle du hub pour chaque machine : elles ne doivent pas interroger la base ou l'annuaire
+// à chaque appel.
+type Access struct {
+	CanView func(machineID string) bool // Machine visible par l'utilisateur
+	Valid   func() bool                 // Session toujours valide
+}
+
+// Conn est la connexion WebSocket d'un client (*websocket.Conn)
+type Conn interface {
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+// client est l'état d'un abonné. Les abonnements et le dernier état envoyé ne sont manipulés
+// que par la boucle du hub ; seule la goroutine d'écriture utilise la connexion en écriture.
+type client struct {
+	conn    Conn
+	access  Access
+	send    chan Frame        // File d'envoi, vidée par writePump
+	topics  map[string]bool   // machines, group:<nom>, machine:<id>, alerts
+	sent    map[string][]byte // Dernier état mis en file : m:<id>, d:<id>, a:<clé>
+	skipped int               // Mises à jour consécutives non mises en file
+	closed  atomic.Bool       // Désinscrit par le hub
+}
+
+type clientRequest struct {
+	client *client
+	req    Request
+}
+
+// Stats sont les compteurs du hub
+type Stats struct {
+	Clients            int    `json:"clients"`
+	DroppedFrames      uint64 `json:"dropped_frames"`      // Mises à jour non mises en file (client lent), fusionnées dans la suivante
+	DroppedClients     uint64 `json:"dropped_clients"`     // Clients déconnectés car trop lents ou en erreur d'écriture
+	CoalescedSnapshots uint64 `json:"coalesced_snapshots"` // États remplacés avant diffusion par un état plus récent
+}
+
+// Hub diffuse les changements aux clients abonnés. Il n'écrit jamais sur les connexions :
+// chaque client a sa file d'envoi et sa goroutine d'écriture, et Broadcast ne bloque pas.
+type Hub struct {
+	clients    map[*client]bool
+	register   chan *client
+	unregister chan *client
+	requests   chan clientRequest
+	broadcast  chan Snapshot // Un seul état en attente : le plus récent remplace le précédent
+	last       Snapshot
+
+	clientCount        atomic.Int64
+	droppedFrames      atomic.Uint64
+	droppedClients     atomic.Uint64
+	coalescedSnapshots atomic.Uint64
+}
+
+// NewHub crée un hub ; Run doit être lancé dans une goroutine
+func NewHub() *Hub {
+	return &Hub{
+		clients:    make(map[*client]bool),
+		register:   make(chan *client),
+		unregister: make(chan *client),
+		requests:   make(chan clientRequest),
+		broadcast:  make(chan Snapshot, 1),
+	}
+}
+
+// Run traite les connexions, abonnements et diffusions
+func (h *Hub) Run() {
+	for {
+		select {
+		case c := <-h.register:
+			h.clients[c] = true
+			h.clientCount.Store(int64(len(h.clients)))
+
+		case c := <-h.unregister:
+			h.remove(c)
+
+		case cr := <-h.requests:
+			if h.clients[cr.client] {
+				h.handleRequest(cr.client, cr.req)
+			}
+
+		case s := <-h.broadcast:
+			h.last = s
+			for c := range h.clients {
+				h.update(c)
+			}
+		}
+	}
+}
+
+// Broadcast diffuse un nouvel état sans bloquer ; chaque client ne reçoit que ses changements.
+// Si l'état précédent n'a pas encore été diffusé, il est remplacé.
+func (h *Hub) Broadcast(s Snapshot) {
+	for {
+		select {
+		case h.broadcast <- s:
+			return
+		default:
+		}
+		select {
+		case <-h.broadcast:
+			h.coalescedSnapshots.Add(1)
+		default:
+		}
+	}
+}
+
+// Stats retourne les compteurs du hub
+func (h *Hub) Stats() Stats {
+	return Stats{
+		Clients:            int(h.clientCount.Load()),
+		DroppedFrames:      h.droppedFrames.Load(),
+		DroppedClients:     h.droppedClients.Load(),
+		CoalescedSnapshots: h.coalescedSnapshots.Load(),
+	}
+}
+
+// Serve enregistre un client et lit ses demandes d'abonnement jusqu'à la fermeture de la connexion
+func (h *Hub) Serve(conn Conn, access Access) {
+	c := &client{
+		conn:   conn,
+		access: access,
+		send:   make(chan Frame, sendBuffer),
+		topics: make(map[string]bool),
+		sent:   make(map[string][]byte),
+	}
+	go c.writePump(h)
+	h.register <- c
+	defer func() { h.unregister <- c }()
+
+	for {
+		var req Request
+		if err := conn.ReadJSON(&req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+				return
+			}
+			req = Request{} // Message illisible : signalé au client
+		}
+		h.requests <- clientRequest{client: c, req: req}
+	}
+}
+
+// writePump écrit les trames de la file du client jusqu'à sa fermeture par le hub
+func (c *client) writePump(h *Hub) {
+	defer c.conn.Close()
+	for f := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.conn.WriteJSON(f); err != nil {
+			if !c.closed.Load() { // Sinon l'écriture a été interrompue par le hub
+				log.Printf("WebSocket: erreur d'envoi, client déconnecté: %v", err)
+				h.droppedClients.Add(1)
+			}
+			return // La fermeture interrompt la lecture, qui désinscrit le client
+		}
+	}
+}
+
+// handleRequest applique un abonnement ou un désabonnement, puis envoie l'état correspondant
+func (h *Hub) handleRequest(c *client, req Request) {
+	if !c.access.Valid() {
+		h.remove(c)
+		return
+	}
+
+	key, msg := topicKey(req)
+	if msg == "" && req.Topic == TopicMachine && !c.access.CanView(req.Machine) {
+		msg = "machine inconnue ou accès refusé"
+	}
+	if msg == "" && req.Type != "subscribe" && req.Type != "unsubscribe" {
+		msg = "type de message inconnu"
+	}
+	if msg != "" {
+		h.reply(c, Frame{Type: FrameError, Error: msg})
+		return
+	}
+
+	if req.Type == "subscribe" {
+		c.topics[key] = true
+	} else {
+		delete(c.topics, key)
+	}
+
+	topics := make([]string, 0, len(c.topics))
+	for t := range c.topics {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	if h.reply(c, Frame{Type: FrameSubscribed, Topics: topics}) {
+		h.update(c)
+	}
+}
+
+// topicKey retourne la clé d'abonnement d'une demande, ou un message d'erreur
+func topicKey(req Request) (string, string) {
+	switch req.Topic {
+	case TopicMachines, TopicAlerts:
+		return req.Topic, ""
+	case TopicGroup:
+		if req.Group == "" {
+			return "", "groupe manquant"
+		}
+		return TopicGroup + ":" + req.Group, ""
+	case TopicMachine:
+		if req.Machine == "" {
+			return "", "machine manquante"
+		}
+		return TopicMachine + ":" + req.Machine, ""
+	}
+	return "", "sujet inconnu"
+}
+
+// update met en file les changements depuis la dernière trame du client. Si la file est pleine,
+// la mise à jour est abandonnée sans avancer l'état envoyé : la suivante contiendra l'état le plus
+// récent. Un client qui reste bloqué est déconnecté.
+func (h *Hub) update(c *client) {
+	if !c.access.Valid() {
+		h.remove(c)
+		return
+	}
+	frame, sent := c.diff(h.last)
+	if frame.empty() {
+		return
+	}
+	select {
+	case c.send <- frame:
+		c.sent = sent
+		c.skipped = 0
+	default:
+		h.droppedFrames.Add(1)
+		c.skipped++
+		if c.skipped > maxSkipped {
+			log.Printf("WebSocket: client trop lent déconnecté")
+			h.droppedClients.Add(1)
+			h.remove(c)
+		}
+	}
+}
+
+// reply met en file une réponse à une demande ; un client dont la file est pleine est déconnecté
+func (h *Hub) reply(c *client, f Frame) bool {
+	select {
+	case c.send <- f:
+		return true
+	default:
+		h.droppedClients.Add(1)
+		h.remove(c)
+		return false
+	}
+}
+
+// remove désinscrit un client : sa file est fermée et la connexion interrompue
+func (h *Hub) remove(c *client) {
+	if !h.clients[c] {
+		return
+	}
+	delete(h.clients, c)
+	h.clientCount.Store(int64(len(h.clients)))
+	c.closed.Store(true)
+	close(c.send)
+	c.conn.Close()
+}
+
+// diff calcule la trame des changements d'un client par rapport à son dernier état envoyé,
+// ainsi que le nouvel état envoyé
+func (c *client) diff(s Snapshot) (Frame, map[string][]byte) {
+	frame := Frame{Type: FrameUpdate}
+	current := make(map[string][]byte)
+
+	changed := func(key string, v interface{}) bool {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		current[key] = data
+		return !bytes.Equal(c.sent[key], data)
+	}
+
+	for _, m := range s.Machines {
+		if !c.access.CanView(m.ID) {
+			continue
+		}
+		if c.topics[TopicMachines] || c.topics[TopicGroup+":"+m.Group] {
+			if summary := Summarize(m); changed("m:"+m.ID, summary) {
+				frame.Machines = append(frame.Machines, summary)
+			}
+		}
+		if c.topics[TopicMachine+":"+m.ID] {
+			detail := m
+			detail.KeyPath = "" // Chemin de clé privée : jamais exposé au navigateur
+			if changed("d:"+m.ID, detail) {
+				frame.Details = append(frame.Details, detail)
+			}
+		}
+	}
+
+	if c.topics[TopicAlerts] {
+		for _, a := range s.Alerts {
+			if a.MachineID != "" && !c.access.CanView(a.MachineID) {
+				continue
+			}
+			// La date de mise à jour change à chaque évaluation : ignorée pour la comparaison
+			cmp := a
+			cmp.UpdatedAt = time.Time{}
+			if changed("a:"+a.Key, cmp) {
+				frame.Alerts = append(frame.Alerts, a)
+			}
+		}
+	}
+
+	for key := range c.sent {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		switch key[:2] {
+		case "m:":
+			frame.Removed = append(frame.Removed, key[2:])
+		case "d:":
+			frame.RemovedDetails = append(frame.RemovedDetails, key[2:])
+		case "a:":
+			frame.Resolved = append(frame.Resolved, key[2:])
+		}
+	}
+	sort.Strings(frame.Removed)
+	sort.Strings(frame.RemovedDetails)
+	sort.Strings(frame.Resolved)
+
+	return frame, current
+}
+
+// Summarize réduit une machine aux informations du dashboard
+// (pourcentages arrondis au dixième pour limiter les trames)
+func Summarize(m models.Machine) MachineSummary {
+	return MachineSummary{
+		ID:            m.ID,
+		Name:          m.Name,
+		Group:         m.Group,
+		Host:          m.Host,
+		Status:        m.Status,
+		OSType:        m.OSType,
+		Hostname:      m.System.Hostname,
+		CPUPercent:    math.Round(m.CPU.UsagePercent*10) / 10,
+		MemoryPercent: math.Round(m.Memory.UsedPercent*10) / 10,
+	}
+}
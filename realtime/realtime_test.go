@@ -0,0 +1,365 @@
+package realtime
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-monitoring/alerts"
+	"go-monitoring/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn simule une connexion WebSocket : les demandes sont poussées dans in,
+// les trames écrites sont relues depuis out
+type fakeConn struct {
+	in      chan Request
+	out     chan Frame
+	closed  chan struct{}
+	once    sync.Once
+	gate    chan struct{} // Client lent : chaque écriture attend la fermeture de gate
+	writing atomic.Int32  // Écritures en cours
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		in:     make(chan Request, 10),
+		out:    make(chan Frame, 100),
+		closed: make(chan struct{}),
+	}
+}
+
+func (f *fakeConn) ReadJSON(v interface{}) error {
+	select {
+	case req := <-f.in:
+		*(v.(*Request)) = req
+		return nil
+	case <-f.closed:
+		return io.EOF
+	}
+}
+
+func (f *fakeConn) WriteJSON(v interface{}) error {
+	f.writing.Add(1)
+	defer f.writing.Add(-1)
+	if f.gate != nil {
+		select {
+		case <-f.gate:
+		case <-f.closed:
+			return io.ErrClosedPipe
+		}
+	}
+	// Aller-retour JSON, comme sur le réseau
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var frame Frame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+	f.out <- frame
+	return nil
+}
+
+func (f *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (f *fakeConn) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+func (f *fakeConn) next(t *testing.T) Frame {
+	t.Helper()
+	select {
+	case frame := <-f.out:
+		return frame
+	case <-time.After(time.Second):
+		t.Fatal("aucune trame reçue")
+		return Frame{}
+	}
+}
+
+func (f *fakeConn) none(t *testing.T) {
+	t.Helper()
+	select {
+	case frame := <-f.out:
+		t.Fatalf("trame inattendue: %+v", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// flush attend que le dernier état diffusé ait été traité par la boucle du hub
+func flush(h *Hub) {
+	for len(h.broadcast) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	h.requests <- clientRequest{client: &client{}} // Ignorée, traitée après la diffusion
+}
+
+func fleet(prodCPU float64) []models.Machine {
+	return []models.Machine{
+		{ID: "prod-1", Name: "Prod", Group: "Production", Status: "online", KeyPath: "/keys/id_rsa", CPU: models.CPUInfo{UsagePercent: prodCPU}},
+		{ID: "stg-1", Name: "Staging", Group: "Staging", Status: "online"},
+	}
+}
+
+func allowAll() Access {
+	return Access{CanView: func(string) bool { return true }, Valid: func() bool { return true }}
+}
+
+func connect(h *Hub, access Access) *fakeConn {
+	conn := newFakeConn()
+	go h.Serve(conn, access)
+	return conn
+}
+
+func TestHubSendsOnlyChanges(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	h.Broadcast(Snapshot{Machines: fleet(10)})
+	flush(h)
+
+	conn := connect(h, allowAll())
+	conn.in <- Request{Type: "subscribe", Topic: TopicMachines}
+
+	ack := conn.next(t)
+	assert.Equal(t, FrameSubscribed, ack.Type)
+	assert.Equal(t, []string{TopicMachines}, ack.Topics)
+
+	initial := conn.next(t)
+	assert.Equal(t, FrameUpdate, initial.Type)
+	assert.Len(t, initial.Machines, 2)
+
+	// Aucun changement : aucune trame
+	h.Broadcast(Snapshot{Machines: fleet(10)})
+	conn.none(t)
+
+	// Variation sous le dixième : ignorée
+	h.Broadcast(Snapshot{Machines: fleet(10.04)})
+	conn.none(t)
+
+	h.Broadcast(Snapshot{Machines: fleet(55.55)})
+	update := conn.next(t)
+	require.Len(t, update.Machines, 1)
+	assert.Equal(t, "prod-1", update.Machines[0].ID)
+	assert.Equal(t, 55.6, update.Machines[0].CPUPercent)
+
+	// Machine retirée de la configuration
+	h.Broadcast(Snapshot{Machines: fleet(55.55)[:1]})
+	removed := conn.next(t)
+	assert.Empty(t, removed.Machines)
+	assert.Equal(t, []string{"stg-1"}, removed.Removed)
+}
+
+func TestHubTopicsAndPermissions(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	h.Broadcast(Snapshot{
+		Machines: fleet(10),
+		Alerts: []alerts.Alert{
+			{Key: "check:prod-1:load", MachineID: "prod-1", Severity: alerts.SeverityCritical},
+			{Key: "check:stg-1:load", MachineID: "stg-1", Severity: alerts.SeverityWarning},
+		},
+	})
+	flush(h)
+
+	stagingOnly := Access{
+		CanView: func(id string) bool { return id == "stg-1" },
+		Valid:   func() bool { return true },
+	}
+	conn := connect(h, stagingOnly)
+
+	// Détail d'une machine non autorisée : refusé
+	conn.in <- Request{Type: "subscribe", Topic: TopicMachine, Machine: "prod-1"}
+	assert.Equal(t, FrameError, conn.next(t).Type)
+
+	conn.in <- Request{Type: "subscribe", Topic: "inconnu"}
+	assert.Equal(t, "sujet inconnu", conn.next(t).Error)
+
+	// Toutes les machines : seules les machines visibles sont envoyées
+	conn.in <- Request{Type: "subscribe", Topic: TopicMachines}
+	conn.next(t)
+	frame := conn.next(t)
+	require.Len(t, frame.Machines, 1)
+	assert.Equal(t, "stg-1", frame.Machines[0].ID)
+
+	conn.in <- Request{Type: "subscribe", Topic: TopicAlerts}
+	conn.next(t)
+	frame = conn.next(t)
+	require.Len(t, frame.Alerts, 1)
+	assert.Equal(t, "check:stg-1:load", frame.Alerts[0].Key)
+
+	// Détail : la clé privée n'est jamais transmise
+	conn.in <- Request{Type: "subscribe", Topic: TopicMachine, Machine: "stg-1"}
+	ack := conn.next(t)
+	assert.Equal(t, []string{TopicAlerts, TopicMachine + ":stg-1", TopicMachines}, ack.Topics)
+	frame = conn.next(t)
+	require.Len(t, frame.Details, 1)
+	assert.Empty(t, frame.Details[0].KeyPath)
+
+	// Résolution d'alerte (la date de mise à jour seule ne compte pas)
+	h.Broadcast(Snapshot{Machines: fleet(10), Alerts: []alerts.Alert{{Key: "check:prod-1:load", MachineID: "prod-1", UpdatedAt: time.Now()}}})
+	frame = conn.next(t)
+	assert.Equal(t, []string{"check:stg-1:load"}, frame.Resolved)
+
+	// Désabonnement : le détail n'est plus suivi
+	conn.in <- Request{Type: "unsubscribe", Topic: TopicMachine, Machine: "stg-1"}
+	conn.next(t)
+	frame = conn.next(t)
+	assert.Equal(t, []string{"stg-1"}, frame.RemovedDetails)
+}
+
+func TestHubGroupTopic(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	h.Broadcast(Snapshot{Machines: fleet(10)})
+	flush(h)
+
+	conn := connect(h, allowAll())
+	conn.in <- Request{Type: "subscribe", Topic: TopicGroup}
+	assert.Equal(t, "groupe manquant", conn.next(t).Error)
+
+	conn.in <- Request{Type: "subscribe", Topic: TopicGroup, Group: "Production"}
+	conn.next(t)
+	frame := conn.next(t)
+	require.Len(t, frame.Machines, 1)
+	assert.Equal(t, "prod-1", frame.Machines[0].ID)
+}
+
+func TestHubClosesExpiredSession(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+
+	var mu sync.Mutex
+	valid := true
+	access := Access{
+		CanView: func(string) bool { return true },
+		Valid: func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return valid
+		},
+	}
+	conn := connect(h, access)
+	conn.in <- Request{Type: "subscribe", Topic: TopicMachines}
+	conn.next(t)
+
+	mu.Lock()
+	valid = false
+	mu.Unlock()
+	h.Broadcast(Snapshot{Machines: fleet(10)})
+
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connexion non fermée après expiration de la session")
+	}
+	conn.none(t)
+}
+
+// subscribeSlow connecte un client lent abonné à toutes les machines : l'accusé de réception
+// reste bloqué dans l'écriture et l'état initial occupe la file
+func subscribeSlow(t *testing.T, h *Hub) *fakeConn {
+	conn := newFakeConn()
+	conn.gate = make(chan struct{})
+	go h.Serve(conn, allowAll())
+	conn.in <- Request{Type: "subscribe", Topic: TopicMachines}
+	// L'écriture de l'accusé de réception bloque : la demande a été traitée
+	require.Eventually(t, func() bool { return conn.writing.Load() == 1 }, time.Second, time.Millisecond)
+	flush(h)
+	return conn
+}
+
+func TestHubCoalescesSlowClient(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	h.Broadcast(Snapshot{Machines: fleet(0)})
+	flush(h)
+
+	slow := subscribeSlow(t, h)
+	fast := connect(h, allowAll())
+	fast.in <- Request{Type: "subscribe", Topic: TopicMachines}
+	fast.next(t)
+	fast.next(t)
+
+	// Remplir la file du client lent (l'état initial y est déjà)
+	for i := 1; i < sendBuffer; i++ {
+		h.Broadcast(Snapshot{Machines: fleet(float64(i))})
+		flush(h)
+		assert.Equal(t, float64(i), fast.next(t).Machines[0].CPUPercent, "le client rapide n'est pas ralenti")
+	}
+
+	// File pleine : les mises à jour sont abandonnées sans bloquer
+	for _, cpu := range []float64{50, 60} {
+		h.Broadcast(Snapshot{Machines: fleet(cpu)})
+		flush(h)
+		fast.next(t)
+	}
+	assert.Equal(t, uint64(2), h.Stats().DroppedFrames)
+
+	// Le client se débloque : il reçoit la file, puis directement l'état le plus récent
+	close(slow.gate)
+	slow.next(t) // Accusé de réception
+	var last Frame
+	for i := 0; i < sendBuffer; i++ {
+		last = slow.next(t)
+	}
+	assert.Equal(t, float64(sendBuffer-1), last.Machines[0].CPUPercent)
+
+	h.Broadcast(Snapshot{Machines: fleet(60)})
+	latest := slow.next(t)
+	require.Len(t, latest.Machines, 1)
+	assert.Equal(t, 60.0, latest.Machines[0].CPUPercent)
+	fast.none(t)
+
+	assert.Equal(t, 2, h.Stats().Clients)
+	assert.Zero(t, h.Stats().DroppedClients)
+}
+
+func TestHubDropsStuckClient(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	h.Broadcast(Snapshot{Machines: fleet(0)})
+	flush(h)
+
+	stuck := subscribeSlow(t, h)
+	for i := 1; i < sendBuffer+maxSkipped+1; i++ {
+		h.Broadcast(Snapshot{Machines: fleet(float64(i))})
+		flush(h)
+	}
+
+	select {
+	case <-stuck.closed:
+	case <-time.After(time.Second):
+		t.Fatal("client bloqué non déconnecté")
+	}
+	stats := h.Stats()
+	assert.Equal(t, 0, stats.Clients)
+	assert.Equal(t, uint64(1), stats.DroppedClients)
+	assert.Equal(t, uint64(maxSkipped+1), stats.DroppedFrames)
+}
+
+func TestHubBroadcastNeverBlocks(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	subscribeSlow(t, h)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			h.Broadcast(Snapshot{Machines: fleet(float64(i))})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Broadcast bloqué par un client lent")
+	}
+}
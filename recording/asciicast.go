@@ -0,0 +1,209 @@
+package recording
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxOutputBytes borne la sortie enregistrée d'une session. Au-delà, seules les
+// saisies et redimensionnements sont conservés : un flux de sortie ne peut pas
+// masquer les commandes tapées ensuite.
+const MaxOutputBytes = 100 << 20
+
+// Types d'événements asciicast v2
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+	EventMarker = "m"
+)
+
+// Header est l'en-tête d'un enregistrement asciicast v2 (première ligne du fichier)
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder écrit une session de terminal au format asciicast v2.
+// Chaque événement est écrit immédiatement : un arrêt brutal du serveur ne perd que l'événement en cours.
+// Les méthodes peuvent être appelées depuis plusieurs goroutines.
+type Recorder struct {
+	w           io.WriteCloser
+	start       time.Time
+	pending     []byte // Fin de sortie contenant un caractère UTF-8 incomplet
+	outputBytes int64
+	outputLimit int64
+	size        int64
+	truncated   bool
+	closed      bool
+	err         error
+	mu          sync.Mutex
+}
+
+// NewID génère un identifiant d'enregistrement aléatoire
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Create crée le fichier d'enregistrement (lisible par le seul propriétaire)
+func Create(path string, h Header) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("création du répertoire d'enregistrements: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("création de l'enregistrement: %w", err)
+	}
+	rec, err := New(f, h)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return rec, nil
+}
+
+// New démarre un enregistrement sur w en écrivant l'en-tête
+func New(w io.WriteCloser, h Header) (*Recorder, error) {
+	h.Version = 2
+	if h.Timestamp == 0 {
+		h.Timestamp = time.Now().Unix()
+	}
+	line, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{w: w, start: time.Now(), outputLimit: MaxOutputBytes}
+	if err := r.writeLine(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Output enregistre une sortie du terminal
+func (r *Recorder) Output(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.truncated {
+		return
+	}
+
+	data := append(r.pending, p...)
+	// Ne pas couper un caractère multi-octets entre deux lectures
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return
+	}
+
+	r.outputBytes += int64(cut)
+	r.event(EventOutput, string(data[:cut]))
+	if r.outputBytes > r.outputLimit {
+		r.truncated = true
+		r.event(EventMarker, "sortie tronquée: limite d'enregistrement atteinte")
+	}
+}
+
+// Input enregistre une saisie de l'utilisateur
+func (r *Recorder) Input(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.event(EventInput, s)
+	}
+}
+
+// Resize enregistre un redimensionnement du terminal
+func (r *Recorder) Resize(cols, rows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.event(EventResize, strconv.Itoa(cols)+"x"+strconv.Itoa(rows))
+	}
+}
+
+// Marker enregistre un marqueur (arrivée d'un observateur, passage de contrôle, ...)
+func (r *Recorder) Marker(label string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.event(EventMarker, label)
+	}
+}
+
+// Close termine l'enregistrement et retourne la première erreur d'écriture rencontrée
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.err
+	}
+	if len(r.pending) > 0 && !r.truncated {
+		r.event(EventOutput, string(r.pending))
+		r.pending = nil
+	}
+	r.closed = true
+	if err := r.w.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// Duration retourne la durée écoulée depuis le début de l'enregistrement
+func (r *Recorder) Duration() time.Duration {
+	return time.Since(r.start)
+}
+
+// Size retourne le nombre d'octets écrits
+func (r *Recorder) Size() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// event écrit une ligne [temps, type, données] (verrou détenu)
+func (r *Recorder) event(kind, data string) {
+	payload, err := json.Marshal([]interface{}{
+		json.Number(strconv.FormatFloat(time.Since(r.start).Seconds(), 'f', 6, 64)),
+		kind,
+		data,
+	})
+	if err != nil {
+		return
+	}
+	r.writeLine(append(payload, '\n'))
+}
+
+func (r *Recorder) writeLine(line []byte) error {
+	n, err := r.w.Write(line)
+	r.size += int64(n)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readCast relit un fichier asciicast: en-tête puis événements [temps, type, données]
+func readCast(t *testing.T, path string) (Header, [][3]interface{}) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+	var h Header
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &h))
+
+	var events [][3]interface{}
+	for scanner.Scan() {
+		var e [3]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	return h, events
+}
+
+func TestRecorder_Asciicast(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "abc.cast")
+	rec, err := Create(path, Header{Width: 80, Height: 24, Title: "admin@srv-1", Env: map[string]string{"TERM": "xterm-256color"}})
+	require.NoError(t, err)
+
+	rec.Resize(120, 40)
+	rec.Input("ls\r")
+	rec.Output([]byte("fichier.txt\r\n"))
+	// "é" coupé entre deux lectures SSH
+	rec.Output([]byte("caf\xc3"))
+	rec.Output([]byte("\xa9\r\n"))
+	rec.Marker("contrôle: bob")
+	require.NoError(t, rec.Close())
+	assert.Greater(t, rec.Size(), int64(0))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	h, events := readCast(t, path)
+	assert.Equal(t, 2, h.Version)
+	assert.Equal(t, 80, h.Width)
+	assert.Equal(t, "admin@srv-1", h.Title)
+	assert.NotZero(t, h.Timestamp)
+
+	require.Len(t, events, 6)
+	assert.Equal(t, []interface{}{"r", "120x40"}, events[0][1:])
+	assert.Equal(t, []interface{}{"i", "ls\r"}, events[1][1:])
+	assert.Equal(t, []interface{}{"o", "caf"}, events[3][1:])
+	assert.Equal(t, []interface{}{"o", "é\r\n"}, events[4][1:])
+	assert.Equal(t, []interface{}{"m", "contrôle: bob"}, events[5][1:])
+
+	// Horodatages croissants
+	for i := 1; i < len(events); i++ {
+		assert.GreaterOrEqual(t, events[i][0].(float64), events[i-1][0].(float64))
+	}
+
+	// Un identifiant ne peut pas écraser un enregistrement existant
+	_, err = Create(path, Header{Width: 80, Height: 24})
+	assert.Error(t, err)
+}
+
+func TestRecorder_OutputLimitKeepsInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limit.cast")
+	rec, err := Create(path, Header{Width: 80, Height: 24})
+	require.NoError(t, err)
+	rec.outputLimit = 10
+
+	rec.Output([]byte(strings.Repeat("x", 20)))
+	rec.Output([]byte("ignoré"))
+	rec.Input("rm -rf /tmp/preuve\r")
+	require.NoError(t, rec.Close())
+
+	_, events := readCast(t, path)
+	require.Len(t, events, 3)
+	assert.Equal(t, "o", events[0][1])
+	assert.Equal(t, "m", events[1][1])
+	assert.Equal(t, []interface{}{"i", "rm -rf /tmp/preuve\r"}, events[2][1:])
+}
+
+func TestNewID(t *testing.T) {
+	a, err := NewID()
+	require.NoError(t, err)
+	b, _ := NewID()
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
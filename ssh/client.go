@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -9,6 +10,7 @@ import (
 
 	"go-monitoring/config"
 
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -173,6 +175,105 @@ func (c *Client) Execute(cmd string) (string, error) {
 	return stdout.String(), nil
 }
 
+// CommandResult contient le résultat détaillé d'une commande distante
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// ErrCommandTimeout indique que la commande a dépassé le délai imparti
+var ErrCommandTimeout = errors.New("délai d'exécution dépassé")
+
+// Attente de la fin des copies de sortie après l'arrêt d'une commande en timeout
+const killGracePeriod = 2 * time.Second
+
+// lockedBuffer est un tampon de sortie lisible pendant que la session SSH y écrit encore
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// ExecuteWithResult exécute une commande et retourne stdout, stderr et le code de sortie.
+// Contrairement à Execute, un code de sortie non nul n'est pas une erreur: seuls les
+// problèmes de connexion, de session ou de timeout sont remontés.
+// Un timeout <= 0 désactive la limite.
+func (c *Client) ExecuteWithResult(cmd string, timeout time.Duration) (*CommandResult, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("erreur création session: %w", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr lockedBuffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	start := time.Now()
+	if err := session.Start(cmd); err != nil {
+		return nil, fmt.Errorf("erreur démarrage commande: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	select {
+	case err = <-done:
+	case <-timer:
+		// Tenter d'arrêter le processus distant puis fermer le canal ; les copies de sortie
+		// se terminent avec Wait (tampons protégés si elles ne s'arrêtent pas à temps)
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		select {
+		case <-done:
+		case <-time.After(killGracePeriod):
+		}
+		return &CommandResult{
+			Stdout:   stdout.String(),
+			Stderr:   stderr.String(),
+			ExitCode: -1,
+			Duration: time.Since(start),
+		}, ErrCommandTimeout
+	}
+
+	result := &CommandResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitStatus()
+			return result, nil
+		}
+		return result, fmt.Errorf("erreur exécution commande: %w", err)
+	}
+
+	return result, nil
+}
+
 // NewSession crée une nouvelle session SSH interactive
 func (c *Client) NewSession() (*ssh.Session, error) {
 	c.mu.Lock()
@@ -185,6 +286,19 @@ func (c *Client) NewSession() (*ssh.Session, error) {
 	return c.client.NewSession()
 }
 
+// NewSFTP ouvre une session SFTP sur la connexion (sous-système "sftp", y compris OpenSSH pour Windows).
+// L'appelant doit fermer le client SFTP ; la connexion SSH reste ouverte.
+func (c *Client) NewSFTP() (*sftp.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.connectLocked(); err != nil {
+		return nil, err
+	}
+
+	return sftp.NewClient(c.client)
+}
+
 // IsConnected vérifie si le client est connecté
 func (c *Client) IsConnected() bool {
 	c.mu.Lock()
@@ -1,11 +1,16 @@
 package ssh
 
-import "golang.org/x/crypto/ssh"
+import (
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
 
 // SSHExecutor définit l'interface minimale pour exécuter des commandes SSH
 // Cette interface permet l'utilisation de mocks dans les tests
 type SSHExecutor interface {
 	Execute(cmd string) (string, error)
+	ExecuteWithResult(cmd string, timeout time.Duration) (*CommandResult, error)
 	Connect() error
 	IsConnected() bool
 	Close() error
@@ -0,0 +1,27 @@
+package ssh
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// La sortie d'une commande en timeout est lue pendant que la session peut encore écrire
+func TestLockedBuffer_ConcurrentReadWrite(t *testing.T) {
+	var b lockedBuffer
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			b.Write([]byte("x"))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = b.String()
+	}
+	wg.Wait()
+	assert.Equal(t, strings.Repeat("x", 1000), b.String())
+}
@@ -3,6 +3,7 @@ package ssh
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"go-monitoring/config"
 
@@ -12,8 +13,9 @@ import (
 // MockClient est un client SSH simulé pour les tests
 type MockClient struct {
 	// Commandes enregistrées et leurs réponses
-	Commands map[string]string // cmd -> output
-	Errors   map[string]error  // cmd -> error
+	Commands  map[string]string // cmd -> output
+	Errors    map[string]error  // cmd -> error
+	ExitCodes map[string]int    // cmd -> code de sortie (ExecuteWithResult)
 
 	// Historique des commandes exécutées
 	ExecutedCommands []string
@@ -38,6 +40,7 @@ func NewMockClient(machineConfig *config.MachineConfig) *MockClient {
 	return &MockClient{
 		Commands:         make(map[string]string),
 		Errors:           make(map[string]error),
+		ExitCodes:        make(map[string]int),
 		ExecutedCommands: []string{},
 		connected:        false,
 		config:           machineConfig,
@@ -99,6 +102,13 @@ func (m *MockClient) SetError(cmd string, err error) {
 	m.Errors[cmd] = err
 }
 
+// SetExitCode enregistre le code de sortie retourné par ExecuteWithResult
+func (m *MockClient) SetExitCode(cmd string, code int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ExitCodes[cmd] = code
+}
+
 // SetResponseMap enregistre plusieurs réponses à la fois
 func (m *MockClient) SetResponseMap(responses map[string]string) {
 	m.mu.Lock()
@@ -149,6 +159,30 @@ func (m *MockClient) Execute(cmd string) (string, error) {
 	return "", fmt.Errorf("mock: no response registered for command: %s", cmd)
 }
 
+// ExecuteWithResult simule l'exécution d'une commande avec code de sortie
+func (m *MockClient) ExecuteWithResult(cmd string, timeout time.Duration) (*CommandResult, error) {
+	if err := m.Connect(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ExecutedCommands = append(m.ExecutedCommands, cmd)
+
+	if err, exists := m.Errors[cmd]; exists {
+		return nil, err
+	}
+
+	output, exists := m.Commands[cmd]
+	code, hasCode := m.ExitCodes[cmd]
+	if !exists && !hasCode {
+		return nil, fmt.Errorf("mock: no response registered for command: %s", cmd)
+	}
+
+	return &CommandResult{Stdout: output, ExitCode: code}, nil
+}
+
 // NewSession simule la création d'une session SSH
 func (m *MockClient) NewSession() (*ssh.Session, error) {
 	m.mu.Lock()
@@ -194,6 +228,7 @@ func (m *MockClient) Reset() {
 	defer m.mu.Unlock()
 	m.Commands = make(map[string]string)
 	m.Errors = make(map[string]error)
+	m.ExitCodes = make(map[string]int)
 	m.ExecutedCommands = []string{}
 	m.connected = false
 }
@@ -214,13 +249,13 @@ func NewMockClientLinux() *MockClient {
 	// Commandes par défaut pour Linux
 	client.SetResponseMap(map[string]string{
 		"cat /proc/cpuinfo | grep 'model name' | head -1 | cut -d':' -f2": "Intel(R) Core(TM) i7-9700K CPU @ 3.60GHz",
-		"grep -c ^processor /proc/cpuinfo":                                 "8",
-		"lscpu | grep '^CPU(s):' | awk '{print $2}'":                       "8",
-		"cat /proc/cpuinfo | grep 'cpu MHz' | head -1 | cut -d':' -f2":     "3600.000",
-		"top -bn1 | grep 'Cpu(s)' | head -1":                               "Cpu(s):  5.2 us,  2.1 sy,  0.0 ni, 92.7 id,  0.0 wa,  0.0 hi,  0.0 si,  0.0 st",
-		"free -b | grep Mem":                                               "Mem:   16777216000   8388608000   2097152000   104857600   6291456000   7340032000",
-		"df -B1 -T | tail -n +2":                                           "/dev/sda1      ext4  107374182400  53687091200  48339148800   53% /",
-		"systemctl is-active nginx apache2 mysql || true":                  "active\ninactive\nactive",
+		"grep -c ^processor /proc/cpuinfo":                                "8",
+		"lscpu | grep '^CPU(s):' | awk '{print $2}'":                      "8",
+		"cat /proc/cpuinfo | grep 'cpu MHz' | head -1 | cut -d':' -f2":    "3600.000",
+		"top -bn1 | grep 'Cpu(s)' | head -1":                              "Cpu(s):  5.2 us,  2.1 sy,  0.0 ni, 92.7 id,  0.0 wa,  0.0 hi,  0.0 si,  0.0 st",
+		"free -b | grep Mem":                                              "Mem:   16777216000   8388608000   2097152000   104857600   6291456000   7340032000",
+		"df -B1 -T | tail -n +2":                                          "/dev/sda1      ext4  107374182400  53687091200  48339148800   53% /",
+		"systemctl is-active nginx apache2 mysql || true":                 "active\ninactive\nactive",
 	})
 
 	return client
@@ -239,12 +274,12 @@ func NewMockClientWindows() *MockClient {
 
 	// Commandes par défaut pour Windows
 	client.SetResponseMap(map[string]string{
-		`powershell -Command "(Get-CimInstance Win32_Processor).Name"`:                    "Intel(R) Core(TM) i7-9700K CPU @ 3.60GHz",
-		`powershell -Command "(Get-CimInstance Win32_Processor).NumberOfCores"`:           "8",
-		`powershell -Command "(Get-CimInstance Win32_Processor).NumberOfLogicalProcessors"`: "8",
-		`powershell -Command "(Get-CimInstance Win32_Processor).MaxClockSpeed"`:           "3600",
-		`powershell -Command "(Get-CimInstance Win32_Processor).LoadPercentage"`:          "15.5",
-		`powershell -Command "$os = Get-CimInstance Win32_OperatingSystem; Write-Output ('{0}|{1}' -f ($os.TotalVisibleMemorySize * 1KB), ($os.FreePhysicalMemory * 1KB))"`: "17179869184|5368709120",
+		`powershell -Command "(Get-CimInstance Win32_Processor).Name"`:                                                                                                                                          "Intel(R) Core(TM) i7-9700K CPU @ 3.60GHz",
+		`powershell -Command "(Get-CimInstance Win32_Processor).NumberOfCores"`:                                                                                                                                 "8",
+		`powershell -Command "(Get-CimInstance Win32_Processor).NumberOfLogicalProcessors"`:                                                                                                                     "8",
+		`powershell -Command "(Get-CimInstance Win32_Processor).MaxClockSpeed"`:                                                                                                                                 "3600",
+		`powershell -Command "(Get-CimInstance Win32_Processor).LoadPercentage"`:                                                                                                                                "15.5",
+		`powershell -Command "$os = Get-CimInstance Win32_OperatingSystem; Write-Output ('{0}|{1}' -f ($os.TotalVisibleMemorySize * 1KB), ($os.FreePhysicalMemory * 1KB))"`:                                     "17179869184|5368709120",
 		`powershell -Command "Get-CimInstance Win32_LogicalDisk | Where-Object {$_.DriveType -eq 3} | ForEach-Object { Write-Output ('{0}|{1}|{2}|{3}' -f $_.DeviceID, $_.Size, $_.FreeSpace, $_.MediaType) }"`: "C:|107374182400|53687091200|SSD",
 	})
 
@@ -0,0 +1,99 @@
+// Lecteur d'enregistrements asciicast v2 (sessions du terminal web)
+// Les saisies ("i") ne sont pas rejouées : leur écho figure déjà dans la sortie.
+class CastPlayer {
+    constructor(term) {
+        this.term = term;
+        this.header = null;
+        this.events = [];
+        this.duration = 0;
+        this.index = 0;
+        this.elapsed = 0;
+        this.speed = 1;
+        this.timer = null;
+        this.onprogress = null;
+    }
+
+    load(text) {
+        const lines = text.split('\n').filter(l => l.trim() !== '');
+        if (lines.length === 0) throw new Error('Enregistrement vide');
+
+        this.header = JSON.parse(lines[0]);
+        if (this.header.version !== 2) throw new Error('Format asciicast non supporté');
+
+        this.events = [];
+        for (const line of lines.slice(1)) {
+            try {
+                const [t, kind, data] = JSON.parse(line);
+                this.events.push({ t: Number(t), kind, data });
+            } catch (e) {
+                // Dernière ligne incomplète (session en cours d'écriture)
+            }
+        }
+        this.duration = this.events.length ? this.events[this.events.length - 1].t : 0;
+        this.reset();
+    }
+
+    reset() {
+        this.index = 0;
+        this.elapsed = 0;
+        this.term.reset();
+        this.term.resize(this.header.width || 80, this.header.height || 24);
+    }
+
+    apply(ev) {
+        switch (ev.kind) {
+            case 'o':
+                this.term.write(ev.data);
+                break;
+            case 'r': {
+                const [cols, rows] = String(ev.data).split('x').map(Number);
+                if (cols > 0 && rows > 0) this.term.resize(cols, rows);
+                break;
+            }
+            case 'm':
+                this.term.write(`\r\n\x1b[33m[${ev.data}]\x1b[0m\r\n`);
+                break;
+        }
+    }
+
+    advance() {
+        while (this.index < this.events.length && this.events[this.index].t <= this.elapsed) {
+            this.apply(this.events[this.index]);
+            this.index++;
+        }
+        if (this.onprogress) this.onprogress(this.elapsed, this.duration);
+    }
+
+    // Revenir en arrière impose de rejouer depuis le début
+    seek(t) {
+        t = Math.max(0, Math.min(t, this.duration));
+        if (t < this.elapsed) this.reset();
+        this.elapsed = t;
+        this.advance();
+    }
+
+    get playing() {
+        return this.timer !== null;
+    }
+
+    play() {
+        if (this.playing || !this.header) return;
+        if (this.index >= this.events.length) this.reset();
+        this.last = performance.now();
+        this.timer = setInterval(() => this.tick(), 30);
+    }
+
+    pause() {
+        clearInterval(this.timer);
+        this.timer = null;
+        if (this.onprogress) this.onprogress(this.elapsed, this.duration);
+    }
+
+    tick() {
+        const now = performance.now();
+        this.elapsed = Math.min(this.elapsed + (now - this.last) / 1000 * this.speed, this.duration);
+        this.last = now;
+        this.advance();
+        if (this.index >= this.events.length) this.pause();
+    }
+}
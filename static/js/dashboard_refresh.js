@@ -22,12 +22,19 @@ function initWebSocket() {
         // Supprimer la notification de déconnexion si elle existe
         const disconnectNotif = document.querySelector('.ws-disconnect-notification');
         if (disconnectNotif) disconnectNotif.remove();
+
+        // Abonnement au résumé de toutes les machines visibles
+        ws.send(JSON.stringify({ type: 'subscribe', topic: 'machines' }));
     };
 
     ws.onmessage = (event) => {
         try {
-            const machines = JSON.parse(event.data);
-            updateDashboardUI(machines);
+            const frame = JSON.parse(event.data);
+            if (frame.type === 'update') {
+                updateDashboardUI(frame.machines || [], frame.removed || []);
+            } else if (frame.type === 'error') {
+                console.error("Erreur WS:", frame.error);
+            }
         } catch (e) {
             console.error("Erreur parsing WS", e);
         }
@@ -170,15 +177,54 @@ function showDisconnectedNotification() {
     document.body.appendChild(notification);
 }
 
-function updateDashboardUI(machines) {
+let reloadTimer = null;
+
+/**
+ * Recharge la page (une seule fois) quand la structure d'une carte change :
+ * machine ajoutée, passage en ligne / hors ligne
+ */
+function scheduleReload() {
+    if (reloadTimer) return;
+    reloadTimer = setTimeout(() => window.location.reload(), 2000);
+}
+
+/**
+ * Met à jour une barre de progression et sa valeur
+ */
+function updateMetric(block, value) {
+    if (!block) return;
+    const text = block.querySelector('.metric-val');
+    const bar = block.querySelector('.progress-fill');
+    if (text) text.textContent = `${value.toFixed(0)}%`;
+    if (bar) {
+        bar.style.width = `${value.toFixed(0)}%`;
+        bar.className = 'progress-fill';
+        if (value >= 90) bar.classList.add('danger');
+        else if (value >= 75) bar.classList.add('warning');
+    }
+}
+
+/**
+ * Applique une trame update : seules les machines modifiées sont reçues
+ */
+function updateDashboardUI(machines, removed) {
+    removed.forEach(id => {
+        const card = document.getElementById(`card-${id}`);
+        if (card) card.remove();
+    });
+
     machines.forEach(m => {
         const card = document.getElementById(`card-${m.id}`);
-        if (!card) return;
+        if (!card) {
+            scheduleReload();
+            return;
+        }
 
-        // Vérifier si le statut a changé pour l'animation
-        const previousStatus = card.classList.contains('status-online') ? 'online' :
-            card.classList.contains('status-offline') ? 'offline' : 'unknown';
-        const statusChanged = previousStatus !== m.status;
+        // Le contenu d'une carte hors ligne diffère (pas de métriques) : rechargement
+        const wasOnline = card.classList.contains('status-online');
+        if (wasOnline !== (m.status === 'online')) {
+            scheduleReload();
+        }
 
         // Mise à jour de la classe de statut
         card.classList.forEach(cls => {
@@ -188,46 +234,11 @@ function updateDashboardUI(machines) {
         });
         card.classList.add(`status-${m.status}`);
 
-        // Animation de transition si le statut a changé
-        if (statusChanged) {
-            card.classList.add('fade-in');
-            setTimeout(() => card.classList.remove('fade-in'), 300);
-        }
-
-        // Mise à jour des métriques si présentes
+        // Métriques : CPU puis RAM
         if (m.status === 'online') {
-            const metricsDiv = card.querySelector('.machine-metrics');
-            if (metricsDiv) {
-                // CPU
-                if (m.cpu) {
-                    const cpuBar = metricsDiv.querySelector('.metric-cpu .progress-fill');
-                    const cpuText = metricsDiv.querySelector('.metric-cpu .metric-value');
-                    if (cpuBar && cpuText) {
-                        const cpuVal = m.cpu.usage_percent;
-                        cpuBar.style.width = `${cpuVal.toFixed(1)}%`;
-                        cpuText.textContent = `${cpuVal.toFixed(1)}%`;
-
-                        cpuBar.className = 'progress-fill';
-                        if (cpuVal > 80) cpuBar.classList.add('danger');
-                        else if (cpuVal > 60) cpuBar.classList.add('warning');
-                    }
-                }
-
-                // RAM
-                if (m.memory) {
-                    const memBar = metricsDiv.querySelector('.metric-mem .progress-fill');
-                    const memText = metricsDiv.querySelector('.metric-mem .metric-value');
-                    if (memBar && memText) {
-                        const memVal = m.memory.used_percent;
-                        memBar.style.width = `${memVal.toFixed(1)}%`;
-                        memText.textContent = `${memVal.toFixed(1)}%`;
-
-                        memBar.className = 'progress-fill';
-                        if (memVal > 80) memBar.classList.add('danger');
-                        else if (memVal > 60) memBar.classList.add('warning');
-                    }
-                }
-            }
+            const blocks = card.querySelectorAll('.metric-compact');
+            updateMetric(blocks[0], m.cpu_percent);
+            updateMetric(blocks[1], m.memory_percent);
         }
     });
 
@@ -76,14 +76,20 @@ function renderDirectory(listing) {
         const onClick = entry.IsDir
             ? `onclick="navigateTo(this.dataset.path)"`
             : '';
+        const path = escapeHtml(entry.Path);
+        const actions = entry.IsDir ? '' : `
+                    <button title="Aperçu" data-path="${path}" onclick="previewFile(this.dataset.path)">👁</button>
+                    <a title="Télécharger" href="${fileUrl('download', entry.Path)}" download>⬇</a>`;
 
         html += `
-            <div class="file-entry ${className}" ${entry.IsDir ? `data-path="${escapeHtml(entry.Path)}" ${onClick}` : ''}>
+            <div class="file-entry ${className}" ${entry.IsDir ? `data-path="${path}" ${onClick}` : ''}>
                 <span class="file-icon">${icon}</span>
                 <span class="file-name">${escapeHtml(entry.Name)}</span>
                 <span class="file-size">${size}</span>
+                <span class="file-mtime">${formatDate(entry.ModTime)}</span>
                 <span class="file-perms">${entry.Permissions || '-'}</span>
-                <span class="file-owner">${entry.Owner || '-'}:${entry.Group || '-'}</span>
+                <span class="file-owner">${escapeHtml(entry.Owner || '-')}:${escapeHtml(entry.Group || '-')}</span>
+                <span class="file-actions">${actions}</span>
             </div>
         `;
     }
@@ -91,6 +97,166 @@ function renderDirectory(listing) {
     fileList.innerHTML = html;
 }
 
+// URL d'une opération sur un fichier (download, preview)
+function fileUrl(action, path) {
+    return `/api/machine/${machineId}/files/${action}?path=${encodeURIComponent(path)}`;
+}
+
+// Affiche l'aperçu texte d'un fichier
+async function previewFile(path) {
+    const panel = document.getElementById('file-preview');
+    const name = document.getElementById('file-preview-name');
+    const language = document.getElementById('file-preview-language');
+    const content = document.getElementById('file-preview-content');
+
+    panel.hidden = false;
+    name.textContent = path;
+    language.textContent = '';
+    content.className = '';
+    content.textContent = 'Chargement...';
+
+    try {
+        const response = await fetch(fileUrl('preview', path));
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Erreur inconnue');
+        }
+        language.textContent = data.Language;
+        content.className = `language-${data.Language}`;
+        content.textContent = data.Content;
+    } catch (error) {
+        content.textContent = `Erreur: ${error.message}`;
+    }
+    panel.scrollIntoView({ behavior: 'smooth' });
+}
+
+function closePreview() {
+    document.getElementById('file-preview').hidden = true;
+}
+
+// Envoie un fichier dans le répertoire courant (administrateurs)
+async function uploadFile(event) {
+    event.preventDefault();
+    const input = document.getElementById('file-upload-input');
+    if (!input.files.length) return;
+
+    // Les champs doivent précéder le fichier dans le formulaire
+    const form = new FormData();
+    form.append('path', currentPath);
+    form.append('overwrite', document.getElementById('file-upload-overwrite').checked ? 'true' : 'false');
+    form.append('file', input.files[0]);
+
+    try {
+        const response = await fetch(`/api/machine/${machineId}/files/upload`, { method: 'POST', body: form });
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Erreur inconnue');
+        }
+        input.value = '';
+        loadDirectory(currentPath);
+    } catch (error) {
+        await dialog.alert(error.message, { title: 'Envoi impossible', type: 'error' });
+    }
+}
+
+// Analyse d'occupation disque : arbre courant et chemin affiché
+let diskUsageRoot = '/';
+let diskUsageTree = null;
+let diskUsageTrail = [];
+
+// Lance (ou récupère en cache) l'analyse d'occupation sous un chemin
+async function analyzeDiskUsage(path, refresh = false) {
+    const section = document.getElementById('disk-usage-section');
+    const status = document.getElementById('disk-usage-status');
+    const list = document.getElementById('disk-usage-list');
+    const depth = document.getElementById('disk-usage-depth').value;
+
+    diskUsageRoot = path;
+    section.style.display = 'block';
+    status.textContent = 'Analyse en cours (peut prendre jusqu\'à une minute)...';
+    list.innerHTML = '<div class="loading"><div class="spinner"></div></div>';
+    section.scrollIntoView({ behavior: 'smooth' });
+
+    try {
+        const params = new URLSearchParams({ path, depth });
+        if (refresh) params.set('refresh', '1');
+        const response = await fetch(`/api/machine/${machineId}/disk/usage?${params}`);
+        const report = await response.json();
+        if (!response.ok) {
+            throw new Error(report.error || 'Erreur inconnue');
+        }
+
+        const when = new Date(report.GeneratedAt).toLocaleString('fr-FR');
+        let text = `Analyse du ${when}${report.Cached ? ' (en cache)' : ''}`;
+        if (report.Warning) text += ` — ${report.Warning}`;
+        status.textContent = text;
+
+        diskUsageTree = report.Tree;
+        diskUsageTrail = [report.Tree];
+        renderDiskUsage();
+    } catch (error) {
+        status.textContent = '';
+        list.innerHTML = `<div class="file-entry" style="justify-content: center; color: #e17055;">Erreur: ${escapeHtml(error.message)}</div>`;
+    }
+}
+
+// Affiche les sous-répertoires du nœud courant, proportionnellement à sa taille
+function renderDiskUsage() {
+    const list = document.getElementById('disk-usage-list');
+    const breadcrumb = document.getElementById('disk-usage-breadcrumb');
+    const node = diskUsageTrail[diskUsageTrail.length - 1];
+
+    breadcrumb.innerHTML = diskUsageTrail.map((n, i) =>
+        `<a href="#" onclick="diskUsageUp(${i}); return false;">${escapeHtml(i === 0 ? n.Path : n.Name)}</a>`
+    ).join(' / ');
+
+    const rows = (node.Children || []).map((child, i) => ({
+        label: child.Name, size: child.Size, index: i, node: child,
+    }));
+    const childrenTotal = rows.reduce((sum, r) => sum + r.size, 0) + (node.OtherSize || 0);
+    if (node.OtherCount) {
+        rows.push({ label: `${node.OtherCount} autres répertoires`, size: node.OtherSize });
+    }
+    const files = node.Size - childrenTotal;
+    if (files > 0) {
+        rows.push({ label: 'Fichiers', size: files });
+    }
+    rows.sort((a, b) => b.size - a.size);
+
+    if (rows.length === 0) {
+        list.innerHTML = '<div class="file-entry" style="justify-content: center; color: #636e72;">Répertoire vide</div>';
+        return;
+    }
+
+    list.innerHTML = rows.map(row => {
+        const pct = node.Size > 0 ? (row.size / node.Size) * 100 : 0;
+        const drill = row.node && row.node.Children && row.node.Children.length > 0;
+        const label = row.node
+            ? `<a href="#" onclick="${drill ? `diskUsageDown(${row.index})` : `browseDisk(this.dataset.path)`}; return false;" data-path="${escapeHtml(row.node.Path)}">${escapeHtml(row.label)}</a>`
+            : `<span class="text-muted">${escapeHtml(row.label)}</span>`;
+        return `
+            <div class="disk-usage-row">
+                <span class="disk-usage-name">${label}</span>
+                <div class="disk-usage-bar"><div class="disk-usage-fill" style="width: ${pct.toFixed(1)}%"></div></div>
+                <span class="disk-usage-size">${formatSize(row.size)}</span>
+                <span class="disk-usage-pct">${pct.toFixed(1)}%</span>
+                ${row.node ? `<button class="btn-text-action" data-path="${escapeHtml(row.node.Path)}" onclick="browseDisk(this.dataset.path)">Parcourir</button>` : '<span></span>'}
+            </div>
+        `;
+    }).join('');
+}
+
+function diskUsageDown(index) {
+    const node = diskUsageTrail[diskUsageTrail.length - 1];
+    diskUsageTrail.push(node.Children[index]);
+    renderDiskUsage();
+}
+
+function diskUsageUp(level) {
+    diskUsageTrail = diskUsageTrail.slice(0, level + 1);
+    renderDiskUsage();
+}
+
 // Navigation vers un sous-répertoire
 function navigateTo(path) {
     pathHistory.push(path);
@@ -115,6 +281,14 @@ function formatSize(bytes) {
     return parseFloat((bytes / Math.pow(k, i)).toFixed(1)) + ' ' + sizes[i];
 }
 
+// Formate une date de modification
+function formatDate(value) {
+    if (!value) return '-';
+    const date = new Date(value);
+    if (isNaN(date) || date.getFullYear() < 1971) return '-';
+    return date.toLocaleString('fr-FR', { dateStyle: 'short', timeStyle: 'short' });
+}
+
 // Retourne une icône basée sur l'extension du fichier
 function getFileIcon(filename) {
     const ext = filename.split('.').pop().toLowerCase();
@@ -1,40 +1,339 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
-// LogAction enregistre une action utilisateur
+// Sérialise les ajouts au journal : chaque ligne référence le hash de la précédente
+var auditMu sync.Mutex
+
+// AuditFilter restreint la recherche dans le journal d'audit (champs vides ignorés)
+type AuditFilter struct {
+	User   string
+	Action string
+	Target string // Sous-chaîne de la cible
+	Since  time.Time
+	Until  time.Time
+	Limit  int // 0 : pas de limite
+	Offset int
+}
+
+// AuditVerification est le résultat de la vérification de la chaîne de hash
+type AuditVerification struct {
+	Valid     bool   `json:"valid"`
+	Checked   int    `json:"checked"`   // Lignes chaînées vérifiées
+	Unchained int    `json:"unchained"` // Lignes antérieures à la chaîne (sans hash, jusqu'à chain_start)
+	BrokenID  int64  `json:"broken_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	LastID    int64  `json:"last_id,omitempty"`
+	LastHash  string `json:"last_hash,omitempty"` // À conserver hors de la base pour détecter une troncature
+}
+
+// auditHash calcule le hash d'une ligne à partir du hash précédent et de son contenu
+func auditHash(prev string, l AuditLog) string {
+	fields, _ := json.Marshal([]string{
+		prev, l.Timestamp.UTC().Format(time.RFC3339Nano), l.User, l.Action, l.Target, l.Details, l.IPAddress,
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// SetAuditHook définit la fonction appelée après chaque ajout au journal (transfert vers un SIEM).
+// Elle est appelée dans l'ordre de la chaîne et ne doit pas bloquer.
+func (db *DB) SetAuditHook(fn func(AuditLog)) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	db.auditHook = fn
+}
+
+// LogAction enregistre une action utilisateur à la suite de la chaîne d'intégrité
 func (db *DB) LogAction(user, action, target, details, ip string) error {
-	query := `INSERT INTO audit_logs (timestamp, user, action, target, details, ip_address) 
-			  VALUES (?, ?, ?, ?, ?, ?)`
-	_, err := db.Exec(query, time.Now(), user, action, target, details, ip)
+	return db.RecordAudit(AuditLog{
+		User:      user,
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		IPAddress: ip,
+	})
+}
+
+// RecordAudit enregistre une entrée horodatée maintenant à la suite de la chaîne d'intégrité,
+// puis la transmet au hook d'audit avec son identifiant et son hash
+func (db *DB) RecordAudit(l AuditLog) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	l.Timestamp = time.Now().UTC()
+	l, err := db.appendAuditLog(l)
 	if err != nil {
 		log.Printf("Erreur audit log: %v", err)
+		return err
+	}
+	if db.auditHook != nil {
+		db.auditHook(l)
 	}
-	return err
+	return nil
 }
 
-// GetAuditLogs récupère les logs d'audit récents
-func (db *DB) GetAuditLogs(limit int) ([]AuditLog, error) {
-	query := `SELECT id, timestamp, user, action, target, details, ip_address 
-			  FROM audit_logs ORDER BY timestamp DESC LIMIT ?`
+func (db *DB) appendAuditLog(l AuditLog) (AuditLog, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return l, err
+	}
+	defer tx.Rollback()
 
-	rows, err := db.Query(query, limit)
+	// Dernier hash de la table, ou celui de l'ancrage si tout a été purgé
+	var prev string
+	err = tx.QueryRow("SELECT hash FROM audit_logs ORDER BY id DESC LIMIT 1").Scan(&prev)
+	if err == sql.ErrNoRows {
+		prev, err = auditAnchor(tx)
+	}
 	if err != nil {
-		return nil, err
+		return l, err
+	}
+
+	l.PrevHash, l.Hash = prev, auditHash(prev, l)
+	res, err := tx.Exec(`INSERT INTO audit_logs (timestamp, user, action, target, details, ip_address, prev_hash, hash)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		l.Timestamp, l.User, l.Action, l.Target, l.Details, l.IPAddress, l.PrevHash, l.Hash)
+	if err != nil {
+		return l, err
+	}
+	if l.ID, err = res.LastInsertId(); err != nil {
+		return l, err
+	}
+	return l, tx.Commit()
+}
+
+// auditAnchor retourne le hash de la dernière ligne purgée ("" sans purge)
+func auditAnchor(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}) (string, error) {
+	var hash string
+	err := q.QueryRow("SELECT hash FROM audit_anchor WHERE id = 1").Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+// auditWhere construit la clause WHERE d'un filtre
+func auditWhere(f AuditFilter) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if f.User != "" {
+		conds = append(conds, "user = ?")
+		args = append(args, f.User)
+	}
+	if f.Action != "" {
+		conds = append(conds, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.Target != "" {
+		escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+		conds = append(conds, `target LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escaper.Replace(f.Target)+"%")
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "timestamp >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, f.Until.UTC())
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+const auditColumns = "id, timestamp, user, action, COALESCE(target, ''), COALESCE(details, ''), COALESCE(ip_address, ''), prev_hash, hash"
+
+func scanAuditLog(rows *sql.Rows) (AuditLog, error) {
+	var l AuditLog
+	err := rows.Scan(&l.ID, &l.Timestamp, &l.User, &l.Action, &l.Target, &l.Details, &l.IPAddress, &l.PrevHash, &l.Hash)
+	return l, err
+}
+
+// SearchAuditLogs retourne une page des logs filtrés (plus récents d'abord) et le nombre total de résultats
+func (db *DB) SearchAuditLogs(f AuditFilter) ([]AuditLog, int, error) {
+	where, args := auditWhere(f)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_logs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_logs" + where + " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var logs []AuditLog
 	for rows.Next() {
-		var l AuditLog
-		if err := rows.Scan(&l.ID, &l.Timestamp, &l.User, &l.Action, &l.Target, &l.Details, &l.IPAddress); err != nil {
+		l, err := scanAuditLog(rows)
+		if err != nil {
 			log.Printf("Erreur scan audit: %v", err)
 			continue
 		}
 		logs = append(logs, l)
 	}
-	return logs, nil
+	return logs, total, rows.Err()
+}
+
+// GetAuditLogs récupère les logs d'audit récents
+func (db *DB) GetAuditLogs(limit int) ([]AuditLog, error) {
+	logs, _, err := db.SearchAuditLogs(AuditFilter{Limit: limit})
+	return logs, err
+}
+
+// EachAuditLog parcourt les logs filtrés dans l'ordre chronologique (export)
+func (db *DB) EachAuditLog(f AuditFilter, fn func(AuditLog) error) error {
+	where, args := auditWhere(f)
+	rows, err := db.Query("SELECT "+auditColumns+" FROM audit_logs"+where+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := scanAuditLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetAuditFacets retourne les utilisateurs et actions présents dans le journal (listes des filtres)
+func (db *DB) GetAuditFacets() (users, actions []string, err error) {
+	if users, err = db.auditDistinct("user"); err != nil {
+		return nil, nil, err
+	}
+	actions, err = db.auditDistinct("action")
+	return users, actions, err
+}
+
+func (db *DB) auditDistinct(column string) ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT " + column + " FROM audit_logs ORDER BY " + column)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// PurgeAuditLogs supprime les logs antérieurs à before. La suppression porte sur les plus
+// anciennes lignes et le hash de la dernière supprimée est conservé comme ancrage de la chaîne.
+func (db *DB) PurgeAuditLogs(before time.Time) (int64, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var lastID int64
+	var lastHash string
+	err = tx.QueryRow("SELECT id, hash FROM audit_logs WHERE timestamp < ? ORDER BY id DESC LIMIT 1", before.UTC()).
+		Scan(&lastID, &lastHash)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec("DELETE FROM audit_logs WHERE id <= ?", lastID)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`INSERT INTO audit_anchor (id, last_id, hash, purged_at) VALUES (1, ?, ?, ?)
+			  ON CONFLICT(id) DO UPDATE SET last_id = excluded.last_id, hash = excluded.hash, purged_at = excluded.purged_at`,
+		lastID, lastHash, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// VerifyAuditChain recalcule la chaîne de hash et signale la première ligne supprimée, insérée ou modifiée.
+// Les lignes enregistrées avant la mise en place de la chaîne (sans hash, au plus chain_start) sont
+// comptées à part ; une ligne sans hash au-delà est une altération.
+func (db *DB) VerifyAuditChain() (*AuditVerification, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	prev, err := auditAnchor(db)
+	if err != nil {
+		return nil, err
+	}
+	var chainStart int64
+	err = db.QueryRow("SELECT chain_start FROM audit_anchor WHERE id = 1").Scan(&chainStart)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT " + auditColumns + " FROM audit_logs ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	v := &AuditVerification{Valid: true}
+	for rows.Next() {
+		l, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case l.Hash == "" && l.ID <= chainStart && v.Checked == 0:
+			v.Unchained++
+			continue
+		case l.Hash == "":
+			v.Reason = "ligne sans hash dans la chaîne"
+		case l.PrevHash != prev:
+			v.Reason = "chaînage rompu : ligne précédente supprimée ou modifiée"
+		case auditHash(l.PrevHash, l) != l.Hash:
+			v.Reason = "contenu de la ligne modifié"
+		}
+		if v.Reason != "" {
+			v.Valid, v.BrokenID = false, l.ID
+			return v, nil
+		}
+		prev = l.Hash
+		v.Checked++
+		v.LastID, v.LastHash = l.ID, l.Hash
+	}
+	return v, rows.Err()
 }
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"log"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// SaveCheckResult enregistre le résultat d'un check et ses perfdata
+func (db *DB) SaveCheckResult(r models.CheckResult) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO check_results (machine_id, check_name, timestamp, state, output, long_text, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.MachineID, r.Name, r.Timestamp, r.State, r.Output, r.LongText, r.Duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	for _, p := range r.PerfData {
+		_, err = tx.Exec(`INSERT INTO check_perfdata (machine_id, check_name, label, timestamp, value, uom)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			r.MachineID, r.Name, p.Label, r.Timestamp, p.Value, p.UOM)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLatestCheckResults retourne le dernier résultat de chaque check d'une machine
+func (db *DB) GetLatestCheckResults(machineID string) ([]models.CheckResult, error) {
+	query := `SELECT machine_id, check_name, timestamp, state, output, long_text, duration_ms
+			  FROM check_results
+			  WHERE id IN (SELECT MAX(id) FROM check_results WHERE machine_id = ? GROUP BY check_name)
+			  ORDER BY check_name ASC`
+
+	rows, err := db.Query(query, machineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.CheckResult
+	for rows.Next() {
+		var r models.CheckResult
+		var durationMs int64
+		if err := rows.Scan(&r.MachineID, &r.Name, &r.Timestamp, &r.State, &r.Output, &r.LongText, &durationMs); err != nil {
+			log.Printf("Erreur scan check %s: %v", machineID, err)
+			continue
+		}
+		r.Duration = time.Duration(durationMs) * time.Millisecond
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// GetPerfDataHistory retourne l'historique des perfdata d'un check
+func (db *DB) GetPerfDataHistory(machineID, checkName string, duration time.Duration) ([]models.PerfPoint, error) {
+	startTime := time.Now().Add(-duration)
+	query := `SELECT timestamp, label, value, uom FROM check_perfdata
+			  WHERE machine_id = ? AND check_name = ? AND timestamp > ?
+			  ORDER BY timestamp ASC`
+
+	rows, err := db.Query(query, machineID, checkName, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []models.PerfPoint
+	for rows.Next() {
+		var p models.PerfPoint
+		if err := rows.Scan(&p.Timestamp, &p.Label, &p.Value, &p.UOM); err != nil {
+			log.Printf("Erreur scan perfdata %s/%s: %v", machineID, checkName, err)
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
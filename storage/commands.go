@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// CreateCommandRun enregistre le lancement d'une commande (résultats ajoutés au fil de l'eau)
+func (db *DB) CreateCommandRun(run models.CommandRun) error {
+	targets, err := json.Marshal(run.Targets)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO command_runs (id, username, template, command, targets, started_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Username, run.Template, run.Command, string(targets), run.StartedAt)
+	return err
+}
+
+// SaveCommandResult enregistre le résultat d'une machine
+func (db *DB) SaveCommandResult(runID string, r models.CommandHostResult) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO command_results (run_id, machine_id, stdout, stderr, exit_code, error, truncated, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		runID, r.MachineID, r.Stdout, r.Stderr, r.ExitCode, r.Error, r.Truncated, r.DurationMs)
+	return err
+}
+
+// FinishCommandRun marque la fin d'une exécution
+func (db *DB) FinishCommandRun(id string, endedAt time.Time) error {
+	_, err := db.Exec(`UPDATE command_runs SET ended_at = ? WHERE id = ?`, endedAt, id)
+	return err
+}
+
+// GetCommandRun retourne une exécution et ses résultats (nil si elle n'existe pas)
+func (db *DB) GetCommandRun(id string) (*models.CommandRun, error) {
+	runs, err := db.queryCommandRuns(`WHERE id = ?`, id)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	run := &runs[0]
+
+	rows, err := db.Query(`SELECT machine_id, stdout, stderr, exit_code, error, truncated, duration_ms
+		FROM command_results WHERE run_id = ? ORDER BY machine_id ASC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.CommandHostResult
+		var stdout, stderr, errMsg sql.NullString
+		if err := rows.Scan(&r.MachineID, &stdout, &stderr, &r.ExitCode, &errMsg, &r.Truncated, &r.DurationMs); err != nil {
+			log.Printf("Erreur scan résultat de commande: %v", err)
+			continue
+		}
+		r.Stdout, r.Stderr, r.Error = stdout.String, stderr.String, errMsg.String
+		run.Results = append(run.Results, r)
+	}
+	return run, rows.Err()
+}
+
+// ListCommandRuns retourne les exécutions les plus récentes (sans les résultats), filtrables par utilisateur
+func (db *DB) ListCommandRuns(username string, limit int) ([]models.CommandRun, error) {
+	return db.queryCommandRuns(`WHERE (? = '' OR username = ?) ORDER BY started_at DESC LIMIT ?`, username, username, limit)
+}
+
+func (db *DB) queryCommandRuns(where string, args ...interface{}) ([]models.CommandRun, error) {
+	rows, err := db.Query(`SELECT id, username, template, command, targets, started_at, ended_at
+		FROM command_runs `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.CommandRun
+	for rows.Next() {
+		var run models.CommandRun
+		var template sql.NullString
+		var targets string
+		var endedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.Username, &template, &run.Command, &targets, &run.StartedAt, &endedAt); err != nil {
+			log.Printf("Erreur scan exécution de commande: %v", err)
+			continue
+		}
+		run.Template = template.String
+		if err := json.Unmarshal([]byte(targets), &run.Targets); err != nil {
+			log.Printf("Erreur lecture des cibles de %s: %v", run.ID, err)
+		}
+		if endedAt.Valid {
+			run.EndedAt = &endedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -13,6 +13,7 @@ import (
 // DB encapsule la connexion base de données
 type DB struct {
 	*sql.DB
+	auditHook func(AuditLog) // Transfert des événements d'audit (syslog, fichier), voir SetAuditHook
 }
 
 // AuditLog représente une entrée dans le journal d'audit
@@ -24,8 +25,20 @@ type AuditLog struct {
 	Target    string    `json:"target"` // Cible de l'action (ex: machine-1, system)
 	Details   string    `json:"details"`
 	IPAddress string    `json:"ip_address"`
+	PrevHash  string    `json:"prev_hash"` // Hash de la ligne précédente (chaîne d'intégrité)
+	Hash      string    `json:"hash"`
+	UserAgent string    `json:"-"` // Transmis aux destinations externes, non conservé en base
+	// Résultat transmis aux destinations externes (AuditSuccess, AuditFailure), non conservé en base ;
+	// vide, il est déduit de l'action
+	Outcome string `json:"-"`
 }
 
+// Résultat d'une action auditée (AuditLog.Outcome)
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
 // UserDB structure pour la base de données
 type UserDB struct {
 	Username       string    `json:"username"`
@@ -35,8 +48,20 @@ type UserDB struct {
 	CreatedAt      time.Time `json:"created_at"`
 	FailedAttempts int       `json:"failed_attempts"`
 	LockedUntil    time.Time `json:"locked_until"`
+	TOTPEnabled    bool      `json:"totp_enabled"`
+	Source         string    `json:"source"`
+	// Dernier changement du mot de passe (date de création à défaut) et changement imposé à la prochaine connexion
+	PasswordChangedAt  time.Time `json:"password_changed_at"`
+	MustChangePassword bool      `json:"must_change_password"`
 }
 
+// Origine des comptes : base locale (mot de passe bcrypt), annuaire ou SSO, provisionnés à la première connexion
+const (
+	UserSourceLocal = "local"
+	UserSourceLDAP  = "ldap"
+	UserSourceOIDC  = "oidc"
+)
+
 // InitDB initialise la connexion SQLite et crée les tables
 func InitDB(filepath string) (*DB, error) {
 	db, err := sql.Open("sqlite3", filepath)
@@ -71,18 +96,23 @@ func InitDB(filepath string) (*DB, error) {
         action TEXT NOT NULL,
         target TEXT,
         details TEXT,
-        ip_address TEXT
-    );
-    CREATE TABLE IF NOT EXISTS audit_logs (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        timestamp DATETIME NOT NULL,
-        user TEXT NOT NULL,
-        action TEXT NOT NULL,
-        target TEXT,
-        details TEXT,
-        ip_address TEXT
+        ip_address TEXT,
+        prev_hash TEXT NOT NULL DEFAULT '',
+        hash TEXT NOT NULL DEFAULT ''
     );
     CREATE INDEX IF NOT EXISTS idx_audit_time ON audit_logs(timestamp);
+    CREATE INDEX IF NOT EXISTS idx_audit_user ON audit_logs(user, timestamp);
+    CREATE INDEX IF NOT EXISTS idx_audit_action ON audit_logs(action, timestamp);
+
+    -- Ancrage de la chaîne d'audit après une purge : hash de la dernière ligne supprimée.
+    -- chain_start : dernière ligne antérieure à la chaîne (sans hash), fixé à la migration
+    CREATE TABLE IF NOT EXISTS audit_anchor (
+        id INTEGER PRIMARY KEY CHECK (id = 1),
+        last_id INTEGER NOT NULL,
+        hash TEXT NOT NULL,
+        purged_at DATETIME NOT NULL,
+        chain_start INTEGER NOT NULL DEFAULT 0
+    );
 
     CREATE TABLE IF NOT EXISTS users (
         username TEXT PRIMARY KEY,
@@ -93,6 +123,155 @@ func InitDB(filepath string) (*DB, error) {
         failed_attempts INTEGER DEFAULT 0,
         locked_until DATETIME
     );
+
+    CREATE TABLE IF NOT EXISTS check_results (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        machine_id TEXT NOT NULL,
+        check_name TEXT NOT NULL,
+        timestamp DATETIME NOT NULL,
+        state INTEGER NOT NULL,
+        output TEXT,
+        long_text TEXT,
+        duration_ms INTEGER DEFAULT 0
+    );
+    CREATE INDEX IF NOT EXISTS idx_check_results_machine ON check_results(machine_id, check_name, timestamp);
+
+    CREATE TABLE IF NOT EXISTS check_perfdata (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        machine_id TEXT NOT NULL,
+        check_name TEXT NOT NULL,
+        label TEXT NOT NULL,
+        timestamp DATETIME NOT NULL,
+        value REAL NOT NULL,
+        uom TEXT
+    );
+    CREATE INDEX IF NOT EXISTS idx_check_perfdata_machine ON check_perfdata(machine_id, check_name, timestamp);
+
+    CREATE TABLE IF NOT EXISTS probe_results (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        probe_name TEXT NOT NULL,
+        probe_type TEXT NOT NULL,
+        target TEXT NOT NULL,
+        via TEXT,
+        timestamp DATETIME NOT NULL,
+        state INTEGER NOT NULL,
+        status_code INTEGER DEFAULT 0,
+        latency_ms REAL DEFAULT 0,
+        cert_expiry DATETIME,
+        message TEXT
+    );
+    CREATE INDEX IF NOT EXISTS idx_probe_results_name ON probe_results(probe_name, timestamp);
+
+    CREATE TABLE IF NOT EXISTS terminal_recordings (
+        id TEXT PRIMARY KEY,
+        username TEXT NOT NULL,
+        machine_id TEXT NOT NULL,
+        remote_addr TEXT,
+        started_at DATETIME NOT NULL,
+        ended_at DATETIME,
+        duration_ms INTEGER DEFAULT 0,
+        size_bytes INTEGER DEFAULT 0,
+        path TEXT NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_terminal_recordings_started ON terminal_recordings(started_at);
+
+    CREATE TABLE IF NOT EXISTS log_cursors (
+        machine_id TEXT NOT NULL,
+        rule_name TEXT NOT NULL,
+        source TEXT NOT NULL,
+        cursor TEXT NOT NULL,
+        updated_at DATETIME NOT NULL,
+        PRIMARY KEY (machine_id, rule_name)
+    );
+
+    CREATE TABLE IF NOT EXISTS disk_usage (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        machine_id TEXT NOT NULL,
+        mount_point TEXT NOT NULL,
+        timestamp DATETIME NOT NULL,
+        used INTEGER NOT NULL,
+        total INTEGER NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_disk_usage_machine_mount_time ON disk_usage(machine_id, mount_point, timestamp);
+
+    CREATE TABLE IF NOT EXISTS command_runs (
+        id TEXT PRIMARY KEY,
+        username TEXT NOT NULL,
+        template TEXT,
+        command TEXT NOT NULL,
+        targets TEXT NOT NULL,
+        started_at DATETIME NOT NULL,
+        ended_at DATETIME
+    );
+    CREATE INDEX IF NOT EXISTS idx_command_runs_started ON command_runs(started_at);
+
+    CREATE TABLE IF NOT EXISTS command_results (
+        run_id TEXT NOT NULL,
+        machine_id TEXT NOT NULL,
+        stdout TEXT,
+        stderr TEXT,
+        exit_code INTEGER NOT NULL,
+        error TEXT,
+        truncated INTEGER DEFAULT 0,
+        duration_ms INTEGER DEFAULT 0,
+        PRIMARY KEY (run_id, machine_id)
+    );
+
+    CREATE TABLE IF NOT EXISTS runbook_runs (
+        id TEXT PRIMARY KEY,
+        runbook TEXT NOT NULL,
+        machine_id TEXT NOT NULL,
+        alert_key TEXT,
+        triggered_by TEXT NOT NULL,
+        username TEXT,
+        status TEXT NOT NULL,
+        started_at DATETIME NOT NULL,
+        ended_at DATETIME,
+        steps TEXT
+    );
+    CREATE INDEX IF NOT EXISTS idx_runbook_runs_started ON runbook_runs(started_at);
+
+    CREATE TABLE IF NOT EXISTS api_tokens (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        kind TEXT NOT NULL,
+        username TEXT NOT NULL,
+        role TEXT,
+        scopes TEXT NOT NULL,
+        prefix TEXT NOT NULL,
+        token_hash TEXT NOT NULL UNIQUE,
+        created_at DATETIME NOT NULL,
+        expires_at DATETIME,
+        last_used_at DATETIME,
+        last_used_ip TEXT
+    );
+
+    CREATE TABLE IF NOT EXISTS sessions (
+        id TEXT PRIMARY KEY,
+        token_hash TEXT NOT NULL UNIQUE,
+        username TEXT NOT NULL,
+        created_at DATETIME NOT NULL,
+        last_seen_at DATETIME NOT NULL,
+        expires_at DATETIME NOT NULL,
+        ip TEXT,
+        user_agent TEXT
+    );
+    CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions(username);
+
+    CREATE TABLE IF NOT EXISTS recovery_codes (
+        username TEXT NOT NULL,
+        code_hash TEXT NOT NULL,
+        used_at DATETIME,
+        PRIMARY KEY (username, code_hash)
+    );
+
+    CREATE TABLE IF NOT EXISTS password_history (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        username TEXT NOT NULL,
+        password_hash TEXT NOT NULL,
+        created_at DATETIME NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(username, id);
     `
 
 	_, err = db.Exec(createTableSQL)
@@ -107,8 +286,29 @@ func InitDB(filepath string) (*DB, error) {
 	db.Exec("ALTER TABLE metrics ADD COLUMN net_tx_rate REAL DEFAULT 0")
 	db.Exec("ALTER TABLE metrics ADD COLUMN disk_read_rate REAL DEFAULT 0")
 	db.Exec("ALTER TABLE metrics ADD COLUMN disk_write_rate REAL DEFAULT 0")
+	db.Exec("ALTER TABLE users ADD COLUMN totp_secret TEXT")
+	db.Exec("ALTER TABLE users ADD COLUMN totp_enabled INTEGER DEFAULT 0")
+	db.Exec("ALTER TABLE users ADD COLUMN totp_last_step INTEGER DEFAULT 0")
+	db.Exec("ALTER TABLE users ADD COLUMN source TEXT DEFAULT 'local'")
+	db.Exec("ALTER TABLE users ADD COLUMN password_changed_at DATETIME")
+	db.Exec("ALTER TABLE users ADD COLUMN must_change_password INTEGER DEFAULT 0")
+	db.Exec("ALTER TABLE audit_logs ADD COLUMN prev_hash TEXT NOT NULL DEFAULT ''")
+	db.Exec("ALTER TABLE audit_logs ADD COLUMN hash TEXT NOT NULL DEFAULT ''")
+
+	// Début de la chaîne d'audit, enregistré une seule fois : les lignes sans hash ne sont
+	// acceptées que jusqu'à la première ligne chaînée (toutes les lignes d'une base antérieure)
+	const chainStart = `(SELECT COALESCE((SELECT MIN(id) - 1 FROM audit_logs WHERE hash != ''), (SELECT MAX(id) FROM audit_logs), 0))`
+	if _, err := db.Exec("ALTER TABLE audit_anchor ADD COLUMN chain_start INTEGER NOT NULL DEFAULT 0"); err == nil {
+		if _, err := db.Exec("UPDATE audit_anchor SET chain_start = " + chainStart); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := db.Exec("INSERT OR IGNORE INTO audit_anchor (id, last_id, hash, purged_at, chain_start) VALUES (1, 0, '', ?, "+chainStart+")",
+		time.Now().UTC()); err != nil {
+		return nil, err
+	}
 
-	return &DB{db}, nil
+	return &DB{DB: db}, nil
 }
 
 // SaveMetric sauvegarde une mesure
@@ -124,8 +324,9 @@ func (db *DB) SaveMetric(m models.Machine) error {
 	)
 	if err != nil {
 		log.Printf("Erreur sauvegarde métrique %s: %v", m.ID, err)
+		return err
 	}
-	return err
+	return db.saveDiskUsage(m)
 }
 
 // GetHistory récupère l'historique d'une machine
@@ -158,6 +359,15 @@ func (db *DB) GetHistory(machineID string, duration time.Duration) ([]models.Met
 // CleanupOldMetrics supprime les métriques plus vieilles que duration
 func (db *DB) CleanupOldMetrics(maxAge time.Duration) error {
 	cutoff := time.Now().Add(-maxAge)
-	_, err := db.Exec("DELETE FROM metrics WHERE timestamp < ?", cutoff)
+	if _, err := db.Exec("DELETE FROM metrics WHERE timestamp < ?", cutoff); err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM check_results WHERE timestamp < ?", cutoff); err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM check_perfdata WHERE timestamp < ?", cutoff); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM probe_results WHERE timestamp < ?", cutoff)
 	return err
 }
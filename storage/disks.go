@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"log"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// DiskHistoryInterval est l'intervalle minimal entre deux relevés d'un même système de fichiers.
+// L'historique disque est conservé plus longtemps que les métriques : inutile de tout garder.
+const DiskHistoryInterval = 10 * time.Minute
+
+// saveDiskUsage enregistre l'occupation de chaque système de fichiers d'une machine en ligne
+// (au plus un relevé par DiskHistoryInterval)
+func (db *DB) saveDiskUsage(m models.Machine) error {
+	if m.Status != "online" {
+		return nil
+	}
+
+	query := `INSERT INTO disk_usage (machine_id, mount_point, timestamp, used, total)
+		SELECT ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM disk_usage WHERE machine_id = ? AND mount_point = ? AND timestamp > ?
+		)`
+
+	since := m.LastCheck.Add(-DiskHistoryInterval)
+	for _, d := range m.Disks {
+		if d.MountPoint == "" || d.Total == 0 {
+			continue
+		}
+		if _, err := db.Exec(query,
+			m.ID, d.MountPoint, m.LastCheck, int64(d.Used), int64(d.Total),
+			m.ID, d.MountPoint, since,
+		); err != nil {
+			log.Printf("Erreur sauvegarde disque %s:%s: %v", m.ID, d.MountPoint, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDiskHistory retourne l'historique d'occupation des systèmes de fichiers d'une machine,
+// par point de montage et dans l'ordre chronologique
+func (db *DB) GetDiskHistory(machineID string, duration time.Duration) (map[string][]models.DiskUsagePoint, error) {
+	startTime := time.Now().Add(-duration)
+	rows, err := db.Query(`SELECT mount_point, timestamp, used, total FROM disk_usage
+		WHERE machine_id = ? AND timestamp > ? ORDER BY timestamp ASC`, machineID, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make(map[string][]models.DiskUsagePoint)
+	for rows.Next() {
+		var mount string
+		var used, total int64
+		var p models.DiskUsagePoint
+		if err := rows.Scan(&mount, &p.Timestamp, &used, &total); err != nil {
+			log.Printf("Erreur scan historique disque %s: %v", machineID, err)
+			continue
+		}
+		p.Used, p.Total = uint64(used), uint64(total)
+		history[mount] = append(history[mount], p)
+	}
+	return history, rows.Err()
+}
+
+// CleanupDiskHistory supprime les relevés d'occupation disque plus vieux que maxAge
+func (db *DB) CleanupDiskHistory(maxAge time.Duration) error {
+	_, err := db.Exec("DELETE FROM disk_usage WHERE timestamp < ?", time.Now().Add(-maxAge))
+	return err
+}
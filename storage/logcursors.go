@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// GetLogCursor retourne la position de lecture d'une règle de logs.
+// Un curseur enregistré pour une autre source est ignoré (source modifiée dans la configuration).
+func (db *DB) GetLogCursor(machineID, ruleName, source string) (string, error) {
+	var storedSource, cursor string
+	err := db.QueryRow(`SELECT source, cursor FROM log_cursors WHERE machine_id = ? AND rule_name = ?`,
+		machineID, ruleName).Scan(&storedSource, &cursor)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && storedSource != source) {
+		return "", nil
+	}
+	return cursor, err
+}
+
+// SaveLogCursor enregistre la position de lecture d'une règle de logs
+func (db *DB) SaveLogCursor(machineID, ruleName, source, cursor string) error {
+	_, err := db.Exec(`INSERT INTO log_cursors (machine_id, rule_name, source, cursor, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(machine_id, rule_name) DO UPDATE SET source = excluded.source, cursor = excluded.cursor, updated_at = excluded.updated_at`,
+		machineID, ruleName, source, cursor, time.Now())
+	return err
+}
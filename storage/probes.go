@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// SaveProbeResult enregistre le résultat d'une sonde
+func (db *DB) SaveProbeResult(r models.ProbeResult) error {
+	var certExpiry interface{}
+	if r.CertExpiry != nil {
+		certExpiry = *r.CertExpiry
+	}
+
+	_, err := db.Exec(`INSERT INTO probe_results (probe_name, probe_type, target, via, timestamp, state, status_code, latency_ms, cert_expiry, message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Name, r.Type, r.Target, r.Via, r.Timestamp, r.State, r.StatusCode, r.LatencyMs, certExpiry, r.Message)
+	return err
+}
+
+// GetLatestProbeResults retourne le dernier résultat de chaque sonde
+func (db *DB) GetLatestProbeResults() ([]models.ProbeResult, error) {
+	query := `SELECT probe_name, probe_type, target, via, timestamp, state, status_code, latency_ms, cert_expiry, message
+			  FROM probe_results
+			  WHERE id IN (SELECT MAX(id) FROM probe_results GROUP BY probe_name)
+			  ORDER BY probe_name ASC`
+	return db.queryProbeResults(query)
+}
+
+// GetProbeHistory retourne l'historique d'une sonde (latence et état)
+func (db *DB) GetProbeHistory(name string, duration time.Duration) ([]models.ProbeResult, error) {
+	startTime := time.Now().Add(-duration)
+	query := `SELECT probe_name, probe_type, target, via, timestamp, state, status_code, latency_ms, cert_expiry, message
+			  FROM probe_results
+			  WHERE probe_name = ? AND timestamp > ?
+			  ORDER BY timestamp ASC`
+	return db.queryProbeResults(query, name, startTime)
+}
+
+func (db *DB) queryProbeResults(query string, args ...interface{}) ([]models.ProbeResult, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.ProbeResult
+	for rows.Next() {
+		var r models.ProbeResult
+		var via, message sql.NullString
+		var certExpiry sql.NullTime
+		if err := rows.Scan(&r.Name, &r.Type, &r.Target, &via, &r.Timestamp, &r.State, &r.StatusCode, &r.LatencyMs, &certExpiry, &message); err != nil {
+			log.Printf("Erreur scan sonde: %v", err)
+			continue
+		}
+		r.Via = via.String
+		r.Message = message.String
+		if certExpiry.Valid {
+			t := certExpiry.Time
+			r.CertExpiry = &t
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// CreateRecording référence un enregistrement de terminal au démarrage de la session
+func (db *DB) CreateRecording(r models.TerminalRecording) error {
+	_, err := db.Exec(`INSERT INTO terminal_recordings (id, username, machine_id, remote_addr, started_at, path)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		r.ID, r.Username, r.MachineID, r.RemoteAddr, r.StartedAt, r.Path)
+	return err
+}
+
+// FinishRecording complète l'index à la fin de la session
+func (db *DB) FinishRecording(id string, endedAt time.Time, duration time.Duration, size int64) error {
+	_, err := db.Exec(`UPDATE terminal_recordings SET ended_at = ?, duration_ms = ?, size_bytes = ? WHERE id = ?`,
+		endedAt, duration.Milliseconds(), size, id)
+	return err
+}
+
+// GetRecording retourne un enregistrement par son identifiant (nil s'il n'existe pas)
+func (db *DB) GetRecording(id string) (*models.TerminalRecording, error) {
+	recs, err := db.queryRecordings(`WHERE id = ?`, id)
+	if err != nil || len(recs) == 0 {
+		return nil, err
+	}
+	return &recs[0], nil
+}
+
+// ListRecordings retourne les enregistrements les plus récents, filtrables par machine et utilisateur
+func (db *DB) ListRecordings(machineID, username string, limit int) ([]models.TerminalRecording, error) {
+	return db.queryRecordings(`WHERE (? = '' OR machine_id = ?) AND (? = '' OR username = ?)
+		ORDER BY started_at DESC LIMIT ?`, machineID, machineID, username, username, limit)
+}
+
+func (db *DB) queryRecordings(where string, args ...interface{}) ([]models.TerminalRecording, error) {
+	rows, err := db.Query(`SELECT id, username, machine_id, remote_addr, started_at, ended_at, duration_ms, size_bytes, path
+		FROM terminal_recordings `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []models.TerminalRecording
+	for rows.Next() {
+		var r models.TerminalRecording
+		var remoteAddr sql.NullString
+		var endedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Username, &r.MachineID, &remoteAddr, &r.StartedAt, &endedAt, &r.DurationMs, &r.SizeBytes, &r.Path); err != nil {
+			log.Printf("Erreur scan enregistrement: %v", err)
+			continue
+		}
+		r.RemoteAddr = remoteAddr.String
+		if endedAt.Valid {
+			r.EndedAt = &endedAt.Time
+		}
+		recs = append(recs, r)
+	}
+	return recs, rows.Err()
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"go-monitoring/models"
+)
+
+// CreateRunbookRun enregistre le lancement d'un runbook
+func (db *DB) CreateRunbookRun(run models.RunbookRun) error {
+	_, err := db.Exec(`INSERT INTO runbook_runs (id, runbook, machine_id, alert_key, triggered_by, username, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Runbook, run.MachineID, run.AlertKey, run.Trigger, run.Username, run.Status, run.StartedAt)
+	return err
+}
+
+// FinishRunbookRun enregistre l'état final et le résultat des étapes
+func (db *DB) FinishRunbookRun(run models.RunbookRun) error {
+	steps, err := json.Marshal(run.Steps)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE runbook_runs SET status = ?, ended_at = ?, steps = ? WHERE id = ?`,
+		run.Status, run.EndedAt, string(steps), run.ID)
+	return err
+}
+
+// GetRunbookRun retourne une exécution de runbook et ses étapes (nil si elle n'existe pas)
+func (db *DB) GetRunbookRun(id string) (*models.RunbookRun, error) {
+	runs, err := db.queryRunbookRuns(`WHERE id = ?`, id)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// ListRunbookRuns retourne les exécutions les plus récentes, filtrables par machine et par runbook
+func (db *DB) ListRunbookRuns(machineID, runbook string, limit int) ([]models.RunbookRun, error) {
+	return db.queryRunbookRuns(`WHERE (? = '' OR machine_id = ?) AND (? = '' OR runbook = ?)
+		ORDER BY started_at DESC LIMIT ?`, machineID, machineID, runbook, runbook, limit)
+}
+
+func (db *DB) queryRunbookRuns(where string, args ...interface{}) ([]models.RunbookRun, error) {
+	rows, err := db.Query(`SELECT id, runbook, machine_id, alert_key, triggered_by, username, status, started_at, ended_at, steps
+		FROM runbook_runs `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.RunbookRun
+	for rows.Next() {
+		var run models.RunbookRun
+		var alertKey, username, steps sql.NullString
+		var endedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.Runbook, &run.MachineID, &alertKey, &run.Trigger, &username,
+			&run.Status, &run.StartedAt, &endedAt, &steps); err != nil {
+			log.Printf("Erreur scan exécution de runbook: %v", err)
+			continue
+		}
+		run.AlertKey, run.Username = alertKey.String, username.String
+		if endedAt.Valid {
+			run.EndedAt = &endedAt.Time
+		}
+		if steps.Valid && steps.String != "" {
+			if err := json.Unmarshal([]byte(steps.String), &run.Steps); err != nil {
+				log.Printf("Erreur lecture des étapes de %s: %v", run.ID, err)
+			}
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
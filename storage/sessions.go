@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// CreateSession enregistre une session de connexion (empreinte du cookie uniquement)
+func (db *DB) CreateSession(s models.UserSession) error {
+	_, err := db.Exec(`INSERT INTO sessions (id, token_hash, username, created_at, last_seen_at, expires_at, ip, user_agent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, s.Hash, s.Username, s.CreatedAt, s.LastSeenAt, s.ExpiresAt, s.IP, s.UserAgent)
+	return err
+}
+
+// GetSessionByHash retourne la session correspondant à une empreinte (nil si elle n'existe pas)
+func (db *DB) GetSessionByHash(hash string) (*models.UserSession, error) {
+	sessions, err := db.querySessions(`WHERE token_hash = ?`, hash)
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+	return &sessions[0], nil
+}
+
+// GetSession retourne une session par identifiant (nil si elle n'existe pas)
+func (db *DB) GetSession(id string) (*models.UserSession, error) {
+	sessions, err := db.querySessions(`WHERE id = ?`, id)
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+	return &sessions[0], nil
+}
+
+// ListUserSessions retourne les sessions d'un utilisateur, la plus récemment active en premier
+func (db *DB) ListUserSessions(username string) ([]models.UserSession, error) {
+	return db.querySessions(`WHERE username = ? ORDER BY last_seen_at DESC`, username)
+}
+
+// TouchSession enregistre l'activité d'une session
+func (db *DB) TouchSession(id, ip string, at time.Time) error {
+	_, err := db.Exec(`UPDATE sessions SET last_seen_at = ?, ip = ? WHERE id = ?`, at, ip, id)
+	return err
+}
+
+// DeleteSession révoque une session
+func (db *DB) DeleteSession(id string) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+// DeleteUserSessions révoque les sessions d'un utilisateur, sauf exceptID (si renseigné).
+// Retourne le nombre de sessions révoquées.
+func (db *DB) DeleteUserSessions(username, exceptID string) (int64, error) {
+	res, err := db.Exec(`DELETE FROM sessions WHERE username = ? AND id != ?`, username, exceptID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpiredSessions supprime les sessions expirées (durée absolue atteinte ou inactives depuis idleCutoff)
+func (db *DB) DeleteExpiredSessions(now, idleCutoff time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM sessions WHERE expires_at <= ? OR last_seen_at <= ?`, now, idleCutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (db *DB) querySessions(where string, args ...interface{}) ([]models.UserSession, error) {
+	rows, err := db.Query(`SELECT id, token_hash, username, created_at, last_seen_at, expires_at, ip, user_agent
+		FROM sessions `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.UserSession{}
+	for rows.Next() {
+		var s models.UserSession
+		var ip, userAgent sql.NullString
+		if err := rows.Scan(&s.ID, &s.Hash, &s.Username, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &ip, &userAgent); err != nil {
+			log.Printf("Erreur scan session: %v", err)
+			continue
+		}
+		s.IP, s.UserAgent = ip.String, userAgent.String
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
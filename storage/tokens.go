@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"go-monitoring/models"
+)
+
+// CreateAPIToken enregistre un jeton d'API (empreinte uniquement)
+func (db *DB) CreateAPIToken(t models.APIToken) error {
+	_, err := db.Exec(`INSERT INTO api_tokens (id, name, kind, username, role, scopes, prefix, token_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.Name, t.Kind, t.Username, t.Role, strings.Join(t.Scopes, ","), t.Prefix, t.Hash, t.CreatedAt, t.ExpiresAt)
+	return err
+}
+
+// GetAPITokenByHash retourne le jeton correspondant à une empreinte (nil s'il n'existe pas)
+func (db *DB) GetAPITokenByHash(hash string) (*models.APIToken, error) {
+	tokens, err := db.queryAPITokens(`WHERE token_hash = ?`, hash)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	return &tokens[0], nil
+}
+
+// GetAPIToken retourne un jeton par identifiant (nil s'il n'existe pas)
+func (db *DB) GetAPIToken(id string) (*models.APIToken, error) {
+	tokens, err := db.queryAPITokens(`WHERE id = ?`, id)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	return &tokens[0], nil
+}
+
+// ListAPITokens retourne les jetons personnels d'un utilisateur, ou tous les jetons si username est vide
+func (db *DB) ListAPITokens(username string) ([]models.APIToken, error) {
+	if username == "" {
+		return db.queryAPITokens(`ORDER BY created_at DESC`)
+	}
+	return db.queryAPITokens(`WHERE username = ? AND kind = ? ORDER BY created_at DESC`, username, models.APITokenPersonal)
+}
+
+// TouchAPIToken enregistre la dernière utilisation d'un jeton
+func (db *DB) TouchAPIToken(id, ip string, at time.Time) error {
+	_, err := db.Exec(`UPDATE api_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?`, at, ip, id)
+	return err
+}
+
+// DeleteAPIToken révoque un jeton
+func (db *DB) DeleteAPIToken(id string) error {
+	_, err := db.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	return err
+}
+
+// DeleteUserAPITokens révoque les jetons personnels d'un utilisateur
+func (db *DB) DeleteUserAPITokens(username string) error {
+	_, err := db.Exec(`DELETE FROM api_tokens WHERE username = ? AND kind = ?`, username, models.APITokenPersonal)
+	return err
+}
+
+func (db *DB) queryAPITokens(where string, args ...interface{}) ([]models.APIToken, error) {
+	rows, err := db.Query(`SELECT id, name, kind, username, role, scopes, prefix, token_hash, created_at, expires_at, last_used_at, last_used_ip
+		FROM api_tokens `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var t models.APIToken
+		var role, lastUsedIP sql.NullString
+		var scopes string
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.Kind, &t.Username, &role, &scopes, &t.Prefix, &t.Hash,
+			&t.CreatedAt, &expiresAt, &lastUsedAt, &lastUsedIP); err != nil {
+			log.Printf("Erreur scan jeton d'API: %v", err)
+			continue
+		}
+		t.Role, t.LastUsedIP = role.String, lastUsedIP.String
+		if scopes != "" {
+			t.Scopes = strings.Split(scopes, ",")
+		}
+		if expiresAt.Valid {
+			t.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			t.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GetTOTP retourne le secret TOTP d'un utilisateur (éventuellement chiffré), s'il est activé,
+// et le dernier pas de temps accepté
+func (db *DB) GetTOTP(username string) (secret string, enabled bool, lastStep int64, err error) {
+	var s sql.NullString
+	err = db.QueryRow(`SELECT totp_secret, COALESCE(totp_enabled, 0), COALESCE(totp_last_step, 0) FROM users WHERE username = ?`,
+		username).Scan(&s, &enabled, &lastStep)
+	return s.String, enabled, lastStep, err
+}
+
+// SetTOTPSecret enregistre un secret en attente de confirmation (2FA non activée)
+func (db *DB) SetTOTPSecret(username, secret string) error {
+	_, err := db.Exec(`UPDATE users SET totp_secret = ?, totp_enabled = 0, totp_last_step = 0 WHERE username = ?`, secret, username)
+	return err
+}
+
+// EnableTOTP active la 2FA et remplace les codes de secours
+func (db *DB) EnableTOTP(username string, step int64, recoveryHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET totp_enabled = 1, totp_last_step = ? WHERE username = ?`, step, username); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, username, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP désactive la 2FA, efface le secret et les codes de secours
+func (db *DB) DisableTOTP(username string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET totp_secret = NULL, totp_enabled = 0, totp_last_step = 0 WHERE username = ?`, username); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE username = ?`, username); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep consomme un pas de temps : un code déjà utilisé (ou plus ancien) est refusé
+func (db *DB) UseTOTPStep(username string, step int64) (bool, error) {
+	res, err := db.Exec(`UPDATE users SET totp_last_step = ? WHERE username = ? AND COALESCE(totp_last_step, 0) < ?`, step, username, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReplaceRecoveryCodes remplace les codes de secours d'un utilisateur (empreintes)
+func (db *DB) ReplaceRecoveryCodes(username string, hashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, username, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode consomme un code de secours ; retourne false s'il est inconnu ou déjà utilisé
+func (db *DB) UseRecoveryCode(username, hash string) (bool, error) {
+	res, err := db.Exec(`UPDATE recovery_codes SET used_at = ? WHERE username = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now(), username, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// CountRecoveryCodes retourne le nombre de codes de secours encore utilisables
+func (db *DB) CountRecoveryCodes(username string) (int, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE username = ? AND used_at IS NULL`, username).Scan(&n)
+	return n, err
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, username string, hashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE username = ?`, username); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (username, code_hash) VALUES (?, ?)`, username, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
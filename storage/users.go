@@ -10,7 +10,8 @@ import (
 
 // GetUser récupère un utilisateur par son username
 func (db *DB) GetUser(username string) (*UserDB, error) {
-	query := `SELECT username, password_hash, role, is_active, created_at, failed_attempts, locked_until 
+	query := `SELECT username, password_hash, role, is_active, created_at, failed_attempts, locked_until, COALESCE(totp_enabled, 0), COALESCE(source, 'local'),
+			  password_changed_at, COALESCE(must_change_password, 0)
 			  FROM users WHERE username = ?`
 
 	row := db.QueryRow(query, username)
@@ -18,10 +19,14 @@ func (db *DB) GetUser(username string) (*UserDB, error) {
 	var u UserDB
 	var lockedUntil sql.NullTime
 
-	err := row.Scan(&u.Username, &u.PasswordHash, &u.Role, &u.IsActive, &u.CreatedAt, &u.FailedAttempts, &lockedUntil)
+	var changedAt sql.NullTime
+
+	err := row.Scan(&u.Username, &u.PasswordHash, &u.Role, &u.IsActive, &u.CreatedAt, &u.FailedAttempts, &lockedUntil, &u.TOTPEnabled, &u.Source,
+		&changedAt, &u.MustChangePassword)
 	if err != nil {
 		return nil, err
 	}
+	u.PasswordChangedAt = passwordChangedAt(changedAt, u.CreatedAt)
 
 	if lockedUntil.Valid {
 		u.LockedUntil = lockedUntil.Time
@@ -32,7 +37,8 @@ func (db *DB) GetUser(username string) (*UserDB, error) {
 
 // GetAllUsers récupère tous les utilisateurs
 func (db *DB) GetAllUsers() ([]UserDB, error) {
-	query := `SELECT username, role, is_active, created_at, failed_attempts, locked_until FROM users ORDER BY username ASC`
+	query := `SELECT username, role, is_active, created_at, failed_attempts, locked_until, COALESCE(totp_enabled, 0), COALESCE(source, 'local'),
+			  password_changed_at, COALESCE(must_change_password, 0) FROM users ORDER BY username ASC`
 
 	rows, err := db.Query(query)
 	if err != nil {
@@ -45,9 +51,13 @@ func (db *DB) GetAllUsers() ([]UserDB, error) {
 		var u UserDB
 		var lockedUntil sql.NullTime
 
-		if err := rows.Scan(&u.Username, &u.Role, &u.IsActive, &u.CreatedAt, &u.FailedAttempts, &lockedUntil); err != nil {
+		var changedAt sql.NullTime
+
+		if err := rows.Scan(&u.Username, &u.Role, &u.IsActive, &u.CreatedAt, &u.FailedAttempts, &lockedUntil, &u.TOTPEnabled, &u.Source,
+			&changedAt, &u.MustChangePassword); err != nil {
 			continue
 		}
+		u.PasswordChangedAt = passwordChangedAt(changedAt, u.CreatedAt)
 
 		if lockedUntil.Valid {
 			u.LockedUntil = lockedUntil.Time
@@ -57,10 +67,26 @@ func (db *DB) GetAllUsers() ([]UserDB, error) {
 	return users, nil
 }
 
-// CreateUser crée un nouvel utilisateur
-func (db *DB) CreateUser(username, passwordHash, role string) error {
-	query := `INSERT INTO users (username, password_hash, role, is_active, created_at) VALUES (?, ?, ?, 1, ?)`
-	_, err := db.Exec(query, username, passwordHash, role, time.Now())
+// passwordChangedAt retourne la date du dernier changement (création du compte pour les comptes antérieurs)
+func passwordChangedAt(changedAt sql.NullTime, createdAt time.Time) time.Time {
+	if changedAt.Valid {
+		return changedAt.Time
+	}
+	return createdAt
+}
+
+// CreateUser crée un nouvel utilisateur ; mustChange impose le changement du mot de passe à la première connexion
+func (db *DB) CreateUser(username, passwordHash, role string, mustChange bool) error {
+	query := `INSERT INTO users (username, password_hash, role, is_active, created_at, password_changed_at, must_change_password) VALUES (?, ?, ?, 1, ?, ?, ?)`
+	now := time.Now()
+	_, err := db.Exec(query, username, passwordHash, role, now, now, mustChange)
+	return err
+}
+
+// CreateExternalUser crée le compte local d'un utilisateur authentifié par un annuaire (sans mot de passe local)
+func (db *DB) CreateExternalUser(username, role, source string) error {
+	query := `INSERT INTO users (username, password_hash, role, is_active, created_at, source) VALUES (?, '', ?, 1, ?, ?)`
+	_, err := db.Exec(query, username, role, time.Now(), source)
 	return err
 }
 
@@ -80,9 +106,61 @@ func (db *DB) DeleteUser(username string) error {
 	return nil
 }
 
-// UpdatePassword met à jour le mot de passe
-func (db *DB) UpdatePassword(username, passwordHash string) error {
-	_, err := db.Exec("UPDATE users SET password_hash = ? WHERE username = ?", passwordHash, username)
+// UpdatePassword met à jour le mot de passe. L'ancien est ajouté à l'historique, dont seules
+// les keep entrées les plus récentes sont conservées (0 : historique vidé).
+func (db *DB) UpdatePassword(username, passwordHash string, mustChange bool, keep int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if keep > 0 {
+		if _, err := tx.Exec(`INSERT INTO password_history (username, password_hash, created_at)
+			SELECT username, password_hash, ? FROM users WHERE username = ? AND password_hash != ''`, now, username); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM password_history WHERE username = ? AND id NOT IN (
+		SELECT id FROM password_history WHERE username = ? ORDER BY id DESC LIMIT ?)`, username, username, keep); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET password_hash = ?, password_changed_at = ?, must_change_password = ? WHERE username = ?`,
+		passwordHash, now, mustChange, username); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPasswordHistory retourne les empreintes des anciens mots de passe, de la plus récente à la plus ancienne
+func (db *DB) GetPasswordHistory(username string, limit int) ([]string, error) {
+	rows, err := db.Query(`SELECT password_hash FROM password_history WHERE username = ? ORDER BY id DESC LIMIT ?`, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, rows.Err()
+}
+
+// DeletePasswordHistory efface l'historique des mots de passe d'un utilisateur
+func (db *DB) DeletePasswordHistory(username string) error {
+	_, err := db.Exec("DELETE FROM password_history WHERE username = ?", username)
+	return err
+}
+
+// SetMustChangePassword impose (ou lève) le changement du mot de passe à la prochaine connexion
+func (db *DB) SetMustChangePassword(username string, mustChange bool) error {
+	_, err := db.Exec("UPDATE users SET must_change_password = ? WHERE username = ?", mustChange, username)
 	return err
 }
 